@@ -0,0 +1,247 @@
+// Package app wires the repositories and services of the application once, at startup.
+// The handlers and middlewares receive the services from the Container instead of creating them on every request.
+package app
+
+import (
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/geoip"
+	"github.com/NutriPocket/UserService/mailer"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/risk"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/storage"
+)
+
+// Dependencies are the implementations the Container is built with, every nil field is replaced by its default
+// implementation. The tests use them to swap in fakes.
+type Dependencies struct {
+	// Config are the settings of the services and of the default backends, config.Default() if nil.
+	Config *config.Config
+
+	// DB is the database of the default repositories and of the erasure and the export of the users, which read every
+	// table. The pool connection if nil.
+	DB repository.IDatabase
+	// Replicas is the database of the user lookups that can be read from the read replicas: the replica pool if nil
+	// and DB is nil too, DB otherwise.
+	Replicas repository.IDatabase
+
+	Users           repository.IUserRepository
+	UsernameHistory repository.IUsernameHistoryRepository
+	JWT             repository.IJWTRepository
+	Audit           repository.IAuditRepository
+	EmailChanges    repository.IEmailChangeRepository
+	PasswordResets  repository.IPasswordResetRepository
+	DataExports     repository.IDataExportRepository
+	LoginHistory    repository.ILoginHistoryRepository
+	LoginChallenges repository.ILoginChallengeRepository
+	TOTP            repository.ITOTPRepository
+
+	Mailer     mailer.IMailer
+	Storage    storage.IStorage
+	Resolver   geoip.IResolver
+	RiskEngine *risk.Engine
+}
+
+// Container holds the services of the application, safe to be shared by every request.
+type Container struct {
+	// Settings are the settings the services were created with.
+	Settings config.Config
+
+	AccountDeletionService *service.AccountDeletionService
+	AccountStateService    *service.AccountStateService
+	AdminService           *service.AdminService
+	AuditService           *service.AuditService
+	AvatarService          *service.AvatarService
+	DataExportService      *service.DataExportService
+	EmailChangeService     *service.EmailChangeService
+	HealthService          *service.HealthService
+	JWTService             *service.JWTService
+	LoginHistoryService    *service.LoginHistoryService
+	LoginRiskService       *service.LoginRiskService
+	PasswordChangeService  *service.PasswordChangeService
+	PasswordResetService   *service.PasswordResetService
+	RevocationCacheService *service.RevocationCacheService
+	StepUpService          *service.StepUpService
+	TOTPService            *service.TOTPService
+	UserService            *service.UserService
+}
+
+// New creates the repositories missing from deps and every service of the application.
+// It returns an error if the database or a default dependency can't be set up.
+func New(deps Dependencies) (*Container, error) {
+	if err := deps.defaults(); err != nil {
+		return nil, err
+	}
+
+	container := Container{Settings: *deps.Config}
+	settings := container.Settings
+	var err error
+
+	if container.JWTService, err = service.NewJWTService(deps.JWT, settings); err != nil {
+		return nil, err
+	}
+
+	if container.UserService, err = service.NewUserService(deps.Users, deps.UsernameHistory, settings); err != nil {
+		return nil, err
+	}
+
+	if container.AuditService, err = service.NewAuditService(deps.Audit); err != nil {
+		return nil, err
+	}
+
+	if container.AccountStateService, err = service.NewAccountStateService(deps.Users, container.JWTService, settings); err != nil {
+		return nil, err
+	}
+
+	if container.AccountDeletionService, err = service.NewAccountDeletionService(deps.DB, deps.Users, container.JWTService, deps.Storage, deps.Mailer, settings); err != nil {
+		return nil, err
+	}
+
+	if container.AdminService, err = service.NewAdminService(deps.Users, deps.Audit, container.JWTService, deps.PasswordResets, deps.Mailer, settings); err != nil {
+		return nil, err
+	}
+
+	if container.AvatarService, err = service.NewAvatarService(deps.Users, deps.Storage, settings); err != nil {
+		return nil, err
+	}
+
+	if container.DataExportService, err = service.NewDataExportService(deps.DB, deps.Users, deps.DataExports, settings); err != nil {
+		return nil, err
+	}
+
+	if container.EmailChangeService, err = service.NewEmailChangeService(deps.Users, deps.EmailChanges, deps.Mailer, settings); err != nil {
+		return nil, err
+	}
+
+	if container.HealthService, err = service.NewHealthService(container.JWTService); err != nil {
+		return nil, err
+	}
+
+	if container.LoginHistoryService, err = service.NewLoginHistoryService(deps.LoginHistory, deps.Mailer, deps.Resolver, settings); err != nil {
+		return nil, err
+	}
+
+	if container.LoginRiskService, err = service.NewLoginRiskService(deps.LoginHistory, deps.Audit, deps.Resolver, deps.RiskEngine, settings); err != nil {
+		return nil, err
+	}
+
+	if container.PasswordChangeService, err = service.NewPasswordChangeService(deps.Users, container.JWTService, deps.Mailer, settings); err != nil {
+		return nil, err
+	}
+
+	if container.PasswordResetService, err = service.NewPasswordResetService(deps.Users, deps.PasswordResets, container.JWTService, deps.Mailer, settings); err != nil {
+		return nil, err
+	}
+
+	if container.RevocationCacheService, err = service.NewRevocationCacheService(container.JWTService, deps.Users, settings); err != nil {
+		return nil, err
+	}
+
+	if container.StepUpService, err = service.NewStepUpService(deps.Users, deps.LoginChallenges, deps.TOTP, deps.Mailer, settings); err != nil {
+		return nil, err
+	}
+
+	if container.TOTPService, err = service.NewTOTPService(deps.Users, deps.TOTP); err != nil {
+		return nil, err
+	}
+
+	return &container, nil
+}
+
+// defaults replaces the nil repositories with the ones of the database, opened once for all of them.
+// The nil backends, e.g. the mailer, are replaced by the ones configured by deps.Config, shared by every service.
+func (deps *Dependencies) defaults() error {
+	var err error
+
+	if deps.Config == nil {
+		settings := config.Default()
+		deps.Config = &settings
+	}
+
+	if deps.Mailer == nil {
+		deps.Mailer = mailer.New(deps.Config.Mailer)
+	}
+
+	if deps.Storage == nil {
+		if deps.Storage, err = storage.New(deps.Config.Storage); err != nil {
+			return err
+		}
+	}
+
+	if deps.Resolver == nil {
+		deps.Resolver = geoip.New(deps.Config.GeoIP)
+	}
+
+	if deps.Replicas == nil && deps.DB != nil {
+		deps.Replicas = deps.DB
+	}
+
+	if deps.DB == nil {
+		if deps.DB, err = database.GetPoolConnection(); err != nil {
+			return err
+		}
+	}
+
+	if deps.Users == nil {
+		if deps.Users, err = repository.NewReplicatedUserRepository(deps.DB, deps.Replicas); err != nil {
+			return err
+		}
+	}
+
+	if deps.UsernameHistory == nil {
+		if deps.UsernameHistory, err = repository.NewUsernameHistoryRepository(deps.DB); err != nil {
+			return err
+		}
+	}
+
+	if deps.JWT == nil {
+		if deps.JWT, err = repository.NewJWTRepository(deps.DB); err != nil {
+			return err
+		}
+	}
+
+	if deps.Audit == nil {
+		if deps.Audit, err = repository.NewAuditRepository(deps.DB); err != nil {
+			return err
+		}
+	}
+
+	if deps.EmailChanges == nil {
+		if deps.EmailChanges, err = repository.NewEmailChangeRepository(deps.DB); err != nil {
+			return err
+		}
+	}
+
+	if deps.PasswordResets == nil {
+		if deps.PasswordResets, err = repository.NewPasswordResetRepository(deps.DB); err != nil {
+			return err
+		}
+	}
+
+	if deps.DataExports == nil {
+		if deps.DataExports, err = repository.NewDataExportRepository(deps.DB); err != nil {
+			return err
+		}
+	}
+
+	if deps.LoginHistory == nil {
+		if deps.LoginHistory, err = repository.NewLoginHistoryRepository(deps.DB); err != nil {
+			return err
+		}
+	}
+
+	if deps.LoginChallenges == nil {
+		if deps.LoginChallenges, err = repository.NewLoginChallengeRepository(deps.DB); err != nil {
+			return err
+		}
+	}
+
+	if deps.TOTP == nil {
+		if deps.TOTP, err = repository.NewTOTPRepository(deps.DB); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/migrate"
+)
+
+const usage = `usage: app [flags] [command]
+
+Without a command the service is started. The commands are:
+  migrate up          apply the pending migrations
+  migrate down [n]    revert the last n applied migrations, 1 by default
+  migrate status      list the migrations and when they were applied`
+
+// runCommand runs the command of the arguments left after the flags.
+// It returns an error if the command is unknown or fails.
+func runCommand(args []string) error {
+	if args[0] != "migrate" || len(args) < 2 {
+		return fmt.Errorf("unknown command %q\n%s", strings.Join(args, " "), usage)
+	}
+
+	migrator, err := migrate.New(database.GetConnection(), database.CurrentDialect())
+	if err != nil {
+		return err
+	}
+
+	switch args[1] {
+	case "up":
+		applied, err := migrator.Up()
+		log.Infof("Applied %d migrations", len(applied))
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			if steps, err = strconv.Atoi(args[2]); err != nil || steps < 1 {
+				return fmt.Errorf("the number of migrations to revert must be a positive integer, not %q", args[2])
+			}
+		}
+
+		reverted, err := migrator.Down(steps)
+		log.Infof("Reverted %d migrations", len(reverted))
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT\tNOTES")
+
+		for _, status := range statuses {
+			appliedAt, notes := "pending", ""
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				notes = "changed after being applied"
+			}
+			if status.Missing {
+				notes = "unknown to this version"
+			}
+
+			fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", status.Version, status.Name, appliedAt, notes)
+		}
+
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unknown command %q\n%s", strings.Join(args, " "), usage)
+	}
+}
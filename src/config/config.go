@@ -0,0 +1,622 @@
+// Package config loads the settings of the service from a YAML or TOML file, the environment and the command line
+// flags, and validates them before the service starts.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NutriPocket/UserService/revocation"
+	"github.com/NutriPocket/UserService/risk"
+	"github.com/op/go-logging"
+)
+
+// DefaultJWTKey is the key the tokens are signed with if none is configured, only allowed outside the release mode.
+const DefaultJWTKey = "secret"
+
+// The modes of the service, the same as the gin ones.
+const (
+	DebugMode   = "debug"
+	ReleaseMode = "release"
+	TestMode    = "test"
+)
+
+// Config are the settings of the service.
+// Each field is read from the file key of its yaml and toml tags, the environment variable of its env tag and the
+// command line flag of its flag tag, in increasing order of precedence.
+type Config struct {
+	// Mode is the gin mode: debug, release or test.
+	Mode string `yaml:"mode" toml:"mode" env:"GIN_MODE" flag:"mode" usage:"mode of the service: debug, release or test"`
+	// LogLevel is the go-logging level, e.g. DEBUG or INFO.
+	LogLevel string `yaml:"log_level" toml:"log_level" env:"LOG_LEVEL" flag:"log-level" usage:"log level, e.g. DEBUG or INFO"`
+
+	Server     ServerConfig     `yaml:"server" toml:"server"`
+	Database   DatabaseConfig   `yaml:"database" toml:"database"`
+	JWT        JWTConfig        `yaml:"jwt" toml:"jwt"`
+	Revocation RevocationConfig `yaml:"revocation" toml:"revocation"`
+	Login      LoginConfig      `yaml:"login" toml:"login"`
+	Password   PasswordConfig   `yaml:"password" toml:"password"`
+	Account    AccountConfig    `yaml:"account" toml:"account"`
+	Risk       RiskConfig       `yaml:"risk" toml:"risk"`
+	GeoIP      GeoIPConfig      `yaml:"geoip" toml:"geoip"`
+	Storage    StorageConfig    `yaml:"storage" toml:"storage"`
+	Mailer     MailerConfig     `yaml:"mailer" toml:"mailer"`
+}
+
+// ServerConfig are the settings of the HTTP server.
+type ServerConfig struct {
+	Host string `yaml:"host" toml:"host" env:"HOST" flag:"host" usage:"address the server listens on"`
+	Port int    `yaml:"port" toml:"port" env:"PORT" flag:"port" usage:"port the server listens on"`
+	// PublicURL is the URL where the clients reach the service, used to build the links sent by email.
+	PublicURL string `yaml:"public_url" toml:"public_url" env:"PUBLIC_URL" flag:"public-url" usage:"URL where the clients reach the service"`
+	// TrustedProxies are the proxies X-Forwarded-For is trusted from, a comma separated list of IPs or CIDRs. The
+	// client IP is used by the audit log and the login risk rules.
+	TrustedProxies string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES" flag:"trusted-proxies" usage:"comma separated IPs or CIDRs of the trusted proxies"`
+}
+
+// Proxies returns the trusted proxies, nil if none.
+func (settings ServerConfig) Proxies() []string {
+	var proxies []string
+
+	for _, proxy := range strings.Split(settings.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
+
+// The database drivers, the same as the dialects of the database package.
+const (
+	MySQLDriver    = "mysql"
+	PostgresDriver = "postgres"
+	SQLiteDriver   = "sqlite"
+)
+
+// SQLiteMemory is the name of a SQLite database kept in memory, lost when the service stops.
+const SQLiteMemory = ":memory:"
+
+// DatabaseConfig are the settings of the database connection.
+type DatabaseConfig struct {
+	// Driver is the database: mysql, postgres or sqlite.
+	Driver string `yaml:"driver" toml:"driver" env:"DB_DRIVER" flag:"db-driver" usage:"database: mysql, postgres or sqlite"`
+	Host   string `yaml:"host" toml:"host" env:"DB_HOST" flag:"db-host" usage:"database host"`
+	// Port is the port of the database, the default one of the driver if 0.
+	Port     int    `yaml:"port" toml:"port" env:"DB_PORT" flag:"db-port" usage:"database port, the default one of the driver if 0"`
+	User     string `yaml:"user" toml:"user" env:"DB_USER" flag:"db-user" usage:"database user"`
+	Password string `yaml:"password" toml:"password" env:"DB_PASSWORD" flag:"db-password" usage:"database password"`
+	// Name is the name of the database, or the path of the file of a SQLite one, :memory: to keep it in memory.
+	Name string `yaml:"name" toml:"name" env:"DB_NAME" flag:"db-name" usage:"database name, or file of a SQLite one"`
+	// MigrateOnStart applies the pending schema migrations when the service starts.
+	MigrateOnStart bool `yaml:"migrate_on_start" toml:"migrate_on_start" env:"DB_MIGRATE_ON_START" flag:"db-migrate-on-start" usage:"apply the pending schema migrations on start"`
+
+	// MaxOpenConns is the maximum number of connections of the pool, unlimited if 0.
+	MaxOpenConns int `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"maximum open database connections, unlimited if 0"`
+	// MaxIdleConns is the maximum number of idle connections kept by the pool, the database/sql default if 0.
+	MaxIdleConns int `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"maximum idle database connections"`
+	// ConnMaxLifetime is how long a connection is reused before it's closed, forever if 0.
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"maximum lifetime of a database connection, e.g. 1h"`
+	// ConnMaxIdleTime is how long a connection is kept idle before it's closed, forever if 0.
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" flag:"db-conn-max-idle-time" usage:"maximum idle time of a database connection, e.g. 5m"`
+
+	// ConnectAttempts is how many times the database is pinged on start before giving up.
+	ConnectAttempts int `yaml:"connect_attempts" toml:"connect_attempts" env:"DB_CONNECT_ATTEMPTS" flag:"db-connect-attempts" usage:"database pings on start before giving up"`
+	// ConnectTimeout is how long each ping waits for the database.
+	ConnectTimeout Duration `yaml:"connect_timeout" toml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" flag:"db-connect-timeout" usage:"timeout of each database ping, e.g. 5s"`
+	// RetryBackoff is the wait after the first failed ping, doubled after each one up to MaxRetryBackoff.
+	RetryBackoff Duration `yaml:"retry_backoff" toml:"retry_backoff" env:"DB_RETRY_BACKOFF" flag:"db-retry-backoff" usage:"wait after the first failed database ping, e.g. 500ms"`
+	// MaxRetryBackoff is the longest wait between two pings.
+	MaxRetryBackoff Duration `yaml:"max_retry_backoff" toml:"max_retry_backoff" env:"DB_MAX_RETRY_BACKOFF" flag:"db-max-retry-backoff" usage:"longest wait between database pings, e.g. 30s"`
+	// Replicas are the read replicas of the database, a comma separated list of host or host:port, with the user,
+	// password and name of the database. The lookups of the users are read from them.
+	Replicas string `yaml:"replicas" toml:"replicas" env:"DB_REPLICAS" flag:"db-replicas" usage:"comma separated host[:port] of the read replicas"`
+
+	// HealthCheckInterval is how often the database is pinged while the service runs, never if 0.
+	HealthCheckInterval Duration `yaml:"health_check_interval" toml:"health_check_interval" env:"DB_HEALTH_CHECK_INTERVAL" flag:"db-health-check-interval" usage:"how often the database is pinged while running, never if 0"`
+}
+
+// ReplicaConfigs returns the settings of each read replica: the ones of the database with the host and port of the
+// replica, its port being the one of the database if it has none.
+// It returns an error if a replica isn't a valid host or host:port, or if the database is SQLite.
+func (settings DatabaseConfig) ReplicaConfigs() ([]DatabaseConfig, error) {
+	if strings.TrimSpace(settings.Replicas) == "" {
+		return nil, nil
+	}
+
+	if settings.Driver == SQLiteDriver {
+		return nil, errors.New("database.replicas can't be used with sqlite")
+	}
+
+	var replicas []DatabaseConfig
+
+	for _, address := range strings.Split(settings.Replicas, ",") {
+		address = strings.TrimSpace(address)
+		replica := settings
+		replica.Replicas = ""
+		replica.Host = address
+
+		if host, port, err := net.SplitHostPort(address); err == nil {
+			number, err := strconv.Atoi(port)
+			if err != nil || number < 1 || number > 65535 {
+				return nil, fmt.Errorf("database.replicas: %q hasn't a valid port", address)
+			}
+
+			replica.Host = host
+			replica.Port = number
+		}
+
+		if replica.Host == "" {
+			return nil, fmt.Errorf("database.replicas: %q hasn't a host", address)
+		}
+
+		replicas = append(replicas, replica)
+	}
+
+	return replicas, nil
+}
+
+// TokenLifetime is how long the session tokens are valid after they're issued.
+const TokenLifetime = 15 * 24 * time.Hour
+
+// JWTConfig are the settings of the session tokens.
+type JWTConfig struct {
+	// SecretKey is the HMAC key the tokens are signed with.
+	SecretKey string `yaml:"secret_key" toml:"secret_key" env:"JWT_SECRET_KEY" flag:"jwt-secret-key" usage:"key the session tokens are signed with"`
+}
+
+// RevocationConfig are the settings of the in-memory cache of the revoked tokens.
+type RevocationConfig struct {
+	// Capacity is the minimum number of blacklisted tokens the Bloom filter is sized for.
+	Capacity int `yaml:"capacity" toml:"capacity" env:"REVOCATION_CACHE_CAPACITY" flag:"revocation-cache-capacity" usage:"blacklisted tokens the revocation cache is sized for"`
+	// FalsePositiveRate is the rate of the Bloom filter at its capacity, the false positives are checked in the
+	// database.
+	FalsePositiveRate float64 `yaml:"false_positive_rate" toml:"false_positive_rate" env:"REVOCATION_CACHE_FALSE_POSITIVE_RATE" flag:"revocation-cache-false-positive-rate" usage:"false positive rate of the revocation cache, between 0 and 1"`
+	// RecentSize is the number of recently blacklisted tokens kept to confirm the matches of the Bloom filter.
+	RecentSize int `yaml:"recent_size" toml:"recent_size" env:"REVOCATION_CACHE_RECENT_SIZE" flag:"revocation-cache-recent-size" usage:"recently blacklisted tokens kept by the revocation cache"`
+	// MaxStaleness is how long the cache answers after its last refresh, then the tokens are checked in the database.
+	MaxStaleness Duration `yaml:"max_staleness" toml:"max_staleness" env:"REVOCATION_CACHE_MAX_STALENESS" flag:"revocation-cache-max-staleness" usage:"how long the revocation cache answers after its last refresh, e.g. 30s"`
+	// Refresh is how often the changes of every instance are read.
+	Refresh Duration `yaml:"refresh" toml:"refresh" env:"REVOCATION_CACHE_REFRESH" flag:"revocation-cache-refresh" usage:"how often the revocation cache is refreshed, e.g. 5s"`
+	// Rebuild is how often the cache is loaded from scratch, to drop the expired tokens.
+	Rebuild Duration `yaml:"rebuild" toml:"rebuild" env:"REVOCATION_CACHE_REBUILD" flag:"revocation-cache-rebuild" usage:"how often the revocation cache is rebuilt, e.g. 1h"`
+}
+
+// LoginConfig are the settings of the lockout after failed logins and of the second factor challenges.
+type LoginConfig struct {
+	// MaxFailures is the number of failed logins in a row that lock an account.
+	MaxFailures int `yaml:"max_failures" toml:"max_failures" env:"LOGIN_MAX_FAILURES" flag:"login-max-failures" usage:"failed logins in a row that lock an account"`
+	// LockoutDuration is how long a locked account can't log in.
+	LockoutDuration Duration `yaml:"lockout_duration" toml:"lockout_duration" env:"LOGIN_LOCKOUT_DURATION" flag:"login-lockout-duration" usage:"how long a locked account can't log in, e.g. 15m"`
+	// ChallengeTTL is how long a second factor challenge can be answered.
+	ChallengeTTL Duration `yaml:"challenge_ttl" toml:"challenge_ttl" env:"LOGIN_CHALLENGE_TTL" flag:"login-challenge-ttl" usage:"how long a login challenge can be answered, e.g. 10m"`
+	// ChallengeMaxAttempts is the number of wrong answers that invalidate a challenge.
+	ChallengeMaxAttempts int `yaml:"challenge_max_attempts" toml:"challenge_max_attempts" env:"LOGIN_CHALLENGE_MAX_ATTEMPTS" flag:"login-challenge-max-attempts" usage:"wrong answers that invalidate a login challenge"`
+}
+
+// PasswordConfig are the settings of the password policy and resets.
+type PasswordConfig struct {
+	// MinLength is the minimum number of characters of a password.
+	MinLength int `yaml:"min_length" toml:"min_length" env:"PASSWORD_MIN_LENGTH" flag:"password-min-length" usage:"minimum length of the passwords"`
+	// MinStrength is the minimum zxcvbn like score of a password, from 0 to 4.
+	MinStrength int `yaml:"min_strength" toml:"min_strength" env:"PASSWORD_MIN_STRENGTH" flag:"password-min-strength" usage:"minimum strength of the passwords, from 0 to 4"`
+	// BreachedDir is the directory of the breached passwords dataset, the passwords aren't checked against data
+	// breaches if it's empty.
+	BreachedDir string `yaml:"breached_dir" toml:"breached_dir" env:"BREACHED_PASSWORDS_DIR" flag:"breached-passwords-dir" usage:"directory of the breached passwords dataset"`
+	// ResetTTL is how long a password reset link can be used.
+	ResetTTL Duration `yaml:"reset_ttl" toml:"reset_ttl" env:"PASSWORD_RESET_TTL" flag:"password-reset-ttl" usage:"how long a password reset link can be used, e.g. 1h"`
+}
+
+// AccountConfig are the settings of the changes of the accounts.
+type AccountConfig struct {
+	// UsernameChangeCooldown is how long a user waits between two username changes.
+	UsernameChangeCooldown Duration `yaml:"username_change_cooldown" toml:"username_change_cooldown" env:"USERNAME_CHANGE_COOLDOWN" flag:"username-change-cooldown" usage:"wait between two username changes, e.g. 720h"`
+	// UsernameReservationPeriod is how long a previous username is kept from the other users.
+	UsernameReservationPeriod Duration `yaml:"username_reservation_period" toml:"username_reservation_period" env:"USERNAME_RESERVATION_PERIOD" flag:"username-reservation-period" usage:"how long a previous username is reserved, e.g. 2160h"`
+	// EmailChangeConfirmTTL is how long the link confirming a new email can be used.
+	EmailChangeConfirmTTL Duration `yaml:"email_change_confirm_ttl" toml:"email_change_confirm_ttl" env:"EMAIL_CHANGE_CONFIRM_TTL" flag:"email-change-confirm-ttl" usage:"how long an email change can be confirmed, e.g. 24h"`
+	// EmailChangeUndoTTL is how long the link sent to the previous email can undo the change.
+	EmailChangeUndoTTL Duration `yaml:"email_change_undo_ttl" toml:"email_change_undo_ttl" env:"EMAIL_CHANGE_UNDO_TTL" flag:"email-change-undo-ttl" usage:"how long an email change can be undone, e.g. 168h"`
+	// AvatarMaxBytes is the maximum size of an uploaded avatar.
+	AvatarMaxBytes int `yaml:"avatar_max_bytes" toml:"avatar_max_bytes" env:"AVATAR_MAX_BYTES" flag:"avatar-max-bytes" usage:"maximum size of an uploaded avatar in bytes"`
+	// DataExportTTL is how long an export of the data of a user can be downloaded.
+	DataExportTTL Duration `yaml:"data_export_ttl" toml:"data_export_ttl" env:"DATA_EXPORT_TTL" flag:"data-export-ttl" usage:"how long a data export can be downloaded, e.g. 168h"`
+	// DeletionGracePeriod is how long a deleted account can be recovered by logging in before it's erased.
+	DeletionGracePeriod Duration `yaml:"deletion_grace_period" toml:"deletion_grace_period" env:"ACCOUNT_DELETION_GRACE_PERIOD" flag:"account-deletion-grace-period" usage:"how long a deleted account can be recovered, e.g. 720h"`
+	// DeletionInterval is how often the accounts whose grace period expired are erased.
+	DeletionInterval Duration `yaml:"deletion_interval" toml:"deletion_interval" env:"ACCOUNT_DELETION_INTERVAL" flag:"account-deletion-interval" usage:"how often the deleted accounts are erased, e.g. 1h"`
+	// StateInterval is how often the expired suspensions and bans are lifted.
+	StateInterval Duration `yaml:"state_interval" toml:"state_interval" env:"ACCOUNT_STATE_INTERVAL" flag:"account-state-interval" usage:"how often the expired suspensions and bans are lifted, e.g. 5m"`
+}
+
+// RiskConfig are the weights and thresholds of the login risk rules, a rule with a score of 0 is disabled.
+type RiskConfig struct {
+	// StepUpScore and BlockScore are the minimum scores to ask for a second factor and to block an attempt.
+	StepUpScore int `yaml:"step_up_score" toml:"step_up_score" env:"RISK_STEP_UP_SCORE" flag:"risk-step-up-score" usage:"minimum risk score that asks for a second factor"`
+	BlockScore  int `yaml:"block_score" toml:"block_score" env:"RISK_BLOCK_SCORE" flag:"risk-block-score" usage:"minimum risk score that blocks a login"`
+	// ImpossibleTravelScore applies when the distance from the previous login couldn't be travelled at
+	// MaxTravelSpeed km/h. Distances under MinTravelDistance km are ignored, as the locations are coarse.
+	ImpossibleTravelScore int     `yaml:"impossible_travel_score" toml:"impossible_travel_score" env:"RISK_IMPOSSIBLE_TRAVEL_SCORE" flag:"risk-impossible-travel-score" usage:"score of an impossible travel"`
+	MaxTravelSpeed        float64 `yaml:"max_travel_speed" toml:"max_travel_speed" env:"RISK_MAX_TRAVEL_SPEED" flag:"risk-max-travel-speed" usage:"fastest possible travel in km/h"`
+	MinTravelDistance     float64 `yaml:"min_travel_distance" toml:"min_travel_distance" env:"RISK_MIN_TRAVEL_DISTANCE" flag:"risk-min-travel-distance" usage:"shortest travel checked in km"`
+	// NewDeviceCountryScore applies when a login comes from a new device in a new country.
+	NewDeviceCountryScore int `yaml:"new_device_country_score" toml:"new_device_country_score" env:"RISK_NEW_DEVICE_COUNTRY_SCORE" flag:"risk-new-device-country-score" usage:"score of a new device in a new country"`
+	// FailedLoginsScore applies when the user had at least FailedLoginsThreshold failed logins in FailedLoginsWindow.
+	FailedLoginsScore     int      `yaml:"failed_logins_score" toml:"failed_logins_score" env:"RISK_FAILED_LOGINS_SCORE" flag:"risk-failed-logins-score" usage:"score of the recent failed logins"`
+	FailedLoginsThreshold int      `yaml:"failed_logins_threshold" toml:"failed_logins_threshold" env:"RISK_FAILED_LOGINS_THRESHOLD" flag:"risk-failed-logins-threshold" usage:"recent failed logins that add their score"`
+	FailedLoginsWindow    Duration `yaml:"failed_logins_window" toml:"failed_logins_window" env:"RISK_FAILED_LOGINS_WINDOW" flag:"risk-failed-logins-window" usage:"how far back the failed logins are counted, e.g. 1h"`
+	// BadIPScore applies when the IP is in the list of known bad IPs of BadIPsFile, disabled if it's empty.
+	BadIPScore int    `yaml:"bad_ip_score" toml:"bad_ip_score" env:"RISK_BAD_IP_SCORE" flag:"risk-bad-ip-score" usage:"score of a known bad IP"`
+	BadIPsFile string `yaml:"bad_ips_file" toml:"bad_ips_file" env:"RISK_BAD_IPS_FILE" flag:"risk-bad-ips-file" usage:"file of the known bad IPs and networks"`
+}
+
+// GeoIPConfig are the settings of the location of the logins.
+type GeoIPConfig struct {
+	// Database is the path of the GeoIP database, every location is unknown if it's empty.
+	Database string `yaml:"database" toml:"database" env:"GEOIP_DATABASE" flag:"geoip-database" usage:"path of the GeoIP database"`
+}
+
+// The storage backends.
+const (
+	LocalStorage = "local"
+	S3Storage    = "s3"
+)
+
+// StorageConfig are the settings of the storage of the uploaded files.
+type StorageConfig struct {
+	// Backend is where the files are saved: local or s3.
+	Backend string `yaml:"backend" toml:"backend" env:"STORAGE_BACKEND" flag:"storage-backend" usage:"where the uploaded files are saved: local or s3"`
+	// LocalDir is the directory of the local backend.
+	LocalDir string `yaml:"local_dir" toml:"local_dir" env:"STORAGE_LOCAL_DIR" flag:"storage-local-dir" usage:"directory of the uploaded files of the local backend"`
+	// PublicURL is the base URL of the files, by default the route of the service for the local backend and the
+	// bucket URL for s3.
+	PublicURL string `yaml:"public_url" toml:"public_url" env:"STORAGE_PUBLIC_URL" flag:"storage-public-url" usage:"base URL of the uploaded files"`
+
+	// S3Endpoint is the base URL of the S3 API, e.g. https://s3.amazonaws.com or http://minio:9000.
+	S3Endpoint string `yaml:"s3_endpoint" toml:"s3_endpoint" env:"S3_ENDPOINT" flag:"s3-endpoint" usage:"base URL of the S3 API"`
+	S3Region   string `yaml:"s3_region" toml:"s3_region" env:"S3_REGION" flag:"s3-region" usage:"S3 region, us-east-1 if empty"`
+	S3Bucket   string `yaml:"s3_bucket" toml:"s3_bucket" env:"S3_BUCKET" flag:"s3-bucket" usage:"S3 bucket of the uploaded files"`
+	// S3AccessKeyId and S3SecretAccessKey are the credentials used to sign the requests.
+	S3AccessKeyId     string `yaml:"s3_access_key_id" toml:"s3_access_key_id" env:"S3_ACCESS_KEY_ID" flag:"s3-access-key-id" usage:"S3 access key id"`
+	S3SecretAccessKey string `yaml:"s3_secret_access_key" toml:"s3_secret_access_key" env:"S3_SECRET_ACCESS_KEY" flag:"s3-secret-access-key" usage:"S3 secret access key"`
+	// S3PathStyle addresses the bucket as <endpoint>/<bucket>, required by most self hosted implementations.
+	S3PathStyle bool `yaml:"s3_path_style" toml:"s3_path_style" env:"S3_PATH_STYLE" flag:"s3-path-style" usage:"address the S3 bucket as <endpoint>/<bucket>"`
+}
+
+// MailerConfig are the settings of the emails sent to the users.
+type MailerConfig struct {
+	// SMTPHost is the SMTP server the emails are sent through, they are only logged if it's empty.
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host" env:"SMTP_HOST" flag:"smtp-host" usage:"SMTP server, the emails are only logged if empty"`
+	SMTPPort     int    `yaml:"smtp_port" toml:"smtp_port" env:"SMTP_PORT" flag:"smtp-port" usage:"SMTP port"`
+	SMTPUser     string `yaml:"smtp_user" toml:"smtp_user" env:"SMTP_USER" flag:"smtp-user" usage:"SMTP user"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password" env:"SMTP_PASSWORD" flag:"smtp-password" usage:"SMTP password"`
+	// From is the sender of the emails.
+	From string `yaml:"from" toml:"from" env:"MAIL_FROM" flag:"mail-from" usage:"sender of the emails"`
+}
+
+// Default returns the settings used for every value that isn't configured.
+func Default() Config {
+	revocationDefaults := revocation.DefaultConfig()
+	riskDefaults := risk.DefaultConfig()
+
+	return Config{
+		Mode:     DebugMode,
+		LogLevel: "DEBUG",
+		Server: ServerConfig{
+			Host:      "0.0.0.0",
+			Port:      8080,
+			PublicURL: "http://localhost:8080",
+		},
+		Database: DatabaseConfig{
+			Driver:              MySQLDriver,
+			Host:                "0.0.0.0",
+			MigrateOnStart:      true,
+			MaxOpenConns:        100,
+			MaxIdleConns:        10,
+			ConnMaxLifetime:     Duration(time.Hour),
+			ConnectAttempts:     10,
+			ConnectTimeout:      Duration(5 * time.Second),
+			RetryBackoff:        Duration(500 * time.Millisecond),
+			MaxRetryBackoff:     Duration(30 * time.Second),
+			HealthCheckInterval: Duration(15 * time.Second),
+		},
+		JWT: JWTConfig{
+			SecretKey: DefaultJWTKey,
+		},
+		Revocation: RevocationConfig{
+			Capacity:          revocationDefaults.Capacity,
+			FalsePositiveRate: revocationDefaults.FalsePositiveRate,
+			RecentSize:        revocationDefaults.RecentSize,
+			MaxStaleness:      Duration(revocationDefaults.MaxStaleness),
+			Refresh:           Duration(5 * time.Second),
+			Rebuild:           Duration(time.Hour),
+		},
+		Login: LoginConfig{
+			MaxFailures:          5,
+			LockoutDuration:      Duration(15 * time.Minute),
+			ChallengeTTL:         Duration(10 * time.Minute),
+			ChallengeMaxAttempts: 5,
+		},
+		Password: PasswordConfig{
+			MinLength:   8,
+			MinStrength: 2,
+			ResetTTL:    Duration(time.Hour),
+		},
+		Account: AccountConfig{
+			UsernameChangeCooldown:    Duration(30 * 24 * time.Hour),
+			UsernameReservationPeriod: Duration(90 * 24 * time.Hour),
+			EmailChangeConfirmTTL:     Duration(24 * time.Hour),
+			EmailChangeUndoTTL:        Duration(7 * 24 * time.Hour),
+			AvatarMaxBytes:            5 << 20,
+			DataExportTTL:             Duration(7 * 24 * time.Hour),
+			DeletionGracePeriod:       Duration(30 * 24 * time.Hour),
+			DeletionInterval:          Duration(time.Hour),
+			StateInterval:             Duration(5 * time.Minute),
+		},
+		Risk: RiskConfig{
+			StepUpScore:           riskDefaults.StepUpScore,
+			BlockScore:            riskDefaults.BlockScore,
+			ImpossibleTravelScore: riskDefaults.ImpossibleTravelScore,
+			MaxTravelSpeed:        riskDefaults.MaxTravelSpeed,
+			MinTravelDistance:     riskDefaults.MinTravelDistance,
+			NewDeviceCountryScore: riskDefaults.NewDeviceCountryScore,
+			FailedLoginsScore:     riskDefaults.FailedLoginsScore,
+			FailedLoginsThreshold: riskDefaults.FailedLoginsThreshold,
+			FailedLoginsWindow:    Duration(time.Hour),
+			BadIPScore:            riskDefaults.BadIPScore,
+		},
+		Storage: StorageConfig{
+			Backend:  LocalStorage,
+			LocalDir: "./uploads",
+		},
+		Mailer: MailerConfig{
+			SMTPPort: 587,
+			From:     "no-reply@nutripocket.com",
+		},
+	}
+}
+
+// Validate checks the settings, e.g. that the release mode doesn't use the default JWT key.
+// It returns an error listing every invalid setting, nil if they are all valid.
+func (config Config) Validate() error {
+	var errs []error
+
+	switch config.Mode {
+	case DebugMode, ReleaseMode, TestMode:
+	default:
+		errs = append(errs, fmt.Errorf("mode must be %s, %s or %s, not %q", DebugMode, ReleaseMode, TestMode, config.Mode))
+	}
+
+	if _, err := logging.LogLevel(config.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("log_level %q isn't a valid level", config.LogLevel))
+	}
+
+	if config.Server.Port < 1 || config.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, not %d", config.Server.Port))
+	}
+
+	switch config.Database.Driver {
+	case MySQLDriver, PostgresDriver, SQLiteDriver:
+	default:
+		errs = append(errs, fmt.Errorf("database.driver must be %s, %s or %s, not %q", MySQLDriver, PostgresDriver, SQLiteDriver, config.Database.Driver))
+	}
+
+	// A SQLite database is a file, it has no server to connect to.
+	if config.Database.Driver != SQLiteDriver {
+		if config.Database.Host == "" {
+			errs = append(errs, errors.New("database.host is required"))
+		}
+
+		if config.Database.Port < 0 || config.Database.Port > 65535 {
+			errs = append(errs, fmt.Errorf("database.port must be between 0 and 65535, not %d", config.Database.Port))
+		}
+
+		if config.Database.User == "" {
+			errs = append(errs, errors.New("database.user is required"))
+		}
+	}
+
+	if config.Database.Name == "" {
+		errs = append(errs, errors.New("database.name is required"))
+	}
+
+	if _, err := config.Database.ReplicaConfigs(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if config.Database.MaxOpenConns < 0 || config.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database.max_open_conns and database.max_idle_conns can't be negative"))
+	}
+
+	if config.Database.ConnectAttempts < 1 {
+		errs = append(errs, fmt.Errorf("database.connect_attempts must be at least 1, not %d", config.Database.ConnectAttempts))
+	}
+
+	durations := []struct {
+		name  string
+		value Duration
+	}{
+		{"conn_max_lifetime", config.Database.ConnMaxLifetime},
+		{"conn_max_idle_time", config.Database.ConnMaxIdleTime},
+		{"connect_timeout", config.Database.ConnectTimeout},
+		{"retry_backoff", config.Database.RetryBackoff},
+		{"max_retry_backoff", config.Database.MaxRetryBackoff},
+		{"health_check_interval", config.Database.HealthCheckInterval},
+	}
+	for _, duration := range durations {
+		if duration.value < 0 {
+			errs = append(errs, fmt.Errorf("database.%s can't be negative, not %s", duration.name, duration.value.Std()))
+		}
+	}
+
+	if config.Database.MaxRetryBackoff < config.Database.RetryBackoff {
+		errs = append(errs, errors.New("database.max_retry_backoff can't be shorter than database.retry_backoff"))
+	}
+
+	errs = append(errs, config.Server.validate()...)
+	errs = append(errs, config.validateServices()...)
+	errs = append(errs, config.Storage.validate()...)
+	errs = append(errs, config.Mailer.validate()...)
+
+	if config.JWT.SecretKey == "" {
+		errs = append(errs, errors.New("jwt.secret_key is required"))
+	} else if config.Mode == ReleaseMode && config.JWT.SecretKey == DefaultJWTKey {
+		errs = append(errs, errors.New("jwt.secret_key can't be the default key in release mode, set JWT_SECRET_KEY or JWT_SECRET_KEY_FILE"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate checks the public URL and the trusted proxies.
+func (settings ServerConfig) validate() []error {
+	var errs []error
+
+	if publicURL, err := url.Parse(settings.PublicURL); err != nil || (publicURL.Scheme != "http" && publicURL.Scheme != "https") || publicURL.Host == "" {
+		errs = append(errs, fmt.Errorf("server.public_url must be an http or https URL, not %q", settings.PublicURL))
+	}
+
+	for _, proxy := range settings.Proxies() {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies: %q isn't an IP or a CIDR", proxy))
+		}
+	}
+
+	return errs
+}
+
+// validateServices checks the settings of the services: the limits must be positive, the scores can be 0 to disable
+// their rules, and the intervals and lifetimes must be positive except the username ones, which can be 0 to disable
+// them.
+func (config Config) validateServices() []error {
+	var errs []error
+
+	positive := []struct {
+		name  string
+		value int
+	}{
+		{"revocation.capacity", config.Revocation.Capacity},
+		{"revocation.recent_size", config.Revocation.RecentSize},
+		{"login.max_failures", config.Login.MaxFailures},
+		{"login.challenge_max_attempts", config.Login.ChallengeMaxAttempts},
+		{"password.min_length", config.Password.MinLength},
+		{"account.avatar_max_bytes", config.Account.AvatarMaxBytes},
+		{"risk.failed_logins_threshold", config.Risk.FailedLoginsThreshold},
+	}
+	for _, limit := range positive {
+		if limit.value < 1 {
+			errs = append(errs, fmt.Errorf("%s must be at least 1, not %d", limit.name, limit.value))
+		}
+	}
+
+	scores := []struct {
+		name  string
+		value int
+	}{
+		{"risk.step_up_score", config.Risk.StepUpScore},
+		{"risk.block_score", config.Risk.BlockScore},
+		{"risk.impossible_travel_score", config.Risk.ImpossibleTravelScore},
+		{"risk.new_device_country_score", config.Risk.NewDeviceCountryScore},
+		{"risk.failed_logins_score", config.Risk.FailedLoginsScore},
+		{"risk.bad_ip_score", config.Risk.BadIPScore},
+	}
+	for _, score := range scores {
+		if score.value < 0 {
+			errs = append(errs, fmt.Errorf("%s can't be negative, not %d", score.name, score.value))
+		}
+	}
+
+	if config.Risk.MaxTravelSpeed <= 0 || config.Risk.MinTravelDistance < 0 {
+		errs = append(errs, errors.New("risk.max_travel_speed must be positive and risk.min_travel_distance can't be negative"))
+	}
+
+	if config.Revocation.FalsePositiveRate <= 0 || config.Revocation.FalsePositiveRate >= 1 {
+		errs = append(errs, fmt.Errorf("revocation.false_positive_rate must be between 0 and 1, not %g", config.Revocation.FalsePositiveRate))
+	}
+
+	if config.Password.MinStrength < 0 || config.Password.MinStrength > 4 {
+		errs = append(errs, fmt.Errorf("password.min_strength must be between 0 and 4, not %d", config.Password.MinStrength))
+	}
+
+	durations := []struct {
+		name     string
+		value    Duration
+		zeroable bool
+	}{
+		{"revocation.max_staleness", config.Revocation.MaxStaleness, false},
+		{"revocation.refresh", config.Revocation.Refresh, false},
+		{"revocation.rebuild", config.Revocation.Rebuild, false},
+		{"login.lockout_duration", config.Login.LockoutDuration, false},
+		{"login.challenge_ttl", config.Login.ChallengeTTL, false},
+		{"password.reset_ttl", config.Password.ResetTTL, false},
+		{"account.username_change_cooldown", config.Account.UsernameChangeCooldown, true},
+		{"account.username_reservation_period", config.Account.UsernameReservationPeriod, true},
+		{"account.email_change_confirm_ttl", config.Account.EmailChangeConfirmTTL, false},
+		{"account.email_change_undo_ttl", config.Account.EmailChangeUndoTTL, false},
+		{"account.data_export_ttl", config.Account.DataExportTTL, false},
+		{"account.deletion_grace_period", config.Account.DeletionGracePeriod, false},
+		{"account.deletion_interval", config.Account.DeletionInterval, false},
+		{"account.state_interval", config.Account.StateInterval, false},
+		{"risk.failed_logins_window", config.Risk.FailedLoginsWindow, false},
+	}
+	for _, duration := range durations {
+		if duration.value < 0 || (duration.value == 0 && !duration.zeroable) {
+			errs = append(errs, fmt.Errorf("%s must be positive, not %s", duration.name, duration.value.Std()))
+		}
+	}
+
+	// The tokens issued before a deletion must expire during its grace period, before the user is erased.
+	if grace := config.Account.DeletionGracePeriod.Std(); grace > 0 && grace < TokenLifetime {
+		errs = append(errs, fmt.Errorf("account.deletion_grace_period must be at least the token lifetime, %s, not %s", TokenLifetime, grace))
+	}
+
+	return errs
+}
+
+// validate checks that the settings of the selected backend are complete.
+func (settings StorageConfig) validate() []error {
+	var errs []error
+
+	switch settings.Backend {
+	case LocalStorage:
+		if settings.LocalDir == "" {
+			errs = append(errs, errors.New("storage.local_dir is required by the local backend"))
+		}
+	case S3Storage:
+		if settings.S3Endpoint == "" || settings.S3Bucket == "" || settings.S3AccessKeyId == "" || settings.S3SecretAccessKey == "" {
+			errs = append(errs, errors.New("storage.s3_endpoint, s3_bucket, s3_access_key_id and s3_secret_access_key are required by the s3 backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend must be %s or %s, not %q", LocalStorage, S3Storage, settings.Backend))
+	}
+
+	return errs
+}
+
+// validate checks the SMTP server, if the emails are sent.
+func (settings MailerConfig) validate() []error {
+	var errs []error
+
+	if settings.SMTPHost == "" {
+		return nil
+	}
+
+	if settings.SMTPPort < 1 || settings.SMTPPort > 65535 {
+		errs = append(errs, fmt.Errorf("mailer.smtp_port must be between 1 and 65535, not %d", settings.SMTPPort))
+	}
+
+	if settings.From == "" {
+		errs = append(errs, errors.New("mailer.from is required to send emails"))
+	}
+
+	return errs
+}
@@ -0,0 +1,347 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clearEnv unsets the environment variables of every setting for the duration of a test.
+func clearEnv(t *testing.T) {
+	config := Default()
+	unset := func(name string) {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	unset("CONFIG_FILE")
+	for _, field := range settings(reflect.ValueOf(&config).Elem()) {
+		if name := field.tag.Get("env"); name != "" {
+			unset(name)
+			unset(name + "_FILE")
+		}
+	}
+
+	t.Setenv("DB_USER", "root")
+	t.Setenv("DB_NAME", "test")
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("It should use the defaults if nothing is configured", func(t *testing.T) {
+		clearEnv(t)
+
+		config, _, err := Load(nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "0.0.0.0", config.Server.Host)
+		assert.Equal(t, 8080, config.Server.Port)
+		assert.Equal(t, MySQLDriver, config.Database.Driver)
+		assert.Equal(t, 0, config.Database.Port)
+		assert.Equal(t, DefaultJWTKey, config.JWT.SecretKey)
+	})
+
+	t.Run("It should read a YAML file", func(t *testing.T) {
+		clearEnv(t)
+		path := writeFile(t, "config.yaml", "server:\n  port: 9000\ndatabase:\n  host: db\n  port: 3307\n")
+
+		config, _, err := Load([]string{"-config", path})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 9000, config.Server.Port)
+		assert.Equal(t, "db", config.Database.Host)
+		assert.Equal(t, 3307, config.Database.Port)
+	})
+
+	t.Run("It should read a TOML file", func(t *testing.T) {
+		clearEnv(t)
+		path := writeFile(t, "config.toml", "log_level = \"INFO\"\n\n[database]\nhost = \"db\"\n")
+
+		config, _, err := Load([]string{"-config", path})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "INFO", config.LogLevel)
+		assert.Equal(t, "db", config.Database.Host)
+	})
+
+	t.Run("It should reject unknown keys in the file", func(t *testing.T) {
+		clearEnv(t)
+		path := writeFile(t, "config.yaml", "server:\n  prot: 9000\n")
+
+		_, _, err := Load([]string{"-config", path})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("It should let the environment override the file and the flags override both", func(t *testing.T) {
+		clearEnv(t)
+		path := writeFile(t, "config.yaml", "server:\n  host: file\n  port: 9000\ndatabase:\n  host: file\n")
+		t.Setenv("CONFIG_FILE", path)
+		t.Setenv("HOST", "env")
+		t.Setenv("PORT", "9001")
+
+		config, _, err := Load([]string{"-port", "9002"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "file", config.Database.Host)
+		assert.Equal(t, "env", config.Server.Host)
+		assert.Equal(t, 9002, config.Server.Port)
+	})
+
+	t.Run("It should read a variable from its _FILE variant before the variable itself", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("DB_PASSWORD", "from-env")
+		t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db_password", "from-file\n"))
+
+		config, _, err := Load(nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "from-file", config.Database.Password)
+	})
+
+	t.Run("It should fail if a _FILE variant can't be read", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("JWT_SECRET_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		_, _, err := Load(nil)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("It should fail if a number isn't one", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("PORT", "http")
+
+		_, _, err := Load(nil)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("It should parse booleans and return the arguments after the flags", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("DB_MIGRATE_ON_START", "false")
+
+		config, args, err := Load([]string{"-port", "9000", "migrate", "down", "2"})
+
+		assert.NoError(t, err)
+		assert.False(t, config.Database.MigrateOnStart)
+		assert.Equal(t, []string{"migrate", "down", "2"}, args)
+	})
+
+	t.Run("It should set the boolean flags without a value and parse the numbers with decimals", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("REVOCATION_CACHE_FALSE_POSITIVE_RATE", "0.01")
+
+		config, _, err := Load([]string{"-db-migrate-on-start=false", "-s3-path-style", "-risk-max-travel-speed", "900.5"})
+
+		assert.NoError(t, err)
+		assert.False(t, config.Database.MigrateOnStart)
+		assert.True(t, config.Storage.S3PathStyle)
+		assert.Equal(t, 0.01, config.Revocation.FalsePositiveRate)
+		assert.Equal(t, 900.5, config.Risk.MaxTravelSpeed)
+	})
+
+	t.Run("It should parse durations in the files, the environment and the flags", func(t *testing.T) {
+		clearEnv(t)
+		path := writeFile(t, "config.toml", "[database]\nconnect_timeout = \"2s\"\nretry_backoff = \"250ms\"\n")
+		t.Setenv("DB_MAX_RETRY_BACKOFF", "1m")
+
+		config, _, err := Load([]string{"-config", path, "-db-health-check-interval", "1m30s"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2*time.Second, config.Database.ConnectTimeout.Std())
+		assert.Equal(t, 250*time.Millisecond, config.Database.RetryBackoff.Std())
+		assert.Equal(t, time.Minute, config.Database.MaxRetryBackoff.Std())
+		assert.Equal(t, 90*time.Second, config.Database.HealthCheckInterval.Std())
+
+		path = writeFile(t, "config.yaml", "database:\n  conn_max_idle_time: 5m\n")
+		config, _, err = Load([]string{"-config", path})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 5*time.Minute, config.Database.ConnMaxIdleTime.Std())
+	})
+
+	t.Run("It should fail if a duration isn't one", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("DB_CONNECT_TIMEOUT", "5")
+
+		_, _, err := Load(nil)
+
+		assert.ErrorContains(t, err, "DB_CONNECT_TIMEOUT")
+	})
+
+	t.Run("It should return flag.ErrHelp for -h", func(t *testing.T) {
+		clearEnv(t)
+
+		_, _, err := Load([]string{"-h"})
+
+		assert.ErrorIs(t, err, flag.ErrHelp)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() Config {
+		config := Default()
+		config.Database.User = "root"
+		config.Database.Name = "test"
+		return config
+	}
+
+	t.Run("It should accept the defaults with a database outside the release mode", func(t *testing.T) {
+		assert.NoError(t, valid().Validate())
+	})
+
+	t.Run("It should refuse the default JWT key in release mode", func(t *testing.T) {
+		config := valid()
+		config.Mode = ReleaseMode
+
+		assert.ErrorContains(t, config.Validate(), "jwt.secret_key")
+
+		config.JWT.SecretKey = "a-long-random-production-key"
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("It should list every invalid setting", func(t *testing.T) {
+		config := valid()
+		config.Mode = "production"
+		config.Server.Port = 0
+		config.Database.Name = ""
+		config.Database.Driver = "oracle"
+
+		err := config.Validate()
+
+		assert.ErrorContains(t, err, "mode")
+		assert.ErrorContains(t, err, "server.port")
+		assert.ErrorContains(t, err, "database.name")
+		assert.ErrorContains(t, err, "database.driver")
+	})
+
+	t.Run("It should refuse negative pool settings and a backoff that can't grow", func(t *testing.T) {
+		config := valid()
+		config.Database.MaxOpenConns = -1
+		config.Database.ConnectAttempts = 0
+		config.Database.ConnectTimeout = Duration(-time.Second)
+		config.Database.MaxRetryBackoff = config.Database.RetryBackoff / 2
+
+		err := config.Validate()
+
+		assert.ErrorContains(t, err, "database.max_open_conns")
+		assert.ErrorContains(t, err, "database.connect_attempts")
+		assert.ErrorContains(t, err, "database.connect_timeout")
+		assert.ErrorContains(t, err, "database.max_retry_backoff")
+	})
+
+	t.Run("It should refuse invalid settings of the services and their backends", func(t *testing.T) {
+		config := valid()
+		config.Server.PublicURL = "localhost:8080"
+		config.Server.TrustedProxies = "10.0.0.0/8, 192.168.1.1, proxy"
+		config.Revocation.FalsePositiveRate = 1
+		config.Login.MaxFailures = 0
+		config.Password.MinStrength = 5
+		config.Account.StateInterval = 0
+		config.Risk.BlockScore = -1
+		config.Storage.Backend = S3Storage
+		config.Mailer.SMTPHost = "smtp"
+		config.Mailer.SMTPPort = 0
+
+		err := config.Validate()
+
+		assert.ErrorContains(t, err, "server.public_url")
+		assert.ErrorContains(t, err, `server.trusted_proxies: "proxy"`)
+		assert.ErrorContains(t, err, "revocation.false_positive_rate")
+		assert.ErrorContains(t, err, "login.max_failures")
+		assert.ErrorContains(t, err, "password.min_strength")
+		assert.ErrorContains(t, err, "account.state_interval")
+		assert.ErrorContains(t, err, "risk.block_score")
+		assert.ErrorContains(t, err, "storage.s3_endpoint")
+		assert.ErrorContains(t, err, "mailer.smtp_port")
+		assert.NotContains(t, err.Error(), "10.0.0.0/8")
+
+		config = valid()
+		config.Account.UsernameChangeCooldown = 0
+		assert.NoError(t, config.Validate(), "the username cooldown can be disabled")
+	})
+
+	t.Run("It should refuse a deletion grace period shorter than the token lifetime", func(t *testing.T) {
+		config := valid()
+		config.Account.DeletionGracePeriod = Duration(TokenLifetime - time.Hour)
+		assert.ErrorContains(t, config.Validate(), "account.deletion_grace_period")
+
+		config.Account.DeletionGracePeriod = Duration(TokenLifetime)
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("It should only require the name of a SQLite database", func(t *testing.T) {
+		config := Default()
+		config.Database.Driver = SQLiteDriver
+		config.Database.Host = ""
+		config.Database.Name = SQLiteMemory
+
+		assert.NoError(t, config.Validate())
+
+		config.Database.Name = ""
+		assert.ErrorContains(t, config.Validate(), "database.name")
+	})
+}
+
+func TestReplicaConfigs(t *testing.T) {
+	settings := Default().Database
+	settings.User = "root"
+	settings.Name = "test"
+	settings.Port = 3307
+
+	t.Run("It should have no replicas by default", func(t *testing.T) {
+		replicas, err := settings.ReplicaConfigs()
+
+		assert.NoError(t, err)
+		assert.Empty(t, replicas)
+	})
+
+	t.Run("It should copy the settings of the database with the address of each replica", func(t *testing.T) {
+		settings := settings
+		settings.Replicas = "replica-1, replica-2:3308,[::1]:3309"
+
+		replicas, err := settings.ReplicaConfigs()
+
+		assert.NoError(t, err)
+		if assert.Len(t, replicas, 3) {
+			assert.Equal(t, "replica-1", replicas[0].Host)
+			assert.Equal(t, 3307, replicas[0].Port)
+			assert.Equal(t, "replica-2", replicas[1].Host)
+			assert.Equal(t, 3308, replicas[1].Port)
+			assert.Equal(t, "::1", replicas[2].Host)
+			assert.Equal(t, "test", replicas[2].Name)
+			assert.Empty(t, replicas[2].Replicas)
+		}
+	})
+
+	t.Run("It should refuse invalid replicas and replicas of SQLite", func(t *testing.T) {
+		for _, replicas := range []string{"replica,", "replica:http", ":3306"} {
+			settings := settings
+			settings.Replicas = replicas
+
+			_, err := settings.ReplicaConfigs()
+			assert.ErrorContains(t, err, "database.replicas", replicas)
+		}
+
+		settings := settings
+		settings.Driver = SQLiteDriver
+		settings.Replicas = "replica"
+
+		_, err := settings.ReplicaConfigs()
+		assert.ErrorContains(t, err, "sqlite")
+	})
+}
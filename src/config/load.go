@@ -0,0 +1,204 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is a field of the Config with the tags that name its sources.
+type setting struct {
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+// set parses raw into the field, with its UnmarshalText method if it has one, e.g. a Duration.
+func (s setting) set(raw string) error {
+	if unmarshaler, ok := s.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(raw)
+	case reflect.Int:
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q isn't an integer", raw)
+		}
+		s.value.SetInt(int64(number))
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q isn't a number", raw)
+		}
+		s.value.SetFloat(number)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q isn't a boolean", raw)
+		}
+		s.value.SetBool(value)
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+
+	return nil
+}
+
+// settings returns the fields of a struct, and of its nested structs, that have a source tag.
+func settings(value reflect.Value) []setting {
+	var result []setting
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			result = append(result, settings(value.Field(i))...)
+			continue
+		}
+
+		if field.Tag.Get("env") != "" || field.Tag.Get("flag") != "" {
+			result = append(result, setting{value: value.Field(i), tag: field.Tag})
+		}
+	}
+
+	return result
+}
+
+// Load returns the settings of the service: the defaults overridden by the config file, then by the environment
+// and then by the command line flags in args, e.g. os.Args[1:]. It also returns the arguments after the flags, e.g. a
+// subcommand.
+// The config file is the one of the -config flag or of CONFIG_FILE, none if both are empty. Every environment
+// variable can also be read from a file, e.g. a Docker secret, whose path is in the variable with the _FILE suffix,
+// which takes precedence over the variable itself.
+// It returns an error if a source can't be read or the settings are invalid, flag.ErrHelp if -h was passed.
+func Load(args []string) (Config, []string, error) {
+	config := Default()
+	fields := settings(reflect.ValueOf(&config).Elem())
+
+	flags := flag.NewFlagSet("user-service", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv("CONFIG_FILE"), "path of the YAML or TOML config file")
+
+	byFlag := make(map[string]setting, len(fields))
+	for _, field := range fields {
+		name := field.tag.Get("flag")
+		if name == "" {
+			continue
+		}
+
+		// A boolean flag is set without a value, e.g. -s3-path-style, or with -flag=false. Its default isn't used, only
+		// the flags that were passed are applied.
+		if field.value.Kind() == reflect.Bool {
+			flags.Bool(name, false, field.tag.Get("usage"))
+		} else {
+			flags.String(name, "", field.tag.Get("usage"))
+		}
+		byFlag[name] = field
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return config, nil, err
+	}
+
+	if *path != "" {
+		if err := readFile(*path, &config); err != nil {
+			return config, nil, fmt.Errorf("failed to read the config file %s: %w", *path, err)
+		}
+	}
+
+	for _, field := range fields {
+		if err := readEnv(field); err != nil {
+			return config, nil, err
+		}
+	}
+
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		if field, ok := byFlag[f.Name]; ok && err == nil {
+			if setErr := field.set(f.Value.String()); setErr != nil {
+				err = fmt.Errorf("flag -%s: %w", f.Name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return config, nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return config, flags.Args(), nil
+}
+
+// readFile decodes a YAML or TOML file, chosen by its extension, into config.
+// Keys that aren't settings are rejected, so a typo doesn't silently leave a default.
+func readFile(path string, config *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+
+		if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(file)
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(config); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported format %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+
+	return nil
+}
+
+// readEnv sets a field from the file of its NAME_FILE variable or else from its NAME variable, if any is set.
+// The trailing newlines of the file are dropped, as most editors and secret tools add one.
+func readEnv(field setting) error {
+	name := field.tag.Get("env")
+	if name == "" {
+		return nil
+	}
+
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+
+		if err := field.set(strings.TrimRight(string(content), "\r\n")); err != nil {
+			return fmt.Errorf("%s_FILE: %w", name, err)
+		}
+
+		return nil
+	}
+
+	if value := os.Getenv(name); value != "" {
+		if err := field.set(value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
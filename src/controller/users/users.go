@@ -2,8 +2,11 @@
 package controller
 
 import (
+	"net/url"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/NutriPocket/UserService/model"
 )
@@ -26,6 +29,23 @@ func (controller *UserController) ValidateString(str string, field string) error
 	return nil
 }
 
+// reservedUsernames are the usernames that can't be used because they are route names.
+var reservedUsernames = map[string]bool{"me": true}
+
+// ValidateUsername validates a username and returns an error if it's an invalid string or a reserved name.
+// username is the username to validate.
+func (controller *UserController) ValidateUsername(username string) error {
+	if err := controller.ValidateString(username, "username"); err != nil {
+		return err
+	}
+
+	if reservedUsernames[strings.ToLower(username)] || strings.Contains(username, "/") {
+		return &model.ValidationError{Detail: "The username " + username + " can't be used", Title: "Invalid username field"}
+	}
+
+	return nil
+}
+
 // ValidateEmail validates an email and returns an error if the email is not a valid email address.
 // email is the email to validate.
 func (controller *UserController) ValidateEmail(email string) error {
@@ -53,3 +73,74 @@ func (controller *UserController) ValidateUsernameOrEmail(str string) error {
 
 	return controller.ValidateString(str, "username")
 }
+
+// ValidatePicture validates that every URL of a picture is an http(s) URL or an absolute path of this service.
+// picture is the picture to validate.
+func (controller *UserController) ValidatePicture(picture model.Picture) error {
+	for _, raw := range []string{picture.Small, picture.Medium, picture.Large} {
+		if raw == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(raw)
+		validAbsolute := err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+		validPath := err == nil && parsed.Scheme == "" && parsed.Host == "" && strings.HasPrefix(parsed.Path, "/")
+
+		if !validAbsolute && !validPath {
+			return &model.ValidationError{Detail: "The picture field must contain http or https URLs", Title: "Invalid picture field"}
+		}
+	}
+
+	return nil
+}
+
+// ValidateAccountStateChange validates the new state of an account set by an admin.
+// Suspensions and deletions must expire in the future, bans may be permanent, and only active accounts can't expire.
+// change is the state change to validate.
+// now is the time the expiry is compared to.
+func (controller *UserController) ValidateAccountStateChange(change model.AccountStateChange, now time.Time) error {
+	if !slices.Contains(model.AccountStates, change.State) {
+		return &model.ValidationError{
+			Detail: "The state field must be one of " + strings.Join(model.AccountStates, ", "),
+			Title:  "Invalid state field",
+		}
+	}
+
+	if len(change.Reason) > 500 {
+		return &model.ValidationError{Detail: "The reason field must be less than 500 characters", Title: "Invalid reason field"}
+	}
+
+	if (change.State == model.AccountSuspended || change.State == model.AccountBanned) && change.Reason == "" {
+		return &model.ValidationError{Detail: "The reason field is required to " + change.State + " an account", Title: "Empty reason field"}
+	}
+
+	switch change.State {
+	case model.AccountActive:
+		if change.Until != nil {
+			return &model.ValidationError{Detail: "Active accounts can't have an until field", Title: "Invalid until field"}
+		}
+	case model.AccountSuspended, model.AccountPendingDeletion:
+		if change.Until == nil {
+			return &model.ValidationError{Detail: "The until field is required for the " + change.State + " state", Title: "Empty until field"}
+		}
+	}
+
+	if change.Until != nil && !change.Until.After(now) {
+		return &model.ValidationError{Detail: "The until field must be in the future", Title: "Invalid until field"}
+	}
+
+	return nil
+}
+
+// ValidateRole validates that a role is one of the roles of the users.
+// role is the role to validate.
+func (controller *UserController) ValidateRole(role string) error {
+	if !slices.Contains(model.Roles, role) {
+		return &model.ValidationError{
+			Detail: "The role field must be one of " + strings.Join(model.Roles, ", "),
+			Title:  "Invalid role field",
+		}
+	}
+
+	return nil
+}
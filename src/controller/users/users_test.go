@@ -3,6 +3,9 @@ package controller
 import (
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
 )
 
 func TestValidateString(t *testing.T) {
@@ -40,6 +43,35 @@ func TestValidateString(t *testing.T) {
 	})
 }
 
+func TestValidateUsername(t *testing.T) {
+	t.Run("A valid username", func(t *testing.T) {
+		controller := UserController{}
+		username := "nutri.pocket"
+
+		if err := controller.ValidateUsername(username); err != nil {
+			t.Errorf("The username '%s' is invalid, what?", username)
+		}
+	})
+
+	t.Run("A reserved username is invalid", func(t *testing.T) {
+		controller := UserController{}
+		username := "Me"
+
+		if err := controller.ValidateUsername(username); err == nil {
+			t.Errorf("The username '%s' is valid, what?", username)
+		}
+	})
+
+	t.Run("A username with slashes is invalid", func(t *testing.T) {
+		controller := UserController{}
+		username := "nutri/pocket"
+
+		if err := controller.ValidateUsername(username); err == nil {
+			t.Errorf("The username '%s' is valid, what?", username)
+		}
+	})
+}
+
 func TestValidateEmail(t *testing.T) {
 	t.Run("A valid email", func(t *testing.T) {
 		controller := UserController{}
@@ -85,3 +117,122 @@ func TestValidateEmail(t *testing.T) {
 		}
 	})
 }
+
+func TestValidatePicture(t *testing.T) {
+	t.Run("An empty picture is valid", func(t *testing.T) {
+		controller := UserController{}
+
+		if err := controller.ValidatePicture(model.Picture{}); err != nil {
+			t.Errorf("An empty picture is invalid, what?")
+		}
+	})
+
+	t.Run("A picture with http URLs and service paths is valid", func(t *testing.T) {
+		controller := UserController{}
+		picture := model.Picture{Small: "https://cdn.test.com/small.jpg", Medium: "/static/avatars/1/medium.jpg"}
+
+		if err := controller.ValidatePicture(picture); err != nil {
+			t.Errorf("The picture '%v' is invalid, what?", picture)
+		}
+	})
+
+	t.Run("A picture with another scheme is invalid", func(t *testing.T) {
+		controller := UserController{}
+		picture := model.Picture{Large: "javascript:alert(1)"}
+
+		if err := controller.ValidatePicture(picture); err == nil {
+			t.Errorf("The picture '%v' is valid, what?", picture)
+		}
+	})
+
+	t.Run("A picture with a relative path is invalid", func(t *testing.T) {
+		controller := UserController{}
+		picture := model.Picture{Large: "avatar.jpg"}
+
+		if err := controller.ValidatePicture(picture); err == nil {
+			t.Errorf("The picture '%v' is valid, what?", picture)
+		}
+	})
+}
+
+func TestValidateAccountStateChange(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tomorrow := now.Add(24 * time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+
+	t.Run("A suspension with a reason and a future expiry is valid", func(t *testing.T) {
+		controller := UserController{}
+		change := model.AccountStateChange{State: model.AccountSuspended, Reason: "Spam", Until: &tomorrow}
+
+		if err := controller.ValidateAccountStateChange(change, now); err != nil {
+			t.Errorf("The change '%v' is invalid, what?", change)
+		}
+	})
+
+	t.Run("A permanent ban is valid", func(t *testing.T) {
+		controller := UserController{}
+		change := model.AccountStateChange{State: model.AccountBanned, Reason: "Harassment"}
+
+		if err := controller.ValidateAccountStateChange(change, now); err != nil {
+			t.Errorf("The change '%v' is invalid, what?", change)
+		}
+	})
+
+	t.Run("An unknown state is invalid", func(t *testing.T) {
+		controller := UserController{}
+		change := model.AccountStateChange{State: "deleted"}
+
+		if err := controller.ValidateAccountStateChange(change, now); err == nil {
+			t.Errorf("The change '%v' is valid, what?", change)
+		}
+	})
+
+	t.Run("A suspension without a reason or an expiry is invalid", func(t *testing.T) {
+		controller := UserController{}
+
+		for _, change := range []model.AccountStateChange{
+			{State: model.AccountSuspended, Until: &tomorrow},
+			{State: model.AccountSuspended, Reason: "Spam"},
+		} {
+			if err := controller.ValidateAccountStateChange(change, now); err == nil {
+				t.Errorf("The change '%v' is valid, what?", change)
+			}
+		}
+	})
+
+	t.Run("An expiry in the past is invalid", func(t *testing.T) {
+		controller := UserController{}
+		change := model.AccountStateChange{State: model.AccountBanned, Reason: "Spam", Until: &yesterday}
+
+		if err := controller.ValidateAccountStateChange(change, now); err == nil {
+			t.Errorf("The change '%v' is valid, what?", change)
+		}
+	})
+
+	t.Run("An active account with an expiry is invalid", func(t *testing.T) {
+		controller := UserController{}
+		change := model.AccountStateChange{State: model.AccountActive, Until: &tomorrow}
+
+		if err := controller.ValidateAccountStateChange(change, now); err == nil {
+			t.Errorf("The change '%v' is valid, what?", change)
+		}
+	})
+}
+
+func TestValidateRole(t *testing.T) {
+	t.Run("The admin role is valid", func(t *testing.T) {
+		controller := UserController{}
+
+		if err := controller.ValidateRole(model.RoleAdmin); err != nil {
+			t.Errorf("The role '%s' is invalid, what?", model.RoleAdmin)
+		}
+	})
+
+	t.Run("An unknown role is invalid", func(t *testing.T) {
+		controller := UserController{}
+
+		if err := controller.ValidateRole("root"); err == nil {
+			t.Errorf("The role 'root' is valid, what?")
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff returns the waits between the attempts of an operation, doubled after each attempt from Initial up to Max.
+// Each wait is jittered, so the instances that fail together, e.g. when the database restarts, don't retry together.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	// attempt is the number of waits returned since the last Reset.
+	attempt int
+}
+
+// Next returns the wait before the next attempt, a random duration between half and all of the exponential one.
+// It returns 0 if Initial is 0.
+func (b *Backoff) Next() time.Duration {
+	if b.Initial <= 0 {
+		return 0
+	}
+
+	limit := max(b.Max, b.Initial)
+
+	wait := b.Initial
+	for i := 0; i < b.attempt && wait < limit; i++ {
+		wait *= 2
+	}
+
+	if wait < limit {
+		b.attempt++
+	}
+	wait = min(wait, limit)
+
+	half := wait / 2
+	return half + rand.N(wait-half+1)
+}
+
+// Reset makes the next wait the initial one again, e.g. after the operation succeeds.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
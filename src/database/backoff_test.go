@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Run("It should double the wait up to the maximum, with jitter", func(t *testing.T) {
+		backoff := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+
+		for _, exponential := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+			exponential *= time.Millisecond
+			wait := backoff.Next()
+
+			assert.GreaterOrEqual(t, wait, exponential/2)
+			assert.LessOrEqual(t, wait, exponential)
+		}
+	})
+
+	t.Run("It should start over after a reset", func(t *testing.T) {
+		backoff := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+		for range 5 {
+			backoff.Next()
+		}
+
+		backoff.Reset()
+
+		assert.LessOrEqual(t, backoff.Next(), 100*time.Millisecond)
+	})
+
+	t.Run("It should not wait without an initial wait", func(t *testing.T) {
+		backoff := Backoff{Max: time.Second}
+
+		assert.Zero(t, backoff.Next())
+	})
+}
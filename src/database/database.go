@@ -4,68 +4,149 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/NutriPocket/UserService/config"
+	"github.com/glebarez/sqlite"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/op/go-logging"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var log = logging.MustGetLogger("log")
 var db *sql.DB
+var dialect = MySQL
 
-// ConnectDB connects to the database.
-// If it fails to connect to the database, it will try again 5 times. If it fails all 5 times, it will panic.
+// ConnectDB connects to the database of the settings, MySQL, PostgreSQL or SQLite as chosen by its driver.
+// It waits for the database as Connect does, and panics if it never answers.
 // If it connects to the database, it will print a message to the console and assign the DB variable to the connection.
-func ConnectDB() {
+func ConnectDB(settings config.DatabaseConfig) {
 	if db != nil {
 		return
 	}
 
-	db_user := os.Getenv("DB_USER")
-	db_password := os.Getenv("DB_PASSWORD")
-	db_name := os.Getenv("DB_NAME")
+	dialect = Dialect(settings.Driver)
 
-	db_host := os.Getenv("DB_HOST")
+	if dialect == SQLite {
+		log.Infof("Opening sqlite database %s\n", settings.Name)
+	} else {
+		log.Infof("Connecting to %s database %s at %s as %s\n", dialect, settings.Name, address(settings), settings.User)
+	}
 
-	if db_host == "" {
-		db_host = "0.0.0.0"
+	conn, err := Open(settings)
+	if err != nil {
+		log.Panicf("Failed to open the database, %s", err)
 	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:3306)/%s?charset=utf8mb4&parseTime=True&loc=UTC", db_user, db_password, db_host, db_name)
+	if err := Connect(conn, settings); err != nil {
+		conn.Close()
+		log.Panicf("Failed to connect to database, %s", err)
+	}
 
-	log.Infof("DSN: %s\n", dsn)
+	db = conn
+	status.record(nil, time.Now())
+	log.Info("Connected to database")
 
-	var try uint
-	var err error
+	if err := connectReplicas(settings); err != nil {
+		log.Panicf("Failed to open the read replicas, %s", err)
+	}
+}
 
-	for try < 5 {
-		db, err = sql.Open("mysql", dsn)
+// Open opens a connection pool to the database of the settings, apart from the one of ConnectDB, e.g. a private
+// SQLite database for a test. It doesn't connect to the database until it's used.
+func Open(settings config.DatabaseConfig) (*sql.DB, error) {
+	driver, dsn := dataSource(settings)
 
-		if err != nil {
-			log.Infof("Failed to connect to database, trying again. Try number: %d\n. Err: %v", try, err)
-			time.Sleep(2 * time.Second)
-			try++
-			continue
-		}
+	conn, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetMaxOpenConns(settings.MaxOpenConns)
+	if settings.MaxIdleConns > 0 {
+		conn.SetMaxIdleConns(settings.MaxIdleConns)
+	}
+	conn.SetConnMaxLifetime(settings.ConnMaxLifetime.Std())
+	conn.SetConnMaxIdleTime(settings.ConnMaxIdleTime.Std())
+
+	// Every connection to :memory: opens a different database, so the pool must keep a single one open forever.
+	if Dialect(settings.Driver) == SQLite && settings.Name == config.SQLiteMemory {
+		conn.SetMaxOpenConns(1)
+		conn.SetMaxIdleConns(1)
+		conn.SetConnMaxLifetime(0)
+		conn.SetConnMaxIdleTime(0)
+	}
 
-		db.SetMaxIdleConns(10)
-		db.SetMaxOpenConns(100)
-		db.SetConnMaxLifetime(time.Hour)
+	return conn, nil
+}
 
-		log.Info("Connected to database")
-		return
+// address returns the host and port of the database server of the settings.
+func address(settings config.DatabaseConfig) string {
+	port := settings.Port
+	if port == 0 {
+		port = Dialect(settings.Driver).DefaultPort()
 	}
 
-	log.Panicf("Failed to connect to database, %s", err)
+	return net.JoinHostPort(settings.Host, strconv.Itoa(port))
+}
+
+// dataSource returns the database/sql driver name and the data source name of the settings.
+// Every dialect stores and reads the times in UTC.
+func dataSource(settings config.DatabaseConfig) (string, string) {
+	switch Dialect(settings.Driver) {
+	case PostgreSQL:
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(settings.User, settings.Password),
+			Host:     address(settings),
+			Path:     "/" + settings.Name,
+			RawQuery: url.Values{"sslmode": {"prefer"}, "timezone": {"UTC"}}.Encode(),
+		}
+
+		return "pgx", dsn.String()
+	case SQLite:
+		// The foreign keys are off by default in SQLite. The transactions take the write lock when they begin, as
+		// the rows locked by FOR UPDATE in the other dialects, and wait for it instead of failing at once.
+		return "sqlite", "file:" + settings.Name +
+			"?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	default:
+		return "mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC", settings.User, settings.Password, address(settings), settings.Name)
+	}
+}
+
+// GetConnection returns the connection pool opened by ConnectDB.
+func GetConnection() *sql.DB {
+	return db
+}
+
+// CurrentDialect returns the dialect of the database opened by ConnectDB.
+func CurrentDialect() Dialect {
+	return dialect
 }
 
 func GetPoolConnection() (*gorm.DB, error) {
-	gormDB, err := gorm.Open(
-		mysql.New(mysql.Config{Conn: db}),
-		&gorm.Config{},
-	)
+	return NewGorm(db, dialect)
+}
+
+// NewGorm wraps a connection pool of a dialect in gorm.
+func NewGorm(conn *sql.DB, dialect Dialect) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch dialect {
+	case PostgreSQL:
+		dialector = postgres.New(postgres.Config{Conn: conn})
+	case SQLite:
+		dialector = &sqlite.Dialector{Conn: conn}
+	default:
+		dialector = mysql.New(mysql.Config{Conn: conn})
+	}
+
+	// The connections are checked by Connect and Monitor, a replica that doesn't answer is still wrapped.
+	gormDB, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true})
 
 	if err != nil {
 		log.Errorf("Failed to initialize gorm: %v", err)
@@ -76,6 +157,13 @@ func GetPoolConnection() (*gorm.DB, error) {
 }
 
 func Close() {
+	for _, replica := range replicas {
+		if err := replica.conn.Close(); err != nil {
+			log.Errorf("Failed to close the connection to the replica %s: %s\n", replica.address, err)
+		}
+	}
+	replicas = nil
+
 	if db != nil {
 		err := db.Close()
 		if err != nil {
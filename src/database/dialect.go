@@ -0,0 +1,137 @@
+package database
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Dialect is a SQL database supported by the service, named as its gorm dialector.
+type Dialect string
+
+const (
+	MySQL      Dialect = "mysql"
+	PostgreSQL Dialect = "postgres"
+	// SQLite is the embedded database, for the development and the tests without a database server.
+	SQLite Dialect = "sqlite"
+)
+
+// The extended result codes of SQLite for the violations of a unique and a primary key.
+const (
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// DefaultPort returns the port the database listens on by default, 0 for SQLite which is a file.
+func (dialect Dialect) DefaultPort() int {
+	switch dialect {
+	case PostgreSQL:
+		return 5432
+	case SQLite:
+		return 0
+	default:
+		return 3306
+	}
+}
+
+// Rebind replaces the ? placeholders of a query with the ones of the dialect, e.g. $1 in PostgreSQL.
+// It's only needed by the queries run without gorm, which rebinds its own, and they can't have ? in their literals.
+func (dialect Dialect) Rebind(query string) string {
+	if dialect != PostgreSQL {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+
+	for _, char := range query {
+		if char != '?' {
+			builder.WriteRune(char)
+			continue
+		}
+
+		n++
+		builder.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return builder.String()
+}
+
+// OnConflict returns the clause of an INSERT that updates the existing row with the same key instead, followed by
+// the assignments, e.g. ON DUPLICATE KEY UPDATE in MySQL.
+// key is the column of the unique key, ignored by MySQL which uses every unique key.
+func (dialect Dialect) OnConflict(key string) string {
+	if dialect == PostgreSQL || dialect == SQLite {
+		return "ON CONFLICT (" + key + ") DO UPDATE SET"
+	}
+
+	return "ON DUPLICATE KEY UPDATE"
+}
+
+// Excluded returns the expression of the value a conflicting INSERT tried to give to a column, to be used in the
+// assignments after OnConflict.
+func (dialect Dialect) Excluded(column string) string {
+	if dialect == PostgreSQL || dialect == SQLite {
+		return "EXCLUDED." + column
+	}
+
+	return "VALUES(" + column + ")"
+}
+
+// Greatest returns the expression of the greatest of two values, GREATEST in MySQL and PostgreSQL and the scalar MAX
+// in SQLite.
+func (dialect Dialect) Greatest(a string, b string) string {
+	if dialect == SQLite {
+		return "MAX(" + a + ", " + b + ")"
+	}
+
+	return "GREATEST(" + a + ", " + b + ")"
+}
+
+// ForUpdate returns the clause of a SELECT that locks the rows read until the end of the transaction.
+// It's empty in SQLite, which has no row locks, as its transactions are begun holding the lock of the whole database.
+func (dialect Dialect) ForUpdate() string {
+	if dialect == SQLite {
+		return ""
+	}
+
+	return "FOR UPDATE"
+}
+
+// DialectOf returns the dialect of a gorm connection, MySQL if it isn't one.
+func DialectOf(db any) Dialect {
+	if gormDB, ok := db.(*gorm.DB); ok && gormDB.Dialector != nil {
+		return Dialect(gormDB.Dialector.Name())
+	}
+
+	return MySQL
+}
+
+// IsDuplicateKey returns true if an error is the violation of a primary or unique key, whatever the driver.
+func IsDuplicateKey(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+
+	var sqliteErr *gosqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqliteConstraintUnique || sqliteErr.Code() == sqliteConstraintPrimaryKey
+	}
+
+	return false
+}
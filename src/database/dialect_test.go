@@ -0,0 +1,82 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestIsDuplicateKey(t *testing.T) {
+	t.Run("It should detect the duplicate keys of every driver", func(t *testing.T) {
+		assert.True(t, IsDuplicateKey(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}))
+		assert.True(t, IsDuplicateKey(fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"})))
+		assert.True(t, IsDuplicateKey(gorm.ErrDuplicatedKey))
+	})
+
+	t.Run("It should detect the duplicate keys of SQLite", func(t *testing.T) {
+		conn, err := Open(config.DatabaseConfig{Driver: config.SQLiteDriver, Name: config.SQLiteMemory})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		_, err = conn.Exec("CREATE TABLE teams (id TEXT PRIMARY KEY)")
+		assert.NoError(t, err)
+		_, err = conn.Exec("CREATE TABLE users (id TEXT PRIMARY KEY, email TEXT UNIQUE, team TEXT REFERENCES teams(id))")
+		assert.NoError(t, err)
+		_, err = conn.Exec("INSERT INTO users (id, email) VALUES ('1', 'a@mail.com')")
+		assert.NoError(t, err)
+
+		_, err = conn.Exec("INSERT INTO users (id, email) VALUES ('1', 'b@mail.com')")
+		assert.True(t, IsDuplicateKey(err))
+
+		_, err = conn.Exec("INSERT INTO users (id, email) VALUES ('2', 'a@mail.com')")
+		assert.True(t, IsDuplicateKey(err))
+
+		_, err = conn.Exec("INSERT INTO users (id, email, team) VALUES ('3', 'c@mail.com', 'missing')")
+		assert.Error(t, err, "the foreign keys must be enforced")
+		assert.False(t, IsDuplicateKey(err))
+	})
+
+	t.Run("It should ignore the other errors", func(t *testing.T) {
+		assert.False(t, IsDuplicateKey(nil))
+		assert.False(t, IsDuplicateKey(errors.New("connection refused")))
+		assert.False(t, IsDuplicateKey(&mysql.MySQLError{Number: 1452}))
+		assert.False(t, IsDuplicateKey(&pgconn.PgError{Code: "23503"}))
+	})
+}
+
+func TestDialect(t *testing.T) {
+	t.Run("It should rebind the placeholders of PostgreSQL only", func(t *testing.T) {
+		query := "DELETE FROM schema_migrations WHERE version = ? AND name = ?"
+
+		assert.Equal(t, query, MySQL.Rebind(query))
+		assert.Equal(t, "DELETE FROM schema_migrations WHERE version = $1 AND name = $2", PostgreSQL.Rebind(query))
+	})
+
+	t.Run("It should build the upsert clauses of each dialect", func(t *testing.T) {
+		assert.Equal(t, "ON DUPLICATE KEY UPDATE secret = VALUES(secret)", MySQL.OnConflict("user_id")+" secret = "+MySQL.Excluded("secret"))
+		assert.Equal(t, "ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret", PostgreSQL.OnConflict("user_id")+" secret = "+PostgreSQL.Excluded("secret"))
+		assert.Equal(t, "ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret", SQLite.OnConflict("user_id")+" secret = "+SQLite.Excluded("secret"))
+	})
+
+	t.Run("It should use the functions and locks SQLite has", func(t *testing.T) {
+		assert.Equal(t, "GREATEST(a, b)", MySQL.Greatest("a", "b"))
+		assert.Equal(t, "MAX(a, b)", SQLite.Greatest("a", "b"))
+		assert.Equal(t, "FOR UPDATE", PostgreSQL.ForUpdate())
+		assert.Empty(t, SQLite.ForUpdate())
+		assert.Equal(t, "SELECT ?", SQLite.Rebind("SELECT ?"))
+	})
+
+	t.Run("It should default to MySQL for connections that aren't gorm ones", func(t *testing.T) {
+		assert.Equal(t, MySQL, DialectOf(nil))
+		assert.Equal(t, 3306, MySQL.DefaultPort())
+		assert.Equal(t, 5432, PostgreSQL.DefaultPort())
+	})
+}
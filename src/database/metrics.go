@@ -0,0 +1,95 @@
+package database
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PrometheusContentType is the content type of the metrics written by WritePrometheus, the text format of Prometheus.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// poolMetric is a metric of the connection pools, with its value in the statistics of a pool.
+type poolMetric struct {
+	name  string
+	kind  string
+	help  string
+	value func(stats PoolStats) float64
+}
+
+// poolMetrics are the metrics written for the primary and every read replica.
+var poolMetrics = []poolMetric{
+	{"db_up", "gauge", "Whether the last ping of the database succeeded.", func(stats PoolStats) float64 {
+		if stats.Healthy {
+			return 1
+		}
+		return 0
+	}},
+	{"db_last_check_timestamp_seconds", "gauge", "Unix time of the last ping of the database.", func(stats PoolStats) float64 {
+		if stats.LastCheck.IsZero() {
+			return 0
+		}
+		return float64(stats.LastCheck.UnixMilli()) / 1000
+	}},
+	{"db_reconnections_total", "counter", "Times the database answered again after a failed ping.", func(stats PoolStats) float64 {
+		return float64(stats.Reconnections)
+	}},
+	{"db_max_open_connections", "gauge", "Maximum number of open connections of the pool.", func(stats PoolStats) float64 {
+		return float64(stats.MaxOpenConnections)
+	}},
+	{"db_open_connections", "gauge", "Open connections of the pool, in use and idle.", func(stats PoolStats) float64 {
+		return float64(stats.OpenConnections)
+	}},
+	{"db_in_use_connections", "gauge", "Connections of the pool running a query.", func(stats PoolStats) float64 {
+		return float64(stats.InUse)
+	}},
+	{"db_idle_connections", "gauge", "Idle connections of the pool.", func(stats PoolStats) float64 {
+		return float64(stats.Idle)
+	}},
+	{"db_wait_count_total", "counter", "Times a query waited for a connection of the pool.", func(stats PoolStats) float64 {
+		return float64(stats.WaitCount)
+	}},
+	{"db_wait_duration_seconds_total", "counter", "Time the queries waited for a connection of the pool.", func(stats PoolStats) float64 {
+		return float64(stats.WaitDurationMs) / 1000
+	}},
+	{"db_max_idle_closed_total", "counter", "Connections closed because the pool had too many idle ones.", func(stats PoolStats) float64 {
+		return float64(stats.MaxIdleClosed)
+	}},
+	{"db_max_idle_time_closed_total", "counter", "Connections closed after being idle for too long.", func(stats PoolStats) float64 {
+		return float64(stats.MaxIdleTimeClosed)
+	}},
+	{"db_max_lifetime_closed_total", "counter", "Connections closed after reaching their maximum lifetime.", func(stats PoolStats) float64 {
+		return float64(stats.MaxLifetimeClosed)
+	}},
+}
+
+// labelEscaper escapes the label values of the text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes the statistics of the primary and of its read replicas in the text format of Prometheus, each
+// metric with the label database: primary or the address of the replica. The names have the prefix namespace_.
+// It returns an error if w fails.
+func (stats PoolStats) WritePrometheus(w io.Writer, namespace string) error {
+	buf := bufio.NewWriter(w)
+
+	pools := append([]PoolStats{stats}, stats.Replicas...)
+
+	for _, metric := range poolMetrics {
+		name := namespace + "_" + metric.name
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, metric.help, name, metric.kind)
+
+		for i, pool := range pools {
+			label := "primary"
+			if i > 0 {
+				label = pool.Name
+			}
+
+			value := strconv.FormatFloat(metric.value(pool), 'f', -1, 64)
+			fmt.Fprintf(buf, "%s{database=\"%s\"} %s\n", name, labelEscaper.Replace(label), value)
+		}
+	}
+
+	return buf.Flush()
+}
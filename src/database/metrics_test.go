@@ -0,0 +1,43 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	t.Run("It should write every metric of the primary and of the replicas", func(t *testing.T) {
+		stats := PoolStats{
+			Healthy:            true,
+			LastCheck:          time.UnixMilli(1700000000500),
+			Reconnections:      2,
+			MaxOpenConnections: 100,
+			OpenConnections:    12,
+			InUse:              3,
+			Idle:               9,
+			WaitCount:          4,
+			WaitDurationMs:     1500,
+			Replicas: []PoolStats{
+				{Name: `replica-"1":3306`, OpenConnections: 5},
+			},
+		}
+
+		var out strings.Builder
+		assert.NoError(t, stats.WritePrometheus(&out, "userservice"))
+
+		lines := strings.Split(out.String(), "\n")
+		assert.Contains(t, lines, "# TYPE userservice_db_up gauge")
+		assert.Contains(t, lines, `userservice_db_up{database="primary"} 1`)
+		assert.Contains(t, lines, `userservice_db_up{database="replica-\"1\":3306"} 0`, "the label values should be escaped")
+		assert.Contains(t, lines, `userservice_db_last_check_timestamp_seconds{database="primary"} 1700000000.5`)
+		assert.Contains(t, lines, "# TYPE userservice_db_reconnections_total counter")
+		assert.Contains(t, lines, `userservice_db_reconnections_total{database="primary"} 2`)
+		assert.Contains(t, lines, `userservice_db_open_connections{database="primary"} 12`)
+		assert.Contains(t, lines, `userservice_db_open_connections{database="replica-\"1\":3306"} 5`)
+		assert.Contains(t, lines, `userservice_db_in_use_connections{database="primary"} 3`)
+		assert.Contains(t, lines, `userservice_db_wait_duration_seconds_total{database="primary"} 1.5`)
+	})
+}
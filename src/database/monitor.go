@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+)
+
+// PoolStats are the statistics of the connection pool opened by ConnectDB, exposed for the metrics, see
+// WritePrometheus.
+type PoolStats struct {
+	// Name is the host and port of a read replica, empty for the primary.
+	Name string `json:"name,omitempty"`
+	// Healthy is true if the last ping of the database succeeded.
+	Healthy bool `json:"healthy"`
+	// LastError is the error of the last ping, empty if it succeeded.
+	LastError string    `json:"lastError,omitempty"`
+	LastCheck time.Time `json:"lastCheck"`
+	// Reconnections is how many times the database answered again after a failed ping.
+	Reconnections int64 `json:"reconnections"`
+
+	MaxOpenConnections int `json:"maxOpenConnections"`
+	OpenConnections    int `json:"openConnections"`
+	InUse              int `json:"inUse"`
+	Idle               int `json:"idle"`
+	// WaitCount is how many times a query waited for a connection, for WaitDurationMs milliseconds in total.
+	WaitCount         int64 `json:"waitCount"`
+	WaitDurationMs    int64 `json:"waitDurationMs"`
+	MaxIdleClosed     int64 `json:"maxIdleClosed"`
+	MaxIdleTimeClosed int64 `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed int64 `json:"maxLifetimeClosed"`
+
+	// Replicas are the statistics of the read replicas, a replica isn't used while it isn't healthy.
+	Replicas []PoolStats `json:"replicas,omitempty"`
+}
+
+// health is the result of the last pings of a connection pool.
+type health struct {
+	// name is the database in the logs, e.g. the database or the replica db-replica:3306.
+	name          string
+	mu            sync.RWMutex
+	healthy       bool
+	lastError     string
+	lastCheck     time.Time
+	reconnections int64
+}
+
+// status is the health of the connection pool opened by ConnectDB.
+var status = &health{name: "the database"}
+
+// record stores the result of a ping, logging when the database stops or starts answering again.
+func (h *health) record(err error, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastCheck = now
+
+	if err != nil {
+		if h.healthy {
+			log.Errorf("Lost the connection to %s: %v", h.name, err)
+		}
+
+		h.healthy = false
+		h.lastError = err.Error()
+		return
+	}
+
+	if h.lastError != "" {
+		log.Infof("Reconnected to %s", h.name)
+		h.reconnections++
+	}
+
+	h.healthy = true
+	h.lastError = ""
+}
+
+// ping checks that the database answers within timeout, without a limit if it's 0.
+func ping(conn *sql.DB, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return conn.PingContext(ctx)
+}
+
+// Connect pings the database until it answers, at most settings.ConnectAttempts times, waiting the backoff of the
+// settings between the pings. sql.Open doesn't connect to the database, so this is what waits for it, e.g. when the
+// service starts before the database in a container.
+// It returns the error of the last ping if the database never answers.
+func Connect(conn *sql.DB, settings config.DatabaseConfig) error {
+	backoff := Backoff{Initial: settings.RetryBackoff.Std(), Max: settings.MaxRetryBackoff.Std()}
+	attempts := max(settings.ConnectAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		err := ping(conn, settings.ConnectTimeout.Std())
+		if err == nil {
+			return nil
+		}
+
+		if attempt == attempts {
+			return fmt.Errorf("the database didn't answer %d pings: %w", attempts, err)
+		}
+
+		wait := backoff.Next()
+		log.Infof("Failed to connect to database, trying again in %s. Try number: %d. Err: %v", wait, attempt, err)
+		time.Sleep(wait)
+	}
+}
+
+// Monitor pings the database of ConnectDB, and each of its read replicas, every settings.HealthCheckInterval until stop
+// is closed, so a lost database is logged and reported by Stats before the requests fail, and a lost replica is
+// ejected until it answers again. While a database doesn't answer, it's pinged with the backoff of the settings, at
+// most every interval. database/sql opens new connections by itself once it's back.
+// It's meant to be run in its own goroutine, it returns at once if the interval is 0.
+func Monitor(stop <-chan struct{}, settings config.DatabaseConfig) {
+	for _, replica := range replicas {
+		go monitor(replica.conn, replica.health, stop, settings)
+	}
+
+	monitor(db, status, stop, settings)
+}
+
+func monitor(conn *sql.DB, h *health, stop <-chan struct{}, settings config.DatabaseConfig) {
+	interval := settings.HealthCheckInterval.Std()
+	if interval <= 0 {
+		return
+	}
+
+	backoff := Backoff{Initial: settings.RetryBackoff.Std(), Max: settings.MaxRetryBackoff.Std()}
+	wait := interval
+
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := ping(conn, settings.ConnectTimeout.Std())
+		h.record(err, time.Now())
+
+		if err == nil {
+			backoff.Reset()
+			wait = interval
+			continue
+		}
+
+		wait = min(backoff.Next(), interval)
+		if wait <= 0 {
+			wait = interval
+		}
+	}
+}
+
+// Ping checks that the database of ConnectDB answers now, within the deadline of ctx, e.g. for the readiness probe.
+// Unlike the health reported by Stats, it doesn't wait for the next ping of Monitor.
+func Ping(ctx context.Context) error {
+	if db == nil {
+		return errors.New("the database isn't connected")
+	}
+
+	return db.PingContext(ctx)
+}
+
+func (h *health) isHealthy() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.healthy
+}
+
+// Stats returns the statistics of the connection pool opened by ConnectDB and of its read replicas, empty if it isn't
+// open.
+func Stats() PoolStats {
+	if db == nil {
+		return PoolStats{}
+	}
+
+	stats := poolStats(db, status)
+
+	for _, replica := range replicas {
+		replicaStats := poolStats(replica.conn, replica.health)
+		replicaStats.Name = replica.address
+		stats.Replicas = append(stats.Replicas, replicaStats)
+	}
+
+	return stats
+}
+
+func poolStats(conn *sql.DB, h *health) PoolStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := conn.Stats()
+
+	return PoolStats{
+		Healthy:            h.healthy,
+		LastError:          h.lastError,
+		LastCheck:          h.lastCheck,
+		Reconnections:      h.reconnections,
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnect(t *testing.T) {
+	t.Run("It should connect to a database that answers", func(t *testing.T) {
+		conn, err := Open(config.DatabaseConfig{Driver: config.SQLiteDriver, Name: config.SQLiteMemory})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		assert.NoError(t, Connect(conn, config.DatabaseConfig{ConnectAttempts: 1}))
+	})
+
+	t.Run("It should give up after the configured attempts", func(t *testing.T) {
+		// Nothing listens on port 1, so every ping is refused at once.
+		settings := config.DatabaseConfig{
+			Driver:          config.MySQLDriver,
+			Host:            "127.0.0.1",
+			Port:            1,
+			User:            "root",
+			Name:            "test",
+			ConnectAttempts: 3,
+			ConnectTimeout:  config.Duration(time.Second),
+			RetryBackoff:    config.Duration(time.Millisecond),
+			MaxRetryBackoff: config.Duration(2 * time.Millisecond),
+		}
+		conn, err := Open(settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		err = Connect(conn, settings)
+
+		assert.ErrorContains(t, err, "3 pings")
+	})
+}
+
+func TestMonitor(t *testing.T) {
+	t.Run("It should count the reconnections after a failed ping", func(t *testing.T) {
+		h := &health{}
+		now := time.Now()
+
+		h.record(nil, now)
+		assert.True(t, h.healthy)
+
+		h.record(errors.New("connection refused"), now)
+		assert.False(t, h.healthy)
+		assert.Equal(t, "connection refused", h.lastError)
+		assert.Zero(t, h.reconnections)
+
+		h.record(nil, now)
+		assert.True(t, h.healthy)
+		assert.Empty(t, h.lastError)
+		assert.Equal(t, int64(1), h.reconnections)
+	})
+
+	t.Run("It should notice a lost database and stop when asked", func(t *testing.T) {
+		conn, err := Open(config.DatabaseConfig{Driver: config.SQLiteDriver, Name: config.SQLiteMemory})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h := &health{}
+		h.record(nil, time.Now())
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			monitor(conn, h, stop, config.DatabaseConfig{HealthCheckInterval: config.Duration(time.Millisecond)})
+			close(done)
+		}()
+
+		conn.Close()
+
+		assert.Eventually(t, func() bool { return !poolStats(conn, h).Healthy }, time.Second, time.Millisecond)
+		assert.Equal(t, "sql: database is closed", poolStats(conn, h).LastError)
+
+		close(stop)
+		assert.Eventually(t, func() bool {
+			select {
+			case <-done:
+				return true
+			default:
+				return false
+			}
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("It should not monitor without an interval", func(t *testing.T) {
+		monitor(nil, &health{}, make(chan struct{}), config.DatabaseConfig{})
+	})
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"gorm.io/gorm"
+)
+
+// replica is a read replica of the database opened by ConnectDB.
+type replica struct {
+	address string
+	conn    *sql.DB
+	gorm    *gorm.DB
+	health  *health
+}
+
+// replicas are the read replicas opened by ConnectDB.
+var replicas []*replica
+
+// connectReplicas opens the read replicas of the settings. A replica that doesn't answer isn't waited for, it's
+// ejected and the reads go to the others until Monitor sees it answering.
+// It returns an error if a replica can't be opened, e.g. its settings are invalid.
+func connectReplicas(settings config.DatabaseConfig) error {
+	configs, err := settings.ReplicaConfigs()
+	if err != nil {
+		return err
+	}
+
+	for _, replicaSettings := range configs {
+		conn, err := Open(replicaSettings)
+		if err != nil {
+			return err
+		}
+
+		gormDB, err := NewGorm(conn, dialect)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+
+		r := &replica{
+			address: address(replicaSettings),
+			conn:    conn,
+			gorm:    gormDB,
+			health:  &health{name: "the replica " + address(replicaSettings)},
+		}
+
+		err = ping(conn, settings.ConnectTimeout.Std())
+		r.health.record(err, time.Now())
+
+		if err != nil {
+			log.Errorf("The replica %s didn't answer, it's ejected until it does: %v", r.address, err)
+		} else {
+			log.Infof("Connected to the replica %s", r.address)
+		}
+
+		replicas = append(replicas, r)
+	}
+
+	return nil
+}
+
+// Router is a database whose Raw queries, the reads, go to the healthy read replicas in turn, or to the primary if
+// none is healthy. The Exec statements and the transactions always go to the primary.
+// The replicas lag behind the primary, so it's only meant for the reads that can miss the latest writes, or bound to
+// a request with WithContext, which reads from the primary once the request wrote.
+type Router struct {
+	primary  *gorm.DB
+	replicas []*replica
+	// next is the number of reads routed, to spread them over the replicas.
+	next atomic.Uint64
+}
+
+func (r *Router) Exec(sql string, args ...interface{}) *gorm.DB {
+	return r.primary.Exec(sql, args...)
+}
+
+func (r *Router) Raw(sql string, args ...interface{}) *gorm.DB {
+	return r.reader().Raw(sql, args...)
+}
+
+func (r *Router) Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error {
+	return r.primary.Transaction(fc, opts...)
+}
+
+// WithContext returns the router bound to the request of ctx, see TrackWrites.
+func (r *Router) WithContext(ctx context.Context) *RequestRouter {
+	return &RequestRouter{router: r, ctx: ctx}
+}
+
+// reader returns the next healthy replica, the primary if none is.
+func (r *Router) reader() *gorm.DB {
+	count := uint64(len(r.replicas))
+	start := r.next.Add(1)
+
+	for i := range count {
+		replica := r.replicas[(start+i)%count]
+		if replica.health.isHealthy() {
+			return replica.gorm
+		}
+	}
+
+	return r.primary
+}
+
+// writesKey is the key of the context values of TrackWrites.
+type writesKey struct{}
+
+// TrackWrites returns a copy of ctx that remembers if its request wrote to the database, so the reads of the
+// RequestRouters bound to it go to the primary afterwards and see the writes. It's set up for every request by a
+// middleware.
+func TrackWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writesKey{}, new(atomic.Bool))
+}
+
+// MarkWritten records that the request of ctx wrote to the database, it does nothing if ctx doesn't track its writes.
+func MarkWritten(ctx context.Context) {
+	if written, ok := ctx.Value(writesKey{}).(*atomic.Bool); ok {
+		written.Store(true)
+	}
+}
+
+// HasWritten returns whether the request of ctx wrote to the database.
+func HasWritten(ctx context.Context) bool {
+	written, ok := ctx.Value(writesKey{}).(*atomic.Bool)
+	return ok && written.Load()
+}
+
+// RequestRouter is a Router bound to a request: its reads go to the replicas until the request writes, then to the
+// primary, so the request reads its own writes. The writes through it are recorded with MarkWritten.
+type RequestRouter struct {
+	router *Router
+	ctx    context.Context
+}
+
+func (r *RequestRouter) Exec(sql string, args ...interface{}) *gorm.DB {
+	MarkWritten(r.ctx)
+	return r.router.Exec(sql, args...)
+}
+
+func (r *RequestRouter) Raw(sql string, args ...interface{}) *gorm.DB {
+	if HasWritten(r.ctx) {
+		return r.router.primary.Raw(sql, args...)
+	}
+
+	return r.router.Raw(sql, args...)
+}
+
+func (r *RequestRouter) Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error {
+	MarkWritten(r.ctx)
+	return r.router.Transaction(fc, opts...)
+}
+
+// GetReplicaPool returns the database opened by ConnectDB with its reads routed to the read replicas, to the primary
+// only if there are none.
+func GetReplicaPool() (*Router, error) {
+	primary, err := GetPoolConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Router{primary: primary, replicas: replicas}, nil
+}
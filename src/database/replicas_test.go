@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newNamedDB returns an in-memory SQLite database whose name table has its name, to tell where a query went.
+func newNamedDB(t *testing.T, name string) *gorm.DB {
+	conn, err := Open(config.DatabaseConfig{Driver: config.SQLiteDriver, Name: config.SQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	gormDB, err := NewGorm(conn, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gormDB.Exec("CREATE TABLE name (value TEXT)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gormDB.Exec("INSERT INTO name (value) VALUES (?)", name).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return gormDB
+}
+
+func newReplica(t *testing.T, name string) *replica {
+	r := &replica{address: name, gorm: newNamedDB(t, name), health: &health{name: name}}
+	r.health.record(nil, time.Now())
+	return r
+}
+
+func readName(t *testing.T, router interface {
+	Raw(sql string, args ...interface{}) *gorm.DB
+}) string {
+	var name string
+	if err := router.Raw("SELECT value FROM name").Scan(&name).Error; err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestRouter(t *testing.T) {
+	t.Run("It should spread the reads over the replicas", func(t *testing.T) {
+		router := &Router{primary: newNamedDB(t, "primary"), replicas: []*replica{newReplica(t, "a"), newReplica(t, "b")}}
+
+		names := []string{readName(t, router), readName(t, router), readName(t, router), readName(t, router)}
+
+		assert.ElementsMatch(t, []string{"a", "a", "b", "b"}, names)
+	})
+
+	t.Run("It should send the writes and the transactions to the primary", func(t *testing.T) {
+		primary := newNamedDB(t, "primary")
+		router := &Router{primary: primary, replicas: []*replica{newReplica(t, "replica")}}
+
+		assert.NoError(t, router.Exec("UPDATE name SET value = 'written'").Error)
+		assert.NoError(t, router.Transaction(func(tx *gorm.DB) error {
+			return tx.Exec("INSERT INTO name (value) VALUES ('transaction')").Error
+		}))
+
+		var names []string
+		assert.NoError(t, primary.Raw("SELECT value FROM name ORDER BY value").Scan(&names).Error)
+		assert.Equal(t, []string{"transaction", "written"}, names)
+		assert.Equal(t, "replica", readName(t, router))
+	})
+
+	t.Run("It should eject the replicas that don't answer and read from the primary if none does", func(t *testing.T) {
+		a, b := newReplica(t, "a"), newReplica(t, "b")
+		router := &Router{primary: newNamedDB(t, "primary"), replicas: []*replica{a, b}}
+
+		a.health.record(assert.AnError, time.Now())
+		assert.Equal(t, "b", readName(t, router))
+		assert.Equal(t, "b", readName(t, router))
+
+		b.health.record(assert.AnError, time.Now())
+		assert.Equal(t, "primary", readName(t, router))
+
+		a.health.record(nil, time.Now())
+		assert.Equal(t, "a", readName(t, router), "a replica answering again is readmitted")
+	})
+
+	t.Run("It should read from the primary without replicas", func(t *testing.T) {
+		router := &Router{primary: newNamedDB(t, "primary")}
+
+		assert.Equal(t, "primary", readName(t, router))
+	})
+
+	t.Run("It should read from the primary once the request wrote", func(t *testing.T) {
+		primary := newNamedDB(t, "primary")
+		router := &Router{primary: primary, replicas: []*replica{newReplica(t, "replica")}}
+		ctx := TrackWrites(context.Background())
+		other := TrackWrites(context.Background())
+
+		assert.Equal(t, "replica", readName(t, router.WithContext(ctx)))
+
+		assert.NoError(t, router.WithContext(ctx).Exec("UPDATE name SET value = 'written'").Error)
+
+		assert.True(t, HasWritten(ctx))
+		assert.Equal(t, "written", readName(t, router.WithContext(ctx)), "the request reads its own writes")
+		assert.Equal(t, "replica", readName(t, router.WithContext(other)), "the other requests still read from the replicas")
+		assert.Equal(t, "replica", readName(t, router))
+	})
+
+	t.Run("It should pin a request to the primary when it's marked as written", func(t *testing.T) {
+		router := &Router{primary: newNamedDB(t, "primary"), replicas: []*replica{newReplica(t, "replica")}}
+		ctx := TrackWrites(context.Background())
+
+		MarkWritten(ctx)
+		MarkWritten(context.Background())
+
+		assert.Equal(t, "primary", readName(t, router.WithContext(ctx)))
+		assert.Equal(t, "replica", readName(t, router.WithContext(context.Background())), "a context without tracking never is")
+	})
+}
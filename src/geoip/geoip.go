@@ -0,0 +1,203 @@
+// Package geoip resolves IP addresses to coarse locations with a local database file, so it works offline.
+package geoip
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("log")
+
+// Location is the coarse location of an IP address, every field is empty if it's unknown.
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country.
+	Country string `json:"country"`
+	Region  string `json:"region"`
+	City    string `json:"city"`
+	// Located is true if Latitude and Longitude are known, which only city databases provide.
+	Located   bool    `json:"-"`
+	Latitude  float64 `json:"-"`
+	Longitude float64 `json:"-"`
+}
+
+// String returns the location as "City, Region, Country", skipping the unknown parts.
+func (l Location) String() string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{l.City, l.Region, l.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// IResolver is an interface that contains the methods that will implement an IP location lookup.
+type IResolver interface {
+	// Lookup returns the location of an IP address.
+	// ip is the IPv4 or IPv6 address in text form.
+	// It returns an empty location if the address is invalid or unknown.
+	Lookup(ip string) Location
+}
+
+// ipRange is a range of addresses, both ends included, located in the same place.
+type ipRange struct {
+	start    netip.Addr
+	end      netip.Addr
+	location Location
+}
+
+// Database is an in-memory copy of a GeoIP database of address ranges.
+type Database struct {
+	ranges []ipRange
+}
+
+// Open loads a database file, gzip compressed if its name ends with .gz.
+// See Parse for the format of the file.
+func Open(path string) (*Database, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	}
+
+	return Parse(reader)
+}
+
+// Parse reads a database in the CSV formats of the DB-IP lite databases, without a header:
+// "start,end,country" (country lite) or "start,end,continent,country,region,city[,latitude,longitude]" (city lite).
+// Ranges may be IPv4 or IPv6 and must not overlap.
+// It returns an error if a line is malformed.
+func Parse(reader io.Reader) (*Database, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	db := &Database{}
+
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var location Location
+		switch {
+		case len(record) == 3:
+			location = Location{Country: record[2]}
+		case len(record) >= 6:
+			location = Location{Country: record[3], Region: record[4], City: record[5]}
+
+			if len(record) >= 8 {
+				location.Latitude, err = strconv.ParseFloat(record[6], 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid latitude: %w", line, err)
+				}
+
+				location.Longitude, err = strconv.ParseFloat(record[7], 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid longitude: %w", line, err)
+				}
+
+				location.Located = true
+			}
+		default:
+			return nil, fmt.Errorf("line %d: expected 3 or at least 6 fields, got %d", line, len(record))
+		}
+
+		start, err := netip.ParseAddr(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		end, err := netip.ParseAddr(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("line %d: invalid range %s - %s", line, start, end)
+		}
+
+		db.ranges = append(db.ranges, ipRange{start: start, end: end, location: location})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return db.ranges[i].start.Less(db.ranges[j].start)
+	})
+
+	return db, nil
+}
+
+// Len returns the number of ranges of the database.
+func (db *Database) Len() int {
+	return len(db.ranges)
+}
+
+func (db *Database) Lookup(ip string) Location {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Location{}
+	}
+
+	// IPv4 clients of a dual stack server have IPv4-mapped IPv6 addresses.
+	addr = addr.Unmap().WithZone("")
+
+	// The first range that starts after the address, the candidate is the one before it.
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return addr.Less(db.ranges[i].start)
+	})
+
+	if i == 0 {
+		return Location{}
+	}
+
+	candidate := db.ranges[i-1]
+	if candidate.end.Less(addr) {
+		return Location{}
+	}
+
+	return candidate.location
+}
+
+// New loads the resolver of the settings, every location is unknown if the database isn't set or can't be loaded.
+func New(settings config.GeoIPConfig) IResolver {
+	if settings.Database == "" {
+		log.Warning("No GeoIP database is configured, login locations will be unknown")
+		return &Database{}
+	}
+
+	db, err := Open(settings.Database)
+	if err != nil {
+		log.Errorf("Failed to load the GeoIP database %s, login locations will be unknown: %v", settings.Database, err)
+		return &Database{}
+	}
+
+	log.Infof("Loaded %d ranges from the GeoIP database %s", db.Len(), settings.Database)
+	return db
+}
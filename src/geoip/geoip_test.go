@@ -0,0 +1,82 @@
+package geoip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const cityDatabase = `1.0.0.0,1.0.0.255,OC,AU,Queensland,South Brisbane,-27.4767,153.017
+8.8.8.0,8.8.8.255,NA,US,California,Mountain View,37.4223,-122.085
+2001:4860::,2001:4860:ffff:ffff:ffff:ffff:ffff:ffff,NA,US,California,Mountain View,37.4223,-122.085
+`
+
+func TestLookup(t *testing.T) {
+	db, err := Parse(strings.NewReader(cityDatabase))
+	assert.Nil(t, err)
+
+	t.Run("It should resolve the addresses inside a range, including both ends", func(t *testing.T) {
+		expected := Location{Country: "US", Region: "California", City: "Mountain View", Located: true, Latitude: 37.4223, Longitude: -122.085}
+
+		assert.Equal(t, expected, db.Lookup("8.8.8.0"))
+		assert.Equal(t, expected, db.Lookup("8.8.8.8"))
+		assert.Equal(t, expected, db.Lookup("8.8.8.255"))
+		assert.Equal(t, expected, db.Lookup("2001:4860:4860::8888"))
+	})
+
+	t.Run("It should resolve IPv4-mapped IPv6 addresses as IPv4", func(t *testing.T) {
+		assert.Equal(t, "AU", db.Lookup("::ffff:1.0.0.1").Country)
+	})
+
+	t.Run("It should return an empty location for unknown and invalid addresses", func(t *testing.T) {
+		assert.Equal(t, Location{}, db.Lookup("8.8.9.0"))
+		assert.Equal(t, Location{}, db.Lookup("0.0.0.1"))
+		assert.Equal(t, Location{}, db.Lookup("127.0.0.1"))
+		assert.Equal(t, Location{}, db.Lookup("not an ip"))
+	})
+
+	t.Run("It should format the known parts of a location", func(t *testing.T) {
+		assert.Equal(t, "Mountain View, California, US", db.Lookup("8.8.8.8").String())
+		assert.Equal(t, "AR", Location{Country: "AR"}.String())
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("It should parse the country databases", func(t *testing.T) {
+		db, err := Parse(strings.NewReader("1.0.0.0,1.0.0.255,AU\n"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, Location{Country: "AU"}, db.Lookup("1.0.0.1"))
+		assert.False(t, db.Lookup("1.0.0.1").Located)
+	})
+
+	t.Run("It should reject malformed lines", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("1.0.0.0,1.0.0.255\n"))
+		assert.NotNil(t, err)
+
+		_, err = Parse(strings.NewReader("1.0.0.255,1.0.0.0,AU\n"))
+		assert.NotNil(t, err)
+
+		_, err = Parse(strings.NewReader("1.0.0.0,2001:4860::,AU\n"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("It should open gzip compressed databases", func(t *testing.T) {
+		var data bytes.Buffer
+		writer := gzip.NewWriter(&data)
+		writer.Write([]byte(cityDatabase))
+		writer.Close()
+
+		path := filepath.Join(t.TempDir(), "dbip-city-lite.csv.gz")
+		assert.Nil(t, os.WriteFile(path, data.Bytes(), 0o644))
+
+		db, err := Open(path)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, db.Len())
+	})
+}
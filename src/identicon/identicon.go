@@ -0,0 +1,157 @@
+// Package identicon generates deterministic avatars for the users that didn't upload a picture.
+package identicon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+)
+
+// grid is the number of cells per side. Only the first (grid+1)/2 columns are random, the rest mirror them.
+const grid = 5
+
+// margin is the empty space around the grid, measured in cells.
+const margin = 0.5
+
+// Background is the color of the cells that aren't filled.
+var Background = color.RGBA{240, 240, 240, 255}
+
+// Identicon is a symmetric grid of filled cells with a color, both derived from a seed.
+type Identicon struct {
+	cells [grid][grid]bool
+	Color color.RGBA
+}
+
+// New creates the identicon of a seed, the same seed always produces the same identicon.
+func New(seed string) Identicon {
+	hash := sha256.Sum256([]byte(seed))
+	identicon := Identicon{Color: hslToRGB(float64(hash[0])/255*360, 0.55, 0.55)}
+
+	nibble := 0
+	for x := 0; x < (grid+1)/2; x++ {
+		for y := 0; y < grid; y++ {
+			b := hash[1+nibble/2]
+			if nibble%2 == 1 {
+				b >>= 4
+			}
+			nibble++
+
+			filled := b&1 == 1
+			identicon.cells[y][x] = filled
+			identicon.cells[y][grid-1-x] = filled
+		}
+	}
+
+	return identicon
+}
+
+// Filled returns true if the cell at column x and row y is filled.
+func (identicon Identicon) Filled(x, y int) bool {
+	return identicon.cells[y][x]
+}
+
+// cellAt returns the cell that contains a point of a size x size canvas, ok is false for points in the margin.
+func cellAt(px, py float64, size int) (x, y int, ok bool) {
+	cell := float64(size) / (grid + 2*margin)
+	fx := px/cell - margin
+	fy := py/cell - margin
+
+	if fx < 0 || fy < 0 || fx >= grid || fy >= grid {
+		return 0, 0, false
+	}
+
+	return int(fx), int(fy), true
+}
+
+// Image renders the identicon as a size x size image.
+func (identicon Identicon) Image(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			c := Background
+
+			if x, y, ok := cellAt(float64(px)+0.5, float64(py)+0.5, size); ok && identicon.cells[y][x] {
+				c = identicon.Color
+			}
+
+			img.SetRGBA(px, py, c)
+		}
+	}
+
+	return img
+}
+
+// PNG encodes the identicon as a size x size PNG.
+func (identicon Identicon) PNG(size int) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, identicon.Image(size)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG renders the identicon as a size x size SVG document.
+func (identicon Identicon) SVG(size int) string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %g %g" shape-rendering="crispEdges">`,
+		size, size, grid+2*margin, grid+2*margin,
+	)
+	fmt.Fprintf(&builder, `<rect width="100%%" height="100%%" fill="%s"/>`, hex(Background))
+
+	for y := 0; y < grid; y++ {
+		for x := 0; x < grid; x++ {
+			if identicon.cells[y][x] {
+				fmt.Fprintf(&builder, `<rect x="%g" y="%g" width="1" height="1" fill="%s"/>`,
+					float64(x)+margin, float64(y)+margin, hex(identicon.Color))
+			}
+		}
+	}
+
+	builder.WriteString("</svg>")
+
+	return builder.String()
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// hslToRGB converts a color from HSL, hue in degrees and saturation and lightness between 0 and 1, to RGB.
+func hslToRGB(h, s, l float64) color.RGBA {
+	chroma := (1 - math.Abs(2*l-1)) * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - chroma/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = chroma, x, 0
+	case h < 120:
+		r, g, b = x, chroma, 0
+	case h < 180:
+		r, g, b = 0, chroma, x
+	case h < 240:
+		r, g, b = 0, x, chroma
+	case h < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 255,
+	}
+}
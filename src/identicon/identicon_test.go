@@ -0,0 +1,67 @@
+package identicon
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("The same seed always produces the same identicon", func(t *testing.T) {
+		assert.Equal(t, New("5e2ab5a6-5601-4b5c-b89c-9aa4054f90af"), New("5e2ab5a6-5601-4b5c-b89c-9aa4054f90af"))
+	})
+
+	t.Run("Different seeds produce different identicons", func(t *testing.T) {
+		assert.NotEqual(t, New("alice"), New("bob"))
+	})
+
+	t.Run("The identicon is horizontally symmetric", func(t *testing.T) {
+		identicon := New("nutri")
+
+		for y := 0; y < grid; y++ {
+			for x := 0; x < grid; x++ {
+				assert.Equal(t, identicon.Filled(x, y), identicon.Filled(grid-1-x, y))
+			}
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	t.Run("The PNG has the requested size and the margin uses the background color", func(t *testing.T) {
+		data, err := New("nutri").PNG(120)
+		assert.Nil(t, err)
+
+		img, err := png.Decode(bytes.NewReader(data))
+		assert.Nil(t, err)
+
+		assert.Equal(t, 120, img.Bounds().Dx())
+		assert.Equal(t, 120, img.Bounds().Dy())
+
+		r, g, b, _ := img.At(0, 0).RGBA()
+		br, bg, bb, _ := Background.RGBA()
+		assert.Equal(t, []uint32{br, bg, bb}, []uint32{r, g, b})
+	})
+
+	t.Run("The PNG and the SVG draw the same cells", func(t *testing.T) {
+		identicon := New("nutri")
+		img := identicon.Image(60)
+		svg := identicon.SVG(60)
+
+		filled := 0
+		for y := 0; y < grid; y++ {
+			for x := 0; x < grid; x++ {
+				if identicon.Filled(x, y) {
+					filled++
+					// Cells are 10 pixels wide and the margin is 5 pixels, so this is the center of the cell.
+					assert.Equal(t, identicon.Color, img.RGBAAt(5+x*10+5, 5+y*10+5))
+				}
+			}
+		}
+
+		assert.Equal(t, filled, strings.Count(svg, `width="1"`))
+		assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="60" height="60"`))
+	})
+}
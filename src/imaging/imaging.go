@@ -0,0 +1,107 @@
+// Package imaging decodes user uploaded images and produces normalized square thumbnails.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxPixels is the maximum number of pixels of an image that will be decoded.
+// It protects the service against small files that decompress into huge images.
+const MaxPixels = 4096 * 4096
+
+// JPEGQuality is the quality used to encode the thumbnails.
+const JPEGQuality = 85
+
+var ErrTooManyPixels = errors.New("the image has too many pixels")
+
+// Decode decodes a JPEG, PNG or GIF image and applies the EXIF orientation of JPEG images.
+// Transparent pixels are composed over a white background, since thumbnails are encoded as JPEG.
+// The returned image doesn't keep any metadata of the original file.
+// It returns ErrTooManyPixels if the image is larger than MaxPixels.
+func Decode(data []byte) (*image.RGBA, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Over)
+
+	if format == "jpeg" {
+		return orient(rgba, jpegOrientation(data)), nil
+	}
+
+	return rgba, nil
+}
+
+// Thumbnail crops the center square of src and scales it to size x size pixels.
+// Every destination pixel is the average of the source pixels it covers, which gives smooth downscaling.
+func Thumbnail(src *image.RGBA, size int) *image.RGBA {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	offsetX := bounds.Min.X + (bounds.Dx()-side)/2
+	offsetY := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for y := 0; y < size; y++ {
+		y0 := y * side / size
+		y1 := max((y+1)*side/size, y0+1)
+
+		for x := 0; x < size; x++ {
+			x0 := x * side / size
+			x1 := max((x+1)*side/size, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(offsetX+x0, offsetY+sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(src.Pix[i])
+					g += uint64(src.Pix[i+1])
+					b += uint64(src.Pix[i+2])
+					a += uint64(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// EncodeJPEG encodes an image as a JPEG without any metadata.
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
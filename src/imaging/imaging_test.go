@@ -0,0 +1,127 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withOrientation inserts an EXIF APP1 segment with the given orientation after the SOI marker of a JPEG.
+func withOrientation(jpg []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:2], tagOrient)
+	binary.BigEndian.PutUint16(entry[2:4], 3)
+	binary.BigEndian.PutUint32(entry[4:8], 1)
+	binary.BigEndian.PutUint16(entry[8:10], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, markerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:4], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	ret := append([]byte{}, jpg[:2]...)
+	ret = append(ret, segment...)
+	return append(ret, jpg[2:]...)
+}
+
+func encodeJPEG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("It should rotate a JPEG according to its EXIF orientation", func(t *testing.T) {
+		data := withOrientation(encodeJPEG(t, 40, 20), 6)
+
+		assert.Equal(t, 6, jpegOrientation(data))
+
+		img, err := Decode(data)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 20, img.Bounds().Dx())
+		assert.Equal(t, 40, img.Bounds().Dy())
+	})
+
+	t.Run("It should keep the size of a JPEG without EXIF data", func(t *testing.T) {
+		data := encodeJPEG(t, 40, 20)
+
+		assert.Equal(t, 1, jpegOrientation(data))
+
+		img, err := Decode(data)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 40, img.Bounds().Dx())
+	})
+
+	t.Run("It should compose transparent pixels over white", func(t *testing.T) {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 2, 2)))
+
+		img, err := Decode(buf.Bytes())
+
+		assert.Nil(t, err)
+		assert.Equal(t, color.RGBA{255, 255, 255, 255}, img.RGBAAt(0, 0))
+	})
+
+	t.Run("It should fail to decode something that isn't an image", func(t *testing.T) {
+		_, err := Decode([]byte("not an image"))
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("It should refuse images with too many pixels", func(t *testing.T) {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4097, 4096)))
+
+		_, err := Decode(buf.Bytes())
+
+		assert.ErrorIs(t, err, ErrTooManyPixels)
+	})
+}
+
+func TestThumbnail(t *testing.T) {
+	t.Run("It should crop the center square and scale it", func(t *testing.T) {
+		src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+		for y := 0; y < 100; y++ {
+			for x := 100; x < 200; x++ {
+				src.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+			}
+		}
+
+		thumb := Thumbnail(src, 64)
+
+		assert.Equal(t, image.Rect(0, 0, 64, 64), thumb.Bounds())
+		assert.Equal(t, color.RGBA{255, 0, 0, 255}, thumb.RGBAAt(0, 0))
+		assert.Equal(t, color.RGBA{255, 0, 0, 255}, thumb.RGBAAt(63, 63))
+	})
+
+	t.Run("It should upscale images smaller than the thumbnail", func(t *testing.T) {
+		thumb := Thumbnail(image.NewRGBA(image.Rect(0, 0, 10, 10)), 64)
+
+		assert.Equal(t, image.Rect(0, 0, 64, 64), thumb.Bounds())
+	})
+}
+
+func TestEncodeJPEG(t *testing.T) {
+	t.Run("The encoded image doesn't keep the EXIF data", func(t *testing.T) {
+		img, err := Decode(withOrientation(encodeJPEG(t, 8, 8), 3))
+		assert.Nil(t, err)
+
+		data, err := EncodeJPEG(img)
+
+		assert.Nil(t, err)
+		assert.False(t, bytes.Contains(data, []byte("Exif")))
+	})
+}
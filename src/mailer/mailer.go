@@ -0,0 +1,136 @@
+// Package mailer provides the backends used to send emails to the users.
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("log")
+
+// Message is an email sent to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	// Body is the plain text content of the email.
+	Body string
+}
+
+// IMailer is an interface that contains the methods that will implement an email backend.
+type IMailer interface {
+	// Send sends an email.
+	// message is the email to send.
+	// It returns an error if the email couldn't be delivered to the backend.
+	Send(message Message) error
+}
+
+// New creates the mailer of the settings: the emails are sent through SMTP if a host is set, otherwise they are only
+// logged.
+func New(settings config.MailerConfig) IMailer {
+	if settings.SMTPHost == "" {
+		log.Warning("No SMTP host is configured, emails will only be logged")
+		return &LogMailer{}
+	}
+
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(settings.SMTPHost, strconv.Itoa(settings.SMTPPort)),
+		host:     settings.SMTPHost,
+		user:     settings.SMTPUser,
+		password: settings.SMTPPassword,
+		from:     settings.From,
+	}
+}
+
+// LogMailer writes the emails to the log instead of sending them, used in development.
+type LogMailer struct{}
+
+func (m *LogMailer) Send(message Message) error {
+	log.Infof("Email to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// MemoryMailer keeps the sent emails in memory, used in the tests.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *MemoryMailer) Send(message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// Reset forgets the sent emails.
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
+
+// Messages returns the emails sent to a recipient, the oldest first.
+func (m *MemoryMailer) Messages(to string) []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := make([]Message, 0)
+	for _, message := range m.messages {
+		if message.To == to {
+			messages = append(messages, message)
+		}
+	}
+
+	return messages
+}
+
+// SMTPMailer sends the emails through an SMTP server, using STARTTLS when the server supports it.
+type SMTPMailer struct {
+	addr     string
+	host     string
+	user     string
+	password string
+	from     string
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+	var auth smtp.Auth
+	if m.user != "" {
+		auth = smtp.PlainAuth("", m.user, m.password, m.host)
+	}
+
+	if err := smtp.SendMail(m.addr, auth, m.from, []string{message.To}, m.format(message)); err != nil {
+		log.Errorf("Failed to send email to %s: %v", message.To, err)
+		return err
+	}
+
+	return nil
+}
+
+// format builds the RFC 5322 representation of a message.
+func (m *SMTPMailer) format(message Message) []byte {
+	var builder strings.Builder
+
+	// Header values can't contain line breaks, otherwise a value could inject new headers.
+	clean := strings.NewReplacer("\r", "", "\n", "")
+
+	fmt.Fprintf(&builder, "From: %s\r\n", clean.Replace(m.from))
+	fmt.Fprintf(&builder, "To: %s\r\n", clean.Replace(message.To))
+	fmt.Fprintf(&builder, "Subject: %s\r\n", clean.Replace(message.Subject))
+	fmt.Fprintf(&builder, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	builder.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(builder.String())
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	mailer := &SMTPMailer{from: "no-reply@nutripocket.com"}
+
+	t.Run("It should build the headers and the body of the email", func(t *testing.T) {
+		data := string(mailer.format(Message{To: "test@test.com", Subject: "Hi", Body: "line 1\nline 2"}))
+
+		assert.True(t, strings.HasPrefix(data, "From: no-reply@nutripocket.com\r\nTo: test@test.com\r\nSubject: Hi\r\n"))
+		assert.True(t, strings.HasSuffix(data, "\r\n\r\nline 1\r\nline 2"))
+	})
+
+	t.Run("It should remove line breaks from the headers", func(t *testing.T) {
+		data := string(mailer.format(Message{To: "test@test.com\r\nBcc: attacker@test.com", Subject: "Hi"}))
+
+		assert.False(t, strings.Contains(data, "\r\nBcc:"))
+	})
+}
+
+func TestMemoryMailer(t *testing.T) {
+	t.Run("It should keep the emails of each recipient", func(t *testing.T) {
+		mailer := &MemoryMailer{}
+		mailer.Send(Message{To: "a@test.com", Subject: "1"})
+		mailer.Send(Message{To: "b@test.com", Subject: "2"})
+		mailer.Send(Message{To: "a@test.com", Subject: "3"})
+
+		messages := mailer.Messages("a@test.com")
+
+		assert.Len(t, messages, 2)
+		assert.Equal(t, "3", messages[1].Subject)
+	})
+}
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/NutriPocket/UserService/app"
+	"github.com/NutriPocket/UserService/config"
 	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/migrate"
 	"github.com/NutriPocket/UserService/utils"
+	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/op/go-logging"
 )
@@ -49,29 +58,62 @@ func InitLogger(logLevel string) error {
 func main() {
 	loadEnv()
 
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" {
-		logLevel = "DEBUG"
+	settings, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load the config: %v", err)
 	}
 
-	InitLogger(logLevel)
+	InitLogger(settings.LogLevel)
+	gin.SetMode(settings.Mode)
 
-	database.ConnectDB()
+	database.ConnectDB(settings.Database)
 	defer database.Close()
 
-	router := utils.SetupRouter()
+	if len(args) > 0 {
+		if err := runCommand(args); err != nil {
+			log.Fatalf("Failed to run %s: %v", strings.Join(args, " "), err)
+		}
+		return
+	}
+
+	if settings.Database.MigrateOnStart {
+		migrator, err := migrate.New(database.GetConnection(), database.CurrentDialect())
+		if err != nil {
+			log.Fatalf("Failed to load the migrations: %v", err)
+		}
+
+		if _, err := migrator.Up(); err != nil {
+			log.Fatalf("Failed to migrate the database: %v", err)
+		}
+	}
+
+	container, err := app.New(app.Dependencies{Config: &settings})
+	if err != nil {
+		log.Fatalf("Failed to create the application container: %v", err)
+	}
 
-	host := os.Getenv("HOST")
-	if host == "" {
-		host = "0.0.0.0"
+	if err := container.UserService.BuildSearchIndex(); err != nil {
+		log.Errorf("Failed to build the users search index, searches will use the database: %v", err)
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if err := container.RevocationCacheService.Warm(); err != nil {
+		log.Errorf("Failed to load the revocation cache, tokens will be checked in the database: %v", err)
 	}
 
-	addr := host + ":" + port
+	stopWorkers := make(chan struct{})
+	defer close(stopWorkers)
+
+	go container.AccountDeletionService.Run(stopWorkers)
+	go container.AccountStateService.Run(stopWorkers)
+	go container.RevocationCacheService.Run(stopWorkers)
+	go database.Monitor(stopWorkers, settings.Database)
+
+	router := utils.SetupRouter(container)
+
+	addr := net.JoinHostPort(settings.Server.Host, strconv.Itoa(settings.Server.Port))
 
 	log.Infof("Starting server on %s", addr)
 	router.Run(addr)
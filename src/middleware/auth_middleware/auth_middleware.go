@@ -2,6 +2,7 @@
 package middleware
 
 import (
+	"regexp"
 	"strings"
 
 	"github.com/NutriPocket/UserService/model"
@@ -51,13 +52,45 @@ func getToken(authHeader string) (token string, err error) {
 	return token, nil
 }
 
+// publicRoots are the root paths that can be accessed without authorization
+// /static serves the uploaded files, which are loaded by clients that can't send headers, e.g. <img> tags
+var publicRoots = map[string]bool{
+	"auth":   true,
+	"static": true,
+}
+
+// publicPaths are the paths outside of publicRoots that can be accessed without authorization
+var publicPaths = []*regexp.Regexp{
+	// Generated avatars are used as the picture of the users without one
+	regexp.MustCompile(`^/users/[^/]+/avatar\.(png|svg)$`),
+}
+
+// isPublicPath returns true if a URL path can be accessed without authorization
+// @param urlPath string - The URL path
+func isPublicPath(urlPath string) bool {
+	if publicRoots[getRootPath(urlPath)] {
+		return true
+	}
+
+	for _, publicPath := range publicPaths {
+		if publicPath.MatchString(urlPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // AuthMiddleware is a middleware that checks if the user is authorized to access the endpoint
-// Only the endpoints that start with /auth are allowed to be accessed without authorization
-func AuthMiddleware() gin.HandlerFunc {
+// Only the endpoints that start with /auth or /static and the generated avatars are allowed to be accessed
+// without authorization
+// It doesn't run for the routes registered before it, e.g. the health probes
+// jwtService checks the tokens and accountStateService the state of their users
+func AuthMiddleware(jwtService *service.JWTService, accountStateService *service.AccountStateService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		urlPath := c.Request.URL.Path
 
-		if getRootPath(urlPath) == "auth" {
+		if isPublicPath(urlPath) {
 			c.Next()
 
 			return
@@ -73,12 +106,6 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		jwtService, err := service.NewJWTService(nil)
-		if err != nil {
-			c.Error(err)
-			return
-		}
-
 		if isBlacklisted, err := jwtService.IsBlacklisted(token); isBlacklisted && err == nil {
 			c.Error(&model.AuthenticationError{
 				Title:  "Invalid authorization",
@@ -103,8 +130,67 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// The state is checked on every request, so suspensions and bans apply to the tokens issued before them. Like the
+		// revocation, it's read from memory unless the revocation cache is stale.
+		// It's checked before the revocation, which also rejects those tokens, to tell their users why.
+		if err := accountStateService.Check(decoded.Payload.Id); err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		if isRevoked, err := jwtService.IsRevoked(decoded); isRevoked && err == nil {
+			c.Error(&model.AuthenticationError{
+				Title:  "Invalid authorization",
+				Detail: "The provided token has been revoked, please try logging in again",
+			})
+			c.Abort()
+			return
+		} else if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
 		c.Set("authUser", decoded.Payload)
 
 		c.Next()
 	}
 }
+
+// AdminMiddleware is a middleware that only allows the users with the admin role to access the endpoints
+// It must be used after AuthMiddleware, which sets the authenticated user
+// userService reads the role of the authenticated user
+func AdminMiddleware(userService *service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authUser, ok := c.Get("authUser")
+		user, isUser := authUser.(model.User)
+
+		if !ok || !isUser || user.Id == "" {
+			c.Error(&model.AuthenticationError{
+				Title:  "Unauthorized user",
+				Detail: "The user isn't authorized because no valid token is provided",
+			})
+			c.Abort()
+			return
+		}
+
+		isAdmin, err := userService.IsAdmin(user.Id)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		if !isAdmin {
+			c.Error(&model.ForbiddenError{
+				Title:  "Forbidden",
+				Detail: "Only admins can access this endpoint",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
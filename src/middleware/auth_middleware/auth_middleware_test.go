@@ -53,6 +53,28 @@ func TestGetRootPath(t *testing.T) {
 	})
 }
 
+func TestIsPublicPath(t *testing.T) {
+	t.Run("The auth endpoints are public", func(t *testing.T) {
+		if !isPublicPath("/auth/login") {
+			t.Error("/auth/login should be public")
+		}
+	})
+
+	t.Run("The generated avatars are public", func(t *testing.T) {
+		if !isPublicPath("/users/test/avatar.png") || !isPublicPath("/users/test/avatar.svg") {
+			t.Error("The generated avatars should be public")
+		}
+	})
+
+	t.Run("The user endpoints are private", func(t *testing.T) {
+		for _, url := range []string{"/users/", "/users/test", "/users/test/avatar", "/users/test/avatar.png/other"} {
+			if isPublicPath(url) {
+				t.Errorf("%s should be private", url)
+			}
+		}
+	})
+}
+
 func TestGetToken(t *testing.T) {
 	t.Run("It should return the parsed token", func(t *testing.T) {
 		authHeader := "Bearer this-is-a-token"
@@ -18,6 +18,8 @@ type errorRfc9457 struct {
 	Status   int    `json:"status"`
 	Detail   string `json:"detail"`
 	Instance string `json:"instance"`
+	// Errors is an extension member with every rule broken by the input, if there are several.
+	Errors []model.Violation `json:"errors,omitempty"`
 }
 
 // parseError parses an error and returns an error in the RFC 9457 format
@@ -28,16 +30,22 @@ func parseError(err error, urlPath string) errorRfc9457 {
 	var status int
 	var detail string
 	var title string
+	var violations []model.Violation
 
 	switch e := err.(type) {
 	case *model.ValidationError:
 		status = http.StatusBadRequest
 		detail = e.Detail
 		title = e.Title
+		violations = e.Violations
 	case *model.AuthenticationError:
 		status = http.StatusUnauthorized
 		detail = e.Detail
 		title = e.Title
+	case *model.ForbiddenError:
+		status = http.StatusForbidden
+		detail = e.Detail
+		title = e.Title
 	case *model.NotFoundError:
 		status = http.StatusNotFound
 		detail = e.Detail
@@ -46,6 +54,10 @@ func parseError(err error, urlPath string) errorRfc9457 {
 		status = http.StatusConflict
 		detail = e.Detail
 		title = e.Title
+	case *model.TooManyRequestsError:
+		status = http.StatusTooManyRequests
+		detail = e.Detail
+		title = e.Title
 	default:
 		status = http.StatusInternalServerError
 		detail = "An unknown error has occurred"
@@ -58,6 +70,7 @@ func parseError(err error, urlPath string) errorRfc9457 {
 		Status:   status,
 		Detail:   detail,
 		Instance: urlPath,
+		Errors:   violations,
 	}
 }
 
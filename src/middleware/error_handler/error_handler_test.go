@@ -56,6 +56,36 @@ func TestParseError(t *testing.T) {
 		}
 	})
 
+	t.Run("The violations of a validation error are parsed as errors", func(t *testing.T) {
+		urlPath := "/auth/register"
+
+		violations := []model.Violation{
+			{Rule: "min_length", Detail: "The password must have at least 8 characters"},
+			{Rule: "breached", Detail: "The password appeared in a data breach"},
+		}
+
+		expected := errorRfc9457{
+			Title:    "Weak password",
+			Detail:   "The password doesn't follow the password policy",
+			Status:   http.StatusBadRequest,
+			Type:     "about:blank",
+			Instance: "/auth/register",
+			Errors:   violations,
+		}
+
+		err := &model.ValidationError{
+			Title:      "Weak password",
+			Detail:     "The password doesn't follow the password policy",
+			Violations: violations,
+		}
+
+		result := parseError(err, urlPath)
+
+		if !reflect.DeepEqual(expected, result) {
+			t.Errorf("The parsed error isn't equal to the expected one")
+		}
+	})
+
 	t.Run("An authentication error is parsed with status code 401", func(t *testing.T) {
 		urlPath := "/"
 
@@ -82,6 +112,32 @@ func TestParseError(t *testing.T) {
 		}
 	})
 
+	t.Run("A forbidden error is parsed with status code 403", func(t *testing.T) {
+		urlPath := "/"
+
+		detail := "You can only change your own avatar"
+		title := "Forbidden"
+
+		expected := errorRfc9457{
+			Title:    title,
+			Detail:   detail,
+			Status:   http.StatusForbidden,
+			Type:     "about:blank",
+			Instance: "/",
+		}
+
+		err := &model.ForbiddenError{
+			Title:  title,
+			Detail: detail,
+		}
+
+		result := parseError(err, urlPath)
+
+		if !reflect.DeepEqual(expected, result) {
+			t.Errorf("The parsed error isn't equal to the expected one")
+		}
+	})
+
 	t.Run("A not found error is parsed with status code 404", func(t *testing.T) {
 		urlPath := "/"
 
@@ -133,4 +189,30 @@ func TestParseError(t *testing.T) {
 			t.Errorf("The parsed error isn't equal to the expected one")
 		}
 	})
+
+	t.Run("A too many requests error is parsed with status code 429", func(t *testing.T) {
+		urlPath := "/"
+
+		detail := "You can change your username again in 10 days"
+		title := "Username recently changed"
+
+		expected := errorRfc9457{
+			Title:    title,
+			Detail:   detail,
+			Status:   http.StatusTooManyRequests,
+			Type:     "about:blank",
+			Instance: "/",
+		}
+
+		err := &model.TooManyRequestsError{
+			Title:  title,
+			Detail: detail,
+		}
+
+		result := parseError(err, urlPath)
+
+		if !reflect.DeepEqual(expected, result) {
+			t.Errorf("The parsed error isn't equal to the expected one")
+		}
+	})
 }
@@ -0,0 +1,37 @@
+// Package middleware provides custom middlewares for the API
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/gin-gonic/gin"
+)
+
+// writes reports whether a request with the method is meant to write, e.g. a POST, as opposed to the safe methods.
+// @param method string - The HTTP method of the request
+func writes(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+// PrimaryReadsMiddleware is a middleware that tracks the writes of the request in its context, so the user lookups
+// read from the primary database once the request wrote, instead of from a replica that may not have the writes yet.
+// Most writes don't go through the replica router, so the requests with a method that writes are pinned to the
+// primary from the start
+func PrimaryReadsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := database.TrackWrites(c.Request.Context())
+
+		if writes(c.Request.Method) {
+			database.MarkWritten(ctx)
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimaryReads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	written := func(method string) bool {
+		var written bool
+
+		router := gin.New()
+		router.Use(PrimaryReadsMiddleware())
+		router.Handle(method, "/", func(c *gin.Context) {
+			written = database.HasWritten(c.Request.Context())
+		})
+
+		req, _ := http.NewRequest(method, "/", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		return written
+	}
+
+	t.Run("It should pin the requests that write to the primary", func(t *testing.T) {
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			assert.True(t, written(method), "%s should read from the primary", method)
+		}
+	})
+
+	t.Run("It should let the safe requests read from the replicas until they write", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+			assert.False(t, written(method), "%s should read from the replicas", method)
+		}
+
+		router := gin.New()
+		router.Use(PrimaryReadsMiddleware())
+		router.GET("/", func(c *gin.Context) {
+			database.MarkWritten(c.Request.Context())
+			assert.True(t, database.HasWritten(c.Request.Context()))
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	})
+}
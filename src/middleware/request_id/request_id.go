@@ -0,0 +1,38 @@
+// Package middleware provides custom middlewares for the API
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIdHeader is the header that carries the id of a request, received from a proxy or set by the service.
+const RequestIdHeader = "X-Request-Id"
+
+// requestIdRegex matches the request ids accepted from the clients, anything else is replaced by a new id.
+var requestIdRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestId returns the id received in the header if it's valid, a new one otherwise.
+// @param header string - The received X-Request-Id header
+func requestId(header string) string {
+	if requestIdRegex.MatchString(header) {
+		return header
+	}
+
+	return uuid.NewString()
+}
+
+// RequestIdMiddleware is a middleware that sets the id of the request in the context, as requestId, and in the
+// X-Request-Id response header. The id received from a proxy is kept, so the logs of both can be correlated
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestId(c.GetHeader(RequestIdHeader))
+
+		c.Set("requestId", id)
+		c.Header(RequestIdHeader, id)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRequestId(t *testing.T) {
+	t.Run("A valid received id should be kept", func(t *testing.T) {
+		expected := "proxy-1234.abc_DEF"
+		result := requestId(expected)
+		if expected != result {
+			t.Errorf("requestId should be '%s', got '%s'", expected, result)
+		}
+	})
+
+	t.Run("A missing id should be replaced by a new uuid", func(t *testing.T) {
+		result := requestId("")
+		if _, err := uuid.Parse(result); err != nil {
+			t.Errorf("requestId should be a uuid, got '%s'", result)
+		}
+	})
+
+	t.Run("An id with invalid characters or too long should be replaced", func(t *testing.T) {
+		for _, header := range []string{"id\r\nSet-Cookie: a=b", strings.Repeat("a", 65)} {
+			if result := requestId(header); result == header {
+				t.Errorf("requestId should replace '%s'", header)
+			}
+		}
+	})
+}
@@ -0,0 +1,136 @@
+// Package migrate applies the versioned schema migrations embedded in the service.
+//
+// Each migration is a pair of files in the directory of each dialect, migrations/mysql, migrations/postgres and
+// migrations/sqlite, VERSION_NAME.up.sql and VERSION_NAME.down.sql, e.g. 0003_add_users_locale.up.sql. Every dialect has the same
+// versions. They are applied in the order of their versions and recorded in the schema_migrations table, so each one
+// runs once per database.
+package migrate
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("log")
+
+//go:embed migrations
+var embedded embed.FS
+
+// Migration is a versioned change of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	// Up applies the change.
+	Up string
+	// Down reverts the change, empty if it can't be reverted.
+	Down string
+}
+
+// Checksum returns the SHA-256 of the up script, recorded to detect the migrations edited after being applied.
+func (migration Migration) Checksum() string {
+	hash := sha256.Sum256([]byte(migration.Up))
+	return hex.EncodeToString(hash[:])
+}
+
+// Statements returns the statements of a script.
+// Statements end with a semicolon at the end of a line, and the lines starting with -- are comments.
+func Statements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+var filenameRegex = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations of the sql files of a directory, ordered by version.
+// It returns an error if a file isn't named as a migration, a version is repeated or a migration has no up script.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := filenameRegex.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s isn't named VERSION_NAME.up.sql or VERSION_NAME.down.sql", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("version %d is used by %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrations returns the migrations of a dialect embedded in the service.
+func Migrations(dialect database.Dialect) ([]Migration, error) {
+	return Load(embedded, path.Join("migrations", string(dialect)))
+}
@@ -0,0 +1,198 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatements(t *testing.T) {
+	t.Run("It should split the statements at the semicolons ending a line and skip the comments", func(t *testing.T) {
+		statements := Statements(`-- The users
+CREATE TABLE users (
+    id VARCHAR(36) PRIMARY KEY
+);
+
+-- The default row
+INSERT INTO users (id) VALUES ('a;b');
+DROP TABLE users`)
+
+		assert.Equal(t, []string{
+			"CREATE TABLE users (\n    id VARCHAR(36) PRIMARY KEY\n);",
+			"INSERT INTO users (id) VALUES ('a;b');",
+			"DROP TABLE users",
+		}, statements)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("It should pair the up and down scripts and order them by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/0010_add_locale.up.sql":   {Data: []byte("ALTER TABLE users ADD locale VARCHAR(10);")},
+			"migrations/0002_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);")},
+			"migrations/0002_create_users.down.sql": {
+				Data: []byte("DROP TABLE users;"),
+			},
+		}
+
+		migrations, err := Load(fsys, "migrations")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Migration{
+			{Version: 2, Name: "create_users", Up: "CREATE TABLE users (id INT);", Down: "DROP TABLE users;"},
+			{Version: 10, Name: "add_locale", Up: "ALTER TABLE users ADD locale VARCHAR(10);"},
+		}, migrations)
+	})
+
+	t.Run("It should reject a file not named as a migration", func(t *testing.T) {
+		fsys := fstest.MapFS{"migrations/create_users.sql": {Data: []byte("CREATE TABLE users (id INT);")}}
+
+		_, err := Load(fsys, "migrations")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("It should reject two migrations with the same version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/0001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);")},
+			"migrations/0001_create_posts.up.sql": {Data: []byte("CREATE TABLE posts (id INT);")},
+		}
+
+		_, err := Load(fsys, "migrations")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("It should reject a migration without up script", func(t *testing.T) {
+		fsys := fstest.MapFS{"migrations/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")}}
+
+		_, err := Load(fsys, "migrations")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("It should load the embedded migrations with the same versions in every dialect", func(t *testing.T) {
+		mysql, err := Migrations(database.MySQL)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, mysql)
+
+		for i, migration := range mysql {
+			assert.NotEmpty(t, migration.Down, "migration %d_%s has no down script", migration.Version, migration.Name)
+			assert.Equal(t, i+1, migration.Version, "the versions must be consecutive")
+		}
+
+		for _, dialect := range []database.Dialect{database.PostgreSQL, database.SQLite} {
+			migrations, err := Migrations(dialect)
+			assert.NoError(t, err)
+			assert.Len(t, migrations, len(mysql))
+
+			for i, migration := range migrations[:min(len(migrations), len(mysql))] {
+				assert.Equal(t, mysql[i].Version, migration.Version)
+				assert.Equal(t, mysql[i].Name, migration.Name)
+				assert.NotEmpty(t, migration.Down, "migration %d_%s has no %s down script", migration.Version, migration.Name, dialect)
+			}
+		}
+	})
+}
+
+func TestMigrator(t *testing.T) {
+	open := func(t *testing.T) *Migrator {
+		conn, err := database.Open(config.DatabaseConfig{Driver: config.SQLiteDriver, Name: config.SQLiteMemory})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		migrator, err := New(conn, database.SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return migrator
+	}
+
+	t.Run("It should apply the pending migrations once", func(t *testing.T) {
+		migrator := open(t)
+
+		applied, err := migrator.Up()
+		assert.NoError(t, err)
+		assert.Len(t, applied, len(migrator.migrations))
+
+		applied, err = migrator.Up()
+		assert.NoError(t, err)
+		assert.Empty(t, applied)
+
+		statuses, err := migrator.Status()
+		assert.NoError(t, err)
+		for _, status := range statuses {
+			assert.NotNil(t, status.AppliedAt, "migration %d_%s isn't applied", status.Version, status.Name)
+			assert.False(t, status.Modified)
+			assert.False(t, status.Missing)
+		}
+	})
+
+	t.Run("It should revert every migration from the newest", func(t *testing.T) {
+		migrator := open(t)
+		_, err := migrator.Up()
+		assert.NoError(t, err)
+
+		reverted, err := migrator.Down(len(migrator.migrations) + 1)
+		assert.NoError(t, err)
+		assert.Len(t, reverted, len(migrator.migrations))
+		assert.Equal(t, len(migrator.migrations), reverted[0].Version)
+
+		statuses, err := migrator.Status()
+		assert.NoError(t, err)
+		for _, status := range statuses {
+			assert.Nil(t, status.AppliedAt, "migration %d_%s is still applied", status.Version, status.Name)
+		}
+
+		applied, err := migrator.Up()
+		assert.NoError(t, err)
+		assert.Len(t, applied, len(migrator.migrations))
+	})
+
+	t.Run("It should report the migrations changed after being applied and the unknown ones", func(t *testing.T) {
+		migrator := open(t)
+		_, err := migrator.Up()
+		assert.NoError(t, err)
+
+		changed := NewWithMigrations(migrator.db, database.SQLite, []Migration{
+			{Version: 1, Name: migrator.migrations[0].Name, Up: "SELECT 1;"},
+		})
+
+		statuses, err := changed.Status()
+		assert.NoError(t, err)
+		assert.True(t, statuses[0].Modified)
+		for _, status := range statuses[1:] {
+			assert.True(t, status.Missing)
+		}
+	})
+	t.Run("It should check that every migration is applied and unchanged", func(t *testing.T) {
+		migrator := open(t)
+		assert.Error(t, migrator.Check(context.Background()))
+
+		_, err := migrator.Up()
+		assert.NoError(t, err)
+		assert.NoError(t, migrator.Check(context.Background()))
+
+		_, err = migrator.Down(1)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, migrator.Check(context.Background()), "1 of")
+
+		_, err = migrator.Up()
+		assert.NoError(t, err)
+
+		changed := NewWithMigrations(migrator.db, database.SQLite, []Migration{
+			{Version: 1, Name: migrator.migrations[0].Name, Up: "SELECT 1;"},
+		})
+		assert.ErrorContains(t, changed.Check(context.Background()), "changed after being applied")
+
+		newer := NewWithMigrations(migrator.db, database.SQLite, migrator.migrations[:1])
+		assert.NoError(t, newer.Check(context.Background()))
+	})
+}
@@ -0,0 +1,154 @@
+-- The schema of the databases created before the migrations, by the former sql/tables.sql, so it's a no-op on them.
+
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(36) PRIMARY KEY,
+    username VARCHAR(100) UNIQUE NOT NULL,
+    email VARCHAR(100) UNIQUE NOT NULL,
+    password VARCHAR(100) NOT NULL,
+    picture TEXT DEFAULT NULL,
+    display_name VARCHAR(100) DEFAULT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    failed_logins INT NOT NULL DEFAULT 0,
+    locked_until DATETIME(6) DEFAULT NULL,
+    state VARCHAR(20) NOT NULL DEFAULT 'active',
+    state_reason VARCHAR(500) DEFAULT NULL,
+    state_until DATETIME(6) DEFAULT NULL,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_state_until (state, state_until)
+);
+
+CREATE TABLE IF NOT EXISTS jwt_blacklist (
+    signature VARCHAR(100) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_expires_at (expires_at),
+    INDEX idx_created_at (created_at)
+);
+
+CREATE TABLE IF NOT EXISTS jwt_revocations (
+    user_id VARCHAR(36) PRIMARY KEY,
+    valid_after DATETIME(6) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS username_history (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(100) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    changed_at DATETIME(6) NOT NULL,
+    reserved_until DATETIME(6) NOT NULL,
+    INDEX idx_username_reserved_until (username, reserved_until),
+    INDEX idx_user_id_changed_at (user_id, changed_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS email_changes (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    old_email VARCHAR(100) NOT NULL,
+    new_email VARCHAR(100) NOT NULL,
+    confirm_token_hash VARCHAR(64) UNIQUE NOT NULL,
+    undo_token_hash VARCHAR(64) UNIQUE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    undo_expires_at DATETIME(6) NOT NULL,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_user_id_status (user_id, status),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    archive LONGBLOB DEFAULT NULL,
+    created_at DATETIME(6) NOT NULL,
+    completed_at DATETIME(6) DEFAULT NULL,
+    expires_at DATETIME(6) DEFAULT NULL,
+    INDEX idx_user_id_status (user_id, status),
+    INDEX idx_expires_at (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    used_at DATETIME(6) DEFAULT NULL,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Successful logins, used to show the login history and to detect new devices
+CREATE TABLE IF NOT EXISTS login_history (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    device_fingerprint CHAR(64) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    country VARCHAR(2) NOT NULL DEFAULT '',
+    region VARCHAR(100) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL DEFAULT '',
+    latitude DOUBLE DEFAULT NULL,
+    longitude DOUBLE DEFAULT NULL,
+    new_device BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME(6) NOT NULL,
+    INDEX idx_user_id_created_at (user_id, created_at),
+    INDEX idx_user_id_device_fingerprint (user_id, device_fingerprint),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Second factors asked to finish the suspicious logins
+CREATE TABLE IF NOT EXISTS login_challenges (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    code_hash VARCHAR(64) NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at DATETIME(6) NOT NULL,
+    used_at DATETIME(6) DEFAULT NULL,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS totp_credentials (
+    user_id VARCHAR(36) PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Entries outlive the users, so the user ids aren't foreign keys.
+-- Entries are hash chained, see model.AuditEntry, and must never be updated except to erase personal data.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    actor_id VARCHAR(36) DEFAULT NULL,
+    action VARCHAR(50) NOT NULL,
+    target_id VARCHAR(36) DEFAULT NULL,
+    details TEXT NOT NULL,
+    ip VARCHAR(45) DEFAULT NULL,
+    user_agent VARCHAR(255) DEFAULT NULL,
+    request_id VARCHAR(64) DEFAULT NULL,
+    pii_digest CHAR(64) NOT NULL,
+    pii_erased BOOLEAN NOT NULL DEFAULT FALSE,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) UNIQUE NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    INDEX idx_actor_id (actor_id),
+    INDEX idx_target_id (target_id),
+    INDEX idx_action_created_at (action, created_at)
+);
+
+-- The hash of the last entry of the audit log, locked while appending so the entries are chained in order
+CREATE TABLE IF NOT EXISTS audit_chain (
+    id TINYINT PRIMARY KEY,
+    head CHAR(64) NOT NULL
+);
+
+INSERT IGNORE INTO audit_chain (id, head) VALUES (1, REPEAT('0', 64));
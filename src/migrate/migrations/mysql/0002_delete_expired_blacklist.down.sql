@@ -0,0 +1 @@
+DROP EVENT IF EXISTS delete_expired_blacklist;
@@ -0,0 +1,4 @@
+-- Delete expired JWTs
+CREATE EVENT IF NOT EXISTS delete_expired_blacklist
+ON SCHEDULE EVERY 1 MINUTE
+DO DELETE FROM jwt_blacklist WHERE expires_at < NOW();
@@ -0,0 +1,5 @@
+ALTER TABLE jwt_revocations DROP INDEX idx_valid_after;
+
+ALTER TABLE users
+    DROP INDEX idx_state_changed_at,
+    DROP COLUMN state_changed_at;
@@ -0,0 +1,6 @@
+-- The revocation cache of each instance reads the account states and the revocations changed since its last refresh
+ALTER TABLE users
+    ADD COLUMN state_changed_at DATETIME(6) DEFAULT NULL,
+    ADD INDEX idx_state_changed_at (state_changed_at);
+
+ALTER TABLE jwt_revocations ADD INDEX idx_valid_after (valid_after);
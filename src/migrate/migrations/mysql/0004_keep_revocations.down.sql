@@ -0,0 +1,4 @@
+DELETE FROM jwt_revocations WHERE user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE jwt_revocations
+    ADD CONSTRAINT jwt_revocations_ibfk_1 FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
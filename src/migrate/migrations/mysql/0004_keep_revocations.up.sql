@@ -0,0 +1,3 @@
+-- The cutoff of an erased user outlives the user, so the tokens issued before the deletion stay revoked. It holds no
+-- personal data, only the id of the user and a time.
+ALTER TABLE jwt_revocations DROP FOREIGN KEY jwt_revocations_ibfk_1;
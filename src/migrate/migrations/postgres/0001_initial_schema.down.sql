@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS audit_chain;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS totp_credentials;
+DROP TABLE IF EXISTS login_challenges;
+DROP TABLE IF EXISTS login_history;
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS data_exports;
+DROP TABLE IF EXISTS email_changes;
+DROP TABLE IF EXISTS username_history;
+DROP TABLE IF EXISTS jwt_revocations;
+DROP TABLE IF EXISTS jwt_blacklist;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,157 @@
+-- The MySQL schema translated to PostgreSQL. The usernames and emails are CITEXT, so they are unique and looked up
+-- ignoring case as with the default MySQL collation.
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(36) PRIMARY KEY,
+    username CITEXT UNIQUE NOT NULL,
+    email CITEXT UNIQUE NOT NULL,
+    password VARCHAR(100) NOT NULL,
+    picture TEXT DEFAULT NULL,
+    display_name CITEXT DEFAULT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    failed_logins INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP(6) DEFAULT NULL,
+    state VARCHAR(20) NOT NULL DEFAULT 'active',
+    state_reason VARCHAR(500) DEFAULT NULL,
+    state_until TIMESTAMP(6) DEFAULT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6)
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_state_until ON users (state, state_until);
+
+CREATE TABLE IF NOT EXISTS jwt_blacklist (
+    signature VARCHAR(100) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+);
+
+CREATE INDEX IF NOT EXISTS idx_jwt_blacklist_expires_at ON jwt_blacklist (expires_at);
+CREATE INDEX IF NOT EXISTS idx_jwt_blacklist_created_at ON jwt_blacklist (created_at);
+
+CREATE TABLE IF NOT EXISTS jwt_revocations (
+    user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    valid_after TIMESTAMP(6) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS username_history (
+    id BIGSERIAL PRIMARY KEY,
+    username CITEXT NOT NULL,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    changed_at TIMESTAMP(6) NOT NULL,
+    reserved_until TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_username_reserved_until ON username_history (username, reserved_until);
+CREATE INDEX IF NOT EXISTS idx_username_history_user_id_changed_at ON username_history (user_id, changed_at);
+
+CREATE TABLE IF NOT EXISTS email_changes (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_email VARCHAR(100) NOT NULL,
+    new_email VARCHAR(100) NOT NULL,
+    confirm_token_hash VARCHAR(64) UNIQUE NOT NULL,
+    undo_token_hash VARCHAR(64) UNIQUE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP(6) NOT NULL,
+    undo_expires_at TIMESTAMP(6) NOT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user_id_status ON email_changes (user_id, status);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    archive BYTEA DEFAULT NULL,
+    created_at TIMESTAMP(6) NOT NULL,
+    completed_at TIMESTAMP(6) DEFAULT NULL,
+    expires_at TIMESTAMP(6) DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id_status ON data_exports (user_id, status);
+CREATE INDEX IF NOT EXISTS idx_data_exports_expires_at ON data_exports (expires_at);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP(6) NOT NULL,
+    used_at TIMESTAMP(6) DEFAULT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6)
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets (user_id);
+
+-- Successful logins, used to show the login history and to detect new devices
+CREATE TABLE IF NOT EXISTS login_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_fingerprint CHAR(64) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    country VARCHAR(2) NOT NULL DEFAULT '',
+    region VARCHAR(100) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL DEFAULT '',
+    latitude DOUBLE PRECISION DEFAULT NULL,
+    longitude DOUBLE PRECISION DEFAULT NULL,
+    new_device BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_history_user_id_created_at ON login_history (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_history_user_id_device_fingerprint ON login_history (user_id, device_fingerprint);
+
+-- Second factors asked to finish the suspicious logins
+CREATE TABLE IF NOT EXISTS login_challenges (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    method VARCHAR(10) NOT NULL,
+    code_hash VARCHAR(64) NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP(6) NOT NULL,
+    used_at TIMESTAMP(6) DEFAULT NULL,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_challenges_user_id ON login_challenges (user_id);
+
+CREATE TABLE IF NOT EXISTS totp_credentials (
+    user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6)
+);
+
+-- Entries outlive the users, so the user ids aren't foreign keys.
+-- Entries are hash chained, see model.AuditEntry, and must never be updated except to erase personal data.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id VARCHAR(36) DEFAULT NULL,
+    action VARCHAR(50) NOT NULL,
+    target_id VARCHAR(36) DEFAULT NULL,
+    details TEXT NOT NULL,
+    ip VARCHAR(45) DEFAULT NULL,
+    user_agent VARCHAR(255) DEFAULT NULL,
+    request_id VARCHAR(64) DEFAULT NULL,
+    pii_digest CHAR(64) NOT NULL,
+    pii_erased BOOLEAN NOT NULL DEFAULT FALSE,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target_id ON audit_log (target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action_created_at ON audit_log (action, created_at);
+
+-- The hash of the last entry of the audit log, locked while appending so the entries are chained in order
+CREATE TABLE IF NOT EXISTS audit_chain (
+    id SMALLINT PRIMARY KEY,
+    head CHAR(64) NOT NULL
+);
+
+INSERT INTO audit_chain (id, head) VALUES (1, REPEAT('0', 64)) ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,3 @@
+-- PostgreSQL has no scheduled events, so the expired tokens stay in jwt_blacklist until deleted by a scheduler, e.g.
+-- pg_cron: SELECT cron.schedule('* * * * *', 'DELETE FROM jwt_blacklist WHERE expires_at < NOW()')
+-- They are ignored by every query, this migration only keeps the versions of both dialects aligned.
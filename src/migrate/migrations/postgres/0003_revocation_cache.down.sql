@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_jwt_revocations_valid_after;
+
+DROP INDEX IF EXISTS idx_users_state_changed_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS state_changed_at;
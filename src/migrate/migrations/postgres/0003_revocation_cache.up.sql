@@ -0,0 +1,6 @@
+-- The revocation cache of each instance reads the account states and the revocations changed since its last refresh
+ALTER TABLE users ADD COLUMN IF NOT EXISTS state_changed_at TIMESTAMP(6) DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_state_changed_at ON users (state_changed_at);
+
+CREATE INDEX IF NOT EXISTS idx_jwt_revocations_valid_after ON jwt_revocations (valid_after);
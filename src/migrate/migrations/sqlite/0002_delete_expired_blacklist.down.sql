@@ -0,0 +1 @@
+-- Nothing to revert, see the up script.
@@ -0,0 +1,3 @@
+-- SQLite has no scheduled events, so the expired tokens stay in jwt_blacklist, ignored by every query. The embedded
+-- databases are meant for the development and the tests, which don't live long enough for them to pile up.
+-- This migration only keeps the versions of every dialect aligned.
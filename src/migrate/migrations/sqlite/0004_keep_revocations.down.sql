@@ -0,0 +1,13 @@
+CREATE TABLE jwt_revocations_new (
+    user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    valid_after DATETIME NOT NULL
+);
+
+INSERT INTO jwt_revocations_new (user_id, valid_after)
+SELECT user_id, valid_after FROM jwt_revocations WHERE user_id IN (SELECT id FROM users);
+
+DROP TABLE jwt_revocations;
+
+ALTER TABLE jwt_revocations_new RENAME TO jwt_revocations;
+
+CREATE INDEX IF NOT EXISTS idx_jwt_revocations_valid_after ON jwt_revocations (valid_after);
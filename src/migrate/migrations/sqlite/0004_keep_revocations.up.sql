@@ -0,0 +1,15 @@
+-- The cutoff of an erased user outlives the user, so the tokens issued before the deletion stay revoked. It holds no
+-- personal data, only the id of the user and a time.
+-- SQLite can't drop a foreign key, so the table is rebuilt without it.
+CREATE TABLE jwt_revocations_new (
+    user_id VARCHAR(36) PRIMARY KEY,
+    valid_after DATETIME NOT NULL
+);
+
+INSERT INTO jwt_revocations_new (user_id, valid_after) SELECT user_id, valid_after FROM jwt_revocations;
+
+DROP TABLE jwt_revocations;
+
+ALTER TABLE jwt_revocations_new RENAME TO jwt_revocations;
+
+CREATE INDEX IF NOT EXISTS idx_jwt_revocations_valid_after ON jwt_revocations (valid_after);
@@ -0,0 +1,393 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+)
+
+// DefaultLockTimeout is how long a Migrator waits for another instance migrating the same database.
+const DefaultLockTimeout = time.Minute
+
+// lockName is the name of the MySQL lock held while migrating, so the replicas starting together don't race.
+const lockName = "user_service_schema_migrations"
+
+// lockKey is the key of the PostgreSQL advisory lock held while migrating, the equivalent of lockName.
+const lockKey = 0x55534552_4d494752
+
+// createTables are the statements creating the schema_migrations table in each dialect.
+var createTables = map[database.Dialect]string{
+	database.MySQL: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at DATETIME(6) NOT NULL
+		)
+	`,
+	database.PostgreSQL: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at TIMESTAMP(6) NOT NULL
+		)
+	`,
+	database.SQLite: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`,
+}
+
+// Status is the state of a migration in a database.
+type Status struct {
+	Migration
+	// AppliedAt is when the migration was applied, nil if it's pending.
+	AppliedAt *time.Time
+	// Modified is true if the up script changed after the migration was applied.
+	Modified bool
+	// Missing is true if the migration was applied but isn't known by this version of the service.
+	Missing bool
+}
+
+// applied is a row of the schema_migrations table.
+type applied struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator applies and reverts migrations on a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    database.Dialect
+	migrations []Migration
+	// LockTimeout is how long to wait for another instance migrating the database.
+	LockTimeout time.Duration
+}
+
+// New creates a Migrator of the embedded migrations of a dialect.
+// It returns an error if they can't be loaded.
+func New(db *sql.DB, dialect database.Dialect) (*Migrator, error) {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithMigrations(db, dialect, migrations), nil
+}
+
+// NewWithMigrations creates a Migrator of the given migrations, which must be ordered by version.
+func NewWithMigrations(db *sql.DB, dialect database.Dialect, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: dialect, migrations: migrations, LockTimeout: DefaultLockTimeout}
+}
+
+// withLock runs fn on a connection holding the migrations lock, after creating the schema_migrations table.
+func (migrator *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := migrator.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := migrator.lock(conn); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := migrator.unlock(conn); err != nil {
+			log.Errorf("Failed to release the migrations lock: %v", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, createTables[migrator.dialect]); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// lock waits for the migrations lock, held by the session of conn, for up to LockTimeout.
+func (migrator *Migrator) lock(conn *sql.Conn) error {
+	ctx := context.Background()
+	timeout := errors.New("timed out waiting for the migrations lock, another instance may be migrating the database")
+
+	if migrator.dialect == database.SQLite {
+		// SQLite has no named locks, the lock of the whole database is held by a transaction spanning the migrations
+		// instead. It waits for the busy timeout of the connection rather than LockTimeout.
+		_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+		return err
+	}
+
+	if migrator.dialect == database.PostgreSQL {
+		// PostgreSQL advisory locks can't wait with a timeout, so they are polled.
+		deadline := time.Now().Add(migrator.LockTimeout)
+
+		for {
+			var acquired bool
+			if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&acquired); err != nil {
+				return err
+			}
+
+			if acquired {
+				return nil
+			}
+
+			if time.Now().After(deadline) {
+				return timeout
+			}
+
+			time.Sleep(time.Second)
+		}
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(migrator.LockTimeout.Seconds())).Scan(&acquired); err != nil {
+		return err
+	}
+
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return timeout
+	}
+
+	return nil
+}
+
+// unlock releases the migrations lock held by the session of conn.
+func (migrator *Migrator) unlock(conn *sql.Conn) error {
+	if migrator.dialect == database.SQLite {
+		// The migrations applied before a failed one stay applied, as in the other dialects.
+		_, err := conn.ExecContext(context.Background(), "COMMIT")
+		return err
+	}
+
+	if migrator.dialect == database.PostgreSQL {
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+		return err
+	}
+
+	_, err := conn.ExecContext(context.Background(), "DO RELEASE_LOCK(?)", lockName)
+	return err
+}
+
+// applied returns the applied migrations by version.
+func (migrator *Migrator) applied(conn *sql.Conn) (map[int]applied, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int]applied)
+	for rows.Next() {
+		var version int
+		var row applied
+
+		if err := rows.Scan(&version, &row.name, &row.checksum, &row.appliedAt); err != nil {
+			return nil, err
+		}
+
+		result[version] = row
+	}
+
+	return result, rows.Err()
+}
+
+// run runs the statements of a script of a migration.
+func run(conn *sql.Conn, migration Migration, script string) error {
+	for _, statement := range Statements(script) {
+		if _, err := conn.ExecContext(context.Background(), statement); err != nil {
+			return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Up applies the pending migrations in order.
+// It returns the applied migrations and an error if one fails, the ones before it stay applied.
+// MySQL commits each schema change on its own, so the statements of the failed migration that ran aren't reverted,
+// and the migrations aren't run in transactions in PostgreSQL either, to behave the same.
+func (migrator *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+
+	err := migrator.withLock(func(conn *sql.Conn) error {
+		existing, err := migrator.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrator.migrations {
+			if row, ok := existing[migration.Version]; ok {
+				if row.checksum != migration.Checksum() {
+					log.Warningf("Migration %d_%s changed after being applied, it won't be applied again", migration.Version, migration.Name)
+				}
+				continue
+			}
+
+			log.Infof("Applying migration %d_%s", migration.Version, migration.Name)
+
+			if err := run(conn, migration, migration.Up); err != nil {
+				return err
+			}
+
+			if _, err := conn.ExecContext(
+				context.Background(),
+				migrator.dialect.Rebind("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+				migration.Version, migration.Name, migration.Checksum(), time.Now().UTC(),
+			); err != nil {
+				return err
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, from the newest.
+// It returns the reverted migrations and an error if one fails or can't be reverted.
+func (migrator *Migrator) Down(steps int) ([]Migration, error) {
+	var done []Migration
+
+	err := migrator.withLock(func(conn *sql.Conn) error {
+		existing, err := migrator.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int, 0, len(existing))
+		for version := range existing {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, version := range versions[:min(steps, len(versions))] {
+			migration, ok := migrator.find(version)
+			if !ok {
+				return fmt.Errorf("migration %d_%s isn't known by this version of the service", version, existing[version].name)
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s can't be reverted", migration.Version, migration.Name)
+			}
+
+			log.Infof("Reverting migration %d_%s", migration.Version, migration.Name)
+
+			if err := run(conn, migration, migration.Down); err != nil {
+				return err
+			}
+
+			if _, err := conn.ExecContext(context.Background(), migrator.dialect.Rebind("DELETE FROM schema_migrations WHERE version = ?"), version); err != nil {
+				return err
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status returns the state of every migration, known or applied, ordered by version.
+func (migrator *Migrator) Status() ([]Status, error) {
+	var statuses []Status
+
+	err := migrator.withLock(func(conn *sql.Conn) error {
+		existing, err := migrator.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrator.migrations {
+			status := Status{Migration: migration}
+
+			if row, ok := existing[migration.Version]; ok {
+				status.AppliedAt = &row.appliedAt
+				status.Modified = row.checksum != migration.Checksum()
+				delete(existing, migration.Version)
+			}
+
+			statuses = append(statuses, status)
+		}
+
+		for version, row := range existing {
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: version, Name: row.name},
+				AppliedAt: &row.appliedAt,
+				Missing:   true,
+			})
+		}
+
+		return nil
+	})
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, err
+}
+
+// find returns the known migration of a version.
+func (migrator *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range migrator.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+
+	return Migration{}, false
+}
+
+// Check returns an error if a known migration isn't applied to the database or changed after being applied, e.g. for
+// the readiness probe. It reads the schema_migrations table without the migrations lock, so it doesn't wait for an
+// instance migrating the database, and it fails if the table doesn't exist yet.
+// The migrations applied but unknown, e.g. by a newer version of the service, are fine.
+func (migrator *Migrator) Check(ctx context.Context) error {
+	conn, err := migrator.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	existing, err := migrator.applied(conn)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, migration := range migrator.migrations {
+		row, ok := existing[migration.Version]
+		if !ok {
+			pending++
+			continue
+		}
+
+		if row.checksum != migration.Checksum() {
+			return fmt.Errorf("migration %d_%s changed after being applied", migration.Version, migration.Name)
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%d of %d migrations are pending", pending, len(migrator.migrations))
+	}
+
+	return nil
+}
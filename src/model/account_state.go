@@ -0,0 +1,84 @@
+// Package model contains the structs types that will be used in the application.
+package model
+
+import "time"
+
+// States of an account.
+const (
+	AccountActive = "active"
+	// AccountSuspended accounts can't be used until the suspension expires.
+	AccountSuspended = "suspended"
+	// AccountBanned accounts can't be used anymore, unless the ban has an expiry.
+	AccountBanned = "banned"
+	// AccountPendingDeletion accounts are erased when the state expires, unless the user logs in before.
+	AccountPendingDeletion = "pending_deletion"
+)
+
+// AccountStates are the valid states of an account.
+var AccountStates = []string{AccountActive, AccountSuspended, AccountBanned, AccountPendingDeletion}
+
+// AccountState is the state of an account, with the reason of the change and when it expires.
+type AccountState struct {
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty" gorm:"column:state_reason"`
+	// Until is when the state expires, nil if it doesn't.
+	Until *time.Time `json:"until,omitempty" gorm:"column:state_until"`
+}
+
+// UserAccountState is the account state of a user, as listed to cache the states.
+type UserAccountState struct {
+	UserId string
+	AccountState
+	// ChangedAt is when the state was changed, zero if it's unknown.
+	ChangedAt time.Time
+}
+
+// IsActive returns true if the account can be used at the given time, either because it's active or because
+// its suspension has expired.
+func (s AccountState) IsActive(now time.Time) bool {
+	switch s.State {
+	case AccountActive, "":
+		return true
+	case AccountSuspended, AccountBanned:
+		return s.Until != nil && !now.Before(*s.Until)
+	default:
+		return false
+	}
+}
+
+// Roles of the users, only RoleAdmin users can access the admin API.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Roles are the valid roles of a user.
+var Roles = []string{RoleUser, RoleAdmin}
+
+// AccountStateChange is a struct that contains the new state of an account received from an admin.
+type AccountStateChange struct {
+	State  string     `json:"state"`
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"`
+}
+
+// AdminUser is a user as seen by the admins, with its role and the state of its account.
+type AdminUser struct {
+	User
+	AccountState
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"emailVerified"`
+	// LockedUntil is when the account is unlocked after too many failed logins, nil if it isn't locked.
+	LockedUntil *time.Time `json:"lockedUntil"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// RoleChange is a struct that contains the new role of a user received from an admin.
+type RoleChange struct {
+	Role string `json:"role"`
+}
+
+// EmailVerificationChange is a struct that contains whether the email of a user is verified, received from an admin.
+type EmailVerificationChange struct {
+	Verified bool `json:"verified"`
+}
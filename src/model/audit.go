@@ -0,0 +1,114 @@
+// Package model contains the structs types that will be used in the application.
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Actions of the audit log entries.
+const (
+	AuditRegister      = "auth.register"
+	AuditLogin         = "auth.login"
+	AuditLoginFailed   = "auth.login_failed"
+	AuditLoginBlocked  = "auth.login_blocked"
+	AuditLoginStepUp   = "auth.login_step_up"
+	AuditLogout        = "auth.logout"
+	AuditLogoutAll     = "auth.logout_all"
+	AuditPasswordReset = "auth.password_reset"
+
+	AuditProfileUpdate       = "user.profile_update"
+	AuditUsernameChange      = "user.username_change"
+	AuditPasswordChange      = "user.password_change"
+	AuditAvatarUpload        = "user.avatar_upload"
+	AuditEmailChangeRequest  = "user.email_change_request"
+	AuditEmailChangeConfirm  = "user.email_change_confirm"
+	AuditEmailChangeUndo     = "user.email_change_undo"
+	AuditAccountDeletion     = "user.account_deletion"
+	AuditDataExportRequested = "user.data_export"
+	AuditTOTPEnable          = "user.totp_enable"
+	AuditTOTPDisable         = "user.totp_disable"
+
+	AuditAdminAccountState   = "admin.account_state"
+	AuditAdminRole           = "admin.role"
+	AuditAdminRevokeSessions = "admin.revoke_sessions"
+	AuditAdminUnlock         = "admin.unlock"
+	AuditAdminEmailVerified  = "admin.email_verified"
+	AuditAdminPasswordReset  = "admin.password_reset"
+)
+
+// AuditGenesisHash is the previous hash of the first entry of the audit log.
+var AuditGenesisHash = strings.Repeat("0", 64)
+
+// AuditActor identifies who did an action and where the request came from.
+type AuditActor struct {
+	// Id is the id of the user that did the action, empty if it's anonymous, e.g. a failed login.
+	Id        string
+	IP        string
+	UserAgent string
+	RequestId string
+}
+
+// AuditEntry is an action recorded in the audit log.
+// Entries are hash chained: each hash covers the previous one, so changing or deleting an entry breaks the chain.
+// The personal data (user ids, IP and user agent) is covered through PIIDigest, so it can be erased without
+// breaking the chain.
+type AuditEntry struct {
+	Id int64 `json:"id"`
+	// ActorId is the id of the user that did the action, empty if it's anonymous or was erased.
+	ActorId string `json:"actorId"`
+	Action  string `json:"action"`
+	// TargetId is the id of the user affected by the action, empty if none or erased.
+	TargetId string `json:"targetId"`
+	// Details is a JSON object with the parameters of the action. It must not contain personal data.
+	Details   string `json:"details"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	RequestId string `json:"requestId"`
+	// PIIDigest is the digest of the personal data of the entry, kept when the personal data is erased.
+	PIIDigest string `json:"-" gorm:"column:pii_digest"`
+	// PIIErased is true if the personal data of the entry was erased.
+	PIIErased bool      `json:"-" gorm:"column:pii_erased"`
+	PrevHash  string    `json:"prevHash"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// digest returns the hex SHA-256 of the fields, separated by a unit separator so they can't be shifted.
+func digest(fields ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(hash[:])
+}
+
+// ComputePIIDigest returns the digest of the personal data of the entry.
+func (e *AuditEntry) ComputePIIDigest() string {
+	return digest(e.ActorId, e.TargetId, e.IP, e.UserAgent)
+}
+
+// ComputeHash returns the hash of the entry, which covers the previous hash, so the entries form a chain.
+// CreatedAt is hashed with microseconds, the precision it's saved with.
+func (e *AuditEntry) ComputeHash() string {
+	return digest(e.PrevHash, e.Action, e.Details, e.RequestId, e.PIIDigest, e.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000Z"))
+}
+
+// Activity is an entry of the audit log as shown to the affected user.
+// IP and UserAgent are only set for the actions done by the user.
+type Activity struct {
+	Action    string    `json:"action"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AuditVerification is the result of checking the hash chain of the audit log.
+type AuditVerification struct {
+	Valid bool `json:"valid"`
+	// Checked is the number of entries checked.
+	Checked int `json:"checked"`
+	// BrokenAt is the id of the first entry that doesn't match the chain, nil if the chain is valid.
+	BrokenAt *int64 `json:"brokenAt,omitempty"`
+	// Reason describes why the chain is broken.
+	Reason string `json:"reason,omitempty"`
+}
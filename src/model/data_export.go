@@ -0,0 +1,22 @@
+// Package model contains the structs types that will be used in the application.
+package model
+
+import "time"
+
+// Status of a data export.
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is an archive with all the data stored about a user, built in the background.
+type DataExport struct {
+	Id          string     `json:"id"`
+	UserId      string     `json:"-"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+	// ExpiresAt is when the archive is deleted, set when it's ready.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
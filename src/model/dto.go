@@ -1,5 +1,39 @@
 package model
 
+import "time"
+
+// GetUsersParams are the filters that can be applied when listing users
 type GetUsersParams struct {
+	// SearchUsername matches the username or the display name of the users, ignoring case and accents.
 	SearchUsername string
+	// Limit is the maximum number of users returned, 0 means no limit.
+	Limit int
+	// ExcludeId is the id of a user left out of the results, e.g. the authenticated one, empty for none.
+	ExcludeId string
+}
+
+// GetAdminUsersParams are the filters that can be applied when the admins list users
+type GetAdminUsersParams struct {
+	// State filters the users by the state of their account, every state if empty.
+	State string
+	// Email filters the users by their exact email, every email if empty.
+	Email string
+	// Limit is the maximum number of users returned, 0 means no limit.
+	Limit int
+	// Offset is the number of users skipped, for pagination.
+	Offset int
+}
+
+// GetAuditParams are the filters that can be applied when the admins query the audit log
+type GetAuditParams struct {
+	ActorId  string
+	TargetId string
+	Action   string
+	// From and To limit the entries to a time range, unbounded if nil.
+	From *time.Time
+	To   *time.Time
+	// Limit is the maximum number of entries returned, 0 means no limit.
+	Limit int
+	// Offset is the number of entries skipped, for pagination.
+	Offset int
 }
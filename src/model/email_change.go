@@ -0,0 +1,39 @@
+// Package model contains the structs types that will be used in the application.
+package model
+
+import "time"
+
+// Status of an email change.
+const (
+	EmailChangePending   = "pending"
+	EmailChangeConfirmed = "confirmed"
+	// EmailChangeCancelled is a pending change replaced by a newer request or cancelled from the old address.
+	EmailChangeCancelled = "cancelled"
+	// EmailChangeUndone is a confirmed change reverted from the old address.
+	EmailChangeUndone = "undone"
+)
+
+// EmailChangeRequest is a struct that contains the new email received from the client, with the current password
+type EmailChangeRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// EmailChangeToken is a struct that contains the token sent by email to confirm or undo an email change
+type EmailChangeToken struct {
+	Token string `json:"token" form:"token"`
+}
+
+// EmailChange is a request to change the email of a user.
+// Only the SHA-256 hashes of the tokens sent by email are saved.
+type EmailChange struct {
+	Id               string    `json:"id"`
+	UserId           string    `json:"-"`
+	OldEmail         string    `json:"-"`
+	NewEmail         string    `json:"newEmail"`
+	ConfirmTokenHash string    `json:"-"`
+	UndoTokenHash    string    `json:"-"`
+	Status           string    `json:"status"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	UndoExpiresAt    time.Time `json:"-"`
+}
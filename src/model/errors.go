@@ -6,6 +6,14 @@ import "fmt"
 type ValidationError struct {
 	Detail string
 	Title  string
+	// Violations are the rules broken by the input, when it's checked against several at once.
+	Violations []Violation
+}
+
+// Violation is a rule broken by an input, e.g. the minimum length of a password.
+type Violation struct {
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
 }
 
 func (e *ValidationError) Error() string {
@@ -21,6 +29,15 @@ func (e *AuthenticationError) Error() string {
 	return fmt.Sprintf("%s, %s", e.Title, e.Detail)
 }
 
+type ForbiddenError struct {
+	Detail string
+	Title  string
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("%s, %s", e.Title, e.Detail)
+}
+
 type NotFoundError struct {
 	Detail string
 	Title  string
@@ -30,6 +47,25 @@ func (e *NotFoundError) Error() string {
 	return fmt.Sprintf("%s, %s", e.Title, e.Detail)
 }
 
+type TooManyRequestsError struct {
+	Detail string
+	Title  string
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return fmt.Sprintf("%s, %s", e.Title, e.Detail)
+}
+
+// UsernameMovedError is returned when a user is looked up by a username it no longer uses,
+// but that is still reserved for it. Username is the current username of the user.
+type UsernameMovedError struct {
+	Username string
+}
+
+func (e *UsernameMovedError) Error() string {
+	return fmt.Sprintf("Username moved, the user is now %s", e.Username)
+}
+
 type EntityAlreadyExistsError struct {
 	Detail string
 	Title  string
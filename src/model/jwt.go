@@ -2,6 +2,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -9,5 +11,21 @@ import (
 type JWTPayload struct {
 	// Payload is the User data
 	Payload User `json:"payload"`
+	// IssuedAtMicro is when the token was issued in microseconds since the epoch, the issued at claim only has
+	// seconds. It's compared with the revocation cutoff of the user, 0 in the tokens signed before it was added.
+	IssuedAtMicro int64 `json:"iatMicro,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// BlacklistedToken is the signature of a token logged out before it expired
+type BlacklistedToken struct {
+	Signature string
+	// CreatedAt is when the token was logged out
+	CreatedAt time.Time
+}
+
+// Revocation is the time before which the tokens of a user are no longer valid
+type Revocation struct {
+	UserId     string
+	ValidAfter time.Time
+}
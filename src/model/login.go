@@ -0,0 +1,24 @@
+// Package model contains the structs types that will be used in the application.
+package model
+
+import "time"
+
+// Login is a successful login of a user, shown in the login history.
+type Login struct {
+	Id     int64  `json:"id"`
+	UserId string `json:"-"`
+	// DeviceFingerprint identifies the device used to log in, see service.DeviceFingerprint.
+	DeviceFingerprint string `json:"deviceFingerprint"`
+	UserAgent         string `json:"userAgent"`
+	IP                string `json:"ip"`
+	// Country, Region and City are the coarse location of the IP, empty if unknown.
+	Country string `json:"country"`
+	Region  string `json:"region"`
+	City    string `json:"city"`
+	// Latitude and Longitude are the coordinates of the location, nil if unknown. Used to detect impossible travels.
+	Latitude  *float64 `json:"-"`
+	Longitude *float64 `json:"-"`
+	// NewDevice is true if the user had never logged in from the device before.
+	NewDevice bool      `json:"newDevice"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,26 @@
+// Package model contains the structs types that will be used in the application.
+package model
+
+import "time"
+
+// PasswordReset is a request to set a new password through a link sent by email.
+// Only the SHA-256 hash of the token sent by email is saved.
+type PasswordReset struct {
+	Id        string
+	UserId    string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// PasswordResetRequest is a struct that contains the token sent by email and the new password received from the client
+type PasswordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// PasswordChangeRequest is a struct that contains the current and the new password received from the client
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
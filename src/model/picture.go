@@ -0,0 +1,95 @@
+// Package model contains the structs types that will be used in the application.
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Picture contains the URLs of every size of a user avatar.
+// It's stored as a JSON object in the picture column. Pictures saved as a single URL, before avatars were
+// processed by the service, are read as the same URL for every size.
+type Picture struct {
+	Small  string `json:"small"`
+	Medium string `json:"medium"`
+	Large  string `json:"large"`
+}
+
+// IsZero returns true if the picture has no URL at all.
+func (p Picture) IsZero() bool {
+	return p == Picture{}
+}
+
+// MarshalJSON encodes an empty picture as null and any other as an object with the URL of each size.
+func (p Picture) MarshalJSON() ([]byte, error) {
+	if p.IsZero() {
+		return []byte("null"), nil
+	}
+
+	type picture Picture
+	return json.Marshal(picture(p))
+}
+
+// UnmarshalJSON accepts an object with the URL of each size or a single URL used for every size.
+func (p *Picture) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*p = Picture{Small: url, Medium: url, Large: url}
+		return nil
+	}
+
+	type picture Picture
+	var parsed picture
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+
+	*p = Picture(parsed)
+	return nil
+}
+
+// Scan implements sql.Scanner, so a Picture can be read directly from the picture column.
+func (p *Picture) Scan(value interface{}) error {
+	var raw string
+
+	switch v := value.(type) {
+	case nil:
+		*p = Picture{}
+		return nil
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported picture type: %T", value)
+	}
+
+	if raw = strings.TrimSpace(raw); raw == "" {
+		*p = Picture{}
+		return nil
+	}
+
+	if !strings.HasPrefix(raw, "{") {
+		*p = Picture{Small: raw, Medium: raw, Large: raw}
+		return nil
+	}
+
+	return p.UnmarshalJSON([]byte(raw))
+}
+
+// Value implements driver.Valuer, an empty picture is stored as NULL.
+func (p Picture) Value() (driver.Value, error) {
+	if p.IsZero() {
+		return nil, nil
+	}
+
+	type picture Picture
+	data, err := json.Marshal(picture(p))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,52 @@
+// Package model contains the structs types that will be used in the application.
+package model
+
+import "time"
+
+// Methods to verify a login asking for a second factor.
+const (
+	// StepUpEmail sends a one-time code to the email of the user.
+	StepUpEmail = "email"
+	// StepUpTOTP asks for the code of the authenticator app of the user.
+	StepUpTOTP = "totp"
+)
+
+// LoginChallenge is a second factor asked to finish a suspicious login.
+// Only the SHA-256 hash of the code sent by email is saved.
+type LoginChallenge struct {
+	Id       string `json:"challengeId"`
+	UserId   string `json:"-"`
+	Method   string `json:"method"`
+	CodeHash string `json:"-"`
+	// Attempts is the number of wrong codes sent for the challenge.
+	Attempts  int        `json:"-"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"-"`
+}
+
+// LoginChallengeResponse is a struct that contains the code received from the client to finish a login
+type LoginChallengeResponse struct {
+	ChallengeId string `json:"challengeId"`
+	Code        string `json:"code"`
+}
+
+// TOTPCredential is the secret of the authenticator app of a user.
+type TOTPCredential struct {
+	UserId string
+	Secret string
+	// Enabled is false until the user confirms the enrollment with a valid code.
+	Enabled bool
+	// LastStep is the period of the last accepted code, so a code can't be used twice.
+	LastStep int64
+}
+
+// TOTPEnrollment is the secret to add to an authenticator app, as text and as an otpauth URI.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TOTPCode is a struct that contains a code of an authenticator app received from the client
+type TOTPCode struct {
+	Code string `json:"code"`
+}
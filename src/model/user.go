@@ -1,6 +1,8 @@
 // Package model contains the structs types that will be used in the application.
 package model
 
+import "time"
+
 // BaseUser is a struct that contains the base user data received from the client
 type BaseUser struct {
 	Username string
@@ -8,8 +10,10 @@ type BaseUser struct {
 	Password string
 }
 
+// EditableUser is a struct that contains the user data that the user can change after registering
 type EditableUser struct {
-	Picture string `json:"picture"`
+	Picture     Picture `json:"picture"`
+	DisplayName string  `json:"displayName"`
 }
 
 // User is a struct that contains the user data that will be sent to the client
@@ -30,4 +34,19 @@ type LoginUser struct {
 type SavedUser struct {
 	BaseUser
 	Id string
+	AccountState
+	// FailedLogins is the number of failed logins since the last successful one or lockout.
+	FailedLogins int
+	// LockedUntil is when the account is unlocked after too many failed logins, nil if it isn't locked.
+	LockedUntil *time.Time
+}
+
+// UsernameChange is a struct that contains the new username received from the client
+type UsernameChange struct {
+	Username string `json:"username"`
+}
+
+// AccountDeletion is the response of a deletion request, the account can be reactivated by logging in until DeleteAfter.
+type AccountDeletion struct {
+	DeleteAfter time.Time `json:"deleteAfter"`
 }
@@ -0,0 +1,83 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// prefixLength is the length of the hash prefixes that name the range files, as in the Pwned Passwords range API.
+const prefixLength = 5
+
+// RangeDataset is an offline copy of a breached passwords dataset split in k-anonymity ranges, like Pwned Passwords.
+// The directory has a file per SHA-1 prefix of 5 hex characters, named like the prefix with an optional .txt
+// extension, e.g. 5BAA6.txt. Each line is the rest of a hash, 35 hex characters, and its count: SUFFIX:COUNT.
+type RangeDataset struct {
+	dir string
+}
+
+// NewRangeDataset creates a dataset from the directory of its range files.
+// It returns an error if the directory doesn't exist.
+func NewRangeDataset(dir string) (*RangeDataset, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, errors.New(dir + " isn't a directory")
+	}
+
+	return &RangeDataset{dir: dir}, nil
+}
+
+func (d *RangeDataset) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	file, err := d.open(prefix)
+	if errors.Is(err, fs.ErrNotExist) {
+		// A missing range has no breached passwords.
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		lineSuffix, count, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, err
+		}
+
+		return parsed, nil
+	}
+
+	return 0, scanner.Err()
+}
+
+// open opens the range file of a prefix, with or without the .txt extension.
+func (d *RangeDataset) open(prefix string) (*os.File, error) {
+	file, err := os.Open(filepath.Join(d.dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.Open(filepath.Join(d.dir, prefix))
+	}
+
+	return file, err
+}
@@ -0,0 +1,98 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrength(t *testing.T) {
+	t.Run("It should rate short, repeated and sequential passwords as weak", func(t *testing.T) {
+		for _, password := range []string{"", "test", "aaaaaaaaaaaa", "abcdefghijkl", "123456789012", "qwertyuiop"} {
+			assert.Equal(t, 0, Strength(password), password)
+		}
+	})
+
+	t.Run("It should see through common words and leetspeak", func(t *testing.T) {
+		assert.Less(t, Strength("password123"), 2)
+		assert.Less(t, Strength("P4ssw0rd!"), 2)
+	})
+
+	t.Run("It should penalize passwords made of the user data", func(t *testing.T) {
+		assert.GreaterOrEqual(t, Strength("gabrielrossi1987"), 3)
+		assert.Less(t, Strength("gabrielrossi1987", "gabrielrossi", "gabrielrossi@test.com"), 2)
+	})
+
+	t.Run("It should rate long and mixed passwords as strong", func(t *testing.T) {
+		assert.Equal(t, 4, Strength("correct horse battery staple"))
+		assert.Equal(t, 4, Strength("Tr0ub4dor&3-Vx"))
+	})
+}
+
+// fakeDataset is a breached passwords dataset with a fixed list.
+type fakeDataset map[string]int
+
+func (d fakeDataset) Count(password string) (int, error) {
+	return d[password], nil
+}
+
+func TestCheck(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxLength: 100, MinStrength: 2, Breached: fakeDataset{"aaaa": 10}}
+
+	t.Run("It should accept a password that follows every rule", func(t *testing.T) {
+		violations, err := policy.Check("correct horse battery staple", "test", "test@test.com")
+
+		assert.Nil(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("It should return every violation at once", func(t *testing.T) {
+		violations, err := policy.Check("aaaa")
+
+		assert.Nil(t, err)
+		rules := make([]string, 0)
+		for _, violation := range violations {
+			rules = append(rules, violation.Rule)
+			assert.NotEmpty(t, violation.Detail)
+		}
+		assert.Equal(t, []string{RuleMinLength, RuleMinStrength, RuleBreached}, rules)
+	})
+
+	t.Run("It should skip the disabled rules", func(t *testing.T) {
+		violations, err := Policy{}.Check("a")
+
+		assert.Nil(t, err)
+		assert.Empty(t, violations)
+	})
+}
+
+func TestRangeDataset(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	range5BAA6 := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(range5BAA6), 0o644))
+
+	dataset, err := NewRangeDataset(dir)
+	assert.Nil(t, err)
+
+	t.Run("It should count a breached password", func(t *testing.T) {
+		count, err := dataset.Count("password")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 9545824, count)
+	})
+
+	t.Run("It should not count a password missing from its range or without range", func(t *testing.T) {
+		count, err := dataset.Count("correct horse battery staple")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("It should reject a missing directory", func(t *testing.T) {
+		_, err := NewRangeDataset(filepath.Join(dir, "missing"))
+		assert.NotNil(t, err)
+	})
+}
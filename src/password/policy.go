@@ -0,0 +1,85 @@
+// Package password checks the passwords chosen by the users against a policy:
+// a minimum length, a minimum estimated strength and not being in a dataset of breached passwords.
+package password
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Rules of the policy, used to identify the violations.
+const (
+	RuleMinLength   = "min_length"
+	RuleMaxLength   = "max_length"
+	RuleMinStrength = "min_strength"
+	RuleBreached    = "breached"
+)
+
+// Violation is a rule of the policy that a password doesn't follow.
+type Violation struct {
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+// IBreachedChecker is an interface that contains the methods that will implement a dataset of breached passwords.
+type IBreachedChecker interface {
+	// Count returns how many times a password appears in the breaches, 0 if it doesn't.
+	// It returns an error if the dataset can't be read.
+	Count(password string) (int, error)
+}
+
+// Policy are the rules the passwords must follow, a zero limit disables its rule.
+type Policy struct {
+	// MinLength and MaxLength are the limits of the length of the passwords, in characters.
+	MinLength int
+	MaxLength int
+	// MinStrength is the minimum score of Strength, from 0 to 4.
+	MinStrength int
+	// Breached is the dataset of breached passwords, the rule is disabled if nil.
+	Breached IBreachedChecker
+}
+
+// Check checks a password against every rule of the policy.
+// userInputs are the other data of the user, e.g. the username and the email, that make a password weaker if it contains them.
+// It returns all the violations, empty if the password follows the policy, and an error if the breached dataset can't be read.
+func (p Policy) Check(password string, userInputs ...string) ([]Violation, error) {
+	violations := make([]Violation, 0)
+	length := utf8.RuneCountInString(password)
+
+	if p.MinLength > 0 && length < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:   RuleMinLength,
+			Detail: fmt.Sprintf("The password must have at least %d characters", p.MinLength),
+		})
+	}
+
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, Violation{
+			Rule:   RuleMaxLength,
+			Detail: fmt.Sprintf("The password must have at most %d characters", p.MaxLength),
+		})
+	}
+
+	if p.MinStrength > 0 && Strength(password, userInputs...) < p.MinStrength {
+		violations = append(violations, Violation{
+			Rule:   RuleMinStrength,
+			Detail: "The password is too easy to guess, use a longer one mixing words, numbers and symbols",
+		})
+	}
+
+	if p.Breached != nil {
+		count, err := p.Breached.Count(password)
+		if err != nil {
+			return nil, err
+		}
+
+		if count > 0 {
+			violations = append(violations, Violation{
+				Rule:   RuleBreached,
+				Detail: "The password appeared in a data breach, choose one you haven't used anywhere else",
+			})
+		}
+	}
+
+	return violations, nil
+}
@@ -0,0 +1,175 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonWords are words so frequent in passwords that guessing them is almost free, even with leetspeak.
+var commonWords = []string{
+	"password", "qwerty", "azerty", "letmein", "welcome", "admin", "iloveyou", "monkey", "dragon",
+	"football", "baseball", "soccer", "master", "login", "sunshine", "princess", "shadow", "superman", "batman",
+	"trustno", "secret", "hello", "freedom", "whatever", "starwars", "pokemon", "summer", "winter", "spring",
+	"autumn", "nutripocket", "nutri", "pocket",
+}
+
+// keyboardRows are the rows of a QWERTY keyboard, adjacent keys in a row are as easy to guess as a sequence.
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// leetspeak maps the usual substitutions back to letters, so p4ssw0rd matches password.
+var leetspeak = map[rune]rune{'0': 'o', '1': 'l', '3': 'e', '4': 'a', '5': 's', '7': 't', '@': 'a', '$': 's', '!': 'i'}
+
+// wordBits are the bits of a common word or a user input found in a password, as if guessed from a short list.
+const wordBits = 4
+
+// Strength estimates how hard it is to guess a password, from 0 (trivial) to 4 (very hard).
+// It's a rough entropy estimate: repeated characters, sequences (abc, 123, qwerty) and common words barely count.
+// userInputs are the other data of the user, e.g. the username and the email, that count as common words.
+func Strength(password string, userInputs ...string) int {
+	bits := entropyBits(password, userInputs)
+
+	switch {
+	case bits < 25:
+		return 0
+	case bits < 35:
+		return 1
+	case bits < 45:
+		return 2
+	case bits < 60:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// entropyBits estimates the bits of entropy of a password.
+func entropyBits(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	perChar := math.Log2(float64(poolSize(runes)))
+
+	// Each word found covers its characters, which then add wordBits in total instead of their own bits.
+	normalized := make([]rune, len(runes))
+	for i, r := range runes {
+		normalized[i] = unicode.ToLower(r)
+		if letter, ok := leetspeak[r]; ok {
+			normalized[i] = letter
+		}
+	}
+
+	covered := make([]bool, len(runes))
+	bits := 0.0
+
+	for _, word := range append(dictionary(userInputs), commonWords...) {
+		target := []rune(word)
+		for start := 0; start+len(target) <= len(normalized); start++ {
+			if string(normalized[start:start+len(target)]) != word || covered[start] {
+				continue
+			}
+
+			for i := start; i < start+len(target); i++ {
+				covered[i] = true
+			}
+			bits += wordBits
+		}
+	}
+
+	for i, r := range runes {
+		switch {
+		case covered[i]:
+		case i > 0 && predictable(runes[i-1], r):
+			bits++
+		default:
+			bits += perChar
+		}
+	}
+
+	return bits
+}
+
+// dictionary returns the user inputs as lowercase words, with the local part of the emails as a word too.
+func dictionary(userInputs []string) []string {
+	words := make([]string, 0, len(userInputs)*2)
+
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		if local, _, ok := strings.Cut(input, "@"); ok {
+			words = append(words, local)
+		}
+		words = append(words, input)
+	}
+
+	// Very short inputs would match by chance.
+	result := make([]string, 0, len(words))
+	for _, word := range words {
+		if len([]rune(word)) >= 3 {
+			result = append(result, word)
+		}
+	}
+
+	return result
+}
+
+// poolSize returns the number of possible characters of a password, from the classes of characters it uses.
+func poolSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+
+	return pool
+}
+
+// predictable checks if a character follows the previous one in an easy to guess way:
+// a repetition, the next or previous in the alphabet or the digits, or an adjacent key in a keyboard row.
+func predictable(previous rune, current rune) bool {
+	previous, current = unicode.ToLower(previous), unicode.ToLower(current)
+
+	if previous == current {
+		return true
+	}
+
+	if isAlphanumeric(previous) && isAlphanumeric(current) && (current-previous == 1 || previous-current == 1) {
+		return true
+	}
+
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, previous)
+		j := strings.IndexRune(row, current)
+		if i >= 0 && j >= 0 && (i-j == 1 || j-i == 1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,235 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"math"
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+	"gorm.io/gorm"
+)
+
+// IAuditRepository is an interface that contains the methods that will implement a repository struct that interact with the audit_log table.
+type IAuditRepository interface {
+	// Append adds an entry at the end of the hash chain of the audit log.
+	// entry is the entry to append, its CreatedAt must be set. Its id, digest and hashes are set by the repository.
+	// It returns an error if the operation fails.
+	Append(entry *model.AuditEntry) error
+	// List lists the entries of the audit log, the newest first.
+	// params are the filters applied to the list.
+	// It returns an error if the operation fails.
+	List(params model.GetAuditParams) ([]model.AuditEntry, error)
+	// Walk reads the entries of the audit log in the order they were appended, in batches.
+	// fn is called with each batch, and the walk stops if it returns an error.
+	// It returns the error of fn or of the database.
+	Walk(batchSize int, fn func(entries []model.AuditEntry) error) error
+	// CountSince counts the entries of an action on a user since a time, e.g. the recent failed logins.
+	// It returns an error if the operation fails.
+	CountSince(action string, targetId string, since time.Time) (int64, error)
+	// Head returns the hash of the last entry of the audit log, model.AuditGenesisHash if it's empty.
+	// It returns an error if the operation fails.
+	Head() (string, error)
+}
+
+func init() {
+	// The entries are kept after the erasure, as the record of what happened, without the personal data of the user.
+	// The chain stays valid because it covers the digest of the personal data instead of the data itself.
+	RegisterEraser(UserDataEraser{
+		Table: "audit_log",
+		Order: EraseOrderDefault,
+		Erase: func(db IDatabase, userId string) error {
+			return db.Exec(`
+				UPDATE audit_log
+				SET actor_id = CASE WHEN actor_id = ? THEN NULL ELSE actor_id END,
+					target_id = CASE WHEN target_id = ? THEN NULL ELSE target_id END,
+					ip = NULL, user_agent = NULL, pii_erased = TRUE
+				WHERE actor_id = ? OR target_id = ?
+			`, userId, userId, userId, userId).Error
+		},
+	})
+	RegisterExporter(UserDataExporter{
+		Name:        "audit_log",
+		Description: "The security relevant actions done by you or on your account",
+		Export: func(db IDatabase, userId string) (any, error) {
+			entries := make([]model.AuditEntry, 0)
+
+			res := db.Raw(`
+				SELECT `+auditColumns+`
+				FROM audit_log
+				WHERE actor_id = ? OR target_id = ?
+				ORDER BY id ASC
+			`, userId, userId).Scan(&entries)
+
+			return entries, res.Error
+		},
+	})
+}
+
+// auditColumns are the columns read into model.AuditEntry.
+const auditColumns = `
+	id, actor_id, action, target_id, details, ip, user_agent, request_id, pii_digest, pii_erased, prev_hash, hash, created_at`
+
+type AuditRepository struct {
+	db IDatabase
+}
+
+func NewAuditRepository(db IDatabase) (*AuditRepository, error) {
+	var err error
+
+	if db == nil {
+		db, err = database.GetPoolConnection()
+		if err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	return &AuditRepository{
+		db: db,
+	}, nil
+}
+
+func (r *AuditRepository) Append(entry *model.AuditEntry) error {
+	// The hash covers the time as it's saved, with microseconds.
+	entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Microsecond)
+
+	// The head row is locked until the entry is saved, so concurrent appends, even from other instances, are chained
+	// one after the other.
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var chain struct{ Head string }
+
+		if err := tx.Raw("SELECT head FROM audit_chain WHERE id = 1 " + database.DialectOf(tx).ForUpdate()).Scan(&chain).Error; err != nil {
+			return err
+		}
+
+		if chain.Head == "" {
+			chain.Head = model.AuditGenesisHash
+			if err := tx.Exec("INSERT INTO audit_chain (id, head) VALUES (1, ?)", chain.Head).Error; err != nil {
+				return err
+			}
+		}
+
+		entry.PrevHash = chain.Head
+		entry.PIIDigest = entry.ComputePIIDigest()
+		entry.Hash = entry.ComputeHash()
+
+		res := tx.Exec(`
+			INSERT INTO audit_log (actor_id, action, target_id, details, ip, user_agent, request_id, pii_digest, prev_hash, hash, created_at)
+			VALUES (NULLIF(?, ''), ?, NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?);
+		`,
+			entry.ActorId, entry.Action, entry.TargetId, entry.Details, entry.IP, entry.UserAgent, entry.RequestId,
+			entry.PIIDigest, entry.PrevHash, entry.Hash, entry.CreatedAt,
+		)
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		return tx.Exec("UPDATE audit_chain SET head = ? WHERE id = 1", entry.Hash).Error
+	})
+}
+
+func (r *AuditRepository) List(params model.GetAuditParams) ([]model.AuditEntry, error) {
+	entries := make([]model.AuditEntry, 0)
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_log WHERE 1 = 1"
+	args := make([]any, 0)
+
+	if params.ActorId != "" {
+		query += " AND actor_id = ?"
+		args = append(args, params.ActorId)
+	}
+
+	if params.TargetId != "" {
+		query += " AND target_id = ?"
+		args = append(args, params.TargetId)
+	}
+
+	if params.Action != "" {
+		query += " AND action = ?"
+		args = append(args, params.Action)
+	}
+
+	if params.From != nil {
+		query += " AND created_at >= ?"
+		args = append(args, params.From.UTC())
+	}
+
+	if params.To != nil {
+		query += " AND created_at < ?"
+		args = append(args, params.To.UTC())
+	}
+
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, params.Offset)
+
+	res := r.db.Raw(query, args...).Scan(&entries)
+
+	if res.Error != nil {
+		return []model.AuditEntry{}, res.Error
+	}
+
+	return entries, nil
+}
+
+func (r *AuditRepository) Walk(batchSize int, fn func(entries []model.AuditEntry) error) error {
+	var lastId int64
+
+	for {
+		entries := make([]model.AuditEntry, 0, batchSize)
+
+		res := r.db.Raw(`
+			SELECT `+auditColumns+`
+			FROM audit_log
+			WHERE id > ?
+			ORDER BY id ASC
+			LIMIT ?
+		`, lastId, batchSize).Scan(&entries)
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if len(entries) == 0 {
+			return nil
+		}
+
+		if err := fn(entries); err != nil {
+			return err
+		}
+
+		lastId = entries[len(entries)-1].Id
+	}
+}
+
+func (r *AuditRepository) Head() (string, error) {
+	var chain struct{ Head string }
+
+	if err := r.db.Raw("SELECT head FROM audit_chain WHERE id = 1").Scan(&chain).Error; err != nil {
+		return "", err
+	}
+
+	if chain.Head == "" {
+		return model.AuditGenesisHash, nil
+	}
+
+	return chain.Head, nil
+}
+
+func (r *AuditRepository) CountSince(action string, targetId string, since time.Time) (int64, error) {
+	var count int64
+
+	res := r.db.Raw(`
+		SELECT COUNT(*)
+		FROM audit_log
+		WHERE target_id = ? AND action = ? AND created_at >= ?
+	`, targetId, action, since.UTC()).Scan(&count)
+
+	return count, res.Error
+}
@@ -0,0 +1,49 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/repository/repositorytest"
+	"github.com/NutriPocket/UserService/test"
+)
+
+func TestMemoryUserRepository(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.IUserRepository {
+		return repository.NewMemoryUserRepository()
+	})
+}
+
+func TestMemoryJWTRepository(t *testing.T) {
+	repositorytest.TestJWTRepository(t, func(t *testing.T) (repository.IJWTRepository, repository.IUserRepository) {
+		return repository.NewMemoryJWTRepository(), repository.NewMemoryUserRepository()
+	})
+}
+
+func TestUserRepository(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.IUserRepository {
+		r, err := repository.NewUserRepository(test.NewDB(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
+
+func TestJWTRepository(t *testing.T) {
+	repositorytest.TestJWTRepository(t, func(t *testing.T) (repository.IJWTRepository, repository.IUserRepository) {
+		db := test.NewDB(t)
+
+		jwtRepository, err := repository.NewJWTRepository(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		userRepository, err := repository.NewUserRepository(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return jwtRepository, userRepository
+	})
+}
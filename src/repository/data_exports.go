@@ -0,0 +1,159 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+)
+
+// IDataExportRepository is an interface that contains the methods that will implement a repository struct that interact with the data_exports table.
+type IDataExportRepository interface {
+	// Create saves a new pending export.
+	// export is the export to save.
+	// It returns an error if the operation fails.
+	Create(export *model.DataExport) error
+	// Get gets an export of a user.
+	// userId is the id of the owner of the export.
+	// id is the id of the export.
+	// It returns an empty export if the user has no export with that id, and an error if the operation fails.
+	Get(userId string, id string) (model.DataExport, error)
+	// GetPending gets the pending export of a user.
+	// It returns an empty export if there is none, and an error if the operation fails.
+	GetPending(userId string) (model.DataExport, error)
+	// GetArchive gets the archive of a ready export.
+	// It returns nil if the export isn't ready, and an error if the operation fails.
+	GetArchive(id string) ([]byte, error)
+	// Complete saves the archive of an export and marks it as ready.
+	// expiresAt is when the archive will be deleted.
+	// It returns an error if the operation fails.
+	Complete(id string, archive []byte, expiresAt time.Time) error
+	// Fail marks an export as failed.
+	// It returns an error if the operation fails.
+	Fail(id string) error
+	// DeleteExpired deletes the exports whose archive expired before now.
+	// It returns an error if the operation fails.
+	DeleteExpired(now time.Time) error
+}
+
+func init() {
+	RegisterEraser(UserDataEraser{Table: "data_exports", Order: EraseOrderDefault, Erase: deleteByUserId("data_exports")})
+	RegisterExporter(UserDataExporter{
+		Name:        "data_exports",
+		Description: "The exports of your data you requested",
+		Export: func(db IDatabase, userId string) (any, error) {
+			exports := make([]model.DataExport, 0)
+
+			res := db.Raw(`
+				SELECT id, status, created_at, completed_at, expires_at
+				FROM data_exports
+				WHERE user_id = ?
+				ORDER BY created_at ASC
+			`, userId).Scan(&exports)
+
+			return exports, res.Error
+		},
+	})
+}
+
+type DataExportRepository struct {
+	db IDatabase
+}
+
+func NewDataExportRepository(db IDatabase) (*DataExportRepository, error) {
+	var err error
+
+	if db == nil {
+		db, err = database.GetPoolConnection()
+		if err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	return &DataExportRepository{
+		db: db,
+	}, nil
+}
+
+func (r *DataExportRepository) Create(export *model.DataExport) error {
+	res := r.db.Exec(`
+		INSERT INTO data_exports (id, user_id, status, created_at)
+		VALUES (?, ?, ?, ?);
+	`, export.Id, export.UserId, export.Status, export.CreatedAt.UTC())
+
+	return res.Error
+}
+
+func (r *DataExportRepository) Get(userId string, id string) (model.DataExport, error) {
+	var export model.DataExport
+
+	res := r.db.Raw(`
+		SELECT id, user_id, status, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE id = ? AND user_id = ?
+	`, id, userId).Scan(&export)
+
+	if res.Error != nil {
+		return model.DataExport{}, res.Error
+	}
+
+	return export, nil
+}
+
+func (r *DataExportRepository) GetPending(userId string) (model.DataExport, error) {
+	var export model.DataExport
+
+	res := r.db.Raw(`
+		SELECT id, user_id, status, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE user_id = ? AND status = ?
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userId, model.DataExportPending).Scan(&export)
+
+	if res.Error != nil {
+		return model.DataExport{}, res.Error
+	}
+
+	return export, nil
+}
+
+func (r *DataExportRepository) GetArchive(id string) ([]byte, error) {
+	var export struct{ Archive []byte }
+
+	res := r.db.Raw("SELECT archive FROM data_exports WHERE id = ? AND status = ?", id, model.DataExportReady).Scan(&export)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return export.Archive, nil
+}
+
+func (r *DataExportRepository) Complete(id string, archive []byte, expiresAt time.Time) error {
+	res := r.db.Exec(`
+		UPDATE data_exports
+		SET status = ?, archive = ?, completed_at = ?, expires_at = ?
+		WHERE id = ?
+	`, model.DataExportReady, archive, time.Now().UTC(), expiresAt.UTC(), id)
+
+	return res.Error
+}
+
+func (r *DataExportRepository) Fail(id string) error {
+	res := r.db.Exec(`
+		UPDATE data_exports
+		SET status = ?, completed_at = ?
+		WHERE id = ?
+	`, model.DataExportFailed, time.Now().UTC(), id)
+
+	return res.Error
+}
+
+func (r *DataExportRepository) DeleteExpired(now time.Time) error {
+	res := r.db.Exec("DELETE FROM data_exports WHERE expires_at <= ?", now.UTC())
+
+	return res.Error
+}
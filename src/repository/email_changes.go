@@ -0,0 +1,125 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+)
+
+// IEmailChangeRepository is an interface that contains the methods that will implement a repository struct that interact with the email_changes table.
+type IEmailChangeRepository interface {
+	// Create saves a new email change.
+	// change is the email change to save.
+	// It returns an error if the operation fails.
+	Create(change *model.EmailChange) error
+	// GetByConfirmToken gets an email change by the hash of its confirmation token.
+	// It returns an empty change if there is none, and an error if the operation fails.
+	GetByConfirmToken(tokenHash string) (model.EmailChange, error)
+	// GetByUndoToken gets an email change by the hash of its undo token.
+	// It returns an empty change if there is none, and an error if the operation fails.
+	GetByUndoToken(tokenHash string) (model.EmailChange, error)
+	// SetStatus updates the status of an email change.
+	// It returns an error if the operation fails.
+	SetStatus(id string, status string) error
+	// CancelPending cancels every pending email change of a user.
+	// It returns an error if the operation fails.
+	CancelPending(userId string) error
+}
+
+func init() {
+	RegisterEraser(UserDataEraser{Table: "email_changes", Order: EraseOrderDefault, Erase: deleteByUserId("email_changes")})
+	RegisterExporter(UserDataExporter{
+		Name:        "email_changes",
+		Description: "The changes of your email and their status",
+		Export: func(db IDatabase, userId string) (any, error) {
+			changes := make([]struct {
+				OldEmail  string    `json:"oldEmail"`
+				NewEmail  string    `json:"newEmail"`
+				Status    string    `json:"status"`
+				CreatedAt time.Time `json:"createdAt"`
+			}, 0)
+
+			res := db.Raw(`
+				SELECT old_email, new_email, status, created_at
+				FROM email_changes
+				WHERE user_id = ?
+				ORDER BY created_at ASC
+			`, userId).Scan(&changes)
+
+			return changes, res.Error
+		},
+	})
+}
+
+type EmailChangeRepository struct {
+	db IDatabase
+}
+
+func NewEmailChangeRepository(db IDatabase) (*EmailChangeRepository, error) {
+	var err error
+
+	if db == nil {
+		db, err = database.GetPoolConnection()
+		if err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	return &EmailChangeRepository{
+		db: db,
+	}, nil
+}
+
+func (r *EmailChangeRepository) Create(change *model.EmailChange) error {
+	res := r.db.Exec(`
+		INSERT INTO email_changes (id, user_id, old_email, new_email, confirm_token_hash, undo_token_hash, status, expires_at, undo_expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`,
+		change.Id, change.UserId, change.OldEmail, change.NewEmail, change.ConfirmTokenHash, change.UndoTokenHash,
+		change.Status, change.ExpiresAt.UTC(), change.UndoExpiresAt.UTC(),
+	)
+
+	return res.Error
+}
+
+func (r *EmailChangeRepository) getBy(column string, tokenHash string) (model.EmailChange, error) {
+	var change model.EmailChange
+
+	res := r.db.Raw(`
+		SELECT id, user_id, old_email, new_email, confirm_token_hash, undo_token_hash, status, expires_at, undo_expires_at
+		FROM email_changes
+		WHERE `+column+` = ?
+	`, tokenHash).Scan(&change)
+
+	if res.Error != nil {
+		return model.EmailChange{}, res.Error
+	}
+
+	return change, nil
+}
+
+func (r *EmailChangeRepository) GetByConfirmToken(tokenHash string) (model.EmailChange, error) {
+	return r.getBy("confirm_token_hash", tokenHash)
+}
+
+func (r *EmailChangeRepository) GetByUndoToken(tokenHash string) (model.EmailChange, error) {
+	return r.getBy("undo_token_hash", tokenHash)
+}
+
+func (r *EmailChangeRepository) SetStatus(id string, status string) error {
+	res := r.db.Exec("UPDATE email_changes SET status = ? WHERE id = ?", status, id)
+
+	return res.Error
+}
+
+func (r *EmailChangeRepository) CancelPending(userId string) error {
+	res := r.db.Exec(
+		"UPDATE email_changes SET status = ? WHERE user_id = ? AND status = ?",
+		model.EmailChangeCancelled, userId, model.EmailChangePending,
+	)
+
+	return res.Error
+}
@@ -0,0 +1,75 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// UserDataEraser deletes or anonymises the rows that belong to a user in one table.
+// Every repository registers an eraser for its table, so deleting an account erases all the data of the user.
+type UserDataEraser struct {
+	// Table is the name of the erased table, used in the logs.
+	Table string
+	// Order sorts the erasers, lower first. Tables referenced by others, like users, must be erased last.
+	Order int
+	// Erase deletes or anonymises the rows of the user.
+	Erase func(db IDatabase, userId string) error
+}
+
+// Order of the erasers of the tables that don't reference other tables.
+const (
+	EraseOrderDefault = 0
+	EraseOrderUsers   = 100
+)
+
+var (
+	erasersMu sync.Mutex
+	erasers   []UserDataEraser
+)
+
+// RegisterEraser adds an eraser to the registry, it's expected to be called from the init function of each repository.
+func RegisterEraser(eraser UserDataEraser) {
+	erasersMu.Lock()
+	defer erasersMu.Unlock()
+
+	erasers = append(erasers, eraser)
+	sort.SliceStable(erasers, func(i, j int) bool {
+		return erasers[i].Order < erasers[j].Order
+	})
+}
+
+// Erasers returns the registered erasers in the order they are run.
+func Erasers() []UserDataEraser {
+	erasersMu.Lock()
+	defer erasersMu.Unlock()
+
+	return append([]UserDataEraser{}, erasers...)
+}
+
+// EraseUser runs every registered eraser for a user in a single transaction.
+// db is the database the user is erased from.
+// It returns an error if any eraser fails, in which case nothing is erased.
+func EraseUser(db IDatabase, userId string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, eraser := range Erasers() {
+			if err := eraser.Erase(tx, userId); err != nil {
+				log.Errorf("Failed to erase the %s rows of the user %s: %v", eraser.Table, userId, err)
+				return err
+			}
+
+			log.Debugf("Erased the %s rows of the user %s", eraser.Table, userId)
+		}
+
+		return nil
+	})
+}
+
+// deleteByUserId returns an eraser function that deletes the rows of a table with a user_id column.
+func deleteByUserId(table string) func(db IDatabase, userId string) error {
+	return func(db IDatabase, userId string) error {
+		return db.Exec("DELETE FROM "+table+" WHERE user_id = ?", userId).Error
+	}
+}
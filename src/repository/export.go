@@ -0,0 +1,69 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"sort"
+	"sync"
+)
+
+// UserDataExporter reads the rows that belong to a user in one table, for the exports of the right of access.
+// Every repository registers an exporter for its table, so an export contains all the data of the user.
+type UserDataExporter struct {
+	// Name is the name of the file of the export, without the extension, e.g. account.
+	Name string
+	// Description tells the user what the data is, shown in the summary of the export.
+	Description string
+	// Export returns the data of the user, it's marshalled to JSON so it should only contain exported fields.
+	// Secrets, like password or token hashes, must be left out.
+	Export func(db IDatabase, userId string) (any, error)
+}
+
+var (
+	exportersMu sync.Mutex
+	exporters   []UserDataExporter
+)
+
+// RegisterExporter adds an exporter to the registry, it's expected to be called from the init function of each repository.
+func RegisterExporter(exporter UserDataExporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+
+	exporters = append(exporters, exporter)
+	sort.SliceStable(exporters, func(i, j int) bool {
+		return exporters[i].Name < exporters[j].Name
+	})
+}
+
+// Exporters returns the registered exporters sorted by name.
+func Exporters() []UserDataExporter {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+
+	return append([]UserDataExporter{}, exporters...)
+}
+
+// ExportedData is the data of a user returned by an exporter.
+type ExportedData struct {
+	Exporter UserDataExporter
+	Data     any
+}
+
+// ExportUser runs every registered exporter for a user.
+// db is the database the data is read from.
+// It returns the data of each exporter, sorted by name, and an error if any exporter fails.
+func ExportUser(db IDatabase, userId string) ([]ExportedData, error) {
+	registered := Exporters()
+	exported := make([]ExportedData, 0, len(registered))
+
+	for _, exporter := range registered {
+		data, err := exporter.Export(db, userId)
+		if err != nil {
+			log.Errorf("Failed to export the %s of the user %s: %v", exporter.Name, userId, err)
+			return nil, err
+		}
+
+		exported = append(exported, ExportedData{Exporter: exporter, Data: data})
+	}
+
+	return exported, nil
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"database/sql"
+
 	"github.com/op/go-logging"
 	"gorm.io/gorm"
 )
@@ -10,4 +12,5 @@ var log = logging.MustGetLogger("log")
 type IDatabase interface {
 	Exec(sql string, args ...interface{}) *gorm.DB
 	Raw(sql string, args ...interface{}) *gorm.DB
+	Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
 }
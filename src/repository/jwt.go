@@ -2,15 +2,13 @@
 package repository
 
 import (
-	"errors"
 	"time"
 
 	"github.com/NutriPocket/UserService/database"
 	"github.com/NutriPocket/UserService/model"
-	"github.com/go-sql-driver/mysql"
 )
 
-// IJWTRepository is an interface that contains the methods that will implement a repository struct that interact with the jwt_blacklist table.
+// IJWTRepository is an interface that contains the methods that will implement a repository struct that interact with the jwt_blacklist and jwt_revocations tables.
 type IJWTRepository interface {
 	// Blacklist adds a JWT signature to the blacklist table.
 	// signature is the JWT signature to blacklist.
@@ -22,6 +20,40 @@ type IJWTRepository interface {
 	// It returns true if the JWT signature is blacklisted, false otherwise.
 	// It returns an error if the operation fails.
 	IsBlacklisted(signature string) (bool, error)
+	// ListBlacklisted lists the signatures of the unexpired tokens blacklisted since a time, from the oldest to the newest.
+	// since is the time from which the blacklisted tokens are listed, the zero time lists all of them.
+	// It returns an error if the operation fails.
+	ListBlacklisted(since time.Time) ([]model.BlacklistedToken, error)
+	// RevokeAll invalidates every JWT of a user issued before a time.
+	// userId is the id of the user.
+	// validAfter is the time before which the tokens of the user are no longer valid.
+	// It returns an error if the operation fails.
+	RevokeAll(userId string, validAfter time.Time) error
+	// GetValidAfter returns the time before which the tokens of a user are no longer valid.
+	// userId is the id of the user.
+	// It returns the zero time if the tokens of the user were never revoked, and an error if the operation fails.
+	GetValidAfter(userId string) (time.Time, error)
+	// ListRevocations lists the revocations of the users whose tokens are valid after a time, from the oldest to the
+	// newest cutoff.
+	// since is the time from which the revocations are listed.
+	// It returns an error if the operation fails.
+	ListRevocations(since time.Time) ([]model.Revocation, error)
+}
+
+func init() {
+	RegisterExporter(UserDataExporter{
+		Name:        "sessions",
+		Description: "Since when your sessions are valid, the sessions started before were closed",
+		Export: func(db IDatabase, userId string) (any, error) {
+			var sessions struct {
+				ValidAfter *time.Time `json:"validAfter"`
+			}
+
+			res := db.Raw("SELECT valid_after FROM jwt_revocations WHERE user_id = ?", userId).Scan(&sessions)
+
+			return sessions, res.Error
+		},
+	})
 }
 
 type JWTRepository struct {
@@ -48,10 +80,10 @@ func (r *JWTRepository) Blacklist(signature string, expiresAt time.Time) error {
 	res := r.db.Exec(`
 		INSERT INTO jwt_blacklist (signature, expires_at)
 		VALUES (?, ?);
-	`, signature, expiresAt)
+	`, signature, expiresAt.UTC())
 
 	if res.Error != nil {
-		if errors.Is(res.Error, &mysql.MySQLError{Number: 1062}) {
+		if database.IsDuplicateKey(res.Error) {
 			return &model.EntityAlreadyExistsError{
 				Title:  "Token no longer used",
 				Detail: "The provided token is no longer in use",
@@ -79,3 +111,57 @@ func (r *JWTRepository) IsBlacklisted(signature string) (bool, error) {
 
 	return blacklistedJWT == struct{ Signature string }{Signature: signature}, nil
 }
+
+func (r *JWTRepository) ListBlacklisted(since time.Time) ([]model.BlacklistedToken, error) {
+	tokens := make([]model.BlacklistedToken, 0)
+
+	res := r.db.Raw(`
+		SELECT signature, created_at
+		FROM jwt_blacklist
+		WHERE created_at >= ? AND expires_at > ?
+		ORDER BY created_at
+	`, since.UTC(), time.Now().UTC()).Scan(&tokens)
+
+	return tokens, res.Error
+}
+
+func (r *JWTRepository) RevokeAll(userId string, validAfter time.Time) error {
+	dialect := database.DialectOf(r.db)
+
+	res := r.db.Exec(`
+		INSERT INTO jwt_revocations (user_id, valid_after)
+		VALUES (?, ?)
+		`+dialect.OnConflict("user_id")+` valid_after = `+dialect.Greatest("jwt_revocations.valid_after", dialect.Excluded("valid_after"))+`;
+	`, userId, validAfter.UTC())
+
+	return res.Error
+}
+
+func (r *JWTRepository) GetValidAfter(userId string) (time.Time, error) {
+	var revocation struct{ ValidAfter time.Time }
+
+	res := r.db.Raw(`
+		SELECT valid_after
+		FROM jwt_revocations
+		WHERE user_id = ?
+	`, userId).Scan(&revocation)
+
+	if res.Error != nil {
+		return time.Time{}, res.Error
+	}
+
+	return revocation.ValidAfter, nil
+}
+
+func (r *JWTRepository) ListRevocations(since time.Time) ([]model.Revocation, error) {
+	revocations := make([]model.Revocation, 0)
+
+	res := r.db.Raw(`
+		SELECT user_id, valid_after
+		FROM jwt_revocations
+		WHERE valid_after >= ?
+		ORDER BY valid_after
+	`, since.UTC()).Scan(&revocations)
+
+	return revocations, res.Error
+}
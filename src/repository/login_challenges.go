@@ -0,0 +1,106 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+)
+
+// ILoginChallengeRepository is an interface that contains the methods that will implement a repository struct that interact with the login_challenges table.
+type ILoginChallengeRepository interface {
+	// Create saves a new login challenge.
+	// challenge is the login challenge to save.
+	// It returns an error if the operation fails.
+	Create(challenge *model.LoginChallenge) error
+	// Get gets a login challenge by its id.
+	// It returns an empty challenge if there is none, and an error if the operation fails.
+	Get(id string) (model.LoginChallenge, error)
+	// RecordAttempt counts a wrong code sent for a login challenge.
+	// It returns an error if the operation fails.
+	RecordAttempt(id string) error
+	// MarkUsed marks a login challenge as used, so its code can't be sent again.
+	// It returns false if the challenge was already used, and an error if the operation fails.
+	MarkUsed(id string) (bool, error)
+}
+
+func init() {
+	RegisterEraser(UserDataEraser{Table: "login_challenges", Order: EraseOrderDefault, Erase: deleteByUserId("login_challenges")})
+	RegisterExporter(UserDataExporter{
+		Name:        "login_challenges",
+		Description: "The second factors asked to finish your suspicious logins",
+		Export: func(db IDatabase, userId string) (any, error) {
+			challenges := make([]struct {
+				Method    string     `json:"method"`
+				Attempts  int        `json:"attempts"`
+				CreatedAt time.Time  `json:"createdAt"`
+				UsedAt    *time.Time `json:"usedAt"`
+			}, 0)
+
+			res := db.Raw(`
+				SELECT method, attempts, created_at, used_at
+				FROM login_challenges
+				WHERE user_id = ?
+				ORDER BY created_at ASC
+			`, userId).Scan(&challenges)
+
+			return challenges, res.Error
+		},
+	})
+}
+
+type LoginChallengeRepository struct {
+	db IDatabase
+}
+
+func NewLoginChallengeRepository(db IDatabase) (*LoginChallengeRepository, error) {
+	var err error
+
+	if db == nil {
+		db, err = database.GetPoolConnection()
+		if err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	return &LoginChallengeRepository{
+		db: db,
+	}, nil
+}
+
+func (r *LoginChallengeRepository) Create(challenge *model.LoginChallenge) error {
+	res := r.db.Exec(`
+		INSERT INTO login_challenges (id, user_id, method, code_hash, expires_at)
+		VALUES (?, ?, ?, ?, ?);
+	`, challenge.Id, challenge.UserId, challenge.Method, challenge.CodeHash, challenge.ExpiresAt.UTC())
+
+	return res.Error
+}
+
+func (r *LoginChallengeRepository) Get(id string) (model.LoginChallenge, error) {
+	var challenge model.LoginChallenge
+
+	res := r.db.Raw(`
+		SELECT id, user_id, method, code_hash, attempts, expires_at, used_at
+		FROM login_challenges
+		WHERE id = ?
+	`, id).Scan(&challenge)
+
+	if res.Error != nil {
+		return model.LoginChallenge{}, res.Error
+	}
+
+	return challenge, nil
+}
+
+func (r *LoginChallengeRepository) RecordAttempt(id string) error {
+	return r.db.Exec("UPDATE login_challenges SET attempts = attempts + 1 WHERE id = ?", id).Error
+}
+
+func (r *LoginChallengeRepository) MarkUsed(id string) (bool, error) {
+	res := r.db.Exec("UPDATE login_challenges SET used_at = ? WHERE id = ? AND used_at IS NULL", time.Now().UTC(), id)
+
+	return res.RowsAffected == 1, res.Error
+}
@@ -0,0 +1,134 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+)
+
+// ILoginHistoryRepository is an interface that contains the methods that will implement a repository struct that interact with the login_history table.
+type ILoginHistoryRepository interface {
+	// Create saves a successful login.
+	// login is the login to save.
+	// It returns an error if the operation fails.
+	Create(login *model.Login) error
+	// List lists the logins of a user, the newest first.
+	// limit is the maximum number of logins returned.
+	// It returns an error if the operation fails.
+	List(userId string, limit int) ([]model.Login, error)
+	// Devices counts the logins of a user, in total and from a device.
+	// fingerprint is the fingerprint of the device.
+	// It returns an error if the operation fails.
+	Devices(userId string, fingerprint string) (total int64, fromDevice int64, err error)
+	// FromCountry counts the logins of a user from a country.
+	// country is the ISO 3166-1 alpha-2 code of the country.
+	// It returns an error if the operation fails.
+	FromCountry(userId string, country string) (int64, error)
+	// Last returns the last login of a user.
+	// It returns an empty login if the user never logged in, and an error if the operation fails.
+	Last(userId string) (model.Login, error)
+}
+
+const loginColumns = "id, user_id, device_fingerprint, user_agent, ip, country, region, city, latitude, longitude, new_device, created_at"
+
+func init() {
+	RegisterEraser(UserDataEraser{Table: "login_history", Order: EraseOrderDefault, Erase: deleteByUserId("login_history")})
+	RegisterExporter(UserDataExporter{
+		Name:        "login_history",
+		Description: "Your logins, with the device, the IP and the approximate location",
+		Export: func(db IDatabase, userId string) (any, error) {
+			logins := make([]model.Login, 0)
+
+			res := db.Raw(`
+				SELECT `+loginColumns+`
+				FROM login_history
+				WHERE user_id = ?
+				ORDER BY created_at ASC
+			`, userId).Scan(&logins)
+
+			return logins, res.Error
+		},
+	})
+}
+
+type LoginHistoryRepository struct {
+	db IDatabase
+}
+
+func NewLoginHistoryRepository(db IDatabase) (*LoginHistoryRepository, error) {
+	var err error
+
+	if db == nil {
+		db, err = database.GetPoolConnection()
+		if err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	return &LoginHistoryRepository{
+		db: db,
+	}, nil
+}
+
+func (r *LoginHistoryRepository) Create(login *model.Login) error {
+	res := r.db.Exec(`
+		INSERT INTO login_history (user_id, device_fingerprint, user_agent, ip, country, region, city, latitude, longitude, new_device, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`,
+		login.UserId, login.DeviceFingerprint, login.UserAgent, login.IP, login.Country, login.Region, login.City,
+		login.Latitude, login.Longitude, login.NewDevice, login.CreatedAt.UTC(),
+	)
+
+	return res.Error
+}
+
+func (r *LoginHistoryRepository) List(userId string, limit int) ([]model.Login, error) {
+	logins := make([]model.Login, 0)
+
+	res := r.db.Raw(`
+		SELECT `+loginColumns+`
+		FROM login_history
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, userId, limit).Scan(&logins)
+
+	return logins, res.Error
+}
+
+func (r *LoginHistoryRepository) Devices(userId string, fingerprint string) (int64, int64, error) {
+	var count struct {
+		Total      int64
+		FromDevice int64
+	}
+
+	res := r.db.Raw(`
+		SELECT COUNT(*) AS total, COALESCE(SUM(device_fingerprint = ?), 0) AS from_device
+		FROM login_history
+		WHERE user_id = ?
+	`, fingerprint, userId).Scan(&count)
+
+	return count.Total, count.FromDevice, res.Error
+}
+
+func (r *LoginHistoryRepository) FromCountry(userId string, country string) (int64, error) {
+	var count int64
+
+	res := r.db.Raw(`
+		SELECT COUNT(*)
+		FROM login_history
+		WHERE user_id = ? AND country = ?
+	`, userId, country).Scan(&count)
+
+	return count, res.Error
+}
+
+func (r *LoginHistoryRepository) Last(userId string) (model.Login, error) {
+	logins, err := r.List(userId, 1)
+	if err != nil || len(logins) == 0 {
+		return model.Login{}, err
+	}
+
+	return logins[0], nil
+}
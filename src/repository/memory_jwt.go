@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+)
+
+// memoryBlacklistedToken is a row of the jwt_blacklist table of a MemoryJWTRepository.
+type memoryBlacklistedToken struct {
+	model.BlacklistedToken
+	expiresAt time.Time
+}
+
+// MemoryJWTRepository keeps the blacklisted tokens and the revocations in memory, used in the tests of the services.
+// It behaves as JWTRepository, as checked by repositorytest.TestJWTRepository.
+type MemoryJWTRepository struct {
+	mu sync.RWMutex
+	// blacklist is ordered from the oldest to the newest token.
+	blacklist   []memoryBlacklistedToken
+	validAfters map[string]time.Time
+}
+
+func NewMemoryJWTRepository() *MemoryJWTRepository {
+	return &MemoryJWTRepository{validAfters: make(map[string]time.Time)}
+}
+
+func (r *MemoryJWTRepository) Blacklist(signature string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if slices.ContainsFunc(r.blacklist, func(token memoryBlacklistedToken) bool { return token.Signature == signature }) {
+		return &model.EntityAlreadyExistsError{
+			Title:  "Token no longer used",
+			Detail: "The provided token is no longer in use",
+		}
+	}
+
+	r.blacklist = append(r.blacklist, memoryBlacklistedToken{
+		BlacklistedToken: model.BlacklistedToken{Signature: signature, CreatedAt: time.Now().UTC()},
+		expiresAt:        expiresAt.UTC(),
+	})
+
+	return nil
+}
+
+func (r *MemoryJWTRepository) IsBlacklisted(signature string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.ContainsFunc(r.blacklist, func(token memoryBlacklistedToken) bool { return token.Signature == signature }), nil
+}
+
+func (r *MemoryJWTRepository) ListBlacklisted(since time.Time) ([]model.BlacklistedToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	tokens := make([]model.BlacklistedToken, 0)
+
+	for _, token := range r.blacklist {
+		if !token.CreatedAt.Before(since) && token.expiresAt.After(now) {
+			tokens = append(tokens, token.BlacklistedToken)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (r *MemoryJWTRepository) RevokeAll(userId string, validAfter time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// As the database, a revocation never moves the time back.
+	if current, ok := r.validAfters[userId]; !ok || validAfter.After(current) {
+		r.validAfters[userId] = validAfter.UTC()
+	}
+
+	return nil
+}
+
+func (r *MemoryJWTRepository) GetValidAfter(userId string) (time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.validAfters[userId], nil
+}
+
+func (r *MemoryJWTRepository) ListRevocations(since time.Time) ([]model.Revocation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revocations := make([]model.Revocation, 0)
+
+	for userId, validAfter := range r.validAfters {
+		if !validAfter.Before(since) {
+			revocations = append(revocations, model.Revocation{UserId: userId, ValidAfter: validAfter})
+		}
+	}
+
+	slices.SortFunc(revocations, func(a, b model.Revocation) int { return a.ValidAfter.Compare(b.ValidAfter) })
+
+	return revocations, nil
+}
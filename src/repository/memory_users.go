@@ -0,0 +1,427 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/search"
+	"github.com/google/uuid"
+)
+
+// memoryUser is a row of the users table of a MemoryUserRepository.
+type memoryUser struct {
+	model.AdminUser
+	password       string
+	failedLogins   int
+	stateChangedAt time.Time
+}
+
+// MemoryUserRepository keeps the users in memory, used in the tests of the services.
+// It behaves as UserRepository, e.g. the usernames and emails are unique ignoring case, as checked by
+// repositorytest.TestUserRepository.
+type MemoryUserRepository struct {
+	mu sync.RWMutex
+	// users are ordered from the oldest to the newest.
+	users []*memoryUser
+	// index is the search index of the users of this repository, apart from the process wide one.
+	index *search.Index
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{index: search.NewIndex()}
+}
+
+// find returns the user of an id, nil if it doesn't exist.
+func (r *MemoryUserRepository) find(userId string) *memoryUser {
+	for _, user := range r.users {
+		if user.Id == userId {
+			return user
+		}
+	}
+
+	return nil
+}
+
+// taken returns true if a user other than userId has the username or the email, ignoring case.
+func (r *MemoryUserRepository) taken(userId string, username string, email string) bool {
+	for _, user := range r.users {
+		if user.Id == userId {
+			continue
+		}
+
+		if (username != "" && strings.EqualFold(user.Username, username)) || (email != "" && strings.EqualFold(user.Email, email)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *MemoryUserRepository) CreateUser(userData *model.BaseUser) (model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.taken("", userData.Username, userData.Email) {
+		return model.User{}, &model.EntityAlreadyExistsError{
+			Title:  "Username or email already in use",
+			Detail: "The provided username or email are already in use, try something else",
+		}
+	}
+
+	user := &memoryUser{password: userData.Password}
+	user.Id = uuid.NewString()
+	user.Username = userData.Username
+	user.Email = userData.Email
+	user.State = model.AccountActive
+	user.Role = model.RoleUser
+	user.CreatedAt = time.Now().UTC()
+
+	r.users = append(r.users, user)
+
+	created := model.User{Id: user.Id, Username: user.Username, Email: user.Email}
+	r.index.Upsert(userDocument(created))
+
+	return created, nil
+}
+
+func (r *MemoryUserRepository) GetUser(username string) (model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, username) && user.State == model.AccountActive {
+			return user.User, nil
+		}
+	}
+
+	return model.User{}, nil
+}
+
+func (r *MemoryUserRepository) GetUserById(userId string) (model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if user := r.find(userId); user != nil {
+		return user.User, nil
+	}
+
+	return model.User{}, nil
+}
+
+func (r *MemoryUserRepository) GetUserWithPassword(emailOrUsername string) (model.SavedUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, emailOrUsername) || strings.EqualFold(user.Email, emailOrUsername) {
+			return model.SavedUser{
+				BaseUser:     model.BaseUser{Username: user.Username, Email: user.Email, Password: user.password},
+				Id:           user.Id,
+				AccountState: user.AccountState,
+				FailedLogins: user.failedLogins,
+				LockedUntil:  user.LockedUntil,
+			}, nil
+		}
+	}
+
+	return model.SavedUser{}, nil
+}
+
+func (r *MemoryUserRepository) GetAllUsers(params model.GetUsersParams) ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]model.User, 0)
+
+	if params.SearchUsername != "" && r.index.Ready() {
+		for _, result := range r.index.Search(params.SearchUsername, params.Limit) {
+			if user := r.find(result.Id); user != nil && user.State == model.AccountActive {
+				users = append(users, user.User)
+			}
+		}
+
+		return users, nil
+	}
+
+	// As the LIKE of the database, the search matches any part of the username or the display name ignoring case.
+	search := strings.ToLower(params.SearchUsername)
+
+	for _, user := range slices.Backward(r.users) {
+		if params.Limit > 0 && len(users) == params.Limit {
+			break
+		}
+
+		if user.State != model.AccountActive {
+			continue
+		}
+
+		if strings.Contains(strings.ToLower(user.Username), search) || strings.Contains(strings.ToLower(user.DisplayName), search) {
+			users = append(users, user.User)
+		}
+	}
+
+	return users, nil
+}
+
+func (r *MemoryUserRepository) UpdateUser(userId string, userData *model.EditableUser) (model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user := r.find(userId)
+	if user == nil {
+		return model.User{}, nil
+	}
+
+	user.EditableUser = *userData
+	r.index.Upsert(userDocument(user.User))
+
+	return user.User, nil
+}
+
+func (r *MemoryUserRepository) ChangeUsername(userId string, username string) (model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.taken(userId, username, "") {
+		return model.User{}, &model.EntityAlreadyExistsError{
+			Title:  "Username already in use",
+			Detail: "The provided username is already in use, try something else",
+		}
+	}
+
+	user := r.find(userId)
+	if user == nil {
+		return model.User{}, nil
+	}
+
+	user.Username = username
+	r.index.Upsert(userDocument(user.User))
+
+	return user.User, nil
+}
+
+func (r *MemoryUserRepository) ChangeEmail(userId string, email string) (model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.taken(userId, "", email) {
+		return model.User{}, &model.EntityAlreadyExistsError{
+			Title:  "Email already in use",
+			Detail: "The provided email is already in use, try something else",
+		}
+	}
+
+	user := r.find(userId)
+	if user == nil {
+		return model.User{}, nil
+	}
+
+	user.Email = email
+	user.EmailVerified = true
+
+	return user.User, nil
+}
+
+func (r *MemoryUserRepository) GetAccountState(userId string) (model.AccountState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if user := r.find(userId); user != nil {
+		return user.AccountState, nil
+	}
+
+	return model.AccountState{}, nil
+}
+
+func (r *MemoryUserRepository) SetAccountState(userId string, state model.AccountState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user := r.find(userId)
+	if user == nil {
+		return nil
+	}
+
+	if state.Until != nil {
+		until := state.Until.UTC()
+		state.Until = &until
+	}
+
+	user.AccountState = state
+	user.stateChangedAt = time.Now().UTC()
+
+	if state.State != model.AccountActive {
+		r.index.Remove(userId)
+	} else {
+		r.index.Upsert(userDocument(user.User))
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepository) ListInactiveAccountStates() ([]model.UserAccountState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := make([]model.UserAccountState, 0)
+	for _, user := range r.users {
+		if user.State != model.AccountActive {
+			states = append(states, model.UserAccountState{UserId: user.Id, AccountState: user.AccountState})
+		}
+	}
+
+	return states, nil
+}
+
+func (r *MemoryUserRepository) ListAccountStateChanges(since time.Time) ([]model.UserAccountState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := make([]model.UserAccountState, 0)
+	for _, user := range r.users {
+		if !user.stateChangedAt.IsZero() && !user.stateChangedAt.Before(since) {
+			states = append(states, model.UserAccountState{UserId: user.Id, AccountState: user.AccountState, ChangedAt: user.stateChangedAt})
+		}
+	}
+
+	slices.SortFunc(states, func(a, b model.UserAccountState) int { return a.ChangedAt.Compare(b.ChangedAt) })
+
+	return states, nil
+}
+
+func (r *MemoryUserRepository) GetExpiredAccountStates(state string, now time.Time) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0)
+	for _, user := range r.users {
+		if user.State == state && user.Until != nil && !user.Until.After(now) {
+			ids = append(ids, user.Id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (r *MemoryUserRepository) GetRole(userId string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if user := r.find(userId); user != nil {
+		return user.Role, nil
+	}
+
+	return "", nil
+}
+
+func (r *MemoryUserRepository) GetAdminUsers(params model.GetAdminUsersParams) ([]model.AdminUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]model.AdminUser, 0)
+	skipped := 0
+
+	for _, user := range slices.Backward(r.users) {
+		if params.Limit > 0 && len(users) == params.Limit {
+			break
+		}
+
+		if (params.State != "" && user.State != params.State) || (params.Email != "" && !strings.EqualFold(user.Email, params.Email)) {
+			continue
+		}
+
+		if skipped < params.Offset {
+			skipped++
+			continue
+		}
+
+		users = append(users, user.AdminUser)
+	}
+
+	return users, nil
+}
+
+func (r *MemoryUserRepository) GetAdminUser(userId string) (model.AdminUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if user := r.find(userId); user != nil {
+		return user.AdminUser, nil
+	}
+
+	return model.AdminUser{}, nil
+}
+
+// update runs fn on the user of an id, if it exists.
+func (r *MemoryUserRepository) update(userId string, fn func(user *memoryUser)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user := r.find(userId); user != nil {
+		fn(user)
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepository) SetRole(userId string, role string) error {
+	return r.update(userId, func(user *memoryUser) { user.Role = role })
+}
+
+func (r *MemoryUserRepository) SetEmailVerified(userId string, verified bool) error {
+	return r.update(userId, func(user *memoryUser) { user.EmailVerified = verified })
+}
+
+func (r *MemoryUserRepository) SetPassword(userId string, password string) error {
+	return r.update(userId, func(user *memoryUser) { user.password = password })
+}
+
+func (r *MemoryUserRepository) RecordFailedLogin(userId string, maxFailures int, lockedUntil time.Time) error {
+	return r.update(userId, func(user *memoryUser) {
+		if user.failedLogins+1 < maxFailures {
+			user.failedLogins++
+			return
+		}
+
+		until := lockedUntil.UTC()
+		user.LockedUntil = &until
+		user.failedLogins = 0
+	})
+}
+
+func (r *MemoryUserRepository) Unlock(userId string) error {
+	return r.update(userId, func(user *memoryUser) {
+		user.failedLogins = 0
+		user.LockedUntil = nil
+	})
+}
+
+func (r *MemoryUserRepository) IndexUsers() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	docs := make([]search.Document, 0, len(r.users))
+	for _, user := range r.users {
+		if user.State == model.AccountActive {
+			docs = append(docs, userDocument(user.User))
+		}
+	}
+
+	r.index.Load(docs)
+
+	return nil
+}
+
+// Primary returns the repository itself, which has no replicas.
+func (r *MemoryUserRepository) Primary() IUserRepository {
+	return r
+}
+
+// WithContext returns the repository itself, which has no replicas.
+func (r *MemoryUserRepository) WithContext(ctx context.Context) IUserRepository {
+	return r
+}
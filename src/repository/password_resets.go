@@ -0,0 +1,109 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+)
+
+// IPasswordResetRepository is an interface that contains the methods that will implement a repository struct that interact with the password_resets table.
+type IPasswordResetRepository interface {
+	// Create saves a new password reset.
+	// reset is the password reset to save.
+	// It returns an error if the operation fails.
+	Create(reset *model.PasswordReset) error
+	// GetByToken gets a password reset by the hash of its token.
+	// It returns an empty reset if there is none, and an error if the operation fails.
+	GetByToken(tokenHash string) (model.PasswordReset, error)
+	// MarkUsed marks a password reset as used, so its link can't be opened again.
+	// It returns an error if the operation fails.
+	MarkUsed(id string) error
+	// CancelPending marks every unused password reset of a user as used.
+	// It returns an error if the operation fails.
+	CancelPending(userId string) error
+}
+
+func init() {
+	RegisterEraser(UserDataEraser{Table: "password_resets", Order: EraseOrderDefault, Erase: deleteByUserId("password_resets")})
+	RegisterExporter(UserDataExporter{
+		Name:        "password_resets",
+		Description: "The password reset links sent to you",
+		Export: func(db IDatabase, userId string) (any, error) {
+			resets := make([]struct {
+				CreatedAt time.Time  `json:"createdAt"`
+				ExpiresAt time.Time  `json:"expiresAt"`
+				UsedAt    *time.Time `json:"usedAt"`
+			}, 0)
+
+			res := db.Raw(`
+				SELECT created_at, expires_at, used_at
+				FROM password_resets
+				WHERE user_id = ?
+				ORDER BY created_at ASC
+			`, userId).Scan(&resets)
+
+			return resets, res.Error
+		},
+	})
+}
+
+type PasswordResetRepository struct {
+	db IDatabase
+}
+
+func NewPasswordResetRepository(db IDatabase) (*PasswordResetRepository, error) {
+	var err error
+
+	if db == nil {
+		db, err = database.GetPoolConnection()
+		if err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	return &PasswordResetRepository{
+		db: db,
+	}, nil
+}
+
+func (r *PasswordResetRepository) Create(reset *model.PasswordReset) error {
+	res := r.db.Exec(`
+		INSERT INTO password_resets (id, user_id, token_hash, expires_at)
+		VALUES (?, ?, ?, ?);
+	`, reset.Id, reset.UserId, reset.TokenHash, reset.ExpiresAt.UTC())
+
+	return res.Error
+}
+
+func (r *PasswordResetRepository) GetByToken(tokenHash string) (model.PasswordReset, error) {
+	var reset model.PasswordReset
+
+	res := r.db.Raw(`
+		SELECT id, user_id, token_hash, expires_at, used_at
+		FROM password_resets
+		WHERE token_hash = ?
+	`, tokenHash).Scan(&reset)
+
+	if res.Error != nil {
+		return model.PasswordReset{}, res.Error
+	}
+
+	return reset, nil
+}
+
+func (r *PasswordResetRepository) MarkUsed(id string) error {
+	return r.db.Exec("UPDATE password_resets SET used_at = ? WHERE id = ?", time.Now().UTC(), id).Error
+}
+
+func (r *PasswordResetRepository) CancelPending(userId string) error {
+	res := r.db.Exec(`
+		UPDATE password_resets
+		SET used_at = ?
+		WHERE user_id = ? AND used_at IS NULL
+	`, time.Now().UTC(), userId)
+
+	return res.Error
+}
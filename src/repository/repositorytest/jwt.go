@@ -0,0 +1,119 @@
+package repositorytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func signatures(tokens []model.BlacklistedToken) []string {
+	ret := make([]string, len(tokens))
+	for i, token := range tokens {
+		ret[i] = token.Signature
+	}
+	return ret
+}
+
+// TestJWTRepository runs the conformance tests of IJWTRepository.
+// newRepositories returns a repository without tokens and the repository of the users the tokens are revoked for,
+// it's called once per test.
+func TestJWTRepository(t *testing.T, newRepositories func(t *testing.T) (repository.IJWTRepository, repository.IUserRepository)) {
+	t.Run("It should blacklist a signature only once", func(t *testing.T) {
+		r, _ := newRepositories(t)
+
+		blacklisted, err := r.IsBlacklisted("signature")
+		assert.NoError(t, err)
+		assert.False(t, blacklisted)
+
+		assert.NoError(t, r.Blacklist("signature", time.Now().Add(time.Hour)))
+
+		blacklisted, err = r.IsBlacklisted("signature")
+		assert.NoError(t, err)
+		assert.True(t, blacklisted)
+
+		err = r.Blacklist("signature", time.Now().Add(time.Hour))
+		assert.IsType(t, &model.EntityAlreadyExistsError{}, err)
+	})
+
+	t.Run("It should list the unexpired blacklisted signatures since a time, from the oldest to the newest", func(t *testing.T) {
+		r, _ := newRepositories(t)
+
+		assert.NoError(t, r.Blacklist("old", time.Now().Add(time.Hour)))
+		assert.NoError(t, r.Blacklist("expired", time.Now().Add(-time.Hour)))
+		// Some databases keep the creation time in milliseconds.
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, r.Blacklist("new", time.Now().Add(time.Hour)))
+
+		tokens, err := r.ListBlacklisted(time.Time{})
+		assert.NoError(t, err)
+		if !assert.Equal(t, []string{"old", "new"}, signatures(tokens)) {
+			return
+		}
+
+		tokens, err = r.ListBlacklisted(tokens[1].CreatedAt)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"new"}, signatures(tokens), "the tokens blacklisted at the time are listed")
+
+		tokens, err = r.ListBlacklisted(time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Empty(t, tokens)
+	})
+
+	t.Run("It should keep the latest revocation of the tokens of a user", func(t *testing.T) {
+		r, users := newRepositories(t)
+		user := createUser(t, users, "test")
+
+		validAfter, err := r.GetValidAfter(user.Id)
+		assert.NoError(t, err)
+		assert.True(t, validAfter.IsZero(), "the tokens of the user were never revoked")
+
+		latest := time.Now()
+		assert.NoError(t, r.RevokeAll(user.Id, latest))
+		assert.NoError(t, r.RevokeAll(user.Id, latest.Add(-time.Hour)))
+
+		validAfter, err = r.GetValidAfter(user.Id)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, latest, validAfter, time.Millisecond)
+
+		later := latest.Add(time.Minute)
+		assert.NoError(t, r.RevokeAll(user.Id, later))
+
+		validAfter, err = r.GetValidAfter(user.Id)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, later, validAfter, time.Millisecond)
+
+		validAfter, err = r.GetValidAfter(uuid.NewString())
+		assert.NoError(t, err)
+		assert.True(t, validAfter.IsZero())
+	})
+	t.Run("It should list the revocations since a time, from the oldest to the newest", func(t *testing.T) {
+		r, users := newRepositories(t)
+		first := createUser(t, users, "first")
+		second := createUser(t, users, "second")
+		now := time.Now().UTC()
+
+		assert.NoError(t, r.RevokeAll(second.Id, now.Add(-time.Hour)))
+		assert.NoError(t, r.RevokeAll(first.Id, now.Add(-2*time.Hour)))
+
+		revocations, err := r.ListRevocations(now.Add(-3 * time.Hour))
+		assert.NoError(t, err)
+		if assert.Len(t, revocations, 2) {
+			assert.Equal(t, first.Id, revocations[0].UserId)
+			assert.Equal(t, second.Id, revocations[1].UserId)
+			assert.WithinDuration(t, now.Add(-time.Hour), revocations[1].ValidAfter, time.Millisecond)
+		}
+
+		assert.NoError(t, r.RevokeAll(first.Id, now))
+
+		revocations, err = r.ListRevocations(now.Add(-time.Hour))
+		assert.NoError(t, err)
+		if assert.Len(t, revocations, 2) {
+			assert.Equal(t, second.Id, revocations[0].UserId)
+			assert.Equal(t, first.Id, revocations[1].UserId, "the revocations moved forward are listed again")
+		}
+	})
+}
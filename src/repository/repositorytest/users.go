@@ -0,0 +1,451 @@
+// Package repositorytest provides the conformance tests every implementation of the repositories must pass, so the
+// in-memory ones used in the tests of the services behave as the ones of the databases.
+package repositorytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// createUser creates a user with the username, whose email is the username at test.com.
+func createUser(t *testing.T, r repository.IUserRepository, username string) model.User {
+	t.Helper()
+
+	user, err := r.CreateUser(&model.BaseUser{Username: username, Email: username + "@test.com", Password: "hashed"})
+	if err != nil {
+		t.Fatalf("Failed to create the user %s: %v", username, err)
+	}
+
+	return user
+}
+
+func usernames[T any](users []T, username func(T) string) []string {
+	ret := make([]string, len(users))
+	for i, user := range users {
+		ret[i] = username(user)
+	}
+	return ret
+}
+
+func userUsername(user model.User) string {
+	return user.Username
+}
+
+func adminUserUsername(user model.AdminUser) string {
+	return user.Username
+}
+
+// TestUserRepository runs the conformance tests of IUserRepository.
+// newRepository returns a repository without users, it's called once per test.
+// The searches are tested after IndexUsers, as the users are ranked by relevance once the index is loaded.
+func TestUserRepository(t *testing.T, newRepository func(t *testing.T) repository.IUserRepository) {
+	t.Run("It should create an active user", func(t *testing.T) {
+		r := newRepository(t)
+
+		user := createUser(t, r, "test")
+		assert.NotEmpty(t, user.Id)
+		assert.Equal(t, "test", user.Username)
+		assert.Equal(t, "test@test.com", user.Email)
+
+		saved, err := r.GetUserWithPassword("test")
+		assert.NoError(t, err)
+		assert.Equal(t, user.Id, saved.Id)
+		assert.Equal(t, "hashed", saved.Password)
+		assert.Equal(t, model.AccountActive, saved.State)
+		assert.Zero(t, saved.FailedLogins)
+		assert.Nil(t, saved.LockedUntil)
+
+		admin, err := r.GetAdminUser(user.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, model.RoleUser, admin.Role)
+		assert.False(t, admin.EmailVerified)
+		assert.WithinDuration(t, time.Now(), admin.CreatedAt, time.Minute)
+	})
+
+	t.Run("It should reject a username or an email already in use, ignoring case", func(t *testing.T) {
+		r := newRepository(t)
+		createUser(t, r, "test")
+
+		_, err := r.CreateUser(&model.BaseUser{Username: "TEST", Email: "other@test.com", Password: "hashed"})
+		assert.IsType(t, &model.EntityAlreadyExistsError{}, err)
+
+		_, err = r.CreateUser(&model.BaseUser{Username: "other", Email: "Test@Test.com", Password: "hashed"})
+		assert.IsType(t, &model.EntityAlreadyExistsError{}, err)
+
+		users, err := r.GetAllUsers(model.GetUsersParams{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"test"}, usernames(users, userUsername))
+	})
+
+	t.Run("It should find a user by username, email or id, ignoring the case of the username and email", func(t *testing.T) {
+		r := newRepository(t)
+		user := createUser(t, r, "test")
+
+		found, err := r.GetUser("Test")
+		assert.NoError(t, err)
+		assert.Equal(t, user.Id, found.Id)
+
+		found, err = r.GetUserById(user.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "test", found.Username)
+
+		saved, err := r.GetUserWithPassword("TEST@test.com")
+		assert.NoError(t, err)
+		assert.Equal(t, user.Id, saved.Id)
+	})
+
+	t.Run("It should return empty results for users that don't exist", func(t *testing.T) {
+		r := newRepository(t)
+		createUser(t, r, "test")
+		unknownId := uuid.NewString()
+
+		user, err := r.GetUser("unknown")
+		assert.NoError(t, err)
+		assert.Empty(t, user.Id)
+
+		user, err = r.GetUserById(unknownId)
+		assert.NoError(t, err)
+		assert.Empty(t, user.Id)
+
+		saved, err := r.GetUserWithPassword("unknown@test.com")
+		assert.NoError(t, err)
+		assert.Empty(t, saved.Id)
+
+		state, err := r.GetAccountState(unknownId)
+		assert.NoError(t, err)
+		assert.Empty(t, state.State)
+
+		role, err := r.GetRole(unknownId)
+		assert.NoError(t, err)
+		assert.Empty(t, role)
+
+		admin, err := r.GetAdminUser(unknownId)
+		assert.NoError(t, err)
+		assert.Empty(t, admin.Id)
+
+		user, err = r.UpdateUser(unknownId, &model.EditableUser{DisplayName: "Unknown"})
+		assert.NoError(t, err)
+		assert.Empty(t, user.Id)
+	})
+
+	t.Run("It should list the active users from the newest to the oldest", func(t *testing.T) {
+		r := newRepository(t)
+		createUser(t, r, "first")
+		second := createUser(t, r, "second")
+		createUser(t, r, "third")
+
+		users, err := r.GetAllUsers(model.GetUsersParams{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"third", "second", "first"}, usernames(users, userUsername))
+
+		users, err = r.GetAllUsers(model.GetUsersParams{Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"third", "second"}, usernames(users, userUsername))
+
+		assert.NoError(t, r.SetAccountState(second.Id, model.AccountState{State: model.AccountSuspended}))
+
+		users, err = r.GetAllUsers(model.GetUsersParams{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"third", "first"}, usernames(users, userUsername))
+
+		user, err := r.GetUser("second")
+		assert.NoError(t, err)
+		assert.Empty(t, user.Id, "only the active users are visible by username")
+
+		user, err = r.GetUserById(second.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", user.Username, "every user is found by id")
+	})
+
+	t.Run("It should rank the searched users by relevance, ignoring case, accents and typos", func(t *testing.T) {
+		r := newRepository(t)
+		jorge := createUser(t, r, "jorge")
+		maria := createUser(t, r, "maria")
+		createUser(t, r, "pedro")
+
+		_, err := r.UpdateUser(jorge.Id, &model.EditableUser{DisplayName: "Jorge Pérez"})
+		assert.NoError(t, err)
+		_, err = r.UpdateUser(maria.Id, &model.EditableUser{DisplayName: "María Gómez"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, r.IndexUsers())
+
+		for _, query := range []string{"JORGE", "jorje", "perez"} {
+			users, err := r.GetAllUsers(model.GetUsersParams{SearchUsername: query})
+			assert.NoError(t, err)
+			if assert.NotEmpty(t, users, query) {
+				assert.Equal(t, "jorge", users[0].Username, query)
+			}
+		}
+
+		users, err := r.GetAllUsers(model.GetUsersParams{SearchUsername: "gomez", Limit: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"maria"}, usernames(users, userUsername))
+	})
+
+	t.Run("It should keep the search up to date with the changes of the users", func(t *testing.T) {
+		r := newRepository(t)
+		jorge := createUser(t, r, "jorge")
+		assert.NoError(t, r.IndexUsers())
+
+		createUser(t, r, "rodolfo")
+		users, err := r.GetAllUsers(model.GetUsersParams{SearchUsername: "rodolfo"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"rodolfo"}, usernames(users, userUsername), "the new users are searchable")
+
+		_, err = r.ChangeUsername(jorge.Id, "gaspar")
+		assert.NoError(t, err)
+		users, err = r.GetAllUsers(model.GetUsersParams{SearchUsername: "gaspar"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"gaspar"}, usernames(users, userUsername), "the renamed users are searchable")
+
+		assert.NoError(t, r.SetAccountState(jorge.Id, model.AccountState{State: model.AccountBanned}))
+		users, err = r.GetAllUsers(model.GetUsersParams{SearchUsername: "gaspar"})
+		assert.NoError(t, err)
+		assert.Empty(t, users, "the users that aren't active aren't searchable")
+
+		assert.NoError(t, r.SetAccountState(jorge.Id, model.AccountState{State: model.AccountActive}))
+		users, err = r.GetAllUsers(model.GetUsersParams{SearchUsername: "gaspar"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"gaspar"}, usernames(users, userUsername), "the reactivated users are searchable again")
+	})
+
+	t.Run("It should update and clear the editable data of a user", func(t *testing.T) {
+		r := newRepository(t)
+		user := createUser(t, r, "test")
+		picture := model.Picture{Small: "small.png", Medium: "medium.png", Large: "large.png"}
+
+		updated, err := r.UpdateUser(user.Id, &model.EditableUser{Picture: picture, DisplayName: "Test User"})
+		assert.NoError(t, err)
+		assert.Equal(t, "test", updated.Username)
+		assert.Equal(t, picture, updated.Picture)
+		assert.Equal(t, "Test User", updated.DisplayName)
+
+		found, err := r.GetUser("test")
+		assert.NoError(t, err)
+		assert.Equal(t, updated, found)
+
+		updated, err = r.UpdateUser(user.Id, &model.EditableUser{})
+		assert.NoError(t, err)
+		assert.True(t, updated.Picture.IsZero())
+		assert.Empty(t, updated.DisplayName)
+	})
+
+	t.Run("It should change the username unless another user has it", func(t *testing.T) {
+		r := newRepository(t)
+		user := createUser(t, r, "test")
+		createUser(t, r, "other")
+
+		_, err := r.ChangeUsername(user.Id, "OTHER")
+		assert.IsType(t, &model.EntityAlreadyExistsError{}, err)
+
+		changed, err := r.ChangeUsername(user.Id, "Test")
+		assert.NoError(t, err, "the case of the own username can be changed")
+		assert.Equal(t, "Test", changed.Username)
+
+		changed, err = r.ChangeUsername(user.Id, "renamed")
+		assert.NoError(t, err)
+		assert.Equal(t, "renamed", changed.Username)
+
+		found, err := r.GetUser("test")
+		assert.NoError(t, err)
+		assert.Empty(t, found.Id)
+
+		found, err = r.GetUser("renamed")
+		assert.NoError(t, err)
+		assert.Equal(t, user.Id, found.Id)
+	})
+
+	t.Run("It should change the email, verified, unless another user has it", func(t *testing.T) {
+		r := newRepository(t)
+		user := createUser(t, r, "test")
+		createUser(t, r, "other")
+
+		_, err := r.ChangeEmail(user.Id, "Other@test.com")
+		assert.IsType(t, &model.EntityAlreadyExistsError{}, err)
+
+		changed, err := r.ChangeEmail(user.Id, "new@test.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "new@test.com", changed.Email)
+
+		admin, err := r.GetAdminUser(user.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "new@test.com", admin.Email)
+		assert.True(t, admin.EmailVerified)
+	})
+
+	t.Run("It should set the account state and list the expired ones", func(t *testing.T) {
+		r := newRepository(t)
+		expired := createUser(t, r, "expired")
+		pending := createUser(t, r, "pending")
+		createUser(t, r, "active")
+
+		now := time.Now()
+		past := now.Add(-time.Hour)
+		future := now.Add(time.Hour)
+
+		assert.NoError(t, r.SetAccountState(expired.Id, model.AccountState{State: model.AccountPendingDeletion, Until: &past}))
+		assert.NoError(t, r.SetAccountState(pending.Id, model.AccountState{
+			State:  model.AccountPendingDeletion,
+			Reason: "Requested by the user",
+			Until:  &future,
+		}))
+
+		state, err := r.GetAccountState(pending.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, model.AccountPendingDeletion, state.State)
+		assert.Equal(t, "Requested by the user", state.Reason)
+		if assert.NotNil(t, state.Until) {
+			assert.WithinDuration(t, future, *state.Until, time.Millisecond)
+		}
+
+		ids, err := r.GetExpiredAccountStates(model.AccountPendingDeletion, now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{expired.Id}, ids)
+
+		ids, err = r.GetExpiredAccountStates(model.AccountSuspended, now)
+		assert.NoError(t, err)
+		assert.Empty(t, ids)
+
+		assert.NoError(t, r.SetAccountState(pending.Id, model.AccountState{State: model.AccountActive}))
+		state, err = r.GetAccountState(pending.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, model.AccountState{State: model.AccountActive}, state)
+	})
+
+	t.Run("It should list the inactive account states and the changes since a time", func(t *testing.T) {
+		r := newRepository(t)
+		suspended := createUser(t, r, "suspended")
+		banned := createUser(t, r, "banned")
+		createUser(t, r, "active")
+
+		assert.NoError(t, r.SetAccountState(suspended.Id, model.AccountState{State: model.AccountSuspended, Reason: "Spam"}))
+		// Some databases keep the time of the change in milliseconds.
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, r.SetAccountState(banned.Id, model.AccountState{State: model.AccountBanned}))
+
+		states, err := r.ListInactiveAccountStates()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []model.UserAccountState{
+			{UserId: suspended.Id, AccountState: model.AccountState{State: model.AccountSuspended, Reason: "Spam"}},
+			{UserId: banned.Id, AccountState: model.AccountState{State: model.AccountBanned}},
+		}, states)
+
+		changes, err := r.ListAccountStateChanges(time.Time{})
+		assert.NoError(t, err)
+		if !assert.Len(t, changes, 2) {
+			return
+		}
+		assert.Equal(t, suspended.Id, changes[0].UserId)
+		assert.Equal(t, banned.Id, changes[1].UserId)
+		assert.Equal(t, model.AccountBanned, changes[1].State)
+		assert.WithinDuration(t, time.Now(), changes[1].ChangedAt, time.Minute)
+
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, r.SetAccountState(suspended.Id, model.AccountState{State: model.AccountActive}))
+
+		changes, err = r.ListAccountStateChanges(changes[1].ChangedAt.Add(time.Millisecond))
+		assert.NoError(t, err)
+		if assert.Len(t, changes, 1) {
+			assert.Equal(t, suspended.Id, changes[0].UserId)
+			assert.Equal(t, model.AccountActive, changes[0].State)
+		}
+	})
+
+	t.Run("It should set the role, the email verification and the password", func(t *testing.T) {
+		r := newRepository(t)
+		user := createUser(t, r, "test")
+
+		assert.NoError(t, r.SetRole(user.Id, model.RoleAdmin))
+		assert.NoError(t, r.SetEmailVerified(user.Id, true))
+		assert.NoError(t, r.SetPassword(user.Id, "rehashed"))
+
+		role, err := r.GetRole(user.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, model.RoleAdmin, role)
+
+		admin, err := r.GetAdminUser(user.Id)
+		assert.NoError(t, err)
+		assert.True(t, admin.EmailVerified)
+
+		saved, err := r.GetUserWithPassword("test")
+		assert.NoError(t, err)
+		assert.Equal(t, "rehashed", saved.Password)
+	})
+
+	t.Run("It should list every user to the admins, filtered and paginated", func(t *testing.T) {
+		r := newRepository(t)
+		createUser(t, r, "first")
+		second := createUser(t, r, "second")
+		createUser(t, r, "third")
+		assert.NoError(t, r.SetAccountState(second.Id, model.AccountState{State: model.AccountSuspended}))
+
+		users, err := r.GetAdminUsers(model.GetAdminUsersParams{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"third", "second", "first"}, usernames(users, adminUserUsername))
+
+		users, err = r.GetAdminUsers(model.GetAdminUsersParams{State: model.AccountSuspended})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"second"}, usernames(users, adminUserUsername))
+
+		users, err = r.GetAdminUsers(model.GetAdminUsersParams{Email: "First@test.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first"}, usernames(users, adminUserUsername))
+
+		users, err = r.GetAdminUsers(model.GetAdminUsersParams{Limit: 1, Offset: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"second"}, usernames(users, adminUserUsername))
+	})
+
+	t.Run("It should read the latest writes from the primary", func(t *testing.T) {
+		r := newRepository(t)
+		user := createUser(t, r, "test")
+
+		_, err := r.UpdateUser(user.Id, &model.EditableUser{DisplayName: "Test User"})
+		assert.NoError(t, err)
+
+		found, err := r.Primary().GetUser("test")
+		assert.NoError(t, err)
+		assert.Equal(t, "Test User", found.DisplayName)
+
+		users, err := r.Primary().GetAllUsers(model.GetUsersParams{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"test"}, usernames(users, userUsername))
+	})
+
+	t.Run("It should lock the account after the maximum failed logins and unlock it", func(t *testing.T) {
+		r := newRepository(t)
+		user := createUser(t, r, "test")
+		lockedUntil := time.Now().Add(15 * time.Minute)
+
+		for range 2 {
+			assert.NoError(t, r.RecordFailedLogin(user.Id, 3, lockedUntil))
+		}
+
+		saved, err := r.GetUserWithPassword("test")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, saved.FailedLogins)
+		assert.Nil(t, saved.LockedUntil)
+
+		assert.NoError(t, r.RecordFailedLogin(user.Id, 3, lockedUntil))
+
+		saved, err = r.GetUserWithPassword("test")
+		assert.NoError(t, err)
+		assert.Zero(t, saved.FailedLogins, "the counter restarts once the account is locked")
+		if assert.NotNil(t, saved.LockedUntil) {
+			assert.WithinDuration(t, lockedUntil, *saved.LockedUntil, time.Millisecond)
+		}
+
+		assert.NoError(t, r.Unlock(user.Id))
+
+		saved, err = r.GetUserWithPassword("test")
+		assert.NoError(t, err)
+		assert.Zero(t, saved.FailedLogins)
+		assert.Nil(t, saved.LockedUntil)
+	})
+}
@@ -0,0 +1,113 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+)
+
+// ITOTPRepository is an interface that contains the methods that will implement a repository struct that interact with the totp_credentials table.
+type ITOTPRepository interface {
+	// Get gets the authenticator app secret of a user.
+	// It returns an empty credential if the user has none, and an error if the operation fails.
+	Get(userId string) (model.TOTPCredential, error)
+	// Save replaces the authenticator app secret of a user with a new one, disabled until it's confirmed.
+	// It returns an error if the operation fails.
+	Save(userId string, secret string) error
+	// Enable enables the authenticator app secret of a user.
+	// It returns an error if the operation fails.
+	Enable(userId string) error
+	// Delete removes the authenticator app secret of a user.
+	// It returns an error if the operation fails.
+	Delete(userId string) error
+	// UseStep records the period of an accepted code, unless a code of the same or a later period was already used.
+	// It returns false if the code was already used, and an error if the operation fails.
+	UseStep(userId string, step int64) (bool, error)
+}
+
+func init() {
+	RegisterEraser(UserDataEraser{Table: "totp_credentials", Order: EraseOrderDefault, Erase: deleteByUserId("totp_credentials")})
+	RegisterExporter(UserDataExporter{
+		Name:        "totp",
+		Description: "Whether you log in with an authenticator app, without its secret",
+		Export: func(db IDatabase, userId string) (any, error) {
+			credentials := make([]struct {
+				Enabled   bool      `json:"enabled"`
+				CreatedAt time.Time `json:"createdAt"`
+			}, 0)
+
+			res := db.Raw(`
+				SELECT enabled, created_at
+				FROM totp_credentials
+				WHERE user_id = ?
+			`, userId).Scan(&credentials)
+
+			return credentials, res.Error
+		},
+	})
+}
+
+type TOTPRepository struct {
+	db IDatabase
+}
+
+func NewTOTPRepository(db IDatabase) (*TOTPRepository, error) {
+	var err error
+
+	if db == nil {
+		db, err = database.GetPoolConnection()
+		if err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	return &TOTPRepository{
+		db: db,
+	}, nil
+}
+
+func (r *TOTPRepository) Get(userId string) (model.TOTPCredential, error) {
+	var credential model.TOTPCredential
+
+	res := r.db.Raw(`
+		SELECT user_id, secret, enabled, last_step
+		FROM totp_credentials
+		WHERE user_id = ?
+	`, userId).Scan(&credential)
+
+	if res.Error != nil {
+		return model.TOTPCredential{}, res.Error
+	}
+
+	return credential, nil
+}
+
+func (r *TOTPRepository) Save(userId string, secret string) error {
+	dialect := database.DialectOf(r.db)
+
+	res := r.db.Exec(`
+		INSERT INTO totp_credentials (user_id, secret, enabled, last_step, created_at)
+		VALUES (?, ?, FALSE, 0, ?)
+		`+dialect.OnConflict("user_id")+` secret = `+dialect.Excluded("secret")+`, enabled = FALSE, last_step = 0,
+			created_at = `+dialect.Excluded("created_at")+`;
+	`, userId, secret, time.Now().UTC())
+
+	return res.Error
+}
+
+func (r *TOTPRepository) Enable(userId string) error {
+	return r.db.Exec("UPDATE totp_credentials SET enabled = TRUE WHERE user_id = ?", userId).Error
+}
+
+func (r *TOTPRepository) Delete(userId string) error {
+	return r.db.Exec("DELETE FROM totp_credentials WHERE user_id = ?", userId).Error
+}
+
+func (r *TOTPRepository) UseStep(userId string, step int64) (bool, error) {
+	res := r.db.Exec("UPDATE totp_credentials SET last_step = ? WHERE user_id = ? AND last_step < ?", step, userId, step)
+
+	return res.RowsAffected == 1, res.Error
+}
@@ -0,0 +1,153 @@
+// Package repository provides structs and methods to interact with the database.
+package repository
+
+import (
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+)
+
+// IUsernameHistoryRepository is an interface that contains the methods that will implement a repository struct that interact with the username_history table.
+type IUsernameHistoryRepository interface {
+	// Reserve records that a user stopped using a username.
+	// The username keeps resolving to the user, and can't be claimed by anyone else, until reservedUntil.
+	// username is the previous username of the user.
+	// userId is the id of the user.
+	// It returns an error if the operation fails.
+	Reserve(username string, userId string, reservedUntil time.Time) error
+	// Unreserve deletes the last reservation of a username by a user, undoing Reserve if the change of the username
+	// failed.
+	// username is the previous username of the user.
+	// userId is the id of the user.
+	// It returns an error if the operation fails.
+	Unreserve(username string, userId string) error
+	// Release ends the reservation of a username, e.g. when its owner takes it back.
+	// username is the username to release.
+	// It returns an error if the operation fails.
+	Release(username string) error
+	// Resolve returns the id of the user that is reserving a username.
+	// username is the previous username of a user.
+	// It returns an empty string if the username isn't reserved, and an error if the operation fails.
+	Resolve(username string) (string, error)
+	// LastChange returns when a user changed the username for the last time.
+	// userId is the id of the user.
+	// It returns the zero time if the user never changed the username, and an error if the operation fails.
+	LastChange(userId string) (time.Time, error)
+}
+
+func init() {
+	RegisterEraser(UserDataEraser{Table: "username_history", Order: EraseOrderDefault, Erase: deleteByUserId("username_history")})
+	RegisterExporter(UserDataExporter{
+		Name:        "username_history",
+		Description: "Your previous usernames and until when they are reserved for you",
+		Export: func(db IDatabase, userId string) (any, error) {
+			changes := make([]struct {
+				Username      string    `json:"username"`
+				ChangedAt     time.Time `json:"changedAt"`
+				ReservedUntil time.Time `json:"reservedUntil"`
+			}, 0)
+
+			res := db.Raw(`
+				SELECT username, changed_at, reserved_until
+				FROM username_history
+				WHERE user_id = ?
+				ORDER BY changed_at ASC
+			`, userId).Scan(&changes)
+
+			return changes, res.Error
+		},
+	})
+}
+
+type UsernameHistoryRepository struct {
+	db IDatabase
+}
+
+func NewUsernameHistoryRepository(db IDatabase) (*UsernameHistoryRepository, error) {
+	var err error
+
+	if db == nil {
+		db, err = database.GetPoolConnection()
+		if err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	return &UsernameHistoryRepository{
+		db: db,
+	}, nil
+}
+
+func (r *UsernameHistoryRepository) Reserve(username string, userId string, reservedUntil time.Time) error {
+	res := r.db.Exec(`
+		INSERT INTO username_history (username, user_id, changed_at, reserved_until)
+		VALUES (?, ?, ?, ?);
+	`, username, userId, time.Now().UTC(), reservedUntil.UTC())
+
+	return res.Error
+}
+
+func (r *UsernameHistoryRepository) Unreserve(username string, userId string) error {
+	var reservation struct{ Id int64 }
+
+	res := r.db.Raw(`
+		SELECT id
+		FROM username_history
+		WHERE username = ? AND user_id = ?
+		ORDER BY changed_at DESC
+		LIMIT 1
+	`, username, userId).Scan(&reservation)
+
+	if res.Error != nil || reservation.Id == 0 {
+		return res.Error
+	}
+
+	return r.db.Exec("DELETE FROM username_history WHERE id = ?", reservation.Id).Error
+}
+
+func (r *UsernameHistoryRepository) Release(username string) error {
+	res := r.db.Exec(`
+		UPDATE username_history
+		SET reserved_until = ?
+		WHERE username = ? AND reserved_until > ?
+	`, time.Now().UTC(), username, time.Now().UTC())
+
+	return res.Error
+}
+
+func (r *UsernameHistoryRepository) Resolve(username string) (string, error) {
+	var reservation struct{ UserId string }
+
+	res := r.db.Raw(`
+		SELECT user_id
+		FROM username_history
+		WHERE username = ? AND reserved_until > ?
+		ORDER BY changed_at DESC
+		LIMIT 1
+	`, username, time.Now().UTC()).Scan(&reservation)
+
+	if res.Error != nil {
+		return "", res.Error
+	}
+
+	return reservation.UserId, nil
+}
+
+func (r *UsernameHistoryRepository) LastChange(userId string) (time.Time, error) {
+	var change struct{ ChangedAt time.Time }
+
+	res := r.db.Raw(`
+		SELECT changed_at
+		FROM username_history
+		WHERE user_id = ?
+		ORDER BY changed_at DESC
+		LIMIT 1
+	`, userId).Scan(&change)
+
+	if res.Error != nil {
+		return time.Time{}, res.Error
+	}
+
+	return change.ChangedAt, nil
+}
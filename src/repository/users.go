@@ -2,11 +2,13 @@
 package repository
 
 import (
-	"errors"
+	"context"
+	"math"
+	"time"
 
 	"github.com/NutriPocket/UserService/database"
 	"github.com/NutriPocket/UserService/model"
-	"github.com/go-sql-driver/mysql"
+	"github.com/NutriPocket/UserService/search"
 	"github.com/google/uuid"
 )
 
@@ -17,22 +19,145 @@ type IUserRepository interface {
 	// It returns the created user and an error if the operation fails.
 	CreateUser(userData *model.BaseUser) (model.User, error)
 	// GetUser gets a user from the database.
+	// It may read from a read replica, which can miss the latest writes, see Primary.
 	// username is the username of the user to get.
 	// It returns the user and an error if the operation fails.
 	GetUser(username string) (model.User, error)
+	// GetUserById gets a user from the database.
+	// userId is the id of the user to get.
+	// It returns the user, empty if it doesn't exist, and an error if the operation fails.
+	GetUserById(userId string) (model.User, error)
 	// GetUserWithPassword gets a user with the password from the database.
 	// emailOrUsername is the email or username of the user to get.
 	// It returns the user and an error if the operation fails.
 	GetUserWithPassword(emailOrUsername string) (model.SavedUser, error)
 	// GetAllUsers gets all the users from the database.
+	// It may read from a read replica, which can miss the latest writes, see Primary.
+	// params.SearchUsername filters the users by username or display name, ranked by relevance.
 	// It returns all the users and an error if the operation fails.
 	GetAllUsers(params model.GetUsersParams) ([]model.User, error)
-
+	// UpdateUser updates the editable data of a user.
+	// userId is the id of the user to update.
+	// It returns the updated user and an error if the operation fails.
 	UpdateUser(userId string, userData *model.EditableUser) (model.User, error)
+	// ChangeUsername changes the username of a user.
+	// userId is the id of the user to update.
+	// username is the new username.
+	// It returns the updated user and an EntityAlreadyExistsError if the username is in use.
+	ChangeUsername(userId string, username string) (model.User, error)
+	// ChangeEmail changes the email of a user.
+	// userId is the id of the user to update.
+	// email is the new email.
+	// It returns the updated user and an EntityAlreadyExistsError if the email is in use.
+	ChangeEmail(userId string, email string) (model.User, error)
+	// GetAccountState gets the state of the account of a user.
+	// userId is the id of the user.
+	// It returns an empty state if the user doesn't exist, and an error if the operation fails.
+	GetAccountState(userId string) (model.AccountState, error)
+	// SetAccountState changes the state of the account of a user. Only active accounts are visible to other users.
+	// userId is the id of the user.
+	// state is the new state of the account.
+	// It returns an error if the operation fails.
+	SetAccountState(userId string, state model.AccountState) error
+	// ListInactiveAccountStates lists the account states of the users that aren't active.
+	// It returns an error if the operation fails.
+	ListInactiveAccountStates() ([]model.UserAccountState, error)
+	// ListAccountStateChanges lists the account states changed since a time, from the oldest to the newest change.
+	// since is the time from which the changes are listed.
+	// It returns an error if the operation fails.
+	ListAccountStateChanges(since time.Time) ([]model.UserAccountState, error)
+	// GetExpiredAccountStates returns the ids of the users whose account state expired before now.
+	// state is the state of the accounts, e.g. model.AccountPendingDeletion.
+	// It returns an error if the operation fails.
+	GetExpiredAccountStates(state string, now time.Time) ([]string, error)
+	// GetRole gets the role of a user.
+	// userId is the id of the user.
+	// It returns an empty string if the user doesn't exist, and an error if the operation fails.
+	GetRole(userId string) (string, error)
+	// GetAdminUsers lists the users with their role and account state, for the admins.
+	// params are the filters applied to the list, the newest users first.
+	// It returns an error if the operation fails.
+	GetAdminUsers(params model.GetAdminUsersParams) ([]model.AdminUser, error)
+	// GetAdminUser gets a user with its role and account state, for the admins.
+	// userId is the id of the user.
+	// It returns an empty user if it doesn't exist, and an error if the operation fails.
+	GetAdminUser(userId string) (model.AdminUser, error)
+	// SetRole changes the role of a user.
+	// It returns an error if the operation fails.
+	SetRole(userId string, role string) error
+	// SetEmailVerified sets whether the email of a user is verified.
+	// It returns an error if the operation fails.
+	SetEmailVerified(userId string, verified bool) error
+	// SetPassword replaces the password of a user.
+	// password is the hash of the new password.
+	// It returns an error if the operation fails.
+	SetPassword(userId string, password string) error
+	// RecordFailedLogin counts a failed login of a user, locking the account once it reaches maxFailures.
+	// lockedUntil is when the account is unlocked if this failure locks it.
+	// It returns an error if the operation fails.
+	RecordFailedLogin(userId string, maxFailures int, lockedUntil time.Time) error
+	// Unlock clears the failed logins of a user and unlocks the account.
+	// It returns an error if the operation fails.
+	Unlock(userId string) error
+	// IndexUsers loads every user into the search index.
+	// It returns an error if the operation fails.
+	IndexUsers() error
+	// Primary returns the repository reading every user from the primary database, for the reads that must see the
+	// latest writes, e.g. of a user about to be updated. The other methods always read from the primary.
+	Primary() IUserRepository
+	// WithContext returns the repository reading the lookups for the request of ctx: from the read replicas until the
+	// request writes, then from the primary, see database.TrackWrites.
+	WithContext(ctx context.Context) IUserRepository
+}
+
+func init() {
+	RegisterEraser(UserDataEraser{
+		Table: "users",
+		Order: EraseOrderUsers,
+		Erase: func(db IDatabase, userId string) error {
+			if err := db.Exec("DELETE FROM users WHERE id = ?", userId).Error; err != nil {
+				return err
+			}
+
+			search.Users().Remove(userId)
+			return nil
+		},
+	})
+	RegisterExporter(UserDataExporter{
+		Name:        "account",
+		Description: "Your account and profile",
+		Export: func(db IDatabase, userId string) (any, error) {
+			var account struct {
+				Id          string        `json:"id"`
+				Username    string        `json:"username"`
+				Email       string        `json:"email"`
+				DisplayName string        `json:"displayName"`
+				Picture     model.Picture `json:"picture"`
+				CreatedAt   time.Time     `json:"createdAt"`
+				model.AccountState
+			}
+
+			res := db.Raw(`
+				SELECT id, username, email, display_name, picture, created_at, state, state_reason, state_until
+				FROM users
+				WHERE id = ?
+			`, userId).Scan(&account)
+
+			return account, res.Error
+		},
+	})
 }
 
+// activeUser is the condition that hides the users whose account isn't active, e.g. suspended or pending deletion.
+const activeUser = "state = '" + model.AccountActive + "'"
+
 type UserRepository struct {
 	db IDatabase
+	// replicas is the database of GetUser and GetAllUsers, the lookups that can miss the latest writes, e.g. the
+	// read replicas of a database.Router.
+	replicas IDatabase
+	// index is the search index kept in sync with the writes to the users table.
+	index *search.Index
 }
 
 func NewUserRepository(db IDatabase) (*UserRepository, error) {
@@ -47,22 +172,61 @@ func NewUserRepository(db IDatabase) (*UserRepository, error) {
 	}
 
 	return &UserRepository{
-		db: db,
+		db:       db,
+		replicas: db,
+		index:    search.Users(),
 	}, nil
 }
 
+// NewReplicatedUserRepository creates a UserRepository whose lookups of GetUser and GetAllUsers are read from replicas.
+// db is the primary database, the pool connection if nil. replicas is the database of the lookups, the replica pool if
+// nil, which routes them to the read replicas configured.
+func NewReplicatedUserRepository(db IDatabase, replicas IDatabase) (*UserRepository, error) {
+	r, err := NewUserRepository(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if replicas == nil {
+		replicas, err = database.GetReplicaPool()
+		if err != nil {
+			log.Errorf("Failed to connect to the read replicas")
+			return nil, err
+		}
+	}
+
+	r.replicas = replicas
+	return r, nil
+}
+
+func (r *UserRepository) Primary() IUserRepository {
+	return &UserRepository{db: r.db, replicas: r.db, index: r.index}
+}
+
+// WithContext returns the repository itself if its lookups aren't routed to read replicas.
+func (r *UserRepository) WithContext(ctx context.Context) IUserRepository {
+	router, ok := r.replicas.(*database.Router)
+	if !ok {
+		return r
+	}
+
+	return &UserRepository{db: r.db, replicas: router.WithContext(ctx), index: r.index}
+}
+
 func (r *UserRepository) CreateUser(userData *model.BaseUser) (model.User, error) {
 	var user model.User
 
+	// The creation time isn't left to the default of the column, which has milliseconds in SQLite, so the users created
+	// in the same millisecond are still listed in the order they were created.
 	res := r.db.Exec(`
-			INSERT INTO users (id, username, email, password) 
-			VALUES (?, ?, ?, ?);
+			INSERT INTO users (id, username, email, password, created_at) 
+			VALUES (?, ?, ?, ?, ?);
 		`,
-		uuid.NewString(), userData.Username, userData.Email, userData.Password,
+		uuid.NewString(), userData.Username, userData.Email, userData.Password, time.Now().UTC(),
 	)
 
 	if res.Error != nil {
-		if errors.Is(res.Error, &mysql.MySQLError{Number: 1062}) {
+		if database.IsDuplicateKey(res.Error) {
 			return model.User{}, &model.EntityAlreadyExistsError{
 				Title:  "Username or email already in use",
 				Detail: "The provided username or email are already in use, try something else",
@@ -78,13 +242,27 @@ func (r *UserRepository) CreateUser(userData *model.BaseUser) (model.User, error
 		return model.User{}, res.Error
 	}
 
+	r.index.Upsert(userDocument(user))
+
 	return user, nil
 }
 
 func (r *UserRepository) GetUser(username string) (model.User, error) {
 	var user model.User
 
-	res := r.db.Raw("SELECT id, username, email, picture FROM users WHERE username = ?", username).Scan(&user)
+	res := r.replicas.Raw("SELECT id, username, email, picture, display_name FROM users WHERE username = ? AND "+activeUser, username).Scan(&user)
+
+	if res.Error != nil {
+		return model.User{}, res.Error
+	}
+
+	return user, nil
+}
+
+func (r *UserRepository) GetUserById(userId string) (model.User, error) {
+	var user model.User
+
+	res := r.db.Raw("SELECT id, username, email, picture, display_name FROM users WHERE id = ?", userId).Scan(&user)
 
 	if res.Error != nil {
 		return model.User{}, res.Error
@@ -96,7 +274,12 @@ func (r *UserRepository) GetUser(username string) (model.User, error) {
 func (r *UserRepository) GetUserWithPassword(emailOrUsername string) (model.SavedUser, error) {
 	var user model.SavedUser
 
-	res := r.db.Raw("SELECT id, username, email, password FROM users WHERE username = ? OR email = ?", emailOrUsername, emailOrUsername).Scan(&user)
+	res := r.db.Raw(`
+		SELECT id, username, email, password, state, state_reason, state_until, failed_logins, locked_until
+		FROM users
+		WHERE username = ? OR email = ?`,
+		emailOrUsername, emailOrUsername,
+	).Scan(&user)
 
 	if res.Error != nil {
 		return model.SavedUser{}, res.Error
@@ -106,16 +289,26 @@ func (r *UserRepository) GetUserWithPassword(emailOrUsername string) (model.Save
 }
 
 func (r *UserRepository) GetAllUsers(params model.GetUsersParams) ([]model.User, error) {
+	if params.SearchUsername != "" && r.index.Ready() {
+		return r.searchUsers(params)
+	}
+
 	var users []model.User = make([]model.User, 0)
 
 	params.SearchUsername = "%" + params.SearchUsername + "%"
 
-	res := r.db.Raw(`
-		SELECT id, username, email, picture
+	limit := params.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+
+	res := r.replicas.Raw(`
+		SELECT id, username, email, picture, display_name
 		FROM users 
-		WHERE username LIKE ? 
-		ORDER BY created_at DESC`,
-		params.SearchUsername,
+		WHERE (username LIKE ? OR display_name LIKE ?) AND `+activeUser+`
+		ORDER BY created_at DESC
+		LIMIT ?`,
+		params.SearchUsername, params.SearchUsername, limit,
 	).Scan(&users)
 
 	if res.Error != nil {
@@ -125,20 +318,305 @@ func (r *UserRepository) GetAllUsers(params model.GetUsersParams) ([]model.User,
 	return users, nil
 }
 
+// searchUsers ranks the users matching params.SearchUsername using the search index.
+// The index only provides the ids, the users are read from the database so deleted users are never returned.
+func (r *UserRepository) searchUsers(params model.GetUsersParams) ([]model.User, error) {
+	results := r.index.Search(params.SearchUsername, params.Limit)
+	if len(results) == 0 {
+		return []model.User{}, nil
+	}
+
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Id
+	}
+
+	var found []model.User
+
+	res := r.replicas.Raw(`
+		SELECT id, username, email, picture, display_name
+		FROM users
+		WHERE id IN ? AND `+activeUser,
+		ids,
+	).Scan(&found)
+
+	if res.Error != nil {
+		return []model.User{}, res.Error
+	}
+
+	byId := make(map[string]model.User, len(found))
+	for _, user := range found {
+		byId[user.Id] = user
+	}
+
+	users := make([]model.User, 0, len(found))
+	for _, id := range ids {
+		if user, ok := byId[id]; ok {
+			users = append(users, user)
+		}
+	}
+
+	return users, nil
+}
+
 func (r *UserRepository) UpdateUser(userId string, userData *model.EditableUser) (model.User, error) {
 	var user model.User
 
-	res := r.db.Exec("UPDATE users SET picture = ? WHERE id = ?", userData.Picture, userId)
+	res := r.db.Exec(
+		"UPDATE users SET picture = NULLIF(?, ''), display_name = NULLIF(?, '') WHERE id = ?",
+		userData.Picture, userData.DisplayName, userId,
+	)
 
 	if res.Error != nil {
 		return model.User{}, res.Error
 	}
 
-	res = r.db.Raw("SELECT id, username, email, picture FROM users WHERE id = ?", userId).Scan(&user)
+	res = r.db.Raw("SELECT id, username, email, picture, display_name FROM users WHERE id = ?", userId).Scan(&user)
 
 	if res.Error != nil {
 		return model.User{}, res.Error
 	}
 
+	r.index.Upsert(userDocument(user))
+
+	return user, nil
+}
+
+func (r *UserRepository) ChangeUsername(userId string, username string) (model.User, error) {
+	res := r.db.Exec("UPDATE users SET username = ? WHERE id = ?", username, userId)
+
+	if res.Error != nil {
+		if database.IsDuplicateKey(res.Error) {
+			return model.User{}, &model.EntityAlreadyExistsError{
+				Title:  "Username already in use",
+				Detail: "The provided username is already in use, try something else",
+			}
+		}
+
+		return model.User{}, res.Error
+	}
+
+	user, err := r.GetUserById(userId)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	r.index.Upsert(userDocument(user))
+
+	return user, nil
+}
+
+func (r *UserRepository) ChangeEmail(userId string, email string) (model.User, error) {
+	// The email is changed through a link sent to the address, so it's verified.
+	res := r.db.Exec("UPDATE users SET email = ?, email_verified = TRUE WHERE id = ?", email, userId)
+
+	if res.Error != nil {
+		if database.IsDuplicateKey(res.Error) {
+			return model.User{}, &model.EntityAlreadyExistsError{
+				Title:  "Email already in use",
+				Detail: "The provided email is already in use, try something else",
+			}
+		}
+
+		return model.User{}, res.Error
+	}
+
+	return r.GetUserById(userId)
+}
+
+func (r *UserRepository) GetAccountState(userId string) (model.AccountState, error) {
+	var state model.AccountState
+
+	res := r.db.Raw("SELECT state, state_reason, state_until FROM users WHERE id = ?", userId).Scan(&state)
+
+	if res.Error != nil {
+		return model.AccountState{}, res.Error
+	}
+
+	return state, nil
+}
+
+func (r *UserRepository) SetAccountState(userId string, state model.AccountState) error {
+	var until *time.Time
+	if state.Until != nil {
+		utc := state.Until.UTC()
+		until = &utc
+	}
+
+	res := r.db.Exec(`
+		UPDATE users
+		SET state = ?, state_reason = NULLIF(?, ''), state_until = ?, state_changed_at = ?
+		WHERE id = ?
+	`, state.State, state.Reason, until, time.Now().UTC(), userId)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if state.State != model.AccountActive {
+		r.index.Remove(userId)
+		return nil
+	}
+
+	user, err := r.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	if user.Id != "" {
+		r.index.Upsert(userDocument(user))
+	}
+
+	return nil
+}
+
+func (r *UserRepository) ListInactiveAccountStates() ([]model.UserAccountState, error) {
+	states := make([]model.UserAccountState, 0)
+
+	res := r.db.Raw(`
+		SELECT id AS user_id, state, state_reason, state_until
+		FROM users
+		WHERE state <> ?
+	`, model.AccountActive).Scan(&states)
+
+	return states, res.Error
+}
+
+func (r *UserRepository) ListAccountStateChanges(since time.Time) ([]model.UserAccountState, error) {
+	states := make([]model.UserAccountState, 0)
+
+	res := r.db.Raw(`
+		SELECT id AS user_id, state, state_reason, state_until, state_changed_at AS changed_at
+		FROM users
+		WHERE state_changed_at >= ?
+		ORDER BY state_changed_at
+	`, since.UTC()).Scan(&states)
+
+	return states, res.Error
+}
+
+func (r *UserRepository) GetExpiredAccountStates(state string, now time.Time) ([]string, error) {
+	ids := make([]string, 0)
+
+	res := r.db.Raw("SELECT id FROM users WHERE state = ? AND state_until <= ?", state, now.UTC()).Scan(&ids)
+
+	if res.Error != nil {
+		return []string{}, res.Error
+	}
+
+	return ids, nil
+}
+
+func (r *UserRepository) GetRole(userId string) (string, error) {
+	var user struct{ Role string }
+
+	res := r.db.Raw("SELECT role FROM users WHERE id = ?", userId).Scan(&user)
+
+	if res.Error != nil {
+		return "", res.Error
+	}
+
+	return user.Role, nil
+}
+
+// adminUserColumns are the columns read into model.AdminUser.
+const adminUserColumns = `
+	id, username, email, picture, display_name, role, state, state_reason, state_until, email_verified, locked_until, created_at`
+
+func (r *UserRepository) GetAdminUsers(params model.GetAdminUsersParams) ([]model.AdminUser, error) {
+	users := make([]model.AdminUser, 0)
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+
+	query := "SELECT " + adminUserColumns + " FROM users WHERE 1 = 1"
+	args := make([]any, 0)
+
+	if params.State != "" {
+		query += " AND state = ?"
+		args = append(args, params.State)
+	}
+
+	if params.Email != "" {
+		query += " AND email = ?"
+		args = append(args, params.Email)
+	}
+
+	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, params.Offset)
+
+	res := r.db.Raw(query, args...).Scan(&users)
+
+	if res.Error != nil {
+		return []model.AdminUser{}, res.Error
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) GetAdminUser(userId string) (model.AdminUser, error) {
+	var user model.AdminUser
+
+	res := r.db.Raw("SELECT "+adminUserColumns+" FROM users WHERE id = ?", userId).Scan(&user)
+
+	if res.Error != nil {
+		return model.AdminUser{}, res.Error
+	}
+
 	return user, nil
 }
+
+func (r *UserRepository) SetRole(userId string, role string) error {
+	return r.db.Exec("UPDATE users SET role = ? WHERE id = ?", role, userId).Error
+}
+
+func (r *UserRepository) SetEmailVerified(userId string, verified bool) error {
+	return r.db.Exec("UPDATE users SET email_verified = ? WHERE id = ?", verified, userId).Error
+}
+
+func (r *UserRepository) SetPassword(userId string, password string) error {
+	return r.db.Exec("UPDATE users SET password = ? WHERE id = ?", password, userId).Error
+}
+
+func (r *UserRepository) RecordFailedLogin(userId string, maxFailures int, lockedUntil time.Time) error {
+	// Both assignments read the previous failed_logins, the counter restarts once the account is locked.
+	res := r.db.Exec(`
+		UPDATE users
+		SET locked_until = CASE WHEN failed_logins + 1 >= ? THEN ? ELSE locked_until END,
+			failed_logins = CASE WHEN failed_logins + 1 >= ? THEN 0 ELSE failed_logins + 1 END
+		WHERE id = ?
+	`, maxFailures, lockedUntil.UTC(), maxFailures, userId)
+
+	return res.Error
+}
+
+func (r *UserRepository) Unlock(userId string) error {
+	return r.db.Exec("UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = ?", userId).Error
+}
+
+func (r *UserRepository) IndexUsers() error {
+	var users []model.User
+
+	res := r.db.Raw("SELECT id, username, email, display_name FROM users WHERE " + activeUser + " ORDER BY created_at ASC").Scan(&users)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	docs := make([]search.Document, len(users))
+	for i, user := range users {
+		docs[i] = userDocument(user)
+	}
+
+	r.index.Load(docs)
+	log.Infof("Search index loaded with %d users", len(docs))
+
+	return nil
+}
+
+// userDocument returns the searchable fields of a user.
+func userDocument(user model.User) search.Document {
+	return search.Document{Id: user.Id, Fields: []string{user.Username, user.DisplayName}}
+}
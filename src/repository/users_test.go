@@ -0,0 +1,57 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicatedUserRepository(t *testing.T) {
+	// The replica is a database apart that never receives the writes, as a replica lagging behind.
+	newRepository := func(t *testing.T) *repository.UserRepository {
+		r, err := repository.NewReplicatedUserRepository(test.NewDB(t), test.NewDB(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	t.Run("It should read the user lookups from the replicas", func(t *testing.T) {
+		r := newRepository(t)
+		_, err := r.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "hashed"})
+		assert.NoError(t, err)
+
+		user, err := r.GetUser("test")
+		assert.NoError(t, err)
+		assert.Empty(t, user.Id)
+
+		users, err := r.GetAllUsers(model.GetUsersParams{})
+		assert.NoError(t, err)
+		assert.Empty(t, users)
+	})
+
+	t.Run("It should read the latest writes from the primary", func(t *testing.T) {
+		r := newRepository(t)
+		created, err := r.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "hashed"})
+		assert.NoError(t, err)
+
+		user, err := r.Primary().GetUser("test")
+		assert.NoError(t, err)
+		assert.Equal(t, created.Id, user.Id)
+
+		users, err := r.Primary().GetAllUsers(model.GetUsersParams{})
+		assert.NoError(t, err)
+		assert.Len(t, users, 1)
+
+		saved, err := r.GetUserWithPassword("test")
+		assert.NoError(t, err)
+		assert.Equal(t, created.Id, saved.Id, "the credentials are always read from the primary")
+
+		user, err = r.GetUserById(created.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "test", user.Username)
+	})
+}
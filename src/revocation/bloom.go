@@ -0,0 +1,81 @@
+package revocation
+
+import (
+	"hash/maphash"
+	"math"
+	"sync/atomic"
+)
+
+// Filter is a Bloom filter of strings, safe for concurrent use without locks.
+// It answers if a string may have been added, with false positives but never false negatives.
+type Filter struct {
+	bits     []atomic.Uint64
+	hashes   int
+	seeds    [2]maphash.Seed
+	count    atomic.Int64
+	capacity int
+}
+
+// NewFilter creates a Bloom filter sized for capacity strings with a false positive rate, e.g. 0.001.
+// Adding more strings than its capacity works, but increases the false positive rate.
+func NewFilter(capacity int, falsePositiveRate float64) *Filter {
+	capacity = max(capacity, 1)
+	falsePositiveRate = min(max(falsePositiveRate, 1e-9), 0.5)
+
+	// The optimal number of bits and hash functions for the capacity and the false positive rate.
+	bits := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := int(math.Round(bits / float64(capacity) * math.Ln2))
+
+	return &Filter{
+		bits:     make([]atomic.Uint64, (int(bits)+63)/64),
+		hashes:   max(hashes, 1),
+		seeds:    [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+		capacity: capacity,
+	}
+}
+
+// positions calls fn with the position of the bit of every hash function of s, until it returns false.
+// The positions are derived from two hashes, as in "Less Hashing, Same Performance" (Kirsch and Mitzenmacher).
+func (f *Filter) positions(s string, fn func(word int, mask uint64) bool) {
+	size := uint64(len(f.bits)) * 64
+	h1 := maphash.String(f.seeds[0], s)
+	h2 := maphash.String(f.seeds[1], s) | 1
+
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		if !fn(int(bit/64), 1<<(bit%64)) {
+			return
+		}
+	}
+}
+
+// Add adds a string to the filter.
+func (f *Filter) Add(s string) {
+	f.positions(s, func(word int, mask uint64) bool {
+		f.bits[word].Or(mask)
+		return true
+	})
+	f.count.Add(1)
+}
+
+// MayContain returns false if a string was never added, and true if it may have been.
+func (f *Filter) MayContain(s string) bool {
+	found := true
+
+	f.positions(s, func(word int, mask uint64) bool {
+		found = f.bits[word].Load()&mask != 0
+		return found
+	})
+
+	return found
+}
+
+// Len returns the number of strings added, counting the repeated ones.
+func (f *Filter) Len() int {
+	return int(f.count.Load())
+}
+
+// Capacity returns the number of strings the filter was sized for.
+func (f *Filter) Capacity() int {
+	return f.capacity
+}
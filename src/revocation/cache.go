@@ -0,0 +1,164 @@
+// Package revocation caches the revoked tokens in memory, so checking a token doesn't need a database round trip.
+//
+// A Bloom filter holds every revoked signature and answers most checks, those of tokens that were never revoked. The
+// signatures it may contain are confirmed with a set of the recently revoked ones, and the false positives and evicted
+// signatures are left to the caller, as well as any check while the cache is stale. The checks don't take locks.
+package revocation
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is the answer of the cache about a signature.
+type Status int
+
+const (
+	// Unknown means the cache can't tell, the caller must check the source of truth.
+	Unknown Status = iota
+	// NotRevoked means the signature was never revoked.
+	NotRevoked
+	// Revoked means the signature was revoked.
+	Revoked
+)
+
+// Config are the settings of a Cache.
+type Config struct {
+	// Capacity is the minimum number of signatures the Bloom filter is sized for.
+	Capacity int
+	// FalsePositiveRate is the rate of the Bloom filter at its capacity.
+	FalsePositiveRate float64
+	// RecentSize is the number of recently revoked signatures kept to confirm the matches of the Bloom filter.
+	RecentSize int
+	// MaxStaleness is how long the cache answers after its last refresh, then every check is Unknown.
+	MaxStaleness time.Duration
+}
+
+// DefaultConfig returns the default settings of a Cache.
+func DefaultConfig() Config {
+	return Config{
+		Capacity:          100_000,
+		FalsePositiveRate: 0.001,
+		RecentSize:        10_000,
+		MaxStaleness:      30 * time.Second,
+	}
+}
+
+// snapshot is the content of a Cache, replaced as a whole when it's rebuilt.
+type snapshot struct {
+	filter *Filter
+	recent *Recent
+}
+
+// Cache is an in-memory cache of the revoked signatures, safe for concurrent use.
+// It isn't ready to answer until it's loaded with Reset.
+type Cache struct {
+	config Config
+	// mu serializes the writes, the lookups don't take it.
+	mu      sync.Mutex
+	current atomic.Pointer[snapshot]
+	// added are the signatures added since the last Reset, which may be missing from the ones the next is given.
+	added       []string
+	refreshedAt atomic.Int64
+}
+
+// NewCache creates an empty cache.
+func NewCache(config Config) *Cache {
+	cache := &Cache{config: config}
+	cache.current.Store(cache.newSnapshot(0))
+
+	return cache
+}
+
+func (cache *Cache) newSnapshot(size int) *snapshot {
+	// Sized for twice the signatures, so the filter keeps its false positive rate while new ones are added.
+	return &snapshot{
+		filter: NewFilter(max(cache.config.Capacity, 2*size), cache.config.FalsePositiveRate),
+		recent: NewRecent(cache.config.RecentSize),
+	}
+}
+
+// Lookup checks if a signature was revoked.
+// now is the time of the check, compared with the last refresh.
+func (cache *Cache) Lookup(signature string, now time.Time) Status {
+	if cache.Stale(now) {
+		return Unknown
+	}
+
+	current := cache.current.Load()
+
+	if !current.filter.MayContain(signature) {
+		return NotRevoked
+	}
+
+	if current.recent.Contains(signature) {
+		return Revoked
+	}
+
+	return Unknown
+}
+
+// Add adds revoked signatures.
+func (cache *Cache) Add(signatures ...string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	current := cache.current.Load()
+
+	for _, signature := range signatures {
+		current.filter.Add(signature)
+	}
+	current.recent.Add(signatures...)
+
+	cache.added = append(cache.added, signatures...)
+}
+
+// Reset replaces the content of the cache with every revoked signature and marks it as refreshed.
+// signatures must be ordered from the oldest to the newest revocation, so the newest are kept as recent.
+// The signatures added since the last Reset are kept too, as they may have been revoked after signatures were read.
+// at is the time the signatures were read.
+func (cache *Cache) Reset(signatures []string, at time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	next := cache.newSnapshot(len(signatures))
+
+	for _, signature := range signatures {
+		next.filter.Add(signature)
+	}
+
+	// The ones the filter may already contain are skipped, so they're not counted twice. A false positive skipped
+	// isn't confirmed as recent, it's left to the caller.
+	for _, signature := range cache.added {
+		if !next.filter.MayContain(signature) {
+			next.filter.Add(signature)
+			signatures = append(signatures, signature)
+		}
+	}
+
+	next.recent.Add(signatures...)
+
+	cache.current.Store(next)
+	cache.added = nil
+	cache.MarkRefreshed(at)
+}
+
+// MarkRefreshed records that the cache has every signature revoked until a time.
+func (cache *Cache) MarkRefreshed(at time.Time) {
+	cache.refreshedAt.Store(at.UnixNano())
+}
+
+// Stale returns true if the cache was never loaded or wasn't refreshed within the maximum staleness.
+func (cache *Cache) Stale(now time.Time) bool {
+	refreshedAt := cache.refreshedAt.Load()
+
+	return refreshedAt == 0 || now.Sub(time.Unix(0, refreshedAt)) > cache.config.MaxStaleness
+}
+
+// Full returns true if the Bloom filter holds more signatures than it was sized for and should be rebuilt.
+func (cache *Cache) Full() bool {
+	filter := cache.current.Load().filter
+
+	return filter.Len() > filter.Capacity()
+}
@@ -0,0 +1,64 @@
+package revocation
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Recent is a set of strings safe for concurrent use that keeps only the most recently added ones.
+// Contains doesn't take locks: the adds, much rarer than the checks, copy the set and publish the copy atomically.
+type Recent struct {
+	// mu serializes the adds.
+	mu   sync.Mutex
+	size int
+	// order are the strings from the oldest to the newest added, only used by the adds.
+	order []string
+	items atomic.Pointer[map[string]struct{}]
+}
+
+// NewRecent creates an empty set that keeps at most size strings.
+func NewRecent(size int) *Recent {
+	recent := &Recent{size: max(size, 1)}
+	recent.items.Store(&map[string]struct{}{})
+
+	return recent
+}
+
+// Add adds strings, evicting the oldest ones if the set is full.
+func (r *Recent) Add(strings ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := *r.items.Load()
+	items := make(map[string]struct{}, min(len(current)+len(strings), r.size))
+	for s := range current {
+		items[s] = struct{}{}
+	}
+
+	for _, s := range strings {
+		if _, ok := items[s]; ok {
+			continue
+		}
+
+		items[s] = struct{}{}
+		r.order = append(r.order, s)
+
+		if len(r.order) > r.size {
+			delete(items, r.order[0])
+			r.order = r.order[1:]
+		}
+	}
+
+	r.items.Store(&items)
+}
+
+// Contains checks if a string is in the set.
+func (r *Recent) Contains(s string) bool {
+	_, ok := (*r.items.Load())[s]
+	return ok
+}
+
+// Len returns the number of strings in the set.
+func (r *Recent) Len() int {
+	return len(*r.items.Load())
+}
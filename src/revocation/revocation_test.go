@@ -0,0 +1,164 @@
+package revocation
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	t.Run("It should never miss an added string", func(t *testing.T) {
+		filter := NewFilter(1000, 0.01)
+
+		for i := 0; i < 1000; i++ {
+			filter.Add(fmt.Sprintf("signature-%d", i))
+		}
+
+		for i := 0; i < 1000; i++ {
+			assert.True(t, filter.MayContain(fmt.Sprintf("signature-%d", i)))
+		}
+		assert.Equal(t, 1000, filter.Len())
+	})
+
+	t.Run("It should keep the false positive rate around the configured one at its capacity", func(t *testing.T) {
+		filter := NewFilter(10_000, 0.01)
+
+		for i := 0; i < 10_000; i++ {
+			filter.Add(fmt.Sprintf("revoked-%d", i))
+		}
+
+		falsePositives := 0
+		for i := 0; i < 10_000; i++ {
+			if filter.MayContain(fmt.Sprintf("valid-%d", i)) {
+				falsePositives++
+			}
+		}
+
+		assert.Less(t, falsePositives, 300)
+	})
+
+	t.Run("It should be safe to add and check concurrently", func(t *testing.T) {
+		filter := NewFilter(1000, 0.01)
+		var wg sync.WaitGroup
+
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					signature := fmt.Sprintf("signature-%d-%d", worker, i)
+					filter.Add(signature)
+					assert.True(t, filter.MayContain(signature))
+				}
+			}()
+		}
+
+		wg.Wait()
+		assert.Equal(t, 800, filter.Len())
+	})
+}
+
+func TestRecent(t *testing.T) {
+	t.Run("It should evict the oldest string", func(t *testing.T) {
+		recent := NewRecent(2)
+
+		recent.Add("a")
+		recent.Add("b", "a")
+		assert.True(t, recent.Contains("a"))
+		recent.Add("c")
+
+		assert.False(t, recent.Contains("a"))
+		assert.True(t, recent.Contains("b"))
+		assert.True(t, recent.Contains("c"))
+		assert.Equal(t, 2, recent.Len())
+	})
+
+	t.Run("It should be safe to add and check concurrently", func(t *testing.T) {
+		recent := NewRecent(1000)
+		var wg sync.WaitGroup
+
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					s := fmt.Sprintf("signature-%d-%d", worker, i)
+					recent.Add(s)
+					assert.True(t, recent.Contains(s))
+				}
+			}()
+		}
+
+		wg.Wait()
+		assert.Equal(t, 800, recent.Len())
+	})
+}
+
+func TestCache(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	config := Config{Capacity: 100, FalsePositiveRate: 0.001, RecentSize: 2, MaxStaleness: time.Minute}
+
+	t.Run("It should answer unknown until it's loaded", func(t *testing.T) {
+		cache := NewCache(config)
+		cache.Add("revoked")
+
+		assert.Equal(t, Unknown, cache.Lookup("revoked", now))
+		assert.Equal(t, Unknown, cache.Lookup("valid", now))
+	})
+
+	t.Run("It should answer from memory once loaded", func(t *testing.T) {
+		cache := NewCache(config)
+		cache.Reset([]string{"old", "revoked"}, now)
+		cache.Add("new")
+
+		assert.Equal(t, Revoked, cache.Lookup("revoked", now))
+		assert.Equal(t, Revoked, cache.Lookup("new", now))
+		assert.Equal(t, NotRevoked, cache.Lookup("valid", now))
+	})
+
+	t.Run("It should leave the evicted signatures to the caller", func(t *testing.T) {
+		cache := NewCache(config)
+		cache.Reset([]string{"old", "revoked", "new"}, now)
+
+		assert.Equal(t, Unknown, cache.Lookup("old", now))
+	})
+
+	t.Run("It should answer unknown when it's stale", func(t *testing.T) {
+		cache := NewCache(config)
+		cache.Reset([]string{"revoked"}, now)
+
+		assert.Equal(t, NotRevoked, cache.Lookup("valid", now.Add(time.Minute)))
+		assert.Equal(t, Unknown, cache.Lookup("valid", now.Add(time.Minute+time.Second)))
+
+		cache.MarkRefreshed(now.Add(time.Minute))
+		assert.Equal(t, NotRevoked, cache.Lookup("valid", now.Add(time.Minute+time.Second)))
+	})
+
+	t.Run("It should keep the signatures added while it was being reset", func(t *testing.T) {
+		cache := NewCache(config)
+		cache.Reset([]string{"old"}, now)
+
+		// The signatures of the next reset are read before "new" is revoked, and it's added before the reset ends.
+		signatures := []string{"old"}
+		cache.Add("new")
+		cache.Reset(signatures, now)
+
+		assert.Equal(t, Revoked, cache.Lookup("new", now))
+		assert.Equal(t, Revoked, cache.Lookup("old", now))
+	})
+
+	t.Run("It should be full when it holds more signatures than its capacity", func(t *testing.T) {
+		cache := NewCache(config)
+		cache.Reset(nil, now)
+
+		for i := 0; i <= 100; i++ {
+			assert.False(t, cache.Full())
+			cache.Add(fmt.Sprintf("signature-%d", i))
+		}
+
+		assert.True(t, cache.Full())
+	})
+}
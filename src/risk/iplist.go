@@ -0,0 +1,86 @@
+package risk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// IPList is a set of IP addresses and networks, e.g. known Tor exit nodes or abusive hosts.
+type IPList struct {
+	prefixes []netip.Prefix
+}
+
+// LoadIPList reads an IP list file, see ParseIPList.
+func LoadIPList(path string) (*IPList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseIPList(file)
+}
+
+// ParseIPList reads an IP list with an address or a CIDR network per line.
+// Empty lines and everything after a # are ignored.
+// It returns an error if a line isn't an address or a network.
+func ParseIPList(reader io.Reader) (*IPList, error) {
+	list := &IPList{}
+	scanner := bufio.NewScanner(reader)
+
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		if strings.Contains(text, "/") {
+			prefix, err := netip.ParsePrefix(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			list.prefixes = append(list.prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		addr = addr.Unmap()
+		list.prefixes = append(list.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return list, scanner.Err()
+}
+
+// Len returns the number of addresses and networks of the list.
+func (l *IPList) Len() int {
+	return len(l.prefixes)
+}
+
+// Contains checks if an address is in the list.
+// ip is the IPv4 or IPv6 address in text form, an invalid address is never in the list.
+func (l *IPList) Contains(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap().WithZone("")
+
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
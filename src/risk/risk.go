@@ -0,0 +1,269 @@
+// Package risk scores login attempts with configurable rules, to ask for a second factor or block suspicious ones.
+// The engine only sees the facts gathered in an Attempt, so it doesn't need a database.
+package risk
+
+import (
+	"math"
+	"time"
+)
+
+// Decision is what to do with a login attempt.
+type Decision string
+
+const (
+	// Allow lets the user log in with the password alone.
+	Allow Decision = "allow"
+	// StepUp asks the user for a second factor before logging in.
+	StepUp Decision = "step_up"
+	// Block rejects the attempt even if the password is right.
+	Block Decision = "block"
+)
+
+// Place is where a login came from, as resolved from its IP.
+type Place struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country, empty if unknown.
+	Country string
+	// Located is true if Latitude and Longitude are known.
+	Located   bool
+	Latitude  float64
+	Longitude float64
+}
+
+// Attempt are the facts known about a login attempt with the right password.
+type Attempt struct {
+	Time  time.Time
+	IP    string
+	Place Place
+	// NewDevice is true if the user logged in before, but never from this device.
+	NewDevice bool
+	// NewCountry is true if the user logged in before, but never from this country.
+	NewCountry bool
+	// RecentFailures is the number of failed logins of the user in the configured window.
+	RecentFailures int
+	// Previous is the last successful login of the user, nil if it's the first one.
+	Previous *PreviousLogin
+}
+
+// PreviousLogin is the last successful login of a user.
+type PreviousLogin struct {
+	Time  time.Time
+	Place Place
+}
+
+// Rule is a signal of a suspicious login.
+type Rule interface {
+	// Name identifies the rule in the assessments, e.g. impossible_travel.
+	Name() string
+	// Score returns how suspicious an attempt is according to the rule, 0 if it doesn't apply.
+	Score(attempt Attempt) int
+}
+
+// Assessment is the result of scoring a login attempt.
+type Assessment struct {
+	// Score is the sum of the scores of the matched rules.
+	Score    int      `json:"score"`
+	Decision Decision `json:"decision"`
+	// Reasons are the names of the matched rules.
+	Reasons []string `json:"reasons"`
+}
+
+// Config are the weights and thresholds of the rules, a rule with a score of 0 is disabled.
+type Config struct {
+	// StepUpScore and BlockScore are the minimum scores to ask for a second factor and to block an attempt.
+	StepUpScore int
+	BlockScore  int
+
+	// ImpossibleTravelScore applies when the distance from the previous login couldn't be travelled
+	// at MaxTravelSpeed km/h. Distances under MinTravelDistance km are ignored, as the locations are coarse.
+	ImpossibleTravelScore int
+	MaxTravelSpeed        float64
+	MinTravelDistance     float64
+
+	// NewDeviceCountryScore applies when a login comes from a new device in a new country.
+	NewDeviceCountryScore int
+
+	// FailedLoginsScore applies when the user had at least FailedLoginsThreshold recent failed logins.
+	FailedLoginsScore     int
+	FailedLoginsThreshold int
+
+	// BadIPScore applies when the IP is in the list of known bad IPs.
+	BadIPScore int
+}
+
+// DefaultConfig returns the default configuration: a bad IP is blocked and any other rule asks for a second factor,
+// except recent failed logins that only add up with the others.
+func DefaultConfig() Config {
+	return Config{
+		StepUpScore:           40,
+		BlockScore:            100,
+		ImpossibleTravelScore: 60,
+		MaxTravelSpeed:        1000,
+		MinTravelDistance:     300,
+		NewDeviceCountryScore: 40,
+		FailedLoginsScore:     30,
+		FailedLoginsThreshold: 3,
+		BadIPScore:            100,
+	}
+}
+
+// Engine scores login attempts with a set of rules.
+type Engine struct {
+	rules       []Rule
+	stepUpScore int
+	blockScore  int
+}
+
+// NewEngine creates an engine with the rules enabled by a configuration.
+// badIPs is the list of known bad IPs, the rule is disabled if nil.
+func NewEngine(config Config, badIPs *IPList) *Engine {
+	rules := make([]Rule, 0, 4)
+
+	if config.ImpossibleTravelScore > 0 {
+		rules = append(rules, &ImpossibleTravel{
+			MaxSpeed:    config.MaxTravelSpeed,
+			MinDistance: config.MinTravelDistance,
+			Points:      config.ImpossibleTravelScore,
+		})
+	}
+
+	if config.NewDeviceCountryScore > 0 {
+		rules = append(rules, &NewDeviceCountry{Points: config.NewDeviceCountryScore})
+	}
+
+	if config.FailedLoginsScore > 0 {
+		rules = append(rules, &FailedLogins{Threshold: config.FailedLoginsThreshold, Points: config.FailedLoginsScore})
+	}
+
+	if config.BadIPScore > 0 && badIPs != nil {
+		rules = append(rules, &BadIP{List: badIPs, Points: config.BadIPScore})
+	}
+
+	return NewEngineWithRules(rules, config.StepUpScore, config.BlockScore)
+}
+
+// NewEngineWithRules creates an engine with custom rules.
+// stepUpScore and blockScore are the minimum scores to ask for a second factor and to block an attempt.
+func NewEngineWithRules(rules []Rule, stepUpScore int, blockScore int) *Engine {
+	return &Engine{rules: rules, stepUpScore: stepUpScore, blockScore: blockScore}
+}
+
+// Assess scores a login attempt with every rule and decides what to do with it.
+func (e *Engine) Assess(attempt Attempt) Assessment {
+	assessment := Assessment{Decision: Allow, Reasons: make([]string, 0)}
+
+	for _, rule := range e.rules {
+		if score := rule.Score(attempt); score > 0 {
+			assessment.Score += score
+			assessment.Reasons = append(assessment.Reasons, rule.Name())
+		}
+	}
+
+	switch {
+	case e.blockScore > 0 && assessment.Score >= e.blockScore:
+		assessment.Decision = Block
+	case e.stepUpScore > 0 && assessment.Score >= e.stepUpScore:
+		assessment.Decision = StepUp
+	}
+
+	return assessment
+}
+
+// ImpossibleTravel applies when a user couldn't have travelled from the previous login to this one in time.
+type ImpossibleTravel struct {
+	// MaxSpeed is the fastest plausible travel speed, in km/h.
+	MaxSpeed float64
+	// MinDistance is the distance, in km, under which the rule doesn't apply.
+	MinDistance float64
+	Points      int
+}
+
+func (r *ImpossibleTravel) Name() string {
+	return "impossible_travel"
+}
+
+func (r *ImpossibleTravel) Score(attempt Attempt) int {
+	previous := attempt.Previous
+	if previous == nil || !previous.Place.Located || !attempt.Place.Located {
+		return 0
+	}
+
+	distance := Distance(previous.Place, attempt.Place)
+	if distance < r.MinDistance {
+		return 0
+	}
+
+	hours := attempt.Time.Sub(previous.Time).Hours()
+	if hours > 0 && distance/hours <= r.MaxSpeed {
+		return 0
+	}
+
+	return r.Points
+}
+
+// NewDeviceCountry applies when a user logs in from a device and a country never used before.
+type NewDeviceCountry struct {
+	Points int
+}
+
+func (r *NewDeviceCountry) Name() string {
+	return "new_device_and_country"
+}
+
+func (r *NewDeviceCountry) Score(attempt Attempt) int {
+	if attempt.NewDevice && attempt.NewCountry {
+		return r.Points
+	}
+
+	return 0
+}
+
+// FailedLogins applies when a user had many failed logins recently, e.g. during a credential stuffing attack.
+type FailedLogins struct {
+	Threshold int
+	Points    int
+}
+
+func (r *FailedLogins) Name() string {
+	return "failed_logins"
+}
+
+func (r *FailedLogins) Score(attempt Attempt) int {
+	if attempt.RecentFailures >= r.Threshold {
+		return r.Points
+	}
+
+	return 0
+}
+
+// BadIP applies when a login comes from a known bad IP.
+type BadIP struct {
+	List   *IPList
+	Points int
+}
+
+func (r *BadIP) Name() string {
+	return "bad_ip"
+}
+
+func (r *BadIP) Score(attempt Attempt) int {
+	if r.List.Contains(attempt.IP) {
+		return r.Points
+	}
+
+	return 0
+}
+
+// earthRadius is the mean radius of the Earth, in km.
+const earthRadius = 6371.0
+
+// Distance returns the great-circle distance between two located places, in km.
+func Distance(from Place, to Place) float64 {
+	lat1 := from.Latitude * math.Pi / 180
+	lat2 := to.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,126 @@
+package risk
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	buenosAires = Place{Country: "AR", Located: true, Latitude: -34.6, Longitude: -58.4}
+	laPlata     = Place{Country: "AR", Located: true, Latitude: -34.9, Longitude: -57.95}
+	madrid      = Place{Country: "ES", Located: true, Latitude: 40.4, Longitude: -3.7}
+)
+
+func TestDistance(t *testing.T) {
+	t.Run("It should compute the great-circle distance in km", func(t *testing.T) {
+		assert.InDelta(t, 10050, Distance(buenosAires, madrid), 50)
+		assert.InDelta(t, 0, Distance(madrid, madrid), 0.001)
+	})
+}
+
+func TestAssess(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	badIPs, err := ParseIPList(strings.NewReader("203.0.113.0/24 # abusive network\n198.51.100.7\n"))
+	assert.Nil(t, err)
+
+	engine := NewEngine(DefaultConfig(), badIPs)
+
+	t.Run("It should allow a login without signals", func(t *testing.T) {
+		assessment := engine.Assess(Attempt{Time: now, IP: "192.0.2.1", Place: buenosAires})
+
+		assert.Equal(t, Allow, assessment.Decision)
+		assert.Equal(t, 0, assessment.Score)
+		assert.Empty(t, assessment.Reasons)
+	})
+
+	t.Run("It should ask for a second factor on an impossible travel", func(t *testing.T) {
+		assessment := engine.Assess(Attempt{
+			Time:     now,
+			Place:    madrid,
+			Previous: &PreviousLogin{Time: now.Add(-2 * time.Hour), Place: buenosAires},
+		})
+
+		assert.Equal(t, StepUp, assessment.Decision)
+		assert.Equal(t, []string{"impossible_travel"}, assessment.Reasons)
+	})
+
+	t.Run("It should allow a travel that takes long enough or a short distance", func(t *testing.T) {
+		assert.Equal(t, Allow, engine.Assess(Attempt{
+			Time:     now,
+			Place:    madrid,
+			Previous: &PreviousLogin{Time: now.Add(-14 * time.Hour), Place: buenosAires},
+		}).Decision)
+
+		assert.Equal(t, Allow, engine.Assess(Attempt{
+			Time:     now,
+			Place:    laPlata,
+			Previous: &PreviousLogin{Time: now, Place: buenosAires},
+		}).Decision)
+	})
+
+	t.Run("It should ignore the travel if a location is unknown", func(t *testing.T) {
+		assert.Equal(t, Allow, engine.Assess(Attempt{
+			Time:     now,
+			Place:    Place{Country: "ES"},
+			Previous: &PreviousLogin{Time: now.Add(-time.Minute), Place: buenosAires},
+		}).Decision)
+	})
+
+	t.Run("It should ask for a second factor on a new device in a new country", func(t *testing.T) {
+		assert.Equal(t, StepUp, engine.Assess(Attempt{Time: now, NewDevice: true, NewCountry: true}).Decision)
+		assert.Equal(t, Allow, engine.Assess(Attempt{Time: now, NewDevice: true}).Decision)
+	})
+
+	t.Run("It should add up recent failed logins with the other signals", func(t *testing.T) {
+		assessment := engine.Assess(Attempt{Time: now, RecentFailures: 3})
+		assert.Equal(t, Allow, assessment.Decision)
+		assert.Equal(t, 30, assessment.Score)
+
+		assessment = engine.Assess(Attempt{
+			Time:           now,
+			Place:          madrid,
+			NewDevice:      true,
+			NewCountry:     true,
+			RecentFailures: 5,
+			Previous:       &PreviousLogin{Time: now.Add(-time.Hour), Place: buenosAires},
+		})
+		assert.Equal(t, Block, assessment.Decision)
+		assert.Equal(t, 130, assessment.Score)
+		assert.Equal(t, []string{"impossible_travel", "new_device_and_country", "failed_logins"}, assessment.Reasons)
+	})
+
+	t.Run("It should block a known bad IP", func(t *testing.T) {
+		assert.Equal(t, Block, engine.Assess(Attempt{Time: now, IP: "203.0.113.50"}).Decision)
+		assert.Equal(t, Block, engine.Assess(Attempt{Time: now, IP: "198.51.100.7"}).Decision)
+		assert.Equal(t, Allow, engine.Assess(Attempt{Time: now, IP: "198.51.100.8"}).Decision)
+	})
+
+	t.Run("It should disable the rules without score", func(t *testing.T) {
+		config := DefaultConfig()
+		config.BadIPScore = 0
+
+		assert.Equal(t, Allow, NewEngine(config, badIPs).Assess(Attempt{Time: now, IP: "203.0.113.50"}).Decision)
+	})
+}
+
+func TestIPList(t *testing.T) {
+	t.Run("It should match addresses and networks of both families", func(t *testing.T) {
+		list, err := ParseIPList(strings.NewReader("# comment\n\n10.0.0.1\n2001:db8::/32\n"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, list.Len())
+		assert.True(t, list.Contains("10.0.0.1"))
+		assert.True(t, list.Contains("::ffff:10.0.0.1"))
+		assert.True(t, list.Contains("2001:db8::1"))
+		assert.False(t, list.Contains("10.0.0.2"))
+		assert.False(t, list.Contains("invalid"))
+	})
+
+	t.Run("It should reject malformed lines", func(t *testing.T) {
+		_, err := ParseIPList(strings.NewReader("10.0.0.300\n"))
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,174 @@
+// Package routes defines the routes for the API endpoints and the handlers for each route.
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NutriPocket/UserService/app"
+	controller "github.com/NutriPocket/UserService/controller/users"
+	"github.com/NutriPocket/UserService/database"
+	middlewareAuth "github.com/NutriPocket/UserService/middleware/auth_middleware"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoutes(router *gin.Engine, container *app.Container) {
+	h := &handlers{app: container}
+
+	{
+		admin_routes := router.Group("/admin")
+		admin_routes.Use(middlewareAuth.AdminMiddleware(container.UserService))
+		admin_routes.GET("/users", h.getAdminUsers)
+		admin_routes.GET("/users/:id", h.getAdminUser)
+		admin_routes.PUT("/users/:id/state", h.adminAction(setAccountState))
+		admin_routes.PUT("/users/:id/role", h.adminAction(setRole))
+		admin_routes.PUT("/users/:id/email-verified", h.adminAction(setEmailVerified))
+		admin_routes.POST("/users/:id/unlock", h.adminAction(unlockUser))
+		admin_routes.POST("/users/:id/password-reset", h.adminAction(forcePasswordReset))
+		admin_routes.DELETE("/users/:id/sessions", h.adminAction(revokeSessions))
+		admin_routes.GET("/audit", h.getAuditLog)
+		admin_routes.GET("/audit/verify", h.verifyAuditLog)
+		admin_routes.GET("/database/stats", h.getDatabaseStats)
+	}
+}
+
+// queryInt parses a non negative integer query param, 0 if it's missing.
+func queryInt(c *gin.Context, name string) (int, error) {
+	param := c.Query(name)
+	if param == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.Atoi(param)
+	if err != nil || parsed < 0 {
+		return 0, &model.ValidationError{
+			Title:  "Invalid " + name,
+			Detail: "The " + name + " query param must be a positive integer",
+		}
+	}
+
+	return parsed, nil
+}
+
+func (h *handlers) getAdminUsers(c *gin.Context) {
+	var params model.GetAdminUsersParams
+	var err error
+
+	params.State = c.Query("state")
+	params.Email = c.Query("email")
+
+	if params.Limit, err = queryInt(c, "limit"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if params.Offset, err = queryInt(c, "offset"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	users, err := h.app.AdminService.List(params)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": users})
+}
+
+func (h *handlers) getAdminUser(c *gin.Context) {
+	user, err := h.app.AdminService.GetUser(c.Param("id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+// adminAction returns a handler that runs an admin action on the user of the :id param and returns the updated user.
+func (h *handlers) adminAction(action func(c *gin.Context, adminService *service.AdminService, actor model.AuditActor, userId string) (model.AdminUser, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := action(c, h.app.AdminService, auditActor(c), c.Param("id"))
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": user})
+	}
+}
+
+func setAccountState(c *gin.Context, adminService *service.AdminService, actor model.AuditActor, userId string) (model.AdminUser, error) {
+	var body model.AccountStateChange
+
+	if err := c.BindJSON(&body); err != nil {
+		return model.AdminUser{}, &model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a json body with the keys 'state', 'reason' and 'until' in it",
+		}
+	}
+
+	controller := controller.UserController{}
+
+	if err := controller.ValidateAccountStateChange(body, time.Now()); err != nil {
+		return model.AdminUser{}, err
+	}
+
+	return adminService.SetAccountState(actor, userId, body)
+}
+
+func setRole(c *gin.Context, adminService *service.AdminService, actor model.AuditActor, userId string) (model.AdminUser, error) {
+	var body model.RoleChange
+
+	if err := c.BindJSON(&body); err != nil {
+		return model.AdminUser{}, &model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a json body with the key 'role' in it",
+		}
+	}
+
+	controller := controller.UserController{}
+
+	if err := controller.ValidateRole(body.Role); err != nil {
+		return model.AdminUser{}, err
+	}
+
+	return adminService.SetRole(actor, userId, body.Role)
+}
+
+func setEmailVerified(c *gin.Context, adminService *service.AdminService, actor model.AuditActor, userId string) (model.AdminUser, error) {
+	var body model.EmailVerificationChange
+
+	if err := c.BindJSON(&body); err != nil {
+		return model.AdminUser{}, &model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a json body with the key 'verified' in it",
+		}
+	}
+
+	return adminService.SetEmailVerified(actor, userId, body.Verified)
+}
+
+func unlockUser(c *gin.Context, adminService *service.AdminService, actor model.AuditActor, userId string) (model.AdminUser, error) {
+	return adminService.Unlock(actor, userId)
+}
+
+func forcePasswordReset(c *gin.Context, adminService *service.AdminService, actor model.AuditActor, userId string) (model.AdminUser, error) {
+	return adminService.ForcePasswordReset(actor, userId)
+}
+
+func revokeSessions(c *gin.Context, adminService *service.AdminService, actor model.AuditActor, userId string) (model.AdminUser, error) {
+	return adminService.RevokeSessions(actor, userId)
+}
+
+// getDatabaseStats returns the health and the statistics of the connection pool of the database, for the metrics.
+func (h *handlers) getDatabaseStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": database.Stats()})
+}
@@ -0,0 +1,99 @@
+// Package routes defines the routes for the API endpoints and the handlers for each route.
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/gin-gonic/gin"
+)
+
+// auditActor returns the authenticated user of a request and where the request came from.
+func auditActor(c *gin.Context) model.AuditActor {
+	return model.AuditActor{
+		Id:        getAuthUser(c).Id,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestId: c.GetString("requestId"),
+	}
+}
+
+// recordAudit records an action done by the user of a request in the audit log.
+// The request already succeeded, so a failure to record it is only logged.
+// actorId overrides the authenticated user, e.g. on a login, if it isn't empty.
+func (h *handlers) recordAudit(c *gin.Context, actorId string, action string, targetId string, details map[string]any) {
+	actor := auditActor(c)
+	if actorId != "" {
+		actor.Id = actorId
+	}
+
+	h.app.AuditService.Record(actor, action, targetId, details)
+}
+
+// queryTime parses an RFC 3339 query param, nil if it's missing.
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	param := c.Query(name)
+	if param == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, param)
+	if err != nil {
+		return nil, &model.ValidationError{
+			Title:  "Invalid " + name,
+			Detail: "The " + name + " query param must be an RFC 3339 date, e.g. 2006-01-02T15:04:05Z",
+		}
+	}
+
+	return &parsed, nil
+}
+
+func (h *handlers) getAuditLog(c *gin.Context) {
+	var params model.GetAuditParams
+	var err error
+
+	params.ActorId = c.Query("actorId")
+	params.TargetId = c.Query("targetId")
+	params.Action = c.Query("action")
+
+	if params.From, err = queryTime(c, "from"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if params.To, err = queryTime(c, "to"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if params.Limit, err = queryInt(c, "limit"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if params.Offset, err = queryInt(c, "offset"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	entries, err := h.app.AuditService.List(params)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries})
+}
+
+func (h *handlers) verifyAuditLog(c *gin.Context) {
+	verification, err := h.app.AuditService.Verify()
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": verification})
+}
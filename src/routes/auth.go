@@ -2,24 +2,35 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/NutriPocket/UserService/app"
 	controller "github.com/NutriPocket/UserService/controller/users"
 	"github.com/NutriPocket/UserService/model"
-	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/risk"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRoutes(router *gin.Engine) {
+func AuthRoutes(router *gin.Engine, container *app.Container) {
+	h := &handlers{app: container}
+
 	{
 		auth_routes := router.Group("/auth")
-		auth_routes.POST("/register", register)
-		auth_routes.POST("/login", login)
-		auth_routes.POST("/logout", logout)
+		auth_routes.POST("/register", h.register)
+		auth_routes.POST("/login", h.login)
+		auth_routes.POST("/login/verify", h.verifyLogin)
+		auth_routes.POST("/logout", h.logout)
+		// The email links are opened from the inbox, so the token is accepted in the query string too
+		auth_routes.GET("/email/confirm", h.confirmEmailChange)
+		auth_routes.POST("/email/confirm", h.confirmEmailChange)
+		auth_routes.GET("/email/undo", h.undoEmailChange)
+		auth_routes.POST("/email/undo", h.undoEmailChange)
+		auth_routes.POST("/password/reset", h.resetPassword)
 	}
 }
 
-func register(c *gin.Context) {
+func (h *handlers) register(c *gin.Context) {
 	var userData model.BaseUser
 
 	if err := c.BindJSON(&userData); err != nil {
@@ -32,7 +43,7 @@ func register(c *gin.Context) {
 
 	controller := controller.UserController{}
 
-	if err := controller.ValidateString(userData.Username, "username"); err != nil {
+	if err := controller.ValidateUsername(userData.Username); err != nil {
 		c.Error(err)
 		return
 	}
@@ -47,36 +58,31 @@ func register(c *gin.Context) {
 		return
 	}
 
-	jwtService, err := service.NewJWTService(nil)
-	if err != nil {
-		c.Error(err)
-		return
-	}
-
-	service, err := service.NewUserService(nil)
-	if err != nil {
+	if err := h.app.UserService.CheckPassword(userData.Password, userData.Username, userData.Email); err != nil {
 		c.Error(err)
 		return
 	}
 
-	createdUser, err := service.CreateUser(&userData)
+	createdUser, err := h.app.UserService.CreateUser(&userData)
 
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	signed, err := jwtService.Sign(createdUser)
+	signed, err := h.app.JWTService.Sign(createdUser)
 
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
+	h.recordAudit(c, createdUser.Id, model.AuditRegister, createdUser.Id, nil)
+
 	c.JSON(http.StatusCreated, gin.H{"data": createdUser, "token": signed})
 }
 
-func login(c *gin.Context) {
+func (h *handlers) login(c *gin.Context) {
 	body := model.LoginUser{}
 
 	if err := c.BindJSON(&body); err != nil {
@@ -92,36 +98,115 @@ func login(c *gin.Context) {
 	controller.ValidateUsernameOrEmail(body.EmailOrUsername)
 	controller.ValidateString(body.Password, "password")
 
-	jwtService, err := service.NewJWTService(nil)
+	user, err := h.app.UserService.Login(&body)
+
 	if err != nil {
+		h.auditLoginFailure(c, body.EmailOrUsername, err)
 		c.Error(err)
 		return
 	}
 
-	service, err := service.NewUserService(nil)
+	assessment, err := h.app.LoginRiskService.Assess(user.Id, c.ClientIP(), c.Request.UserAgent(), c.GetHeader("X-Device-Id"))
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	user, err := service.Login(&body)
+	details := map[string]any{"score": assessment.Score, "reasons": assessment.Reasons}
+
+	switch assessment.Decision {
+	case risk.Block:
+		h.recordAudit(c, user.Id, model.AuditLoginBlocked, user.Id, details)
+		c.Error(&model.ForbiddenError{
+			Title:  "Login blocked",
+			Detail: "The login looks suspicious and was blocked, please try again later or contact support",
+		})
+		return
+	case risk.StepUp:
+		challenge, err := h.app.StepUpService.Start(user)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		details["method"] = challenge.Method
+		h.recordAudit(c, user.Id, model.AuditLoginStepUp, user.Id, details)
+
+		// There is no token until the challenge is answered in /auth/login/verify
+		c.JSON(http.StatusAccepted, gin.H{"data": challenge})
+		return
+	}
+
+	h.completeLogin(c, user, nil)
+}
+
+// completeLogin responds to a successful login with the user and a new token, and records it.
+// details are added to the audit log entry of the login.
+func (h *handlers) completeLogin(c *gin.Context, user model.User, details map[string]any) {
+	signed, err := h.app.JWTService.Sign(user)
 
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	signed, err := jwtService.Sign(user)
+	h.recordAudit(c, user.Id, model.AuditLogin, user.Id, details)
+	h.recordLogin(c, user)
+
+	c.JSON(http.StatusOK, gin.H{"data": user, "token": signed})
+}
+
+func (h *handlers) verifyLogin(c *gin.Context) {
+	body := model.LoginChallengeResponse{}
+
+	if err := c.BindJSON(&body); err != nil || body.ChallengeId == "" || body.Code == "" {
+		c.Error(&model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a json body with the challengeId and the code in it",
+		})
+		return
+	}
+
+	user, err := h.app.StepUpService.Verify(&body)
 
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": user, "token": signed})
+	h.completeLogin(c, user, map[string]any{"stepUp": true})
+}
+
+// recordLogin adds a successful login to the login history of the user, alerting the user if it's from a new device.
+// The user is already logged in, so a failure is only logged.
+func (h *handlers) recordLogin(c *gin.Context, user model.User) {
+	h.app.LoginHistoryService.Record(user, c.ClientIP(), c.Request.UserAgent(), c.GetHeader("X-Device-Id"))
+}
+
+// auditLoginFailure records a failed login in the audit log, with the reason and the targeted account if it exists.
+func (h *handlers) auditLoginFailure(c *gin.Context, emailOrUsername string, err error) {
+	var authErr *model.AuthenticationError
+	var forbiddenErr *model.ForbiddenError
+	var tooManyErr *model.TooManyRequestsError
+
+	var reason string
+	switch {
+	case errors.As(err, &authErr):
+		reason = "invalid_credentials"
+	case errors.As(err, &forbiddenErr):
+		reason = "account_disabled"
+	case errors.As(err, &tooManyErr):
+		reason = "account_locked"
+	default:
+		return
+	}
+
+	targetId, _ := h.app.UserService.GetUserIdByLogin(emailOrUsername)
+
+	h.recordAudit(c, "", model.AuditLoginFailed, targetId, map[string]any{"reason": reason})
 }
 
-func logout(c *gin.Context) {
+func (h *handlers) logout(c *gin.Context) {
 	body := struct{ Token string }{}
 
 	if err := c.BindJSON(&body); err != nil {
@@ -132,17 +217,108 @@ func logout(c *gin.Context) {
 		return
 	}
 
-	jwtService, err := service.NewJWTService(nil)
+	if err := h.app.JWTService.Blacklist(body.Token); err != nil {
+		c.Error(err)
+
+		return
+	}
+
+	if decoded, err := h.app.JWTService.Decode(body.Token); err == nil {
+		h.recordAudit(c, decoded.Payload.Id, model.AuditLogout, decoded.Payload.Id, nil)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindEmailChangeToken reads the email change token from the query string or the json body.
+func bindEmailChangeToken(c *gin.Context) (string, error) {
+	body := model.EmailChangeToken{Token: c.Query("token")}
+
+	if body.Token == "" && c.Request.Method == http.MethodPost {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			body.Token = ""
+		}
+	}
+
+	if body.Token == "" {
+		return "", &model.ValidationError{
+			Title:  "Missing token",
+			Detail: "Expected the token sent by email in the 'token' query param or json key",
+		}
+	}
+
+	return body.Token, nil
+}
+
+func (h *handlers) confirmEmailChange(c *gin.Context) {
+	token, err := bindEmailChangeToken(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.app.EmailChangeService.Confirm(token)
+
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	if err := jwtService.Blacklist(body.Token); err != nil {
+	h.recordAudit(c, user.Id, model.AuditEmailChangeConfirm, user.Id, nil)
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func (h *handlers) undoEmailChange(c *gin.Context) {
+	token, err := bindEmailChangeToken(c)
+	if err != nil {
 		c.Error(err)
+		return
+	}
+
+	user, err := h.app.EmailChangeService.Undo(token)
 
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	h.recordAudit(c, user.Id, model.AuditEmailChangeUndo, user.Id, nil)
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func (h *handlers) resetPassword(c *gin.Context) {
+	var body model.PasswordResetRequest
+
+	if err := c.BindJSON(&body); err != nil {
+		c.Error(&model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a json body with the keys 'token' and 'password' in it",
+		})
+		return
+	}
+
+	controller := controller.UserController{}
+
+	if err := controller.ValidateString(body.Token, "token"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := controller.ValidateString(body.Password, "password"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.app.PasswordResetService.Reset(&body)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, user.Id, model.AuditPasswordReset, user.Id, nil)
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
 }
@@ -0,0 +1,9 @@
+// Package routes defines the routes for the API endpoints and the handlers for each route.
+package routes
+
+import "github.com/NutriPocket/UserService/app"
+
+// handlers are the handlers of the routes, with the services of the application they use.
+type handlers struct {
+	app *app.Container
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/NutriPocket/UserService/app"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthRoutes defines the liveness and readiness probes, e.g. for the healthcheck of docker-compose.
+func HealthRoutes(router *gin.Engine, container *app.Container) {
+	h := &handlers{app: container}
+
+	router.GET("/healthz", h.getLiveness)
+	router.GET("/readyz", h.getReadiness)
+}
+
+// getLiveness answers while the process can serve requests, without checking its dependencies.
+func (h *handlers) getLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, h.app.HealthService.Live())
+}
+
+// getReadiness answers 200 if every dependency of the service can be used, 503 otherwise, with the result and the
+// latency of each check.
+func (h *handlers) getReadiness(c *gin.Context) {
+	report := h.app.HealthService.Ready()
+
+	code := http.StatusOK
+	if !report.Healthy() {
+		code = http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, report)
+}
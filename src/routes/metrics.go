@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/NutriPocket/UserService/app"
+	"github.com/NutriPocket/UserService/database"
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsNamespace is the prefix of the names of the metrics.
+const MetricsNamespace = "userservice"
+
+// MetricsRoutes defines the metrics scraped by Prometheus.
+func MetricsRoutes(router *gin.Engine, container *app.Container) {
+	h := &handlers{app: container}
+
+	router.GET("/metrics", h.getMetrics)
+}
+
+// getMetrics writes the health and the statistics of the connection pools of the database and its read replicas in
+// the text format of Prometheus.
+func (h *handlers) getMetrics(c *gin.Context) {
+	var body bytes.Buffer
+
+	if err := database.Stats().WritePrometheus(&body, MetricsNamespace); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Data(http.StatusOK, database.PrometheusContentType, body.Bytes())
+}
@@ -2,35 +2,78 @@
 package routes
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 
+	"github.com/NutriPocket/UserService/app"
 	controller "github.com/NutriPocket/UserService/controller/users"
 	"github.com/NutriPocket/UserService/model"
 	"github.com/NutriPocket/UserService/service"
 	"github.com/gin-gonic/gin"
 )
 
-func UsersRoutes(router *gin.Engine) {
+func UsersRoutes(router *gin.Engine, container *app.Container) {
+	h := &handlers{app: container}
+
 	{
 		users_routes := router.Group("/users")
-		users_routes.GET("/", getUsers)
-		users_routes.GET("/:username", getUser)
-		users_routes.PATCH("/:username", updateUser)
+		users_routes.GET("/", h.getUsers)
+		users_routes.POST("/me/username", h.changeUsername)
+		users_routes.POST("/me/email", h.requestEmailChange)
+		users_routes.POST("/me/password", h.changePassword)
+		users_routes.DELETE("/me", h.deleteAccount)
+		users_routes.DELETE("/me/sessions", h.logoutAll)
+		users_routes.POST("/me/export", h.requestDataExport)
+		users_routes.GET("/me/export/:id", h.getDataExport)
+		users_routes.GET("/me/activity", h.getActivity)
+		users_routes.GET("/me/logins", h.getLogins)
+		users_routes.POST("/me/totp", h.enrollTOTP)
+		users_routes.POST("/me/totp/confirm", h.confirmTOTP)
+		users_routes.DELETE("/me/totp", h.disableTOTP)
+		users_routes.GET("/:username", h.getUser)
+		users_routes.PATCH("/:username", h.updateUser)
+		users_routes.PUT("/:username/avatar", h.uploadAvatar)
+		users_routes.GET("/:username/avatar.png", h.getGeneratedAvatar("png"))
+		users_routes.GET("/:username/avatar.svg", h.getGeneratedAvatar("svg"))
+	}
+}
+
+// getAuthUser returns the user of the token validated by the auth middleware.
+func getAuthUser(c *gin.Context) model.User {
+	if authUser, ok := c.Get("authUser"); ok {
+		if user, ok := authUser.(model.User); ok {
+			return user
+		}
 	}
+
+	return model.User{}
 }
 
-func getUsers(c *gin.Context) {
+func (h *handlers) getUsers(c *gin.Context) {
 	var params model.GetUsersParams
 
 	params.SearchUsername = c.Query("searchUsername")
+	// The authenticated user is looking for others, e.g. friends, so it isn't listed.
+	params.ExcludeId = getAuthUser(c).Id
 
-	service, err := service.NewUserService(nil)
-	if err != nil {
-		c.Error(err)
-		return
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 0 {
+			c.Error(&model.ValidationError{
+				Title:  "Invalid limit",
+				Detail: "The limit query param must be a positive integer",
+			})
+			return
+		}
+
+		params.Limit = parsed
 	}
 
-	users, err := service.GetAllUsers(params)
+	users, err := h.app.UserService.GetAllUsers(c.Request.Context(), params)
 
 	if err != nil {
 		c.Error(err)
@@ -40,36 +83,90 @@ func getUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
-func getUser(c *gin.Context) {
+func (h *handlers) getUser(c *gin.Context) {
 	username := c.Param("username")
 
 	controller := controller.UserController{}
 
 	controller.ValidateString(username, "username")
 
-	service, err := service.NewUserService(nil)
+	user, err := h.app.UserService.GetUser(c.Request.Context(), username)
+
+	var moved *model.UsernameMovedError
+	if errors.As(err, &moved) {
+		c.Redirect(http.StatusMovedPermanently, "/users/"+url.PathEscape(moved.Username))
+		return
+	}
+
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	user, err := service.GetUser(username)
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *handlers) changeUsername(c *gin.Context) {
+	var body model.UsernameChange
+
+	if err := c.BindJSON(&body); err != nil {
+		c.Error(&model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a json body with the key 'username' in it",
+		})
+		return
+	}
+
+	controller := controller.UserController{}
+
+	if err := controller.ValidateUsername(body.Username); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.app.UserService.ChangeUsername(getAuthUser(c).Id, body.Username)
 
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	signed, err := h.app.JWTService.Sign(user)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, "", model.AuditUsernameChange, user.Id, nil)
+
+	c.JSON(http.StatusOK, gin.H{"data": user, "token": signed})
 }
 
-func updateUser(c *gin.Context) {
+func (h *handlers) updateUser(c *gin.Context) {
 	username := c.Param("username")
 
 	controller := controller.UserController{}
 
 	controller.ValidateString(username, "username")
 
+	// The profile, e.g. the display name ranked by the search, can only be changed by its user or an admin.
+	if authUser := getAuthUser(c); authUser.Username != username {
+		isAdmin, err := h.app.UserService.IsAdmin(authUser.Id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if !isAdmin {
+			c.Error(&model.ForbiddenError{
+				Title:  "Forbidden",
+				Detail: "You can only change your own profile",
+			})
+			return
+		}
+	}
+
 	var user *model.EditableUser
 
 	if err := c.ShouldBindJSON(&user); err != nil {
@@ -77,18 +174,359 @@ func updateUser(c *gin.Context) {
 		return
 	}
 
-	service, err := service.NewUserService(nil)
+	if err := controller.ValidatePicture(user.Picture); err != nil {
+		c.Error(err)
+		return
+	}
+
+	ret, err := h.app.UserService.UpdateUser(username, user)
+
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	ret, err := service.UpdateUser(username, user)
+	h.recordAudit(c, "", model.AuditProfileUpdate, ret.Id, nil)
+
+	c.JSON(http.StatusOK, ret)
+}
+
+func (h *handlers) uploadAvatar(c *gin.Context) {
+	username := c.Param("username")
+
+	if getAuthUser(c).Username != username {
+		c.Error(&model.ForbiddenError{
+			Title:  "Forbidden",
+			Detail: "You can only change your own avatar",
+		})
+		return
+	}
+
+	maxBytes := h.app.AvatarService.MaxBytes()
+	// Leave room for the multipart boundaries and headers, the file size is checked by the service.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+64<<10)
 
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.Error(&model.ValidationError{
+				Title:  "Avatar too large",
+				Detail: fmt.Sprintf("The avatar must be smaller than %d bytes", maxBytes),
+			})
+			return
+		}
+
+		c.Error(&model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a multipart body with the image in the 'avatar' field",
+		})
+		return
+	}
+
+	if fileHeader.Size > maxBytes {
+		c.Error(&model.ValidationError{
+			Title:  "Avatar too large",
+			Detail: fmt.Sprintf("The avatar must be smaller than %d bytes", maxBytes),
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
+	ret, err := h.app.AvatarService.Upload(username, data)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, "", model.AuditAvatarUpload, ret.Id, nil)
+
 	c.JSON(http.StatusOK, ret)
 }
+
+// getGeneratedAvatar returns a handler that renders the generated avatar of a user in the given format, png or svg.
+func (h *handlers) getGeneratedAvatar(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.Param("username")
+
+		size := service.DefaultGeneratedAvatarSize
+		if param := c.Query("size"); param != "" {
+			parsed, err := strconv.Atoi(param)
+			if err != nil || parsed < service.MinGeneratedAvatarSize || parsed > service.MaxGeneratedAvatarSize {
+				c.Error(&model.ValidationError{
+					Title: "Invalid size",
+					Detail: fmt.Sprintf(
+						"The size query param must be an integer between %d and %d",
+						service.MinGeneratedAvatarSize, service.MaxGeneratedAvatarSize,
+					),
+				})
+				return
+			}
+
+			size = parsed
+		}
+
+		identicon, userId, err := h.app.AvatarService.Identicon(c.Request.Context(), username)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		// The avatar only depends on the user id, the size and the format, so it can be cached for a long time.
+		etag := fmt.Sprintf(`"%s-%d-%s"`, userId, size, format)
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Header("ETag", etag)
+
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		if format == "svg" {
+			c.Data(http.StatusOK, "image/svg+xml", []byte(identicon.SVG(size)))
+			return
+		}
+
+		data, err := identicon.PNG(size)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Data(http.StatusOK, "image/png", data)
+	}
+}
+
+func (h *handlers) requestEmailChange(c *gin.Context) {
+	var body model.EmailChangeRequest
+
+	if err := c.BindJSON(&body); err != nil {
+		c.Error(&model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a json body with the keys 'email' and 'password' in it",
+		})
+		return
+	}
+
+	controller := controller.UserController{}
+
+	if err := controller.ValidateEmail(body.Email); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := controller.ValidateString(body.Password, "password"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	change, err := h.app.EmailChangeService.Request(getAuthUser(c).Id, &body)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, "", model.AuditEmailChangeRequest, change.UserId, nil)
+
+	c.JSON(http.StatusAccepted, gin.H{"data": change})
+}
+
+func (h *handlers) changePassword(c *gin.Context) {
+	var body model.PasswordChangeRequest
+
+	if err := c.BindJSON(&body); err != nil {
+		c.Error(&model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a json body with the keys 'currentPassword' and 'newPassword' in it",
+		})
+		return
+	}
+
+	controller := controller.UserController{}
+
+	if err := controller.ValidateString(body.CurrentPassword, "currentPassword"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := controller.ValidateString(body.NewPassword, "newPassword"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, signed, err := h.app.PasswordChangeService.Change(getAuthUser(c).Id, &body)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, "", model.AuditPasswordChange, user.Id, nil)
+
+	c.JSON(http.StatusOK, gin.H{"data": user, "token": signed})
+}
+
+func (h *handlers) deleteAccount(c *gin.Context) {
+	deletion, err := h.app.AccountDeletionService.RequestDeletion(getAuthUser(c).Id)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, "", model.AuditAccountDeletion, getAuthUser(c).Id, nil)
+
+	c.JSON(http.StatusAccepted, gin.H{"data": deletion})
+}
+
+func (h *handlers) logoutAll(c *gin.Context) {
+	if err := h.app.JWTService.RevokeAll(getAuthUser(c).Id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, "", model.AuditLogoutAll, getAuthUser(c).Id, nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handlers) requestDataExport(c *gin.Context) {
+	export, err := h.app.DataExportService.Request(getAuthUser(c).Id)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, "", model.AuditDataExportRequested, export.UserId, map[string]any{"exportId": export.Id})
+
+	c.JSON(http.StatusAccepted, gin.H{"data": export})
+}
+
+func (h *handlers) getDataExport(c *gin.Context) {
+	export, archive, err := h.app.DataExportService.Get(getAuthUser(c).Id, c.Param("id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	switch export.Status {
+	case model.DataExportReady:
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="nutripocket-export-%s.zip"`, export.Id))
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "application/zip", archive)
+	case model.DataExportPending:
+		c.JSON(http.StatusAccepted, gin.H{"data": export})
+	default:
+		// The export failed, the client has to request a new one.
+		c.JSON(http.StatusOK, gin.H{"data": export})
+	}
+}
+
+func (h *handlers) getActivity(c *gin.Context) {
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	activity, err := h.app.AuditService.Activity(getAuthUser(c).Id, limit)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": activity})
+}
+
+func (h *handlers) getLogins(c *gin.Context) {
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	logins, err := h.app.LoginHistoryService.List(getAuthUser(c).Id, limit)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": logins})
+}
+
+func (h *handlers) enrollTOTP(c *gin.Context) {
+	enrollment, err := h.app.TOTPService.Enroll(getAuthUser(c).Id)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": enrollment})
+}
+
+// bindTOTPCode reads the code of an authenticator app from the body of a request.
+func bindTOTPCode(c *gin.Context) (string, error) {
+	var body model.TOTPCode
+
+	if err := c.BindJSON(&body); err != nil || body.Code == "" {
+		return "", &model.ValidationError{
+			Title:  "Wrong body format",
+			Detail: "Expected a json body with the code of the authenticator app in it",
+		}
+	}
+
+	return body.Code, nil
+}
+
+func (h *handlers) confirmTOTP(c *gin.Context) {
+	code, err := bindTOTPCode(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.app.TOTPService.Confirm(getAuthUser(c).Id, code); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, "", model.AuditTOTPEnable, getAuthUser(c).Id, nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handlers) disableTOTP(c *gin.Context) {
+	code, err := bindTOTPCode(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.app.TOTPService.Disable(getAuthUser(c).Id, code); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordAudit(c, "", model.AuditTOTPDisable, getAuthUser(c).Id, nil)
+
+	c.Status(http.StatusNoContent)
+}
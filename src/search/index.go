@@ -0,0 +1,287 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// SimilarityThreshold is the minimum trigram similarity a document needs to match a query that it doesn't contain.
+const SimilarityThreshold = 0.3
+
+// Relevance tiers, a document in a higher tier always ranks before one in a lower tier.
+const (
+	tierSimilar = iota + 1
+	tierContains
+	tierExactToken
+	tierPrefix
+)
+
+// Document is the searchable representation of an entity.
+type Document struct {
+	// Id is the identifier of the indexed entity.
+	Id string
+	// Fields are the texts that a query is matched against, e.g. the username and the display name.
+	Fields []string
+}
+
+// Result is a document that matched a query.
+type Result struct {
+	Id    string
+	Score float64
+}
+
+type entry struct {
+	fields []string
+	tokens []string
+	// fieldTrigrams are the trigrams of every field, a query is compared against each field on its own.
+	fieldTrigrams []map[string]struct{}
+	// trigrams is the union of fieldTrigrams.
+	trigrams map[string]struct{}
+	// seq is the insertion order of the document, used to rank the most recent documents first on ties.
+	seq uint64
+}
+
+// Index is an in-memory inverted trigram index safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	entries  map[string]*entry
+	postings map[string]map[string]struct{}
+	seq      uint64
+	ready    bool
+}
+
+var users = NewIndex()
+
+// Users returns the process wide index of the users table.
+func Users() *Index {
+	return users
+}
+
+// NewIndex creates an empty index.
+// The index isn't ready until Load is called.
+func NewIndex() *Index {
+	return &Index{
+		entries:  make(map[string]*entry),
+		postings: make(map[string]map[string]struct{}),
+	}
+}
+
+// Load replaces the content of the index with docs and marks it as ready.
+// docs must be ordered from the oldest to the newest document.
+func (index *Index) Load(docs []Document) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.entries = make(map[string]*entry, len(docs))
+	index.postings = make(map[string]map[string]struct{})
+
+	for _, doc := range docs {
+		index.upsert(doc)
+	}
+
+	index.ready = true
+}
+
+// Ready returns true if the index has been loaded and can answer queries.
+func (index *Index) Ready() bool {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return index.ready
+}
+
+// Len returns the number of indexed documents.
+func (index *Index) Len() int {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return len(index.entries)
+}
+
+// Upsert adds a document to the index or replaces the indexed fields of an existing one.
+func (index *Index) Upsert(doc Document) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.upsert(doc)
+}
+
+// Remove deletes a document from the index. Removing an unknown id is a no-op.
+func (index *Index) Remove(id string) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.remove(id)
+}
+
+func (index *Index) upsert(doc Document) {
+	seq := index.seq + 1
+	if old, ok := index.entries[doc.Id]; ok {
+		seq = old.seq
+		index.remove(doc.Id)
+	} else {
+		index.seq++
+	}
+
+	e := &entry{trigrams: make(map[string]struct{}), seq: seq}
+
+	for _, field := range doc.Fields {
+		normalized := Normalize(field)
+		if normalized == "" {
+			continue
+		}
+
+		e.fields = append(e.fields, normalized)
+		e.tokens = append(e.tokens, Tokens(normalized)...)
+
+		fieldTrigrams := Trigrams(normalized)
+		e.fieldTrigrams = append(e.fieldTrigrams, fieldTrigrams)
+
+		for trigram := range fieldTrigrams {
+			e.trigrams[trigram] = struct{}{}
+		}
+	}
+
+	for trigram := range e.trigrams {
+		if index.postings[trigram] == nil {
+			index.postings[trigram] = make(map[string]struct{})
+		}
+		index.postings[trigram][doc.Id] = struct{}{}
+	}
+
+	index.entries[doc.Id] = e
+}
+
+func (index *Index) remove(id string) {
+	e, ok := index.entries[id]
+	if !ok {
+		return
+	}
+
+	for trigram := range e.trigrams {
+		delete(index.postings[trigram], id)
+		if len(index.postings[trigram]) == 0 {
+			delete(index.postings, trigram)
+		}
+	}
+
+	delete(index.entries, id)
+}
+
+// candidates returns the ids of the documents that may match the query.
+// Queries without a token of at least three runes can match inside any word, so every document is a candidate.
+func (index *Index) candidates(tokens []string, trigrams map[string]struct{}) map[string]struct{} {
+	useIndex := false
+	for _, token := range tokens {
+		if utf8.RuneCountInString(token) >= 3 {
+			useIndex = true
+			break
+		}
+	}
+
+	ids := make(map[string]struct{})
+
+	if !useIndex {
+		for id := range index.entries {
+			ids[id] = struct{}{}
+		}
+		return ids
+	}
+
+	for trigram := range trigrams {
+		for id := range index.postings[trigram] {
+			ids[id] = struct{}{}
+		}
+	}
+
+	return ids
+}
+
+// score returns the relevance of an entry for a normalized query, 0 means it doesn't match.
+func (e *entry) score(query string, trigrams map[string]struct{}) float64 {
+	similarity := 0.0
+	tier := 0
+
+	for i, field := range e.fields {
+		similarity = max(similarity, Similarity(trigrams, e.fieldTrigrams[i]))
+
+		switch {
+		case strings.HasPrefix(field, query):
+			tier = max(tier, tierPrefix)
+		case strings.Contains(field, query):
+			tier = max(tier, tierContains)
+		}
+	}
+
+	if tier < tierExactToken {
+		for _, token := range e.tokens {
+			if token == query {
+				tier = tierExactToken
+				break
+			}
+		}
+	}
+
+	if tier == 0 && similarity >= SimilarityThreshold {
+		tier = tierSimilar
+	}
+
+	if tier == 0 {
+		return 0
+	}
+
+	return float64(tier) + similarity
+}
+
+// Search finds the documents matching query, ranked by relevance.
+// A username or display name starting with the query ranks first, then an exact word match,
+// then any other containment and finally documents that are only similar to the query.
+// limit is the maximum number of results, 0 means no limit.
+// It returns the matching results sorted from the most to the least relevant.
+func (index *Index) Search(query string, limit int) []Result {
+	normalized := Normalize(query)
+	if normalized == "" {
+		return []Result{}
+	}
+
+	tokens := Tokens(normalized)
+	trigrams := Trigrams(normalized)
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	type ranked struct {
+		Result
+		seq uint64
+	}
+
+	matches := make([]ranked, 0)
+
+	for id := range index.candidates(tokens, trigrams) {
+		e := index.entries[id]
+
+		if score := e.score(normalized, trigrams); score > 0 {
+			matches = append(matches, ranked{Result: Result{Id: id, Score: score}, seq: e.seq})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].seq > matches[j].seq
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	results := make([]Result, len(matches))
+	for i, match := range matches {
+		results[i] = match.Result
+	}
+
+	return results
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(results []Result) []string {
+	ret := make([]string, len(results))
+	for i, result := range results {
+		ret[i] = result.Id
+	}
+	return ret
+}
+
+func TestNormalize(t *testing.T) {
+	t.Run("It should lowercase and remove the accents of a string", func(t *testing.T) {
+		assert.Equal(t, "jose maria", Normalize("  José MARÍA "))
+	})
+
+	t.Run("It should keep characters without diacritics untouched", func(t *testing.T) {
+		assert.Equal(t, "nutri_pocket42", Normalize("Nutri_Pocket42"))
+	})
+}
+
+func TestSimilarity(t *testing.T) {
+	t.Run("Equal strings have similarity 1", func(t *testing.T) {
+		assert.Equal(t, 1.0, Similarity(Trigrams("jorge"), Trigrams("jorge")))
+	})
+
+	t.Run("Strings without common trigrams have similarity 0", func(t *testing.T) {
+		assert.Equal(t, 0.0, Similarity(Trigrams("jorge"), Trigrams("egroj")))
+	})
+}
+
+func TestSearch(t *testing.T) {
+	newIndex := func() *Index {
+		index := NewIndex()
+		index.Load([]Document{
+			{Id: "1", Fields: []string{"test1"}},
+			{Id: "2", Fields: []string{"jorge", "Jorge Pérez"}},
+			{Id: "3", Fields: []string{"pedro"}},
+			{Id: "4", Fields: []string{"perezoso"}},
+			{Id: "5", Fields: []string{"maria", "María Pérez"}},
+		})
+		return index
+	}
+
+	t.Run("An index is ready after being loaded", func(t *testing.T) {
+		index := NewIndex()
+		assert.False(t, index.Ready())
+
+		index.Load(nil)
+		assert.True(t, index.Ready())
+	})
+
+	t.Run("An empty query returns no results", func(t *testing.T) {
+		assert.Empty(t, newIndex().Search("  ", 0))
+	})
+
+	t.Run("A short query matches the documents containing it, the most recent first", func(t *testing.T) {
+		assert.Equal(t, []string{"4", "3", "2"}, ids(newIndex().Search("o", 0)))
+	})
+
+	t.Run("A prefix match ranks before an exact token match", func(t *testing.T) {
+		assert.Equal(t, []string{"4", "5", "2"}, ids(newIndex().Search("perez", 0)))
+	})
+
+	t.Run("The search is accent and case insensitive", func(t *testing.T) {
+		assert.Equal(t, []string{"5"}, ids(newIndex().Search("MARÍA", 0)))
+	})
+
+	t.Run("A typo still finds similar documents", func(t *testing.T) {
+		assert.Equal(t, []string{"2"}, ids(newIndex().Search("jorje", 0)))
+	})
+
+	t.Run("A reversed string doesn't match", func(t *testing.T) {
+		assert.Empty(t, newIndex().Search("egroj", 0))
+	})
+
+	t.Run("The results are limited", func(t *testing.T) {
+		assert.Len(t, newIndex().Search("e", 2), 2)
+	})
+
+	t.Run("An upserted document replaces the previous fields", func(t *testing.T) {
+		index := newIndex()
+		index.Upsert(Document{Id: "3", Fields: []string{"pablo"}})
+
+		assert.Empty(t, index.Search("pedro", 0))
+		assert.Equal(t, []string{"3"}, ids(index.Search("pablo", 0)))
+		assert.Equal(t, 5, index.Len())
+	})
+
+	t.Run("A removed document is no longer found", func(t *testing.T) {
+		index := newIndex()
+		index.Remove("2")
+
+		assert.Empty(t, index.Search("jorge", 0))
+		assert.Equal(t, 4, index.Len())
+	})
+}
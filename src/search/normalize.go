@@ -0,0 +1,68 @@
+// Package search provides text normalization and an in-process index used to find users by username or display name.
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize lowercases a string and removes its diacritics, so "José" and "jose" are considered equal.
+// s is the string to normalize.
+// It returns the normalized string without leading or trailing spaces.
+func Normalize(s string) string {
+	var builder strings.Builder
+
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		builder.WriteRune(unicode.ToLower(r))
+	}
+
+	return strings.TrimSpace(builder.String())
+}
+
+// Tokens splits an already normalized string into its words.
+// Any rune that isn't a letter or a digit is considered a separator, so "jorge_perez" has two tokens.
+func Tokens(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Trigrams returns the set of trigrams of an already normalized string.
+// Every token is padded with two spaces at the start and one at the end, following pg_trgm,
+// so short strings and word prefixes still produce trigrams.
+func Trigrams(s string) map[string]struct{} {
+	trigrams := make(map[string]struct{})
+
+	for _, token := range Tokens(s) {
+		runes := []rune("  " + token + " ")
+
+		for i := 0; i+3 <= len(runes); i++ {
+			trigrams[string(runes[i:i+3])] = struct{}{}
+		}
+	}
+
+	return trigrams
+}
+
+// Similarity returns the Jaccard similarity between two trigram sets.
+// It returns a number between 0, nothing in common, and 1, the same trigrams.
+func Similarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for trigram := range a {
+		if _, ok := b[trigram]; ok {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,184 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/mailer"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/storage"
+)
+
+// AccountDeletionService is a struct that deletes the accounts of the users.
+// Accounts are first hidden for a grace period, during which logging in reactivates them, and then erased.
+type AccountDeletionService struct {
+	// db is the database the erased users are deleted from, by the erasers of every repository.
+	db             repository.IDatabase
+	userRepository repository.IUserRepository
+	jwtService     *JWTService
+	storage        storage.IStorage
+	mailer         mailer.IMailer
+	// gracePeriod and interval are how long a deleted account can be recovered and how often the expired ones are
+	// erased.
+	gracePeriod time.Duration
+	interval    time.Duration
+}
+
+// NewAccountDeletionService creates a new AccountDeletionService, every nil argument is replaced by its default
+// implementation, configured by settings.
+func NewAccountDeletionService(
+	db repository.IDatabase,
+	userRepository repository.IUserRepository,
+	jwtService *JWTService,
+	fileStorage storage.IStorage,
+	emailMailer mailer.IMailer,
+	settings config.Config,
+) (*AccountDeletionService, error) {
+	var err error
+
+	if db == nil {
+		if db, err = database.GetPoolConnection(); err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if jwtService == nil {
+		if jwtService, err = NewJWTService(nil, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if fileStorage == nil {
+		fileStorage, err = storage.New(settings.Storage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if emailMailer == nil {
+		emailMailer = mailer.New(settings.Mailer)
+	}
+
+	return &AccountDeletionService{
+		db:             db,
+		userRepository: userRepository,
+		jwtService:     jwtService,
+		storage:        fileStorage,
+		mailer:         emailMailer,
+		gracePeriod:    settings.Account.DeletionGracePeriod.Std(),
+		interval:       settings.Account.DeletionInterval.Std(),
+	}, nil
+}
+
+// RequestDeletion schedules the deletion of an account after the grace period.
+// The account is hidden and every token of the user is revoked, logging in again before the deletion reactivates it.
+// userId is the id of the authenticated user.
+// It returns when the account will be erased and an error if the operation fails.
+func (service *AccountDeletionService) RequestDeletion(userId string) (model.AccountDeletion, error) {
+	user, err := service.userRepository.GetUserById(userId)
+	if err != nil {
+		return model.AccountDeletion{}, err
+	}
+
+	if user == (model.User{}) {
+		return model.AccountDeletion{}, &model.NotFoundError{Title: "User not found", Detail: "The authenticated user was not found"}
+	}
+
+	deleteAfter := time.Now().Add(service.gracePeriod).UTC()
+
+	state := model.AccountState{State: model.AccountPendingDeletion, Reason: "Requested by the user", Until: &deleteAfter}
+
+	if err := service.userRepository.SetAccountState(user.Id, state); err != nil {
+		return model.AccountDeletion{}, err
+	}
+
+	rememberAccountState(user.Id, state)
+
+	if err := service.jwtService.RevokeAll(user.Id); err != nil {
+		return model.AccountDeletion{}, err
+	}
+
+	if err := service.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your NutriPocket account will be deleted",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour NutriPocket account and all its data will be deleted on %s.\n"+
+				"If you change your mind, log in before that date to keep your account.\n",
+			user.Username, deleteAfter.Format(time.RFC1123),
+		),
+	}); err != nil {
+		log.Errorf("Failed to notify %s about the deletion of the account %s: %v", user.Email, user.Id, err)
+	}
+
+	return model.AccountDeletion{DeleteAfter: deleteAfter}, nil
+}
+
+// Erase deletes the avatars of a user and runs every registered eraser, removing all the data of the user.
+// userId is the id of the user to erase.
+// It returns an error if the operation fails, in which case the database rows are kept so it can be retried.
+func (service *AccountDeletionService) Erase(userId string) error {
+	for _, size := range avatarSizes {
+		if err := service.storage.Delete(avatarKey(userId, size.name)); err != nil {
+			log.Errorf("Failed to delete the %s avatar of the user %s: %v", size.name, userId, err)
+			return err
+		}
+	}
+
+	return repository.EraseUser(service.db, userId)
+}
+
+// EraseExpired erases the accounts whose grace period has ended.
+// It returns the number of erased accounts and the last error, a failing account doesn't stop the others.
+func (service *AccountDeletionService) EraseExpired() (int, error) {
+	userIds, err := service.userRepository.GetExpiredAccountStates(model.AccountPendingDeletion, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	erased := 0
+	var lastErr error
+
+	for _, userId := range userIds {
+		if err := service.Erase(userId); err != nil {
+			lastErr = err
+			continue
+		}
+
+		log.Infof("Erased the account of the user %s", userId)
+		erased++
+	}
+
+	return erased, lastErr
+}
+
+// Run erases the expired accounts every interval until stop is closed.
+// It's meant to be run in its own goroutine.
+func (service *AccountDeletionService) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(service.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := service.EraseExpired(); err != nil {
+			log.Errorf("Failed to erase the expired accounts: %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
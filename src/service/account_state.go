@@ -0,0 +1,207 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+)
+
+// AccountStateService is a struct that enforces and changes the state of the accounts, e.g. suspensions and bans.
+type AccountStateService struct {
+	repository repository.IUserRepository
+	jwtService *JWTService
+	// users answers the checks without querying the repository, unless the revocation cache is stale.
+	users *UserRevocationCache
+	// interval is how often the expired suspensions and bans are lifted.
+	interval time.Duration
+}
+
+// NewAccountStateService creates a new AccountStateService.
+// userRepository is the repository used to read and save the account states, the default one if nil.
+// jwtService is the service used to revoke the tokens of the suspended and banned users, the default one if nil.
+// settings are how often the expired suspensions and bans are lifted.
+func NewAccountStateService(userRepository repository.IUserRepository, jwtService *JWTService, settings config.Config) (*AccountStateService, error) {
+	var err error
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if jwtService == nil {
+		if jwtService, err = NewJWTService(nil, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	return &AccountStateService{
+		repository: userRepository,
+		jwtService: jwtService,
+		users:      UserRevocations(),
+		interval:   settings.Account.StateInterval.Std(),
+	}, nil
+}
+
+// accountStateError returns the error shown to a user whose account can't be used, nil if it can.
+func accountStateError(state model.AccountState, now time.Time) error {
+	if state.IsActive(now) {
+		return nil
+	}
+
+	detail := func(prefix string) string {
+		if state.Until != nil {
+			prefix += " until " + state.Until.UTC().Format(time.RFC1123)
+		}
+
+		if state.Reason != "" {
+			prefix += ": " + state.Reason
+		}
+
+		return prefix
+	}
+
+	switch state.State {
+	case model.AccountSuspended:
+		return &model.ForbiddenError{Title: "Account suspended", Detail: detail("Your account is suspended")}
+	case model.AccountBanned:
+		return &model.ForbiddenError{Title: "Account banned", Detail: detail("Your account is banned")}
+	case model.AccountPendingDeletion:
+		return &model.AuthenticationError{
+			Title:  "Account pending deletion",
+			Detail: "Your account will be deleted, log in again to keep it",
+		}
+	default:
+		return &model.ForbiddenError{Title: "Account disabled", Detail: "Your account can't be used"}
+	}
+}
+
+// Check checks that the account of a user can be used, lifting its suspension or ban if it has expired.
+// It's used to enforce the account states on the tokens issued before the change.
+// The states cached in memory answer without querying the repository, unless the revocation cache is stale. The
+// cache doesn't know the erased users, whose tokens are revoked by the cutoff of their deletion, which outlives them.
+// userId is the id of the user.
+// It returns an error if the account can't be used or doesn't exist anymore.
+func (service *AccountStateService) Check(userId string) error {
+	now := time.Now()
+	state := service.users.AccountState(userId)
+
+	if service.jwtService.cache.Stale(now) {
+		var err error
+
+		if state, err = service.repository.GetAccountState(userId); err != nil {
+			return err
+		}
+
+		if state.State == "" {
+			return &model.AuthenticationError{Title: "Invalid authorization", Detail: "The user of the token doesn't exist anymore"}
+		}
+	}
+
+	if err := accountStateError(state, now); err != nil {
+		return err
+	}
+
+	if state.State != model.AccountActive {
+		return service.lift(userId, state)
+	}
+
+	return nil
+}
+
+// lift reactivates an account whose suspension or ban has expired.
+func (service *AccountStateService) lift(userId string, state model.AccountState) error {
+	if err := service.repository.SetAccountState(userId, model.AccountState{State: model.AccountActive}); err != nil {
+		return err
+	}
+
+	rememberAccountState(userId, model.AccountState{State: model.AccountActive})
+
+	log.Infof("Lifted the expired %s state of the user %s", state.State, userId)
+	return nil
+}
+
+// Set changes the state of an account, the change is expected to be validated by the controller.
+// Suspending or banning an account revokes its tokens, so the user must log in again once it's lifted.
+// userId is the id of the user.
+// change is the new state of the account.
+// It returns the new state and an error if the user doesn't exist or the operation fails.
+func (service *AccountStateService) Set(userId string, change model.AccountStateChange) (model.AccountState, error) {
+	current, err := service.repository.GetAccountState(userId)
+	if err != nil {
+		return model.AccountState{}, err
+	}
+
+	if current.State == "" {
+		return model.AccountState{}, &model.NotFoundError{Title: "User not found", Detail: "The user with the id " + userId + " was not found"}
+	}
+
+	state := model.AccountState{State: change.State, Reason: change.Reason, Until: change.Until}
+
+	if err := service.repository.SetAccountState(userId, state); err != nil {
+		return model.AccountState{}, err
+	}
+
+	rememberAccountState(userId, state)
+
+	if state.State == model.AccountSuspended || state.State == model.AccountBanned {
+		if err := service.jwtService.RevokeAll(userId); err != nil {
+			return model.AccountState{}, err
+		}
+	}
+
+	log.Infof("Changed the account state of the user %s from %s to %s", userId, current.State, state.State)
+
+	return state, nil
+}
+
+// LiftExpired reactivates the accounts whose suspension or ban has expired.
+// It returns the number of reactivated accounts and the last error, a failing account doesn't stop the others.
+func (service *AccountStateService) LiftExpired() (int, error) {
+	lifted := 0
+	var lastErr error
+
+	for _, state := range []string{model.AccountSuspended, model.AccountBanned} {
+		userIds, err := service.repository.GetExpiredAccountStates(state, time.Now())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, userId := range userIds {
+			if err := service.lift(userId, model.AccountState{State: state}); err != nil {
+				lastErr = err
+				continue
+			}
+
+			lifted++
+		}
+	}
+
+	return lifted, lastErr
+}
+
+// Run lifts the expired suspensions and bans every interval until stop is closed.
+// Expired accounts are also lifted when they are used, this only makes them visible again to the other users.
+// It's meant to be run in its own goroutine.
+func (service *AccountStateService) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(service.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := service.LiftExpired(); err != nil {
+			log.Errorf("Failed to lift the expired account states: %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/mailer"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+)
+
+// AdminService is a struct that implements the user management actions of the admins.
+// Every action is recorded in the audit log with the admin as the actor.
+type AdminService struct {
+	repository           repository.IUserRepository
+	audit                *AuditService
+	accountStateService  *AccountStateService
+	passwordResetService *PasswordResetService
+	jwtService           *JWTService
+}
+
+// NewAdminService creates a new AdminService, every nil argument is replaced by its default implementation,
+// configured by settings.
+func NewAdminService(
+	userRepository repository.IUserRepository,
+	auditRepository repository.IAuditRepository,
+	jwtService *JWTService,
+	passwordResetRepository repository.IPasswordResetRepository,
+	emailMailer mailer.IMailer,
+	settings config.Config,
+) (*AdminService, error) {
+	var err error
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	audit, err := NewAuditService(auditRepository)
+	if err != nil {
+		return nil, err
+	}
+
+	if jwtService == nil {
+		if jwtService, err = NewJWTService(nil, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	accountStateService, err := NewAccountStateService(userRepository, jwtService, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordResetService, err := NewPasswordResetService(userRepository, passwordResetRepository, jwtService, emailMailer, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminService{
+		repository:           userRepository,
+		audit:                audit,
+		accountStateService:  accountStateService,
+		passwordResetService: passwordResetService,
+		jwtService:           jwtService,
+	}, nil
+}
+
+// List lists the users with their role and account state.
+// params are the filters applied to the list.
+// It returns the users and an error if the operation fails.
+func (service *AdminService) List(params model.GetAdminUsersParams) ([]model.AdminUser, error) {
+	users, err := service.repository.GetAdminUsers(params)
+	if err != nil {
+		return users, err
+	}
+
+	for i := range users {
+		users[i].User = withDefaultPicture(users[i].User)
+	}
+
+	return users, nil
+}
+
+// GetUser gets a user with its role and account state.
+// userId is the id of the user.
+// It returns the user and an error if it doesn't exist.
+func (service *AdminService) GetUser(userId string) (model.AdminUser, error) {
+	user, err := service.repository.GetAdminUser(userId)
+	if err != nil {
+		return model.AdminUser{}, err
+	}
+
+	if user.Id == "" {
+		return model.AdminUser{}, &model.NotFoundError{Title: "User not found", Detail: "The user with the id " + userId + " was not found"}
+	}
+
+	user.User = withDefaultPicture(user.User)
+
+	return user, nil
+}
+
+// act checks that the target user exists, runs an action on it and records it in the audit log.
+// It returns the target user after the action.
+func (service *AdminService) act(actor model.AuditActor, action string, userId string, details map[string]any, run func() error) (model.AdminUser, error) {
+	if _, err := service.GetUser(userId); err != nil {
+		return model.AdminUser{}, err
+	}
+
+	if err := run(); err != nil {
+		return model.AdminUser{}, err
+	}
+
+	if err := service.audit.Record(actor, action, userId, details); err != nil {
+		return model.AdminUser{}, err
+	}
+
+	return service.GetUser(userId)
+}
+
+// SetAccountState changes the state of an account, e.g. to suspend it.
+// actor is the admin and where the request came from.
+// userId is the id of the user.
+// change is the new state, expected to be validated by the controller.
+// It returns the updated user and an error if the user doesn't exist or the operation fails.
+func (service *AdminService) SetAccountState(actor model.AuditActor, userId string, change model.AccountStateChange) (model.AdminUser, error) {
+	details := map[string]any{"state": change.State, "reason": change.Reason, "until": change.Until}
+
+	return service.act(actor, model.AuditAdminAccountState, userId, details, func() error {
+		_, err := service.accountStateService.Set(userId, change)
+		return err
+	})
+}
+
+// SetRole changes the role of a user. Admins can't change their own role, so there is always an admin left.
+// actor is the admin and where the request came from.
+// userId is the id of the user.
+// role is the new role, expected to be validated by the controller.
+// It returns the updated user and an error if the user doesn't exist or the operation fails.
+func (service *AdminService) SetRole(actor model.AuditActor, userId string, role string) (model.AdminUser, error) {
+	if actor.Id == userId {
+		return model.AdminUser{}, &model.ForbiddenError{
+			Title:  "Forbidden",
+			Detail: "You can't change your own role",
+		}
+	}
+
+	return service.act(actor, model.AuditAdminRole, userId, map[string]any{"role": role}, func() error {
+		return service.repository.SetRole(userId, role)
+	})
+}
+
+// RevokeSessions invalidates every token of a user.
+// actor is the admin and where the request came from.
+// userId is the id of the user.
+// It returns the user and an error if the user doesn't exist or the operation fails.
+func (service *AdminService) RevokeSessions(actor model.AuditActor, userId string) (model.AdminUser, error) {
+	return service.act(actor, model.AuditAdminRevokeSessions, userId, nil, func() error {
+		return service.jwtService.RevokeAll(userId)
+	})
+}
+
+// Unlock unlocks an account locked after too many failed logins.
+// actor is the admin and where the request came from.
+// userId is the id of the user.
+// It returns the updated user and an error if the user doesn't exist or the operation fails.
+func (service *AdminService) Unlock(actor model.AuditActor, userId string) (model.AdminUser, error) {
+	return service.act(actor, model.AuditAdminUnlock, userId, nil, func() error {
+		return service.repository.Unlock(userId)
+	})
+}
+
+// SetEmailVerified overrides whether the email of a user is verified.
+// actor is the admin and where the request came from.
+// userId is the id of the user.
+// It returns the updated user and an error if the user doesn't exist or the operation fails.
+func (service *AdminService) SetEmailVerified(actor model.AuditActor, userId string, verified bool) (model.AdminUser, error) {
+	return service.act(actor, model.AuditAdminEmailVerified, userId, map[string]any{"verified": verified}, func() error {
+		return service.repository.SetEmailVerified(userId, verified)
+	})
+}
+
+// ForcePasswordReset invalidates the password and the sessions of a user and emails a link to set a new one.
+// actor is the admin and where the request came from.
+// userId is the id of the user.
+// It returns the user and an error if the user doesn't exist or the operation fails.
+func (service *AdminService) ForcePasswordReset(actor model.AuditActor, userId string) (model.AdminUser, error) {
+	return service.act(actor, model.AuditAdminPasswordReset, userId, nil, func() error {
+		return service.passwordResetService.Force(userId)
+	})
+}
@@ -0,0 +1,184 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+)
+
+// DefaultActivityLimit is the number of entries shown in the recent activity of a user when no limit is given.
+const DefaultActivityLimit = 50
+
+// auditVerifyBatch is the number of entries read at once when verifying the chain.
+const auditVerifyBatch = 1000
+
+// errChainBroken stops the walk of the audit log once the chain is broken.
+var errChainBroken = errors.New("audit chain broken")
+
+// AuditService is a struct that records the security relevant actions in the tamper-evident audit log.
+type AuditService struct {
+	repository repository.IAuditRepository
+}
+
+// NewAuditService creates a new AuditService.
+// auditRepository is the repository used to save the entries, the default one if nil.
+func NewAuditService(auditRepository repository.IAuditRepository) (*AuditService, error) {
+	var err error
+
+	if auditRepository == nil {
+		auditRepository, err = repository.NewAuditRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create audit repository: %v", err)
+			return nil, err
+		}
+	}
+
+	return &AuditService{repository: auditRepository}, nil
+}
+
+// Record appends an action to the audit log.
+// actor is who did the action and where the request came from.
+// action is one of the model.Audit* actions.
+// targetId is the id of the user affected by the action, empty if none.
+// details are the parameters of the action, marshalled to a JSON object. They must not contain personal data.
+// It returns an error if the entry couldn't be saved.
+func (service *AuditService) Record(actor model.AuditActor, action string, targetId string, details map[string]any) error {
+	if details == nil {
+		details = map[string]any{}
+	}
+
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	entry := model.AuditEntry{
+		ActorId:   actor.Id,
+		Action:    action,
+		TargetId:  targetId,
+		Details:   string(encoded),
+		IP:        truncate(actor.IP, 45),
+		UserAgent: truncate(actor.UserAgent, 255),
+		RequestId: truncate(actor.RequestId, 64),
+		CreatedAt: time.Now(),
+	}
+
+	if err := service.repository.Append(&entry); err != nil {
+		log.Errorf("Failed to record the %s action of %s on %s: %v", action, actor.Id, targetId, err)
+		return err
+	}
+
+	return nil
+}
+
+// truncate cuts a string to fit in a column of the given length, without splitting a multi-byte character.
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+
+	return strings.ToValidUTF8(value[:length], "")
+}
+
+// List lists the entries of the audit log, the newest first.
+// params are the filters applied to the list.
+// It returns the entries and an error if the operation fails.
+func (service *AuditService) List(params model.GetAuditParams) ([]model.AuditEntry, error) {
+	return service.repository.List(params)
+}
+
+// Activity returns the recent actions done on the account of a user, the newest first.
+// userId is the id of the user.
+// limit is the maximum number of actions, DefaultActivityLimit if 0.
+// It returns the actions and an error if the operation fails.
+func (service *AuditService) Activity(userId string, limit int) ([]model.Activity, error) {
+	if limit <= 0 {
+		limit = DefaultActivityLimit
+	}
+
+	entries, err := service.repository.List(model.GetAuditParams{TargetId: userId, Limit: limit})
+	if err != nil {
+		return []model.Activity{}, err
+	}
+
+	activity := make([]model.Activity, 0, len(entries))
+	for _, entry := range entries {
+		action := model.Activity{Action: entry.Action, CreatedAt: entry.CreatedAt}
+
+		// Where the request came from is only shown for the actions of the user, not of admins or anonymous requests.
+		if entry.ActorId == userId {
+			action.IP = entry.IP
+			action.UserAgent = entry.UserAgent
+		}
+
+		activity = append(activity, action)
+	}
+
+	return activity, nil
+}
+
+// Verify walks the audit log checking that every entry matches its hash and the hash of the previous entry.
+// It returns the result of the verification and an error if the audit log couldn't be read.
+func (service *AuditService) Verify() (model.AuditVerification, error) {
+	result := model.AuditVerification{Valid: true}
+	prevHash := model.AuditGenesisHash
+
+	broken := func(entry model.AuditEntry, reason string) error {
+		result.Valid = false
+		result.BrokenAt = &entry.Id
+		result.Reason = reason
+		return errChainBroken
+	}
+
+	err := service.repository.Walk(auditVerifyBatch, func(entries []model.AuditEntry) error {
+		for _, entry := range entries {
+			if entry.PrevHash != prevHash {
+				return broken(entry, "The previous hash doesn't match, an entry was deleted or inserted before this one")
+			}
+
+			if !entry.PIIErased && entry.ComputePIIDigest() != entry.PIIDigest {
+				return broken(entry, "The users, IP or user agent of the entry were modified")
+			}
+
+			// The flag isn't covered by the hash, as it's set after the entry is recorded, so an erased entry must
+			// have lost its IP, user agent and the id of the erased user.
+			if entry.PIIErased && (entry.IP != "" || entry.UserAgent != "" || (entry.ActorId != "" && entry.TargetId != "")) {
+				return broken(entry, "The entry is marked as erased but still has its IP, user agent or users")
+			}
+
+			if entry.ComputeHash() != entry.Hash {
+				return broken(entry, "The entry was modified")
+			}
+
+			prevHash = entry.Hash
+			result.Checked++
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errChainBroken) {
+		return result, nil
+	}
+
+	if err != nil {
+		return model.AuditVerification{}, err
+	}
+
+	head, err := service.repository.Head()
+	if err != nil {
+		return model.AuditVerification{}, err
+	}
+
+	if head != prevHash {
+		result.Valid = false
+		result.Reason = "The last entries of the audit log were deleted"
+	}
+
+	return result, nil
+}
@@ -0,0 +1,182 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/identicon"
+	"github.com/NutriPocket/UserService/imaging"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/storage"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// avatarSizes are the side, in pixels, of the thumbnails generated for every avatar.
+var avatarSizes = []struct {
+	name string
+	side int
+}{
+	{"small", 64},
+	{"medium", 256},
+	{"large", 512},
+}
+
+// Limits of the size of the generated avatars.
+const (
+	MinGeneratedAvatarSize     = 16
+	MaxGeneratedAvatarSize     = 1024
+	DefaultGeneratedAvatarSize = 256
+)
+
+// avatarMimes are the image formats accepted as avatars.
+var avatarMimes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// AvatarService is a struct that processes the uploaded avatars and saves them in a storage.
+type AvatarService struct {
+	repository repository.IUserRepository
+	storage    storage.IStorage
+	// maxBytes is the maximum size of an uploaded avatar.
+	maxBytes int64
+}
+
+// NewAvatarService creates a new AvatarService.
+// userRepository is the repository used to save the avatar URLs, the default one if nil.
+// fileStorage is where the thumbnails are saved, the one configured by settings if nil.
+// settings are the storage and the maximum size of the avatars.
+func NewAvatarService(userRepository repository.IUserRepository, fileStorage storage.IStorage, settings config.Config) (*AvatarService, error) {
+	var err error
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if fileStorage == nil {
+		fileStorage, err = storage.New(settings.Storage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &AvatarService{repository: userRepository, storage: fileStorage, maxBytes: int64(settings.Account.AvatarMaxBytes)}, nil
+}
+
+// MaxBytes returns the maximum size of an uploaded avatar.
+func (service *AvatarService) MaxBytes() int64 {
+	return service.maxBytes
+}
+
+// avatarKey returns the storage key of an avatar size. Keys don't change between uploads so URLs are stable.
+func avatarKey(userId string, size string) string {
+	return fmt.Sprintf("avatars/%s/%s.jpg", userId, size)
+}
+
+// Upload replaces the avatar of a user.
+// The image is sniffed, decoded, stripped of its metadata and resized to every avatar size.
+// username is the username of the avatar owner.
+// data is the content of the uploaded file.
+// It returns the updated user and an error if the operation fails.
+func (service *AvatarService) Upload(username string, data []byte) (model.User, error) {
+	if int64(len(data)) > service.maxBytes {
+		return model.User{}, &model.ValidationError{
+			Title:  "Avatar too large",
+			Detail: fmt.Sprintf("The avatar must be smaller than %d bytes", service.maxBytes),
+		}
+	}
+
+	mime := mimetype.Detect(data)
+	if !mimetype.EqualsAny(mime.String(), avatarMimes...) {
+		return model.User{}, &model.ValidationError{
+			Title:  "Unsupported avatar format",
+			Detail: "The avatar must be a JPEG, PNG or GIF image, got " + mime.String(),
+		}
+	}
+
+	// The display name is written back with the picture, so it's read from the primary as it was last written.
+	user, err := service.repository.Primary().GetUser(username)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if user == (model.User{}) {
+		return user, &model.NotFoundError{Title: "User not found", Detail: "The user with the username " + username + " was not found"}
+	}
+
+	img, err := imaging.Decode(data)
+	if err != nil {
+		detail := "The avatar couldn't be decoded as an image"
+		if errors.Is(err, imaging.ErrTooManyPixels) {
+			detail = "The avatar dimensions are too large"
+		}
+
+		return model.User{}, &model.ValidationError{Title: "Invalid avatar", Detail: detail}
+	}
+
+	urls := make(map[string]string, len(avatarSizes))
+
+	for _, size := range avatarSizes {
+		thumbnail, err := imaging.EncodeJPEG(imaging.Thumbnail(img, size.side))
+		if err != nil {
+			return model.User{}, err
+		}
+
+		key := avatarKey(user.Id, size.name)
+		if err := service.storage.Put(key, thumbnail, "image/jpeg"); err != nil {
+			log.Errorf("Failed to store avatar %s: %v", key, err)
+			return model.User{}, err
+		}
+
+		urls[size.name] = service.storage.URL(key)
+	}
+
+	user.Picture = model.Picture{Small: urls["small"], Medium: urls["medium"], Large: urls["large"]}
+
+	return service.repository.UpdateUser(user.Id, &user.EditableUser)
+}
+
+// DefaultPicture returns the URLs of the generated avatar of a user, in the same sizes as the uploaded ones.
+// username is the username of the user.
+func DefaultPicture(username string) model.Picture {
+	urls := make(map[string]string, len(avatarSizes))
+
+	for _, size := range avatarSizes {
+		urls[size.name] = fmt.Sprintf("/users/%s/avatar.png?size=%d", url.PathEscape(username), size.side)
+	}
+
+	return model.Picture{Small: urls["small"], Medium: urls["medium"], Large: urls["large"]}
+}
+
+// withDefaultPicture sets the generated avatar as the picture of a user without one.
+func withDefaultPicture(user model.User) model.User {
+	if user.Picture.IsZero() && user.Username != "" {
+		user.Picture = DefaultPicture(user.Username)
+	}
+
+	return user
+}
+
+// Identicon returns the generated avatar of a user.
+// The identicon is derived from the user id, so it doesn't change if the user changes the username.
+// ctx is the context of the request, whose lookups read from the primary once it wrote.
+// username is the username of the user.
+// It returns the identicon and the user id, and an error if the user doesn't exist.
+func (service *AvatarService) Identicon(ctx context.Context, username string) (identicon.Identicon, string, error) {
+	user, err := service.repository.WithContext(ctx).GetUser(username)
+	if err != nil {
+		return identicon.Identicon{}, "", err
+	}
+
+	if user == (model.User{}) {
+		return identicon.Identicon{}, "", &model.NotFoundError{Title: "User not found", Detail: "The user with the username " + username + " was not found"}
+	}
+
+	return identicon.New(user.Id), user.Id, nil
+}
@@ -0,0 +1,237 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/google/uuid"
+)
+
+// dataExportTimeout is how long an export can be pending before it's considered lost, e.g. after a restart.
+const dataExportTimeout = 15 * time.Minute
+
+// DataExportService is a struct that builds the archives with all the data stored about the users.
+// The archive has a JSON file per registered repository.UserDataExporter and a human-readable summary.
+type DataExportService struct {
+	// db is the database the exported data is read from, by the exporters of every repository.
+	db             repository.IDatabase
+	userRepository repository.IUserRepository
+	repository     repository.IDataExportRepository
+	// ttl is how long a finished export can be downloaded.
+	ttl time.Duration
+}
+
+// NewDataExportService creates a new DataExportService, every nil argument is replaced by its default implementation.
+// settings are how long the exports can be downloaded.
+func NewDataExportService(
+	db repository.IDatabase,
+	userRepository repository.IUserRepository,
+	dataExportRepository repository.IDataExportRepository,
+	settings config.Config,
+) (*DataExportService, error) {
+	var err error
+
+	if db == nil {
+		if db, err = database.GetPoolConnection(); err != nil {
+			log.Errorf("Failed to connect to database")
+			return nil, err
+		}
+	}
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if dataExportRepository == nil {
+		dataExportRepository, err = repository.NewDataExportRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create data export repository: %v", err)
+			return nil, err
+		}
+	}
+
+	return &DataExportService{
+		db:             db,
+		userRepository: userRepository,
+		repository:     dataExportRepository,
+		ttl:            settings.Account.DataExportTTL.Std(),
+	}, nil
+}
+
+// Request starts building an export of the data of a user in the background.
+// If the user already has a pending export, that one is returned instead of starting another.
+// userId is the id of the authenticated user.
+// It returns the pending export and an error if the operation fails.
+func (service *DataExportService) Request(userId string) (model.DataExport, error) {
+	if err := service.repository.DeleteExpired(time.Now()); err != nil {
+		return model.DataExport{}, err
+	}
+
+	pending, err := service.repository.GetPending(userId)
+	if err != nil {
+		return model.DataExport{}, err
+	}
+
+	if pending.Id != "" {
+		if time.Since(pending.CreatedAt) < dataExportTimeout {
+			return pending, nil
+		}
+
+		if err := service.repository.Fail(pending.Id); err != nil {
+			return model.DataExport{}, err
+		}
+	}
+
+	user, err := service.userRepository.GetUserById(userId)
+	if err != nil {
+		return model.DataExport{}, err
+	}
+
+	if user == (model.User{}) {
+		return model.DataExport{}, &model.NotFoundError{Title: "User not found", Detail: "The authenticated user was not found"}
+	}
+
+	export := model.DataExport{
+		Id:        uuid.NewString(),
+		UserId:    user.Id,
+		Status:    model.DataExportPending,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := service.repository.Create(&export); err != nil {
+		return model.DataExport{}, err
+	}
+
+	go service.build(export, user)
+
+	return export, nil
+}
+
+// build gathers the data of a user and saves the archive, or marks the export as failed.
+func (service *DataExportService) build(export model.DataExport, user model.User) {
+	archive, err := service.archive(user, export.CreatedAt)
+	if err != nil {
+		log.Errorf("Failed to build the data export %s: %v", export.Id, err)
+
+		if err := service.repository.Fail(export.Id); err != nil {
+			log.Errorf("Failed to mark the data export %s as failed: %v", export.Id, err)
+		}
+
+		return
+	}
+
+	expiresAt := time.Now().Add(service.ttl)
+
+	if err := service.repository.Complete(export.Id, archive, expiresAt); err != nil {
+		log.Errorf("Failed to save the data export %s: %v", export.Id, err)
+		return
+	}
+
+	log.Infof("Built the data export %s of the user %s", export.Id, user.Id)
+}
+
+// archive returns a ZIP with a JSON file per exporter and a summary.txt describing them.
+func (service *DataExportService) archive(user model.User, generatedAt time.Time) ([]byte, error) {
+	exported, err := repository.ExportUser(service.db, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+
+	var summary bytes.Buffer
+	fmt.Fprintf(&summary, "NutriPocket data export\n\n")
+	fmt.Fprintf(&summary, "User: %s (%s)\n", user.Username, user.Id)
+	fmt.Fprintf(&summary, "Generated at: %s\n\n", generatedAt.UTC().Format(time.RFC1123))
+	fmt.Fprintf(&summary, "This archive contains all the data NutriPocket stores about you, a JSON file for each kind of data:\n\n")
+
+	for _, data := range exported {
+		content, err := json.MarshalIndent(data.Data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := writer.Create(data.Exporter.Name + ".json")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := file.Write(content); err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&summary, "- %s.json: %s (%s)\n", data.Exporter.Name, data.Exporter.Description, records(data.Data))
+	}
+
+	file, err := writer.Create("summary.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Write(summary.Bytes()); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// records describes how many records an exporter returned, a list has one per element and anything else is one.
+func records(data any) string {
+	count := 1
+	if value := reflect.ValueOf(data); value.Kind() == reflect.Slice {
+		count = value.Len()
+	}
+
+	if count == 1 {
+		return "1 record"
+	}
+
+	return fmt.Sprintf("%d records", count)
+}
+
+// Get gets an export of a user and, if it's ready, its archive.
+// userId is the id of the authenticated user.
+// id is the id of the export.
+// It returns the export, the archive, nil unless the export is ready, and an error if the user has no such export.
+func (service *DataExportService) Get(userId string, id string) (model.DataExport, []byte, error) {
+	export, err := service.repository.Get(userId, id)
+	if err != nil {
+		return model.DataExport{}, nil, err
+	}
+
+	if export.Id == "" || (export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt)) {
+		return model.DataExport{}, nil, &model.NotFoundError{
+			Title:  "Export not found",
+			Detail: "The export with the id " + id + " was not found or has expired",
+		}
+	}
+
+	if export.Status != model.DataExportReady {
+		return export, nil, nil
+	}
+
+	archive, err := service.repository.GetArchive(export.Id)
+	if err != nil {
+		return model.DataExport{}, nil, err
+	}
+
+	return export, archive, nil
+}
@@ -0,0 +1,253 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/mailer"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/google/uuid"
+)
+
+var invalidEmailChangeToken = &model.ValidationError{
+	Title:  "Invalid token",
+	Detail: "The provided token is invalid or has expired",
+}
+
+// EmailChangeService is a struct that changes the email of the users once the new address is confirmed.
+// The old address is notified and can undo the change, which protects the accounts from takeovers.
+type EmailChangeService struct {
+	userRepository repository.IUserRepository
+	repository     repository.IEmailChangeRepository
+	mailer         mailer.IMailer
+	// settings are the lifetime of the links and the public URL they point to.
+	settings config.Config
+}
+
+// NewEmailChangeService creates a new EmailChangeService, every nil argument is replaced by its default
+// implementation, configured by settings.
+func NewEmailChangeService(
+	userRepository repository.IUserRepository,
+	emailChangeRepository repository.IEmailChangeRepository,
+	emailMailer mailer.IMailer,
+	settings config.Config,
+) (*EmailChangeService, error) {
+	var err error
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if emailChangeRepository == nil {
+		emailChangeRepository, err = repository.NewEmailChangeRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create email change repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if emailMailer == nil {
+		emailMailer = mailer.New(settings.Mailer)
+	}
+
+	return &EmailChangeService{
+		userRepository: userRepository,
+		repository:     emailChangeRepository,
+		mailer:         emailMailer,
+		settings:       settings,
+	}, nil
+}
+
+// Request starts the change of the email of a user.
+// A confirmation link is sent to the new address and a notification with an undo link to the current one.
+// Any previous pending change of the user is cancelled.
+// userId is the id of the authenticated user.
+// request contains the new email and the current password of the user.
+// It returns the pending change and an error if the password is wrong or the email is in use.
+func (service *EmailChangeService) Request(userId string, request *model.EmailChangeRequest) (model.EmailChange, error) {
+	user, err := service.userRepository.GetUserById(userId)
+	if err != nil {
+		return model.EmailChange{}, err
+	}
+
+	if user == (model.User{}) {
+		return model.EmailChange{}, &model.NotFoundError{Title: "User not found", Detail: "The authenticated user was not found"}
+	}
+
+	savedUser, err := service.userRepository.GetUserWithPassword(user.Username)
+	if err != nil {
+		return model.EmailChange{}, err
+	}
+
+	if savedUser.Password != hashPassword(request.Password) {
+		return model.EmailChange{}, &model.AuthenticationError{
+			Title:  "Wrong password",
+			Detail: "The provided password doesn't match your current password",
+		}
+	}
+
+	if request.Email == user.Email {
+		return model.EmailChange{}, &model.ValidationError{
+			Title:  "Same email",
+			Detail: "The new email must be different from the current one",
+		}
+	}
+
+	if existing, err := service.userRepository.GetUserWithPassword(request.Email); err != nil {
+		return model.EmailChange{}, err
+	} else if existing.Email == request.Email {
+		return model.EmailChange{}, &model.EntityAlreadyExistsError{
+			Title:  "Email already in use",
+			Detail: "The provided email is already in use, try something else",
+		}
+	}
+
+	confirmToken, err := newToken()
+	if err != nil {
+		return model.EmailChange{}, err
+	}
+
+	undoToken, err := newToken()
+	if err != nil {
+		return model.EmailChange{}, err
+	}
+
+	now := time.Now()
+	change := model.EmailChange{
+		Id:               uuid.NewString(),
+		UserId:           user.Id,
+		OldEmail:         user.Email,
+		NewEmail:         request.Email,
+		ConfirmTokenHash: hashToken(confirmToken),
+		UndoTokenHash:    hashToken(undoToken),
+		Status:           model.EmailChangePending,
+		ExpiresAt:        now.Add(service.settings.Account.EmailChangeConfirmTTL.Std()),
+		UndoExpiresAt:    now.Add(service.settings.Account.EmailChangeUndoTTL.Std()),
+	}
+
+	if err := service.repository.CancelPending(user.Id); err != nil {
+		return model.EmailChange{}, err
+	}
+
+	if err := service.repository.Create(&change); err != nil {
+		return model.EmailChange{}, err
+	}
+
+	if err := service.mailer.Send(mailer.Message{
+		To:      change.NewEmail,
+		Subject: "Confirm your new NutriPocket email",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nConfirm that this is the new email of your NutriPocket account by opening this link before %s:\n\n%s\n",
+			user.Username, change.ExpiresAt.UTC().Format(time.RFC1123), service.link("confirm", confirmToken),
+		),
+	}); err != nil {
+		return model.EmailChange{}, err
+	}
+
+	if err := service.mailer.Send(mailer.Message{
+		To:      change.OldEmail,
+		Subject: "Your NutriPocket email is being changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to change the email of your NutriPocket account to %s.\n"+
+				"If it wasn't you, undo the change and change your password by opening this link before %s:\n\n%s\n",
+			user.Username, change.NewEmail, change.UndoExpiresAt.UTC().Format(time.RFC1123), service.link("undo", undoToken),
+		),
+	}); err != nil {
+		log.Errorf("Failed to notify %s about the email change %s: %v", change.OldEmail, change.Id, err)
+	}
+
+	return change, nil
+}
+
+// link returns the link sent by email to confirm or undo an email change.
+func (service *EmailChangeService) link(action string, token string) string {
+	return publicURL(service.settings.Server) + "/auth/email/" + action + "?token=" + url.QueryEscape(token)
+}
+
+// Confirm swaps the email of a user with the new one of a pending change.
+// token is the confirmation token sent to the new address.
+// It returns the updated user and an error if the token is invalid, expired or the email is now in use.
+func (service *EmailChangeService) Confirm(token string) (model.User, error) {
+	change, err := service.repository.GetByConfirmToken(hashToken(token))
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if change.Id == "" || change.Status != model.EmailChangePending || time.Now().After(change.ExpiresAt) {
+		return model.User{}, invalidEmailChangeToken
+	}
+
+	user, err := service.userRepository.GetUserById(change.UserId)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	// The email changed in some other way after the request, so the request is outdated.
+	if user.Email != change.OldEmail {
+		if err := service.repository.SetStatus(change.Id, model.EmailChangeCancelled); err != nil {
+			return model.User{}, err
+		}
+
+		return model.User{}, invalidEmailChangeToken
+	}
+
+	updated, err := service.userRepository.ChangeEmail(change.UserId, change.NewEmail)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if err := service.repository.SetStatus(change.Id, model.EmailChangeConfirmed); err != nil {
+		return model.User{}, err
+	}
+
+	return withDefaultPicture(updated), nil
+}
+
+// Undo cancels a pending email change or, if it was already confirmed, restores the previous email.
+// token is the undo token sent to the previous address.
+// It returns the user and an error if the token is invalid or expired.
+func (service *EmailChangeService) Undo(token string) (model.User, error) {
+	change, err := service.repository.GetByUndoToken(hashToken(token))
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if change.Id == "" || time.Now().After(change.UndoExpiresAt) {
+		return model.User{}, invalidEmailChangeToken
+	}
+
+	switch change.Status {
+	case model.EmailChangePending:
+		if err := service.repository.SetStatus(change.Id, model.EmailChangeCancelled); err != nil {
+			return model.User{}, err
+		}
+
+		user, err := service.userRepository.GetUserById(change.UserId)
+		if err != nil {
+			return model.User{}, err
+		}
+
+		return withDefaultPicture(user), nil
+	case model.EmailChangeConfirmed:
+		updated, err := service.userRepository.ChangeEmail(change.UserId, change.OldEmail)
+		if err != nil {
+			return model.User{}, err
+		}
+
+		if err := service.repository.SetStatus(change.Id, model.EmailChangeUndone); err != nil {
+			return model.User{}, err
+		}
+
+		return withDefaultPicture(updated), nil
+	default:
+		return model.User{}, invalidEmailChangeToken
+	}
+}
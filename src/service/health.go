@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/migrate"
+)
+
+// HealthCheckTimeout is how long each check of the readiness probe can take before it fails.
+const HealthCheckTimeout = 2 * time.Second
+
+const (
+	// HealthOK is the status of a check that passed, and of a report whose checks all passed.
+	HealthOK = "ok"
+	// HealthFailing is the status of a check that failed or timed out, and of a report with a failing check.
+	HealthFailing = "failing"
+)
+
+// HealthCheck is a dependency the service needs to answer the requests.
+type HealthCheck struct {
+	Name string
+	// Check returns an error if the dependency can't be used, it must return once ctx is done.
+	Check func(ctx context.Context) error
+}
+
+// CheckResult is the result of a HealthCheck.
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport is the answer of the liveness and readiness probes, with the result of each check by name.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Healthy returns true if every check of the report passed.
+func (report HealthReport) Healthy() bool {
+	return report.Status == HealthOK
+}
+
+// HealthService is a struct that checks the dependencies of the service for the liveness and readiness probes.
+type HealthService struct {
+	checks []HealthCheck
+}
+
+// NewHealthService creates a new HealthService checking the database of ConnectDB, that its migrations are applied
+// and that the JWT key can sign tokens.
+// jwtService is the service whose key is checked.
+// It returns an error if jwtService is nil.
+func NewHealthService(jwtService *JWTService) (*HealthService, error) {
+	if jwtService == nil {
+		return nil, errors.New("the health checks need the JWT service")
+	}
+
+	return NewHealthServiceWithChecks([]HealthCheck{
+		{Name: "database", Check: database.Ping},
+		{Name: "migrations", Check: checkMigrations},
+		{Name: "jwtKey", Check: func(ctx context.Context) error { return jwtService.CheckKey() }},
+	}), nil
+}
+
+// NewHealthServiceWithChecks creates a new HealthService with its own checks, e.g. for the tests.
+func NewHealthServiceWithChecks(checks []HealthCheck) *HealthService {
+	return &HealthService{checks: checks}
+}
+
+// checkMigrations checks that the migrations of this version of the service are applied to the database of ConnectDB.
+func checkMigrations(ctx context.Context) error {
+	migrator, err := migrate.New(database.GetConnection(), database.CurrentDialect())
+	if err != nil {
+		return err
+	}
+
+	return migrator.Check(ctx)
+}
+
+// Live returns the report of the liveness probe. It doesn't check the dependencies, a process that answers is alive,
+// so it isn't restarted while a dependency is down.
+func (service *HealthService) Live() HealthReport {
+	return HealthReport{Status: HealthOK}
+}
+
+// Ready returns the report of the readiness probe, running every check at once, each for up to HealthCheckTimeout.
+// Its status is HealthFailing if a check fails.
+func (service *HealthService) Ready() HealthReport {
+	report := HealthReport{Status: HealthOK, Checks: make(map[string]CheckResult, len(service.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range service.checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			result := runCheck(check)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[check.Name] = result
+			if result.Status != HealthOK {
+				report.Status = HealthFailing
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+// runCheck runs a check, failing it if it doesn't return within HealthCheckTimeout.
+func runCheck(check HealthCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(context.Background(), HealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+
+	go func() {
+		done <- check.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: HealthOK, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		log.Errorf("The %s health check failed: %v", check.Name, err)
+		result.Status = HealthFailing
+		result.Error = err.Error()
+	}
+
+	return result
+}
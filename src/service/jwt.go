@@ -4,13 +4,14 @@ package service
 import (
 	"errors"
 	"fmt"
-	"os"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/NutriPocket/UserService/config"
 	"github.com/NutriPocket/UserService/model"
 	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/revocation"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -20,19 +21,22 @@ type JWTService struct {
 	key []byte
 	// repository is the repository that will be used to interact with the jwt_blacklist table.
 	repository repository.IJWTRepository
+	// cache answers most blacklist checks without querying the repository.
+	cache *revocation.Cache
+	// users answers the revocation checks without querying the repository, unless cache is stale.
+	users *UserRevocationCache
 }
 
-var jwtKey = os.Getenv("JWT_SECRET_KEY")
+// tokenLifetime is how long the tokens are valid after they're issued.
+const tokenLifetime = config.TokenLifetime
 
 // NewJWTService creates a new JWTService with the provided IJWTRepository.
 // jwtRepository is the repository that will be used to interact with the jwt_blacklist table.
+// settings are the key the tokens are signed with and the settings of the revocation cache, which is loaded by a
+// RevocationCacheService of this service.
 // It returns a new JWTService.
-func NewJWTService(jwtRepository repository.IJWTRepository) (*JWTService, error) {
-	var key = []byte("secret")
+func NewJWTService(jwtRepository repository.IJWTRepository, settings config.Config) (*JWTService, error) {
 	var err error
-	if jwtKey != "" {
-		key = []byte(jwtKey)
-	}
 
 	if jwtRepository == nil {
 		jwtRepository, err = repository.NewJWTRepository(nil)
@@ -42,20 +46,31 @@ func NewJWTService(jwtRepository repository.IJWTRepository) (*JWTService, error)
 		}
 	}
 
-	return &JWTService{key: []byte(key), repository: jwtRepository}, nil
+	cache := revocation.NewCache(revocation.Config{
+		Capacity:          settings.Revocation.Capacity,
+		FalsePositiveRate: settings.Revocation.FalsePositiveRate,
+		RecentSize:        settings.Revocation.RecentSize,
+		MaxStaleness:      settings.Revocation.MaxStaleness.Std(),
+	})
+
+	return &JWTService{key: []byte(settings.JWT.SecretKey), repository: jwtRepository, cache: cache, users: UserRevocations()}, nil
 }
 
 // Sign signs a JWT token with the provided payload.
 // payload is the user data to sign.
 // It returns the signed token and an error if the operation fails.
 func (service *JWTService) Sign(payload model.User) (string, error) {
-	nowUtc := time.Now().UTC()
+	return service.signAt(payload, time.Now().UTC())
+}
 
+// signAt signs a JWT token with the provided payload, issued at a time.
+func (service *JWTService) signAt(payload model.User, issuedAt time.Time) (string, error) {
 	claim := model.JWTPayload{
-		Payload: payload,
+		Payload:       payload,
+		IssuedAtMicro: issuedAt.UnixMicro(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(nowUtc.Add(time.Hour * 24 * 15)),
-			IssuedAt:  jwt.NewNumericDate(nowUtc),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(tokenLifetime)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
 		},
 	}
 
@@ -66,6 +81,25 @@ func (service *JWTService) Sign(payload model.User) (string, error) {
 	return tokenString, err
 }
 
+// CheckKey checks that the key of the service can sign a token and verify it, e.g. for the readiness probe.
+// It returns an error if the key is empty or the token can't be signed or verified.
+func (service *JWTService) CheckKey() error {
+	if len(service.key) == 0 {
+		return errors.New("the JWT key isn't set")
+	}
+
+	tokenString, err := service.signAt(model.User{}, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	_, err = jwt.ParseWithClaims(tokenString, &model.JWTPayload{}, func(token *jwt.Token) (interface{}, error) {
+		return service.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	return err
+}
+
 // isJWT checks if a token has the JWT format.
 // tokenString is the token to check.
 // It returns true if the token has the JWT format, false otherwise.
@@ -146,15 +180,100 @@ func (service *JWTService) Blacklist(tokenString string) error {
 		return err
 	}
 
+	service.cache.Add(signature)
+
 	return nil
 }
 
 // IsBlacklisted checks if a JWT token is blacklisted.
+// The revocation cache answers without querying the repository, unless it's stale or can't tell.
 // tokenString is the token to check.
 // It returns true if the token is blacklisted, false otherwise and an error if the operation fails.
 func (service *JWTService) IsBlacklisted(tokenString string) (bool, error) {
 	lastDotIndex := strings.LastIndex(tokenString, ".")
 	signature := tokenString[lastDotIndex:]
 
+	switch service.cache.Lookup(signature, time.Now()) {
+	case revocation.Revoked:
+		return true, nil
+	case revocation.NotRevoked:
+		return false, nil
+	}
+
 	return service.repository.IsBlacklisted(signature)
 }
+
+// RevokeAll invalidates every JWT issued to a user until now.
+// userId is the id of the user.
+// It returns an error if the operation fails.
+func (service *JWTService) RevokeAll(userId string) error {
+	return service.revoke(userId, revocationCutoff())
+}
+
+// revoke saves the revocation cutoff of a user and records it in the cache, so it's enforced by this instance before
+// the next refresh.
+func (service *JWTService) revoke(userId string, cutoff time.Time) error {
+	if err := service.repository.RevokeAll(userId, cutoff); err != nil {
+		return err
+	}
+
+	service.users.Revoke(model.Revocation{UserId: userId, ValidAfter: cutoff})
+	return nil
+}
+
+// revocationCutoff returns the cutoff revoking the tokens issued until now, in microseconds as the databases store it
+// and as the tokens are compared with it.
+func revocationCutoff() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// RevokeOthers invalidates every JWT issued to a user until now, and signs a new one for the current session.
+// The new token is issued a microsecond after the cutoff, so it isn't revoked even if it's signed within the same
+// microsecond.
+// user is the payload of the new token.
+// It returns the new token and an error if the operation fails.
+func (service *JWTService) RevokeOthers(user model.User) (string, error) {
+	cutoff := revocationCutoff()
+
+	if err := service.revoke(user.Id, cutoff); err != nil {
+		return "", err
+	}
+
+	return service.signAt(user, cutoff.Add(time.Microsecond))
+}
+
+// IsRevoked checks if a decoded JWT was issued before the tokens of its user were revoked.
+// The cutoffs cached in memory answer without querying the repository, unless the revocation cache is stale.
+// The tokens are compared with the cutoff in microseconds. The ones signed before IssuedAtMicro was added only have
+// the issued at claim, in seconds, so those issued in the same second as the revocation are revoked too.
+// payload is the decoded token to check.
+// It returns true if the token is revoked, false otherwise and an error if the operation fails.
+func (service *JWTService) IsRevoked(payload model.JWTPayload) (bool, error) {
+	if !service.cache.Stale(time.Now()) {
+		return isRevokedAt(payload, service.users.ValidAfter(payload.Payload.Id)), nil
+	}
+
+	validAfter, err := service.repository.GetValidAfter(payload.Payload.Id)
+	if err != nil {
+		return true, err
+	}
+
+	return isRevokedAt(payload, validAfter), nil
+}
+
+// isRevokedAt checks if a decoded JWT was issued before a revocation cutoff, never if it's zero.
+func isRevokedAt(payload model.JWTPayload, validAfter time.Time) bool {
+	if validAfter.IsZero() {
+		return false
+	}
+
+	if payload.IssuedAtMicro != 0 {
+		return !time.UnixMicro(payload.IssuedAtMicro).After(validAfter)
+	}
+
+	if payload.IssuedAt == nil {
+		return true
+	}
+
+	return !payload.IssuedAt.Time.After(validAfter.Truncate(time.Second))
+}
@@ -0,0 +1,157 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/geoip"
+	"github.com/NutriPocket/UserService/mailer"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+)
+
+// Limits of the number of logins returned in the login history.
+const (
+	DefaultLoginHistoryLimit = 20
+	MaxLoginHistoryLimit     = 100
+)
+
+// versionRegex matches the version numbers of a user agent, e.g. 120.0.6099.109.
+var versionRegex = regexp.MustCompile(`\d+([._]\d+)*`)
+
+// DeviceFingerprint returns the hex SHA-256 that identifies the device of a login.
+// deviceId is the id sent by the client in the X-Device-Id header, if any, and takes precedence.
+// Otherwise the user agent is used without its version numbers, so updating the browser or the app isn't a new device.
+func DeviceFingerprint(deviceId string, userAgent string) string {
+	source := "device:" + deviceId
+	if deviceId == "" {
+		source = "agent:" + strings.ToLower(versionRegex.ReplaceAllString(userAgent, ""))
+	}
+
+	hash := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(hash[:])
+}
+
+// LoginHistoryService is a struct that records the logins of the users and alerts them of logins from new devices.
+type LoginHistoryService struct {
+	repository repository.ILoginHistoryRepository
+	mailer     mailer.IMailer
+	resolver   geoip.IResolver
+}
+
+// NewLoginHistoryService creates a new LoginHistoryService, every nil argument is replaced by its default
+// implementation, configured by settings.
+func NewLoginHistoryService(
+	loginHistoryRepository repository.ILoginHistoryRepository,
+	emailMailer mailer.IMailer,
+	resolver geoip.IResolver,
+	settings config.Config,
+) (*LoginHistoryService, error) {
+	var err error
+
+	if loginHistoryRepository == nil {
+		loginHistoryRepository, err = repository.NewLoginHistoryRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create login history repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if emailMailer == nil {
+		emailMailer = mailer.New(settings.Mailer)
+	}
+
+	if resolver == nil {
+		resolver = geoip.New(settings.GeoIP)
+	}
+
+	return &LoginHistoryService{repository: loginHistoryRepository, mailer: emailMailer, resolver: resolver}, nil
+}
+
+// Record saves a successful login of a user.
+// If the user logged in before, but never from this device, an alert is emailed to the user.
+// The first login of a user isn't alerted, as there is nothing to compare it with.
+// user is the user that logged in.
+// ip, userAgent and deviceId describe where the request came from, deviceId may be empty.
+// It returns the saved login and an error if it couldn't be saved. Failing to send the alert is only logged.
+func (service *LoginHistoryService) Record(user model.User, ip string, userAgent string, deviceId string) (model.Login, error) {
+	fingerprint := DeviceFingerprint(deviceId, userAgent)
+
+	total, fromDevice, err := service.repository.Devices(user.Id, fingerprint)
+	if err != nil {
+		return model.Login{}, err
+	}
+
+	location := service.resolver.Lookup(ip)
+
+	login := model.Login{
+		UserId:            user.Id,
+		DeviceFingerprint: fingerprint,
+		UserAgent:         truncate(userAgent, 255),
+		IP:                truncate(ip, 45),
+		Country:           location.Country,
+		Region:            truncate(location.Region, 100),
+		City:              truncate(location.City, 100),
+		NewDevice:         fromDevice == 0,
+		CreatedAt:         time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	if location.Located {
+		login.Latitude, login.Longitude = &location.Latitude, &location.Longitude
+	}
+
+	if err := service.repository.Create(&login); err != nil {
+		log.Errorf("Failed to record a login of %s: %v", user.Id, err)
+		return model.Login{}, err
+	}
+
+	if login.NewDevice && total > 0 {
+		if err := service.alert(user, login, location); err != nil {
+			log.Errorf("Failed to alert %s about a login from a new device: %v", user.Id, err)
+		}
+	}
+
+	return login, nil
+}
+
+// alert emails a user about a login from a new device.
+func (service *LoginHistoryService) alert(user model.User, login model.Login, location geoip.Location) error {
+	where := location.String()
+	if where == "" {
+		where = "Unknown"
+	}
+
+	device := login.UserAgent
+	if device == "" {
+		device = "Unknown"
+	}
+
+	return service.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "New login to your NutriPocket account",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour NutriPocket account was logged in from a new device:\n\n"+
+				"Device: %s\nIP: %s\nLocation: %s\nTime: %s\n\n"+
+				"If it was you, you can ignore this email. "+
+				"Otherwise someone else may know your password, change it right away.\n",
+			user.Username, device, login.IP, where, login.CreatedAt.Format(time.RFC1123),
+		),
+	})
+}
+
+// List lists the logins of a user, the newest first.
+// limit is the maximum number of logins, DefaultLoginHistoryLimit if 0 and at most MaxLoginHistoryLimit.
+// It returns an error if the operation fails.
+func (service *LoginHistoryService) List(userId string, limit int) ([]model.Login, error) {
+	if limit <= 0 {
+		limit = DefaultLoginHistoryLimit
+	}
+
+	return service.repository.List(userId, min(limit, MaxLoginHistoryLimit))
+}
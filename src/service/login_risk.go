@@ -0,0 +1,164 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/geoip"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/risk"
+)
+
+var (
+	badIPs     *risk.IPList
+	badIPsOnce sync.Once
+)
+
+// loadBadIPs returns the list of known bad IPs of the file at path, loaded on the first call.
+// It returns nil if path is empty or the file can't be loaded, which disables the rule.
+func loadBadIPs(path string) *risk.IPList {
+	badIPsOnce.Do(func() {
+		if path == "" {
+			return
+		}
+
+		list, err := risk.LoadIPList(path)
+		if err != nil {
+			log.Errorf("Failed to load the bad IPs file %s, the rule is disabled: %v", path, err)
+			return
+		}
+
+		log.Infof("Loaded %d bad IPs and networks from %s", list.Len(), path)
+		badIPs = list
+	})
+
+	return badIPs
+}
+
+// RiskConfig returns the configuration of the login risk rules of settings.
+func RiskConfig(settings config.RiskConfig) risk.Config {
+	return risk.Config{
+		StepUpScore:           settings.StepUpScore,
+		BlockScore:            settings.BlockScore,
+		ImpossibleTravelScore: settings.ImpossibleTravelScore,
+		MaxTravelSpeed:        settings.MaxTravelSpeed,
+		MinTravelDistance:     settings.MinTravelDistance,
+		NewDeviceCountryScore: settings.NewDeviceCountryScore,
+		FailedLoginsScore:     settings.FailedLoginsScore,
+		FailedLoginsThreshold: settings.FailedLoginsThreshold,
+		BadIPScore:            settings.BadIPScore,
+	}
+}
+
+// LoginRiskService is a struct that gathers what is known about a login attempt and scores it with the risk engine.
+type LoginRiskService struct {
+	loginHistoryRepository repository.ILoginHistoryRepository
+	auditRepository        repository.IAuditRepository
+	resolver               geoip.IResolver
+	engine                 *risk.Engine
+	// failedLoginsWindow is how far back the failed logins are counted.
+	failedLoginsWindow time.Duration
+}
+
+// NewLoginRiskService creates a new LoginRiskService, every nil argument is replaced by its default implementation,
+// configured by settings.
+func NewLoginRiskService(
+	loginHistoryRepository repository.ILoginHistoryRepository,
+	auditRepository repository.IAuditRepository,
+	resolver geoip.IResolver,
+	engine *risk.Engine,
+	settings config.Config,
+) (*LoginRiskService, error) {
+	var err error
+
+	if loginHistoryRepository == nil {
+		loginHistoryRepository, err = repository.NewLoginHistoryRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create login history repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if auditRepository == nil {
+		auditRepository, err = repository.NewAuditRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create audit repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if resolver == nil {
+		resolver = geoip.New(settings.GeoIP)
+	}
+
+	if engine == nil {
+		engine = risk.NewEngine(RiskConfig(settings.Risk), loadBadIPs(settings.Risk.BadIPsFile))
+	}
+
+	return &LoginRiskService{
+		loginHistoryRepository: loginHistoryRepository,
+		auditRepository:        auditRepository,
+		resolver:               resolver,
+		engine:                 engine,
+		failedLoginsWindow:     settings.Risk.FailedLoginsWindow.Std(),
+	}, nil
+}
+
+// Assess scores a login attempt of a user with the right password.
+// ip, userAgent and deviceId describe where the request came from, deviceId may be empty.
+// It returns the assessment and an error if the facts about the attempt couldn't be read.
+func (service *LoginRiskService) Assess(userId string, ip string, userAgent string, deviceId string) (risk.Assessment, error) {
+	now := time.Now()
+	location := service.resolver.Lookup(ip)
+
+	attempt := risk.Attempt{
+		Time:  now,
+		IP:    ip,
+		Place: risk.Place{Country: location.Country, Located: location.Located, Latitude: location.Latitude, Longitude: location.Longitude},
+	}
+
+	last, err := service.loginHistoryRepository.Last(userId)
+	if err != nil {
+		return risk.Assessment{}, err
+	}
+
+	// Without previous logins there is nothing to compare with, so the device and the country aren't new.
+	if last.Id != 0 {
+		attempt.Previous = &risk.PreviousLogin{Time: last.CreatedAt, Place: risk.Place{Country: last.Country}}
+		if last.Latitude != nil && last.Longitude != nil {
+			attempt.Previous.Place.Located = true
+			attempt.Previous.Place.Latitude, attempt.Previous.Place.Longitude = *last.Latitude, *last.Longitude
+		}
+
+		_, fromDevice, err := service.loginHistoryRepository.Devices(userId, DeviceFingerprint(deviceId, userAgent))
+		if err != nil {
+			return risk.Assessment{}, err
+		}
+		attempt.NewDevice = fromDevice == 0
+
+		if location.Country != "" {
+			fromCountry, err := service.loginHistoryRepository.FromCountry(userId, location.Country)
+			if err != nil {
+				return risk.Assessment{}, err
+			}
+			attempt.NewCountry = fromCountry == 0
+		}
+	}
+
+	since := now.Add(-service.failedLoginsWindow)
+	failures, err := service.auditRepository.CountSince(model.AuditLoginFailed, userId, since)
+	if err != nil {
+		return risk.Assessment{}, err
+	}
+	attempt.RecentFailures = int(failures)
+
+	assessment := service.engine.Assess(attempt)
+	if assessment.Decision != risk.Allow {
+		log.Infof("Login of %s assessed as %s with score %d: %v", userId, assessment.Decision, assessment.Score, assessment.Reasons)
+	}
+
+	return assessment, nil
+}
@@ -0,0 +1,119 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/mailer"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+)
+
+// PasswordChangeService is a struct that lets the authenticated users change their password.
+type PasswordChangeService struct {
+	userRepository repository.IUserRepository
+	jwtService     *JWTService
+	mailer         mailer.IMailer
+	// policy are the settings of the password policy.
+	policy config.PasswordConfig
+}
+
+// NewPasswordChangeService creates a new PasswordChangeService, every nil argument is replaced by its default
+// implementation, configured by settings.
+func NewPasswordChangeService(
+	userRepository repository.IUserRepository,
+	jwtService *JWTService,
+	emailMailer mailer.IMailer,
+	settings config.Config,
+) (*PasswordChangeService, error) {
+	var err error
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if jwtService == nil {
+		if jwtService, err = NewJWTService(nil, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if emailMailer == nil {
+		emailMailer = mailer.New(settings.Mailer)
+	}
+
+	return &PasswordChangeService{
+		userRepository: userRepository,
+		jwtService:     jwtService,
+		mailer:         emailMailer,
+		policy:         settings.Password,
+	}, nil
+}
+
+// Change sets a new password for a user and logs out every other session, the user is notified by email.
+// userId is the id of the authenticated user.
+// request contains the current password and the new one, which must follow the password policy.
+// It returns the user, a new token for the current session and an error if the current password is wrong or the new
+// one is weak.
+func (service *PasswordChangeService) Change(userId string, request *model.PasswordChangeRequest) (model.User, string, error) {
+	user, err := service.userRepository.GetUserById(userId)
+	if err != nil {
+		return model.User{}, "", err
+	}
+
+	if user == (model.User{}) {
+		return model.User{}, "", &model.NotFoundError{Title: "User not found", Detail: "The authenticated user was not found"}
+	}
+
+	savedUser, err := service.userRepository.GetUserWithPassword(user.Username)
+	if err != nil {
+		return model.User{}, "", err
+	}
+
+	if savedUser.Password != hashPassword(request.CurrentPassword) {
+		return model.User{}, "", &model.AuthenticationError{
+			Title:  "Wrong password",
+			Detail: "The provided password doesn't match your current password",
+		}
+	}
+
+	if request.NewPassword == request.CurrentPassword {
+		return model.User{}, "", &model.ValidationError{
+			Title:  "Same password",
+			Detail: "The new password must be different from the current one",
+		}
+	}
+
+	if err := checkPassword(service.policy, request.NewPassword, user.Username, user.Email); err != nil {
+		return model.User{}, "", err
+	}
+
+	if err := service.userRepository.SetPassword(user.Id, hashPassword(request.NewPassword)); err != nil {
+		return model.User{}, "", err
+	}
+
+	token, err := service.jwtService.RevokeOthers(user)
+	if err != nil {
+		return model.User{}, "", err
+	}
+
+	if err := service.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your NutriPocket password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password of your NutriPocket account was changed on %s and your other sessions were closed.\n"+
+				"If it wasn't you, reset your password right away and contact our support team.\n",
+			user.Username, time.Now().UTC().Format(time.RFC1123),
+		),
+	}); err != nil {
+		log.Errorf("Failed to notify %s about the password change of the user %s: %v", user.Email, user.Id, err)
+	}
+
+	return withDefaultPicture(user), token, nil
+}
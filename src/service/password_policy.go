@@ -0,0 +1,80 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/password"
+)
+
+// DefaultPasswordMaxLength is the maximum length of the passwords, longer ones are refused by the validation of the
+// requests.
+const DefaultPasswordMaxLength = 100
+
+var (
+	breachedDataset     password.IBreachedChecker
+	breachedDatasetOnce sync.Once
+)
+
+// loadBreachedDataset returns the breached passwords dataset at dir, opened on the first call.
+// It returns nil if dir is empty or can't be opened, which disables the rule.
+func loadBreachedDataset(dir string) password.IBreachedChecker {
+	breachedDatasetOnce.Do(func() {
+		if dir == "" {
+			log.Warning("password.breached_dir isn't set, passwords won't be checked against data breaches")
+			return
+		}
+
+		dataset, err := password.NewRangeDataset(dir)
+		if err != nil {
+			log.Errorf("Failed to open the breached passwords dataset %s, the rule is disabled: %v", dir, err)
+			return
+		}
+
+		breachedDataset = dataset
+	})
+
+	return breachedDataset
+}
+
+// PasswordPolicy returns the policy of the passwords chosen by the users, configured by settings.
+func PasswordPolicy(settings config.PasswordConfig) password.Policy {
+	return password.Policy{
+		MinLength:   settings.MinLength,
+		MaxLength:   DefaultPasswordMaxLength,
+		MinStrength: settings.MinStrength,
+		Breached:    loadBreachedDataset(settings.BreachedDir),
+	}
+}
+
+// checkPassword checks a new password of a user against the password policy configured by settings.
+// userInputs are the other data of the user, e.g. the username and the email, that the password shouldn't contain.
+// It returns a validation error with every broken rule, and an error if the breached dataset can't be read.
+func checkPassword(settings config.PasswordConfig, newPassword string, userInputs ...string) error {
+	violations, err := PasswordPolicy(settings).Check(newPassword, userInputs...)
+	if err != nil {
+		log.Errorf("Failed to check a password against the breached passwords dataset: %v", err)
+		return err
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	details := make([]string, 0, len(violations))
+	modelViolations := make([]model.Violation, 0, len(violations))
+
+	for _, violation := range violations {
+		details = append(details, violation.Detail)
+		modelViolations = append(modelViolations, model.Violation{Rule: violation.Rule, Detail: violation.Detail})
+	}
+
+	return &model.ValidationError{
+		Title:      "Weak password",
+		Detail:     strings.Join(details, ". "),
+		Violations: modelViolations,
+	}
+}
@@ -0,0 +1,173 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/mailer"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/google/uuid"
+)
+
+var invalidPasswordResetToken = &model.ValidationError{
+	Title:  "Invalid token",
+	Detail: "The provided token is invalid or has expired",
+}
+
+// PasswordResetService is a struct that lets the users set a new password through a link sent by email.
+type PasswordResetService struct {
+	userRepository repository.IUserRepository
+	repository     repository.IPasswordResetRepository
+	jwtService     *JWTService
+	mailer         mailer.IMailer
+	// settings are the lifetime of the links, the public URL they point to and the password policy.
+	settings config.Config
+}
+
+// NewPasswordResetService creates a new PasswordResetService, every nil argument is replaced by its default
+// implementation, configured by settings.
+func NewPasswordResetService(
+	userRepository repository.IUserRepository,
+	passwordResetRepository repository.IPasswordResetRepository,
+	jwtService *JWTService,
+	emailMailer mailer.IMailer,
+	settings config.Config,
+) (*PasswordResetService, error) {
+	var err error
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if passwordResetRepository == nil {
+		passwordResetRepository, err = repository.NewPasswordResetRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create password reset repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if jwtService == nil {
+		if jwtService, err = NewJWTService(nil, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if emailMailer == nil {
+		emailMailer = mailer.New(settings.Mailer)
+	}
+
+	return &PasswordResetService{
+		userRepository: userRepository,
+		repository:     passwordResetRepository,
+		jwtService:     jwtService,
+		mailer:         emailMailer,
+		settings:       settings,
+	}, nil
+}
+
+// Force invalidates the password and the sessions of a user, and sends a link to set a new password.
+// It's used by the admins when an account may be compromised.
+// userId is the id of the user.
+// It returns an error if the user doesn't exist or the operation fails.
+func (service *PasswordResetService) Force(userId string) error {
+	user, err := service.userRepository.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	if user == (model.User{}) {
+		return &model.NotFoundError{Title: "User not found", Detail: "The user with the id " + userId + " was not found"}
+	}
+
+	// The password is replaced by a random one nobody knows, so only the link can be used to log in again.
+	unusable, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	if err := service.userRepository.SetPassword(user.Id, hashPassword(unusable)); err != nil {
+		return err
+	}
+
+	if err := service.jwtService.RevokeAll(user.Id); err != nil {
+		return err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	reset := model.PasswordReset{
+		Id:        uuid.NewString(),
+		UserId:    user.Id,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(service.settings.Password.ResetTTL.Std()),
+	}
+
+	if err := service.repository.CancelPending(user.Id); err != nil {
+		return err
+	}
+
+	if err := service.repository.Create(&reset); err != nil {
+		return err
+	}
+
+	return service.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Set a new NutriPocket password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password of your NutriPocket account was reset by our support team and you were logged out.\n"+
+				"Set a new password by opening this link before %s:\n\n%s\n",
+			user.Username, reset.ExpiresAt.UTC().Format(time.RFC1123),
+			publicURL(service.settings.Server)+"/auth/password/reset?token="+url.QueryEscape(token),
+		),
+	})
+}
+
+// Reset sets a new password with the token sent by email, and unlocks the account.
+// request contains the token and the new password, expected to be validated by the controller.
+// It returns the user and an error if the token is invalid, used or expired.
+func (service *PasswordResetService) Reset(request *model.PasswordResetRequest) (model.User, error) {
+	reset, err := service.repository.GetByToken(hashToken(request.Token))
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if reset.Id == "" || reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return model.User{}, invalidPasswordResetToken
+	}
+
+	user, err := service.userRepository.GetUserById(reset.UserId)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	// Checked before using the token, so the user can try another password with the same link.
+	if err := checkPassword(service.settings.Password, request.Password, user.Username, user.Email); err != nil {
+		return model.User{}, err
+	}
+
+	if err := service.repository.MarkUsed(reset.Id); err != nil {
+		return model.User{}, err
+	}
+
+	if err := service.userRepository.SetPassword(reset.UserId, hashPassword(request.Password)); err != nil {
+		return model.User{}, err
+	}
+
+	if err := service.userRepository.Unlock(reset.UserId); err != nil {
+		return model.User{}, err
+	}
+
+	return withDefaultPicture(user), nil
+}
@@ -0,0 +1,317 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/revocation"
+)
+
+// revocationCacheOverlap is how far back each refresh reads again, so the tokens blacklisted by transactions that
+// committed late, or by another instance with a clock behind, aren't missed.
+const revocationCacheOverlap = 2 * time.Second
+
+// userRevocations is the process wide cache of the revocation cutoffs and account states of the users.
+var userRevocations = NewUserRevocationCache()
+
+// UserRevocations returns the process wide cache of the revocation cutoffs and account states of the users, loaded
+// and refreshed with the blacklisted tokens, so it's up to date unless the cache of the blacklisted tokens is stale.
+func UserRevocations() *UserRevocationCache {
+	return userRevocations
+}
+
+// cachedAccountState is an account state of a UserRevocationCache, with when it was changed to keep the newest.
+type cachedAccountState struct {
+	model.AccountState
+	changedAt time.Time
+}
+
+// UserRevocationCache keeps the revocation cutoffs and the account states that aren't active in memory, so the
+// authenticated requests don't query them. It's safe for concurrent use, the lookups don't take locks.
+// The maps are copied on write, the cutoffs and the states change much less often than they're read.
+type UserRevocationCache struct {
+	// mu serializes the writes.
+	mu sync.Mutex
+	// cutoffs are the revocation cutoffs of the users, by id. Those older than the lifetime of the tokens are dropped,
+	// they only revoke expired tokens.
+	cutoffs atomic.Pointer[map[string]time.Time]
+	// states are the account states of the users, by id. The users missing are active, or were erased after the
+	// grace period of their deletion, longer than the lifetime of their tokens.
+	states atomic.Pointer[map[string]cachedAccountState]
+}
+
+// NewUserRevocationCache creates an empty cache.
+func NewUserRevocationCache() *UserRevocationCache {
+	cache := &UserRevocationCache{}
+	cache.cutoffs.Store(&map[string]time.Time{})
+	cache.states.Store(&map[string]cachedAccountState{})
+
+	return cache
+}
+
+// ValidAfter returns the revocation cutoff of a user, the zero time if its tokens weren't revoked lately.
+func (cache *UserRevocationCache) ValidAfter(userId string) time.Time {
+	return (*cache.cutoffs.Load())[userId]
+}
+
+// AccountState returns the account state of a user, active if it isn't known.
+func (cache *UserRevocationCache) AccountState(userId string) model.AccountState {
+	if state, ok := (*cache.states.Load())[userId]; ok {
+		return state.AccountState
+	}
+
+	return model.AccountState{State: model.AccountActive}
+}
+
+// Revoke records revocation cutoffs, a cutoff never moves back.
+func (cache *UserRevocationCache) Revoke(revocations ...model.Revocation) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cutoffs := maps.Clone(*cache.cutoffs.Load())
+	addCutoffs(cutoffs, revocations)
+	cache.cutoffs.Store(&cutoffs)
+}
+
+// SetAccountStates records account states, unless the state known of a user was changed later.
+func (cache *UserRevocationCache) SetAccountStates(states ...model.UserAccountState) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	next := maps.Clone(*cache.states.Load())
+	addAccountStates(next, states)
+	cache.states.Store(&next)
+}
+
+// Reset replaces the content of the cache with every revocation since a time and every account state that isn't
+// active. The states changed since the time they were read are kept, as they may be missing from the ones given.
+// revokedSince is the time from which the cutoffs are kept.
+// readAt is when the states were read.
+func (cache *UserRevocationCache) Reset(revocations []model.Revocation, states []model.UserAccountState, revokedSince time.Time, readAt time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// The cutoffs never move back, so the ones recorded meanwhile are kept by merging them.
+	cutoffs := maps.Clone(*cache.cutoffs.Load())
+	maps.DeleteFunc(cutoffs, func(_ string, validAfter time.Time) bool { return validAfter.Before(revokedSince) })
+	addCutoffs(cutoffs, revocations)
+
+	next := make(map[string]cachedAccountState, len(states))
+	addAccountStates(next, states)
+
+	for userId, state := range *cache.states.Load() {
+		if !state.changedAt.Before(readAt) {
+			addAccountStates(next, []model.UserAccountState{{UserId: userId, AccountState: state.AccountState, ChangedAt: state.changedAt}})
+		}
+	}
+
+	cache.cutoffs.Store(&cutoffs)
+	cache.states.Store(&next)
+}
+
+func addCutoffs(cutoffs map[string]time.Time, revocations []model.Revocation) {
+	for _, revocation := range revocations {
+		if revocation.ValidAfter.After(cutoffs[revocation.UserId]) {
+			cutoffs[revocation.UserId] = revocation.ValidAfter
+		}
+	}
+}
+
+// addAccountStates adds account states to a map, the active ones are kept too so an older change read later doesn't
+// replace them.
+func addAccountStates(states map[string]cachedAccountState, changes []model.UserAccountState) {
+	for _, change := range changes {
+		if current, ok := states[change.UserId]; ok && current.changedAt.After(change.ChangedAt) {
+			continue
+		}
+
+		states[change.UserId] = cachedAccountState{AccountState: change.AccountState, changedAt: change.ChangedAt}
+	}
+}
+
+// rememberAccountState records an account state just saved by this instance, so it's enforced before the next
+// refresh of the cache.
+func rememberAccountState(userId string, state model.AccountState) {
+	userRevocations.SetAccountStates(model.UserAccountState{UserId: userId, AccountState: state, ChangedAt: time.Now()})
+}
+
+// RevocationCacheService is a struct that keeps the revocation caches in sync with the jwt_blacklist and
+// jwt_revocations tables and the account states, which are shared by every instance of the service.
+type RevocationCacheService struct {
+	repository     repository.IJWTRepository
+	userRepository repository.IUserRepository
+	cache          *revocation.Cache
+	users          *UserRevocationCache
+	// since is the creation time of the newest blacklisted token read.
+	since time.Time
+	// revokedSince is the newest revocation cutoff read.
+	revokedSince time.Time
+	// statesSince is the time of the newest account state change read.
+	statesSince time.Time
+	// builtAt is when the cache was last loaded from scratch.
+	builtAt time.Time
+	// refresh and rebuild are how often the changes are read and the cache is loaded from scratch.
+	refresh time.Duration
+	rebuild time.Duration
+}
+
+// NewRevocationCacheService creates a new RevocationCacheService of the cache of jwtService and of the process wide
+// cache of the users.
+// jwtService is the service whose cache is loaded, with the blacklisted tokens and the revocations of its repository,
+// the default one if nil.
+// userRepository is the repository used to read the account states, the default one if nil.
+// settings are how often the caches are refreshed and rebuilt.
+func NewRevocationCacheService(jwtService *JWTService, userRepository repository.IUserRepository, settings config.Config) (*RevocationCacheService, error) {
+	var err error
+
+	if jwtService == nil {
+		if jwtService, err = NewJWTService(nil, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	return &RevocationCacheService{
+		repository:     jwtService.repository,
+		userRepository: userRepository,
+		cache:          jwtService.cache,
+		users:          UserRevocations(),
+		refresh:        settings.Revocation.Refresh.Std(),
+		rebuild:        settings.Revocation.Rebuild.Std(),
+	}, nil
+}
+
+// Cache returns the cache of the blacklisted tokens loaded by the service.
+func (service *RevocationCacheService) Cache() *revocation.Cache {
+	return service.cache
+}
+
+// Warm loads every unexpired blacklisted token, every revocation of the unexpired tokens and every account state
+// that isn't active into the caches, replacing their content.
+// It returns an error if the operation fails, the caches keep their previous content.
+func (service *RevocationCacheService) Warm() error {
+	now := time.Now()
+	revokedSince := now.Add(-tokenLifetime)
+
+	tokens, err := service.repository.ListBlacklisted(time.Time{})
+	if err != nil {
+		return err
+	}
+
+	revocations, err := service.repository.ListRevocations(revokedSince)
+	if err != nil {
+		return err
+	}
+
+	states, err := service.userRepository.ListInactiveAccountStates()
+	if err != nil {
+		return err
+	}
+
+	signatures := make([]string, len(tokens))
+	for i, token := range tokens {
+		signatures[i] = token.Signature
+	}
+
+	if len(tokens) > 0 {
+		service.since = tokens[len(tokens)-1].CreatedAt
+	}
+
+	service.revokedSince = revokedSince
+	if len(revocations) > 0 {
+		service.revokedSince = revocations[len(revocations)-1].ValidAfter
+	}
+
+	service.statesSince = now
+
+	// The blacklist cache is reset last, as it marks both caches as refreshed.
+	service.users.Reset(revocations, states, revokedSince, now)
+	service.cache.Reset(signatures, now)
+	service.builtAt = now
+
+	log.Infof("Loaded %d blacklisted tokens, %d revocations and %d account states into the revocation cache",
+		len(signatures), len(revocations), len(states))
+	return nil
+}
+
+// Refresh adds the tokens blacklisted, the revocations and the account state changes since the last refresh to the
+// caches.
+// The caches are loaded from scratch every rebuild interval, to drop the expired tokens, or when the
+// blacklist cache is full.
+// It returns an error if the operation fails, the caches become stale if it keeps failing.
+func (service *RevocationCacheService) Refresh() error {
+	now := time.Now()
+
+	if service.builtAt.IsZero() || service.cache.Full() ||
+		now.Sub(service.builtAt) > service.rebuild {
+		return service.Warm()
+	}
+
+	tokens, err := service.repository.ListBlacklisted(service.since.Add(-revocationCacheOverlap))
+	if err != nil {
+		return err
+	}
+
+	revocations, err := service.repository.ListRevocations(service.revokedSince.Add(-revocationCacheOverlap))
+	if err != nil {
+		return err
+	}
+
+	states, err := service.userRepository.ListAccountStateChanges(service.statesSince.Add(-revocationCacheOverlap))
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		service.cache.Add(token.Signature)
+
+		if token.CreatedAt.After(service.since) {
+			service.since = token.CreatedAt
+		}
+	}
+
+	service.users.Revoke(revocations...)
+	if len(revocations) > 0 && revocations[len(revocations)-1].ValidAfter.After(service.revokedSince) {
+		service.revokedSince = revocations[len(revocations)-1].ValidAfter
+	}
+
+	service.users.SetAccountStates(states...)
+	if len(states) > 0 && states[len(states)-1].ChangedAt.After(service.statesSince) {
+		service.statesSince = states[len(states)-1].ChangedAt
+	}
+
+	service.cache.MarkRefreshed(now)
+	return nil
+}
+
+// Run refreshes the cache every refresh interval until stop is closed.
+// It's meant to be run in its own goroutine, after Warm.
+func (service *RevocationCacheService) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(service.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := service.Refresh(); err != nil {
+			log.Errorf("Failed to refresh the revocation cache: %v", err)
+		}
+	}
+}
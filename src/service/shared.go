@@ -1,5 +1,36 @@
 package service
 
-import "github.com/op/go-logging"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/op/go-logging"
+)
 
 var log = logging.MustGetLogger("log")
+
+// publicURL returns the URL where the clients reach the service without a trailing slash, used to build the links
+// sent by email.
+func publicURL(settings config.ServerConfig) string {
+	return strings.TrimSuffix(settings.PublicURL, "/")
+}
+
+// newToken returns a random token that can be sent to the users, e.g. in an email link.
+func newToken() (string, error) {
+	bytes := make([]byte, 32)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+// hashToken returns the SHA-256 of a token, the only thing saved so a database leak doesn't leak usable tokens.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,196 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/NutriPocket/UserService/mailer"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/google/uuid"
+)
+
+var invalidLoginChallenge = &model.ValidationError{
+	Title:  "Invalid challenge",
+	Detail: "The login challenge is invalid, was already used or has expired, please log in again",
+}
+
+// StepUpService is a struct that asks for a second factor to finish the suspicious logins.
+// The code of the authenticator app is asked if the user enabled one, otherwise a code is sent by email.
+type StepUpService struct {
+	userRepository      repository.IUserRepository
+	challengeRepository repository.ILoginChallengeRepository
+	totpRepository      repository.ITOTPRepository
+	mailer              mailer.IMailer
+	// settings are the lifetime and the attempts of the challenges.
+	settings config.LoginConfig
+}
+
+// NewStepUpService creates a new StepUpService, every nil argument is replaced by its default implementation,
+// configured by settings.
+func NewStepUpService(
+	userRepository repository.IUserRepository,
+	challengeRepository repository.ILoginChallengeRepository,
+	totpRepository repository.ITOTPRepository,
+	emailMailer mailer.IMailer,
+	settings config.Config,
+) (*StepUpService, error) {
+	var err error
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if challengeRepository == nil {
+		challengeRepository, err = repository.NewLoginChallengeRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create login challenge repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if totpRepository == nil {
+		totpRepository, err = repository.NewTOTPRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create TOTP repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if emailMailer == nil {
+		emailMailer = mailer.New(settings.Mailer)
+	}
+
+	return &StepUpService{
+		userRepository:      userRepository,
+		challengeRepository: challengeRepository,
+		totpRepository:      totpRepository,
+		mailer:              emailMailer,
+		settings:            settings.Login,
+	}, nil
+}
+
+// newEmailCode returns a random 6 digits code, short enough to be typed.
+func newEmailCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// Start asks for a second factor to finish the login of a user.
+// user is the user that logged in with the right password.
+// It returns the challenge the client must answer, and an error if it couldn't be created or the code couldn't be sent.
+func (service *StepUpService) Start(user model.User) (model.LoginChallenge, error) {
+	credential, err := service.totpRepository.Get(user.Id)
+	if err != nil {
+		return model.LoginChallenge{}, err
+	}
+
+	challenge := model.LoginChallenge{
+		Id:        uuid.NewString(),
+		UserId:    user.Id,
+		Method:    model.StepUpTOTP,
+		ExpiresAt: time.Now().Add(service.settings.ChallengeTTL.Std()),
+	}
+
+	var code string
+	if !credential.Enabled {
+		challenge.Method = model.StepUpEmail
+
+		if code, err = newEmailCode(); err != nil {
+			return model.LoginChallenge{}, err
+		}
+		challenge.CodeHash = hashToken(code)
+	}
+
+	if err := service.challengeRepository.Create(&challenge); err != nil {
+		return model.LoginChallenge{}, err
+	}
+
+	if challenge.Method == model.StepUpEmail {
+		if err := service.mailer.Send(mailer.Message{
+			To:      user.Email,
+			Subject: "Your NutriPocket login code",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nWe noticed an unusual login to your NutriPocket account. To finish it, enter this code before %s:\n\n%s\n\n"+
+					"If it wasn't you, someone else knows your password, change it right away.\n",
+				user.Username, challenge.ExpiresAt.UTC().Format(time.RFC1123), code,
+			),
+		}); err != nil {
+			return model.LoginChallenge{}, err
+		}
+	}
+
+	return challenge, nil
+}
+
+// Verify finishes a login with the code of its challenge.
+// A challenge can only be answered login.challenge_max_attempts times, then the user must log in again.
+// response contains the id of the challenge and the code.
+// It returns the user that logged in, and an error if the challenge is invalid or the code is wrong.
+func (service *StepUpService) Verify(response *model.LoginChallengeResponse) (model.User, error) {
+	challenge, err := service.challengeRepository.Get(response.ChallengeId)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	maxAttempts := service.settings.ChallengeMaxAttempts
+	if challenge.Id == "" || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxAttempts {
+		return model.User{}, invalidLoginChallenge
+	}
+
+	var ok bool
+	switch challenge.Method {
+	case model.StepUpEmail:
+		ok = subtle.ConstantTimeCompare([]byte(hashToken(response.Code)), []byte(challenge.CodeHash)) == 1
+	case model.StepUpTOTP:
+		credential, err := service.totpRepository.Get(challenge.UserId)
+		if err != nil {
+			return model.User{}, err
+		}
+
+		if ok, err = checkTOTP(service.totpRepository, credential, response.Code); err != nil {
+			return model.User{}, err
+		}
+	}
+
+	if !ok {
+		if err := service.challengeRepository.RecordAttempt(challenge.Id); err != nil {
+			return model.User{}, err
+		}
+
+		return model.User{}, &model.AuthenticationError{
+			Title:  "Wrong code",
+			Detail: "The code is wrong or was already used, please try again",
+		}
+	}
+
+	if used, err := service.challengeRepository.MarkUsed(challenge.Id); err != nil {
+		return model.User{}, err
+	} else if !used {
+		return model.User{}, invalidLoginChallenge
+	}
+
+	user, err := service.userRepository.GetUserById(challenge.UserId)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if user == (model.User{}) {
+		return model.User{}, invalidLoginChallenge
+	}
+
+	return user, nil
+}
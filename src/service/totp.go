@@ -0,0 +1,145 @@
+// Package service contains the services that will be used in the application.
+package service
+
+import (
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/totp"
+)
+
+// TOTPIssuer is the name of the service shown by the authenticator apps.
+const TOTPIssuer = "NutriPocket"
+
+// TOTPService is a struct that enrolls the authenticator apps of the users, used as a second factor in suspicious logins.
+type TOTPService struct {
+	userRepository repository.IUserRepository
+	repository     repository.ITOTPRepository
+}
+
+// NewTOTPService creates a new TOTPService, every nil argument is replaced by its default implementation.
+func NewTOTPService(userRepository repository.IUserRepository, totpRepository repository.ITOTPRepository) (*TOTPService, error) {
+	var err error
+
+	if userRepository == nil {
+		userRepository, err = repository.NewUserRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create user repository: %v", err)
+			return nil, err
+		}
+	}
+
+	if totpRepository == nil {
+		totpRepository, err = repository.NewTOTPRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create TOTP repository: %v", err)
+			return nil, err
+		}
+	}
+
+	return &TOTPService{userRepository: userRepository, repository: totpRepository}, nil
+}
+
+// checkTOTP checks a code of the authenticator app of a user, rejecting codes that were already used.
+// It returns false if the code is wrong or was already used, and an error if the operation fails.
+func checkTOTP(totpRepository repository.ITOTPRepository, credential model.TOTPCredential, code string) (bool, error) {
+	step, ok := totp.Validate(credential.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return totpRepository.UseStep(credential.UserId, step)
+}
+
+var invalidTOTPCode = &model.ValidationError{
+	Title:  "Invalid code",
+	Detail: "The code of the authenticator app is wrong or was already used",
+}
+
+// Enroll starts adding an authenticator app, replacing a previous unconfirmed enrollment.
+// userId is the id of the authenticated user.
+// It returns the secret to add to the app, and an error if an app is already enabled.
+func (service *TOTPService) Enroll(userId string) (model.TOTPEnrollment, error) {
+	user, err := service.userRepository.GetUserById(userId)
+	if err != nil {
+		return model.TOTPEnrollment{}, err
+	}
+
+	if user == (model.User{}) {
+		return model.TOTPEnrollment{}, &model.NotFoundError{Title: "User not found", Detail: "The authenticated user was not found"}
+	}
+
+	credential, err := service.repository.Get(userId)
+	if err != nil {
+		return model.TOTPEnrollment{}, err
+	}
+
+	if credential.Enabled {
+		return model.TOTPEnrollment{}, &model.EntityAlreadyExistsError{
+			Title:  "Authenticator app already enabled",
+			Detail: "Disable the current authenticator app before adding a new one",
+		}
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return model.TOTPEnrollment{}, err
+	}
+
+	if err := service.repository.Save(userId, secret); err != nil {
+		return model.TOTPEnrollment{}, err
+	}
+
+	return model.TOTPEnrollment{Secret: secret, URI: totp.URI(secret, TOTPIssuer, user.Username)}, nil
+}
+
+// Confirm enables the enrolled authenticator app with one of its codes, proving it was added.
+// userId is the id of the authenticated user.
+// It returns an error if there is no pending enrollment or the code is wrong.
+func (service *TOTPService) Confirm(userId string, code string) error {
+	credential, err := service.repository.Get(userId)
+	if err != nil {
+		return err
+	}
+
+	if credential.Secret == "" || credential.Enabled {
+		return &model.NotFoundError{Title: "Enrollment not found", Detail: "There is no authenticator app waiting to be confirmed"}
+	}
+
+	ok, err := checkTOTP(service.repository, credential, code)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return invalidTOTPCode
+	}
+
+	return service.repository.Enable(userId)
+}
+
+// Disable removes the authenticator app of a user, which requires one of its codes.
+// userId is the id of the authenticated user.
+// It returns an error if there is no enabled app or the code is wrong.
+func (service *TOTPService) Disable(userId string, code string) error {
+	credential, err := service.repository.Get(userId)
+	if err != nil {
+		return err
+	}
+
+	if !credential.Enabled {
+		return &model.NotFoundError{Title: "Authenticator app not found", Detail: "You don't have an authenticator app enabled"}
+	}
+
+	ok, err := checkTOTP(service.repository, credential, code)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return invalidTOTPCode
+	}
+
+	return service.repository.Delete(userId)
+}
@@ -2,18 +2,27 @@
 package service
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"math"
+	"time"
 
+	"github.com/NutriPocket/UserService/config"
 	"github.com/NutriPocket/UserService/model"
 	"github.com/NutriPocket/UserService/repository"
 )
 
 type UserService struct {
 	repository repository.IUserRepository
+	// historyRepository keeps the previous usernames, so they can be resolved and aren't claimed by other users.
+	historyRepository repository.IUsernameHistoryRepository
+	// settings are the login lockout, the username changes and the password policy.
+	settings config.Config
 }
 
-func NewUserService(userRepository repository.IUserRepository) (*UserService, error) {
+func NewUserService(userRepository repository.IUserRepository, historyRepository repository.IUsernameHistoryRepository, settings config.Config) (*UserService, error) {
 	var err error
 
 	if userRepository == nil {
@@ -24,19 +33,56 @@ func NewUserService(userRepository repository.IUserRepository) (*UserService, er
 		}
 	}
 
-	return &UserService{repository: userRepository}, nil
+	if historyRepository == nil {
+		historyRepository, err = repository.NewUsernameHistoryRepository(nil)
+		if err != nil {
+			log.Errorf("Failed to create username history repository: %v", err)
+			return nil, err
+		}
+	}
+
+	return &UserService{repository: userRepository, historyRepository: historyRepository, settings: settings}, nil
+}
+
+// ptrTime returns the time pointed by t, the zero time if it's nil.
+func ptrTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return *t
+}
+
+// CheckPassword checks a new password of a user against the password policy.
+// userInputs are the other data of the user, e.g. the username and the email, that the password shouldn't contain.
+// It returns a validation error with every broken rule, and an error if the breached dataset can't be read.
+func (service *UserService) CheckPassword(newPassword string, userInputs ...string) error {
+	return checkPassword(service.settings.Password, newPassword, userInputs...)
 }
 
 func (service *UserService) EncodePassword(password string) string {
+	return hashPassword(password)
+}
+
+// hashPassword returns the hash of a password as it's saved in the users table.
+func hashPassword(password string) string {
 	hashPasswordBytes := sha256.Sum256([]byte(password))
 	return hex.EncodeToString(hashPasswordBytes[:])
-
 }
 
 func (service *UserService) CreateUser(userData *model.BaseUser) (model.User, error) {
+	if err := service.checkUsernameAvailable(userData.Username, ""); err != nil {
+		return model.User{}, err
+	}
+
 	userData.Password = service.EncodePassword(userData.Password)
 
-	return service.repository.CreateUser(userData)
+	user, err := service.repository.CreateUser(userData)
+	if err != nil {
+		return user, err
+	}
+
+	return withDefaultPicture(user), nil
 }
 
 func (service *UserService) Login(userData *model.LoginUser) (model.User, error) {
@@ -53,38 +99,244 @@ func (service *UserService) Login(userData *model.LoginUser) (model.User, error)
 		}
 	}
 
+	if wait := time.Until(ptrTime(savedUser.LockedUntil)); wait > 0 {
+		return model.User{}, &model.TooManyRequestsError{
+			Title:  "Account locked",
+			Detail: fmt.Sprintf("Too many failed logins, try again in %d minutes", int(math.Ceil(wait.Minutes()))),
+		}
+	}
+
 	userData.Password = service.EncodePassword(userData.Password)
 
 	if userData.Password != savedUser.Password {
+		lockedUntil := time.Now().Add(service.settings.Login.LockoutDuration.Std())
+		if err := service.repository.RecordFailedLogin(savedUser.Id, service.settings.Login.MaxFailures, lockedUntil); err != nil {
+			return model.User{}, err
+		}
+
 		return model.User{}, &model.AuthenticationError{
 			Title:  "Credentials don't match",
 			Detail: "User identification or password are wrong, please try again",
 		}
 	}
 
+	if savedUser.FailedLogins > 0 {
+		if err := service.repository.Unlock(savedUser.Id); err != nil {
+			return model.User{}, err
+		}
+	}
+
+	switch {
+	case savedUser.State == model.AccountPendingDeletion:
+		// Logging in during the grace period of a deletion reactivates the account.
+		if err := service.repository.SetAccountState(savedUser.Id, model.AccountState{State: model.AccountActive}); err != nil {
+			return model.User{}, err
+		}
+
+		rememberAccountState(savedUser.Id, model.AccountState{State: model.AccountActive})
+
+		log.Infof("Reactivated the account of the user %s", savedUser.Id)
+	case savedUser.State != model.AccountActive:
+		if err := accountStateError(savedUser.AccountState, time.Now()); err != nil {
+			return model.User{}, err
+		}
+
+		// The suspension or ban has expired.
+		if err := service.repository.SetAccountState(savedUser.Id, model.AccountState{State: model.AccountActive}); err != nil {
+			return model.User{}, err
+		}
+
+		rememberAccountState(savedUser.Id, model.AccountState{State: model.AccountActive})
+	}
+
 	return model.User{Id: savedUser.Id, Username: savedUser.Username, Email: savedUser.Email}, nil
 }
 
-func (service *UserService) GetAllUsers(params model.GetUsersParams) ([]model.User, error) {
-	return service.repository.GetAllUsers(params)
+// GetUserIdByLogin returns the id of the user identified by an email or a username, as used to log in.
+// It returns an empty string if there is no such user, and an error if the operation fails.
+func (service *UserService) GetUserIdByLogin(emailOrUsername string) (string, error) {
+	savedUser, err := service.repository.GetUserWithPassword(emailOrUsername)
+	if err != nil {
+		return "", err
+	}
+
+	return savedUser.Id, nil
+}
+
+// IsAdmin checks if a user has the admin role.
+// userId is the id of the user.
+// It returns true if the user is an admin, false otherwise and an error if the operation fails.
+func (service *UserService) IsAdmin(userId string) (bool, error) {
+	role, err := service.repository.GetRole(userId)
+	if err != nil {
+		return false, err
+	}
+
+	return role == model.RoleAdmin, nil
+}
+
+// GetAllUsers lists the users matching params.
+// ctx is the context of the request, whose lookups read from the primary once it wrote.
+// It returns the users and an error if the operation fails.
+func (service *UserService) GetAllUsers(ctx context.Context, params model.GetUsersParams) ([]model.User, error) {
+	// The excluded user may be one of the results, so one more is fetched to fill the limit without it.
+	limit := params.Limit
+	if params.ExcludeId != "" && limit > 0 {
+		params.Limit++
+	}
+
+	users, err := service.repository.WithContext(ctx).GetAllUsers(params)
+	if err != nil {
+		return users, err
+	}
+
+	listed := users[:0]
+	for _, user := range users {
+		if params.ExcludeId != "" && user.Id == params.ExcludeId {
+			continue
+		}
+
+		listed = append(listed, withDefaultPicture(user))
+	}
+
+	if limit > 0 && len(listed) > limit {
+		listed = listed[:limit]
+	}
+
+	return listed, nil
 }
 
-func (service *UserService) GetUser(username string) (model.User, error) {
-	user, err := service.repository.GetUser(username)
+// GetUser gets a user by its username, or by a previous username the user changed.
+// ctx is the context of the request, whose lookups read from the primary once it wrote.
+// It returns the user and an error if the user doesn't exist or the operation fails.
+func (service *UserService) GetUser(ctx context.Context, username string) (model.User, error) {
+	user, err := service.repository.WithContext(ctx).GetUser(username)
 
 	if err != nil {
 		return user, err
 	}
 
 	if user == (model.User{}) {
-		return user, &model.NotFoundError{Title: "User not found", Detail: "The user with the username " + username + " was not found"}
+		return user, service.resolveMovedUser(username)
+	}
+
+	return withDefaultPicture(user), nil
+}
+
+// resolveMovedUser returns the error for a username that isn't used by anyone.
+// It returns a UsernameMovedError if the username still belongs to a user that changed it, a NotFoundError otherwise.
+func (service *UserService) resolveMovedUser(username string) error {
+	notFound := &model.NotFoundError{Title: "User not found", Detail: "The user with the username " + username + " was not found"}
+
+	userId, err := service.historyRepository.Resolve(username)
+	if err != nil {
+		return err
+	}
+
+	if userId == "" {
+		return notFound
+	}
+
+	user, err := service.repository.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	if user == (model.User{}) {
+		return notFound
 	}
 
-	return user, nil
+	return &model.UsernameMovedError{Username: user.Username}
+}
+
+// checkUsernameAvailable checks that a username isn't reserved by a user that stopped using it.
+// userId is the user that wants the username, it can always take back its own previous usernames.
+func (service *UserService) checkUsernameAvailable(username string, userId string) error {
+	reservedBy, err := service.historyRepository.Resolve(username)
+	if err != nil {
+		return err
+	}
+
+	if reservedBy != "" && reservedBy != userId {
+		return &model.EntityAlreadyExistsError{
+			Title:  "Username reserved",
+			Detail: "The provided username was recently used by another user, try something else",
+		}
+	}
+
+	return nil
+}
+
+// ChangeUsername changes the username of a user.
+// The previous username keeps resolving to the user for USERNAME_RESERVATION_PERIOD (90 days by default),
+// and can't be claimed by other users meanwhile. A user can change the username once every
+// USERNAME_CHANGE_COOLDOWN (30 days by default).
+// userId is the id of the user.
+// username is the new username.
+// It returns the updated user and an error if the operation fails.
+func (service *UserService) ChangeUsername(userId string, username string) (model.User, error) {
+	user, err := service.repository.GetUserById(userId)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if user == (model.User{}) {
+		return model.User{}, &model.NotFoundError{Title: "User not found", Detail: "The authenticated user was not found"}
+	}
+
+	if user.Username == username {
+		return model.User{}, &model.ValidationError{
+			Title:  "Same username",
+			Detail: "The new username must be different from the current one",
+		}
+	}
+
+	lastChange, err := service.historyRepository.LastChange(userId)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	cooldown := service.settings.Account.UsernameChangeCooldown.Std()
+	if wait := time.Until(lastChange.Add(cooldown)); !lastChange.IsZero() && wait > 0 {
+		return model.User{}, &model.TooManyRequestsError{
+			Title:  "Username recently changed",
+			Detail: fmt.Sprintf("You can change your username again in %d days", int(math.Ceil(wait.Hours()/24))),
+		}
+	}
+
+	if err := service.checkUsernameAvailable(username, userId); err != nil {
+		return model.User{}, err
+	}
+
+	// The previous username is reserved before the change, so a failed reservation leaves the username as it was, and
+	// undone if the change fails.
+	reservedUntil := time.Now().Add(service.settings.Account.UsernameReservationPeriod.Std())
+	if err := service.historyRepository.Reserve(user.Username, userId, reservedUntil); err != nil {
+		log.Errorf("Failed to reserve the previous username %s of %s: %v", user.Username, userId, err)
+		return model.User{}, err
+	}
+
+	updated, err := service.repository.ChangeUsername(userId, username)
+	if err != nil {
+		if err := service.historyRepository.Unreserve(user.Username, userId); err != nil {
+			log.Errorf("Failed to undo the reservation of the previous username %s of %s: %v", user.Username, userId, err)
+		}
+
+		return model.User{}, err
+	}
+
+	// Taking back a previous username ends its reservation. A reservation left behind is harmless, it only resolves the
+	// username to the user that is using it.
+	if err := service.historyRepository.Release(username); err != nil {
+		log.Errorf("Failed to release the username %s: %v", username, err)
+	}
+
+	return withDefaultPicture(updated), nil
 }
 
 func (service *UserService) UpdateUser(username string, userData *model.EditableUser) (model.User, error) {
-	user, err := service.repository.GetUser(username)
+	// The fields that aren't updated are written back, so they're read from the primary as they were last written.
+	user, err := service.repository.Primary().GetUser(username)
 
 	if err != nil {
 		return user, err
@@ -94,9 +346,24 @@ func (service *UserService) UpdateUser(username string, userData *model.Editable
 		return user, &model.NotFoundError{Title: "User not found", Detail: "The user with the username " + username + " was not found"}
 	}
 
-	if userData.Picture != "" {
+	if !userData.Picture.IsZero() {
 		user.EditableUser.Picture = userData.Picture
 	}
 
-	return service.repository.UpdateUser(user.Id, userData)
+	if userData.DisplayName != "" {
+		user.EditableUser.DisplayName = userData.DisplayName
+	}
+
+	updated, err := service.repository.UpdateUser(user.Id, &user.EditableUser)
+	if err != nil {
+		return updated, err
+	}
+
+	return withDefaultPicture(updated), nil
+}
+
+// BuildSearchIndex loads every user into the in-process search index used by GetAllUsers.
+// Until it's called, searches fall back to the database.
+func (service *UserService) BuildSearchIndex() error {
+	return service.repository.IndexUsers()
 }
\ No newline at end of file
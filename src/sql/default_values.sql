@@ -1,6 +0,0 @@
-INSERT INTO mydb.users (id,username,email,password,picture,created_at) VALUES
-    ('5e2ab5a6-5601-4b5c-b89c-9aa4054f90af','nutri','nutri@nutripocket.com','a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3',NULL,'2025-06-24 22:26:43.953038'),
-    ('1a3b5c7d-8901-4e2f-b3c4-1d2e3f4a5b6c','alice','alice@example.com','a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3',NULL,'2025-06-24 22:30:00.000000'),
-    ('2b4c6d8e-1234-4f5e-c6d7-2e3f4a5b6c7d','bob','bob@example.com','a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3',NULL,'2025-06-24 22:35:00.000000'),
-    ('3c5d7e9f-2345-4g6h-d7e8-3f4a5b6c7d8e','carol','carol@example.com','a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3',NULL,'2025-06-24 22:40:00.000000'),
-    ('4d6e8f0a-3456-4h7i-e8f9-4a5b6c7d8e9f','dave','dave@example.com','a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3',NULL,'2025-06-24 22:45:00.000000');
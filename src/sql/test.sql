@@ -3,5 +3,3 @@ CREATE DATABASE IF NOT EXISTS test;
 USE test;
 
 SET time_zone = '+00:00';
-
-SOURCE /src/sql/tables.sql;
\ No newline at end of file
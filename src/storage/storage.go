@@ -0,0 +1,49 @@
+// Package storage provides backends to persist the files uploaded by the users.
+package storage
+
+import (
+	"fmt"
+
+	"github.com/NutriPocket/UserService/config"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("log")
+
+// IStorage is an interface that contains the methods that will implement a file storage backend.
+type IStorage interface {
+	// Put saves a file, replacing it if it already exists.
+	// key is the path of the file inside the storage, e.g. avatars/<user id>/small.jpg.
+	// data is the content of the file.
+	// contentType is the MIME type of the file.
+	// It returns an error if the operation fails.
+	Put(key string, data []byte, contentType string) error
+	// Delete removes a file. Deleting a missing file isn't an error.
+	// key is the path of the file inside the storage.
+	// It returns an error if the operation fails.
+	Delete(key string) error
+	// URL returns the public URL of a file.
+	// key is the path of the file inside the storage.
+	URL(key string) string
+}
+
+// New creates the storage backend of the settings.
+// It returns an error if the settings of the backend are invalid.
+func New(settings config.StorageConfig) (IStorage, error) {
+	switch settings.Backend {
+	case config.LocalStorage:
+		return NewLocalStorage(settings.LocalDir, settings.PublicURL)
+	case config.S3Storage:
+		return NewS3Storage(S3Config{
+			Endpoint:        settings.S3Endpoint,
+			Region:          settings.S3Region,
+			Bucket:          settings.S3Bucket,
+			AccessKeyId:     settings.S3AccessKeyId,
+			SecretAccessKey: settings.S3SecretAccessKey,
+			PublicURL:       settings.PublicURL,
+			PathStyle:       settings.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", settings.Backend)
+	}
+}
@@ -0,0 +1,146 @@
+package e2e_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	gormDB, err := database.GetPoolConnection()
+	if err != nil {
+		log.Panicf("Failed to connect to database: %v", err)
+	}
+
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	deleteAccount := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodDelete, "/users/me", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	getUser := func(token string, username string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/users/"+username, nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	login := func(emailOrUsername string, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(model.LoginUser{EmailOrUsername: emailOrUsername, Password: password})
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The tokens are revoked with a precision of seconds, so the tokens of a test are issued in a later second.
+	signAfterRevocation := func(user model.User) string {
+		time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+		token, _ := jwtService.Sign(user)
+		return token
+	}
+
+	t.Run("It should hide the account, revoke the tokens and notify the user", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+		other, _ := userService.CreateUser(&model.BaseUser{Username: "other", Email: "other@test.com", Password: "other"})
+		otherToken, _ := jwtService.Sign(other)
+
+		w := deleteAccount(token)
+		assert.Equal(t, http.StatusAccepted, w.Code, "Status code should be 202")
+
+		var resData struct{ Data model.AccountDeletion }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		assert.WithinDuration(t, time.Now().Add(test.Settings().Account.DeletionGracePeriod.Std()), resData.Data.DeleteAfter, time.Minute)
+
+		assert.Equal(t, http.StatusNotFound, getUser(otherToken, "test").Code, "The account should be hidden")
+		assert.Equal(t, http.StatusUnauthorized, getUser(token, "other").Code, "The token should be revoked")
+		assert.Len(t, mails.Messages("test@test.com"), 1, "The user should be notified")
+	})
+
+	t.Run("It should reactivate the account when the user logs in", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		assert.Equal(t, http.StatusAccepted, deleteAccount(token).Code, "Status code should be 202")
+
+		time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+		w := login("test", "test")
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Token string }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+
+		assert.Equal(t, http.StatusOK, getUser(resData.Token, "test").Code, "The account should be visible again")
+	})
+
+	t.Run("It should erase all the data of the user after the grace period", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+		settings := test.Settings()
+		settings.Account.DeletionGracePeriod = 0
+
+		accountDeletionService, err := service.NewAccountDeletionService(nil, nil, nil, nil, mails, settings)
+		assert.Nil(t, err)
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		user, err = userService.ChangeUsername(user.Id, "renamed")
+		assert.Nil(t, err)
+		token, _ := jwtService.Sign(user)
+		other, _ := userService.CreateUser(&model.BaseUser{Username: "other", Email: "other@test.com", Password: "other"})
+
+		_, err = accountDeletionService.RequestDeletion(user.Id)
+		assert.Nil(t, err)
+
+		erased, err := accountDeletionService.EraseExpired()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, erased)
+
+		for _, table := range []string{"users", "username_history"} {
+			var count int64
+			column := "user_id"
+			if table == "users" {
+				column = "id"
+			}
+
+			gormDB.Raw("SELECT COUNT(*) FROM "+table+" WHERE "+column+" = ?", user.Id).Scan(&count)
+			assert.Zero(t, count, "The %s rows of the user should be erased", table)
+		}
+
+		var cutoffs int64
+		gormDB.Raw("SELECT COUNT(*) FROM jwt_revocations WHERE user_id = ?", user.Id).Scan(&cutoffs)
+		assert.Equal(t, int64(1), cutoffs, "The cutoff of the user should be kept")
+
+		otherToken := signAfterRevocation(other)
+		assert.Equal(t, http.StatusOK, getUser(otherToken, "other").Code, "Other users should be kept")
+		assert.Equal(t, http.StatusUnauthorized, getUser(token, "other").Code, "The tokens of the erased user should stay revoked")
+	})
+}
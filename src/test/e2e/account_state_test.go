@@ -0,0 +1,160 @@
+package e2e_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountState(t *testing.T) {
+	gormDB, err := database.GetPoolConnection()
+	if err != nil {
+		log.Panicf("Failed to connect to database: %v", err)
+	}
+
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	userRepository, err := repository.NewUserRepository(nil)
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user repository: %v\n", err)
+	}
+
+	request := func(method string, path string, token string, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(data))
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	login := func(username string, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(model.LoginUser{EmailOrUsername: username, Password: password})
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	createAdmin := func() string {
+		admin, _ := userService.CreateUser(&model.BaseUser{Username: "admin", Email: "admin@test.com", Password: "admin"})
+		if err := gormDB.Exec("UPDATE users SET role = ? WHERE id = ?", model.RoleAdmin, admin.Id).Error; err != nil {
+			log.Fatalf("An error ocurred when making the user an admin: %v\n", err)
+		}
+
+		token, _ := jwtService.Sign(admin)
+		return token
+	}
+
+	t.Run("It should retrieve a forbidden status if the user isn't an admin", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		w := request(http.MethodGet, "/admin/users", token, nil)
+
+		assert.Equal(t, http.StatusForbidden, w.Code, "Status code should be 403")
+	})
+
+	t.Run("It should suspend an account, rejecting its tokens and logins and hiding it", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		adminToken := createAdmin()
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		until := time.Now().Add(time.Hour)
+		w := request(http.MethodPut, "/admin/users/"+user.Id+"/state", adminToken, model.AccountStateChange{
+			State: model.AccountSuspended, Reason: "Spam", Until: &until,
+		})
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/users/admin", token, nil).Code, "The token should be rejected")
+		assert.Equal(t, http.StatusForbidden, login("test", "test").Code, "The login should be rejected")
+		assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/users/test", adminToken, nil).Code, "The user should be hidden")
+
+		w = request(http.MethodGet, "/admin/users?state=suspended", adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Data []model.AdminUser }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		assert.Len(t, resData.Data, 1)
+		assert.Equal(t, user.Id, resData.Data[0].Id)
+		assert.Equal(t, "Spam", resData.Data[0].Reason)
+	})
+
+	t.Run("It should keep rejecting the tokens issued before a suspension after it's lifted", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		adminToken := createAdmin()
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		until := time.Now().Add(time.Hour)
+		w := request(http.MethodPut, "/admin/users/"+user.Id+"/state", adminToken, model.AccountStateChange{
+			State: model.AccountSuspended, Reason: "Spam", Until: &until,
+		})
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		w = request(http.MethodPut, "/admin/users/"+user.Id+"/state", adminToken, model.AccountStateChange{State: model.AccountActive})
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/users/admin", token, nil).Code, "The token should be revoked")
+		assert.Equal(t, http.StatusOK, login("test", "test").Code, "The user should be able to log in again")
+	})
+
+	t.Run("It should lift an expired suspension when the user logs in", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		expired := time.Now().Add(-time.Minute)
+		assert.Nil(t, userRepository.SetAccountState(user.Id, model.AccountState{State: model.AccountSuspended, Reason: "Spam", Until: &expired}))
+
+		assert.Equal(t, http.StatusOK, login("test", "test").Code, "Status code should be 200")
+
+		state, _ := userRepository.GetAccountState(user.Id)
+		assert.Equal(t, model.AccountActive, state.State)
+	})
+
+	t.Run("It should retrieve a bad request status if the state is invalid", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		adminToken := createAdmin()
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		w := request(http.MethodPut, "/admin/users/"+user.Id+"/state", adminToken, model.AccountStateChange{State: model.AccountSuspended})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Status code should be 400")
+	})
+
+	t.Run("It should retrieve a not found status if the user doesn't exist", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		adminToken := createAdmin()
+
+		w := request(http.MethodPut, "/admin/users/missing/state", adminToken, model.AccountStateChange{State: model.AccountBanned, Reason: "Spam"})
+
+		assert.Equal(t, http.StatusNotFound, w.Code, "Status code should be 404")
+	})
+}
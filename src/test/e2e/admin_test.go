@@ -0,0 +1,195 @@
+package e2e_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmin(t *testing.T) {
+	gormDB, err := database.GetPoolConnection()
+	if err != nil {
+		log.Panicf("Failed to connect to database: %v", err)
+	}
+
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	request := func(method string, path string, token string, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(data))
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+		req.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	login := func(username string, password string) *httptest.ResponseRecorder {
+		return request(http.MethodPost, "/auth/login", "", model.LoginUser{EmailOrUsername: username, Password: password})
+	}
+
+	decodeUser := func(w *httptest.ResponseRecorder) model.AdminUser {
+		var resData struct{ Data model.AdminUser }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		return resData.Data
+	}
+
+	// setup creates an admin and a regular user, returning the admin token and the user.
+	setup := func() (string, model.User) {
+		admin, _ := userService.CreateUser(&model.BaseUser{Username: "admin", Email: "admin@test.com", Password: "admin"})
+		if err := gormDB.Exec("UPDATE users SET role = ? WHERE id = ?", model.RoleAdmin, admin.Id).Error; err != nil {
+			log.Fatalf("An error ocurred when making the user an admin: %v\n", err)
+		}
+
+		adminToken, _ := jwtService.Sign(admin)
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		return adminToken, user
+	}
+
+	auditCount := func(action string, targetId string) int64 {
+		var count int64
+		gormDB.Raw("SELECT COUNT(*) FROM audit_log WHERE action = ? AND target_id = ?", action, targetId).Scan(&count)
+		return count
+	}
+
+	t.Run("It should look up users by id and by email", func(t *testing.T) {
+		defer test.ClearUsers()
+		adminToken, user := setup()
+
+		w := request(http.MethodGet, "/admin/users/"+user.Id, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Equal(t, "test@test.com", decodeUser(w).Email)
+		assert.Equal(t, model.RoleUser, decodeUser(w).Role)
+
+		w = request(http.MethodGet, "/admin/users?email=test@test.com", adminToken, nil)
+		var resData struct{ Data []model.AdminUser }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		assert.Len(t, resData.Data, 1)
+		assert.Equal(t, user.Id, resData.Data[0].Id)
+
+		assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/admin/users/missing", adminToken, nil).Code)
+	})
+
+	t.Run("It should assign roles and record them in the audit log", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		adminToken, user := setup()
+
+		w := request(http.MethodPut, "/admin/users/"+user.Id+"/role", adminToken, model.RoleChange{Role: model.RoleAdmin})
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Equal(t, model.RoleAdmin, decodeUser(w).Role)
+		assert.Equal(t, int64(1), auditCount(model.AuditAdminRole, user.Id))
+
+		w = request(http.MethodPut, "/admin/users/"+user.Id+"/role", adminToken, model.RoleChange{Role: "root"})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Status code should be 400")
+	})
+
+	t.Run("It should retrieve a forbidden status if an admin changes its own role", func(t *testing.T) {
+		defer test.ClearUsers()
+		adminToken, _ := setup()
+		admin, _ := userService.GetUser(context.Background(), "admin")
+
+		w := request(http.MethodPut, "/admin/users/"+admin.Id+"/role", adminToken, model.RoleChange{Role: model.RoleUser})
+
+		assert.Equal(t, http.StatusForbidden, w.Code, "Status code should be 403")
+	})
+
+	t.Run("It should revoke the sessions of a user", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		adminToken, user := setup()
+		token, _ := jwtService.Sign(user)
+
+		assert.Equal(t, http.StatusOK, request(http.MethodDelete, "/admin/users/"+user.Id+"/sessions", adminToken, nil).Code)
+
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/users/test", token, nil).Code, "The token should be revoked")
+		assert.Equal(t, int64(1), auditCount(model.AuditAdminRevokeSessions, user.Id))
+	})
+
+	t.Run("It should unlock an account locked after too many failed logins", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		adminToken, user := setup()
+
+		for i := 0; i < test.Settings().Login.MaxFailures; i++ {
+			assert.Equal(t, http.StatusUnauthorized, login("test", "wrong").Code, "Status code should be 401")
+		}
+
+		assert.Equal(t, http.StatusTooManyRequests, login("test", "test").Code, "The account should be locked")
+
+		w := request(http.MethodPost, "/admin/users/"+user.Id+"/unlock", adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Nil(t, decodeUser(w).LockedUntil)
+
+		assert.Equal(t, http.StatusOK, login("test", "test").Code, "The account should be unlocked")
+	})
+
+	t.Run("It should override the email verification", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		adminToken, user := setup()
+
+		w := request(http.MethodPut, "/admin/users/"+user.Id+"/email-verified", adminToken, model.EmailVerificationChange{Verified: true})
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.True(t, decodeUser(w).EmailVerified)
+	})
+
+	t.Run("It should force a password reset and let the user set a new password", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		mails.Reset()
+		adminToken, user := setup()
+
+		assert.Equal(t, http.StatusOK, request(http.MethodPost, "/admin/users/"+user.Id+"/password-reset", adminToken, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, login("test", "test").Code, "The old password should be invalidated")
+
+		messages := mails.Messages("test@test.com")
+		assert.Len(t, messages, 1)
+		token := emailTokenRegex.FindStringSubmatch(messages[0].Body)[1]
+
+		w := request(http.MethodPost, "/auth/password/reset", "", model.PasswordResetRequest{Token: token, Password: "Correct-Horse-7-Battery"})
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		assert.Equal(t, http.StatusOK, login("test", "Correct-Horse-7-Battery").Code, "The new password should be valid")
+
+		w = request(http.MethodPost, "/auth/password/reset", "", model.PasswordResetRequest{Token: token, Password: "Another-Horse-8-Staple"})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "The token can't be used twice")
+		assert.Equal(t, int64(1), auditCount(model.AuditAdminPasswordReset, user.Id))
+	})
+	t.Run("It should report the health and the statistics of the database pool to the admins only", func(t *testing.T) {
+		defer test.ClearUsers()
+		adminToken, user := setup()
+		userToken, _ := jwtService.Sign(user)
+
+		w := request(http.MethodGet, "/admin/database/stats", adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Data database.PoolStats }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		assert.True(t, resData.Data.Healthy)
+		assert.Positive(t, resData.Data.OpenConnections)
+
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/admin/database/stats", userToken, nil).Code)
+	})
+}
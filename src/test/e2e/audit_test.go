@@ -0,0 +1,189 @@
+package e2e_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	gormDB, err := database.GetPoolConnection()
+	if err != nil {
+		log.Panicf("Failed to connect to database: %v", err)
+	}
+
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	request := func(method string, path string, token string, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(data))
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("User-Agent", "audit-test")
+		req.Header.Add("X-Request-Id", "audit-request")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	login := func(username string, password string) *httptest.ResponseRecorder {
+		return request(http.MethodPost, "/auth/login", "", model.LoginUser{EmailOrUsername: username, Password: password})
+	}
+
+	// setup creates an admin and a regular user, returning the admin token and the user.
+	setup := func() (string, model.User) {
+		admin, _ := userService.CreateUser(&model.BaseUser{Username: "admin", Email: "admin@test.com", Password: "admin"})
+		if err := gormDB.Exec("UPDATE users SET role = ? WHERE id = ?", model.RoleAdmin, admin.Id).Error; err != nil {
+			log.Fatalf("An error ocurred when making the user an admin: %v\n", err)
+		}
+
+		adminToken, _ := jwtService.Sign(admin)
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		return adminToken, user
+	}
+
+	entries := func(adminToken string, query string) []model.AuditEntry {
+		w := request(http.MethodGet, "/admin/audit"+query, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Data []model.AuditEntry }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		return resData.Data
+	}
+
+	verify := func(adminToken string) model.AuditVerification {
+		w := request(http.MethodGet, "/admin/audit/verify", adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Data model.AuditVerification }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		return resData.Data
+	}
+
+	t.Run("It should record the logins with where the request came from", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		adminToken, user := setup()
+
+		assert.Equal(t, http.StatusUnauthorized, login("test", "wrong").Code)
+		assert.Equal(t, http.StatusOK, login("test", "test").Code)
+
+		failed := entries(adminToken, "?action="+model.AuditLoginFailed+"&targetId="+user.Id)
+		assert.Len(t, failed, 1)
+		assert.Equal(t, "", failed[0].ActorId)
+		assert.JSONEq(t, `{"reason": "invalid_credentials"}`, failed[0].Details)
+
+		succeeded := entries(adminToken, "?action="+model.AuditLogin+"&actorId="+user.Id)
+		assert.Len(t, succeeded, 1)
+		assert.Equal(t, "audit-test", succeeded[0].UserAgent)
+		assert.Equal(t, "audit-request", succeeded[0].RequestId)
+		assert.NotEmpty(t, succeeded[0].IP)
+		assert.Equal(t, failed[0].Hash, succeeded[0].PrevHash, "The entries should be chained")
+	})
+
+	t.Run("It should detect an entry changed after it was recorded", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		adminToken, _ := setup()
+
+		login("test", "wrong")
+		login("test", "test")
+
+		verification := verify(adminToken)
+		assert.True(t, verification.Valid)
+		assert.Equal(t, 2, verification.Checked)
+
+		first := entries(adminToken, "?action="+model.AuditLoginFailed)[0]
+		gormDB.Exec(`UPDATE audit_log SET details = '{"reason": "none"}' WHERE id = ?`, first.Id)
+
+		verification = verify(adminToken)
+		assert.False(t, verification.Valid)
+		assert.Equal(t, first.Id, *verification.BrokenAt)
+	})
+
+	t.Run("It should keep the chain valid after erasing the personal data of a user", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		adminToken, user := setup()
+
+		login("test", "test")
+
+		accountDeletionService, err := service.NewAccountDeletionService(nil, nil, nil, nil, mails, test.Settings())
+		assert.Nil(t, err)
+		assert.Nil(t, accountDeletionService.Erase(user.Id))
+
+		logins := entries(adminToken, "?action="+model.AuditLogin)
+		assert.Len(t, logins, 1)
+		assert.Equal(t, "", logins[0].ActorId)
+		assert.Equal(t, "", logins[0].IP)
+
+		assert.True(t, verify(adminToken).Valid)
+	})
+
+	t.Run("It should detect the personal data rewritten in an entry marked as erased", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		adminToken, _ := setup()
+
+		login("test", "test")
+
+		first := entries(adminToken, "?action="+model.AuditLogin)[0]
+		gormDB.Exec(`UPDATE audit_log SET pii_erased = TRUE, ip = '10.0.0.1', user_agent = 'forged' WHERE id = ?`, first.Id)
+
+		verification := verify(adminToken)
+		assert.False(t, verification.Valid)
+		assert.Equal(t, first.Id, *verification.BrokenAt)
+	})
+
+	t.Run("It should show the users the activity on their account", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		setup()
+
+		login("test", "wrong")
+		w := login("test", "test")
+
+		var loginData struct{ Token string }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &loginData))
+
+		w = request(http.MethodGet, "/users/me/activity", loginData.Token, nil)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Data []model.Activity }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		assert.Len(t, resData.Data, 2)
+		assert.Equal(t, model.AuditLogin, resData.Data[0].Action)
+		assert.Equal(t, "audit-test", resData.Data[0].UserAgent)
+		assert.NotEmpty(t, resData.Data[0].IP)
+		assert.Equal(t, model.AuditLoginFailed, resData.Data[1].Action)
+		assert.Empty(t, resData.Data[1].UserAgent, "Where the anonymous requests came from should be hidden")
+		assert.Empty(t, resData.Data[1].IP, "Where the anonymous requests came from should be hidden")
+	})
+
+	t.Run("It should retrieve a forbidden status if a regular user reads the audit log", func(t *testing.T) {
+		defer test.ClearUsers()
+		_, user := setup()
+		token, _ := jwtService.Sign(user)
+
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/admin/audit", token, nil).Code)
+	})
+}
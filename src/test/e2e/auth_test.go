@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/NutriPocket/UserService/database"
 	"github.com/NutriPocket/UserService/model"
@@ -30,7 +31,7 @@ func TestPostRegister(t *testing.T) {
 	t.Run("It should create a new user", func(t *testing.T) {
 		defer test.ClearUsers()
 
-		bodyData := RequestData{Username: "test", Email: "test@test.com", Password: "test"}
+		bodyData := RequestData{Username: "test", Email: "test@test.com", Password: "Correct-Horse-7-Battery"}
 		jsonData, err := json.Marshal(bodyData)
 
 		if err != nil {
@@ -169,7 +170,7 @@ func TestPostRegister(t *testing.T) {
 			log.Fatalf("An error ocurred when inserting a user: %v\n", res.Error)
 		}
 
-		bodyData := RequestData{Username: "test", Email: "test2@test.com", Password: "test"}
+		bodyData := RequestData{Username: "test", Email: "test2@test.com", Password: "Correct-Horse-7-Battery"}
 		jsonData, err := json.Marshal(bodyData)
 
 		if err != nil {
@@ -259,6 +260,34 @@ func TestPostRegister(t *testing.T) {
 		assert.Equal(t, "about:blank", data["type"])
 	})
 
+	t.Run("It should retrieve a bad request status with the violations if the provided password is weak", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		bodyData := RequestData{Username: "test", Email: "test@test.com", Password: "P4ssw0rd"}
+		jsonData, _ := json.Marshal(bodyData)
+
+		req, _ := http.NewRequest(http.MethodPost, "/auth/register", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Status code should be 400")
+
+		var data struct {
+			Title  string
+			Errors []model.Violation
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &data)
+		if err != nil {
+			log.Fatal("The response body is not a JSON parseable string, ", err)
+		}
+
+		assert.Equal(t, "Weak password", data.Title)
+		assert.Equal(t, []model.Violation{{
+			Rule:   "min_strength",
+			Detail: "The password is too easy to guess, use a longer one mixing words, numbers and symbols",
+		}}, data.Errors)
+	})
+
 	t.Run("It should retrieve a bad request status if the provided email is blank", func(t *testing.T) {
 		defer test.ClearUsers()
 
@@ -367,7 +396,7 @@ func TestPostRegister(t *testing.T) {
 			log.Fatalf("An error ocurred when inserting a user: %v\n", res.Error)
 		}
 
-		bodyData := RequestData{Username: "test2", Email: "test@test.com", Password: "test"}
+		bodyData := RequestData{Username: "test2", Email: "test@test.com", Password: "Correct-Horse-7-Battery"}
 		jsonData, err := json.Marshal(bodyData)
 
 		if err != nil {
@@ -409,7 +438,7 @@ func TestPostLogin(t *testing.T) {
 		Password        string `json:"password"`
 	}
 
-	service, err := service.NewUserService(nil)
+	service, err := service.NewUserService(nil, nil, test.Settings())
 	if err != nil {
 		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
 	}
@@ -672,7 +701,7 @@ func TestPostLogout(t *testing.T) {
 		Signature string `json:"signature"`
 	}
 
-	jwtService, err := service.NewJWTService(nil)
+	jwtService, err := service.NewJWTService(nil, test.Settings())
 	if err != nil {
 		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
 	}
@@ -747,9 +776,9 @@ func TestPostLogout(t *testing.T) {
 		gormDB.Exec(
 			`
 				INSERT INTO jwt_blacklist (signature, expires_at)
-				VALUES (?, NOW() + INTERVAL 1 DAY)
+				VALUES (?, ?)
 			`,
-			signature,
+			signature, time.Now().UTC().Add(24*time.Hour),
 		)
 
 		req, _ := http.NewRequest(http.MethodPost, "/auth/logout", body)
@@ -771,3 +800,62 @@ func TestPostLogout(t *testing.T) {
 		assert.Equal(t, "about:blank", data["type"])
 	})
 }
+
+func TestDeleteSessions(t *testing.T) {
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	request := func(method string, path string, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("It should log out every session of the user with a single revocation", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		other, _ := userService.CreateUser(&model.BaseUser{Username: "other", Email: "other@test.com", Password: "other"})
+		token, _ := jwtService.Sign(user)
+		otherSession, _ := jwtService.Sign(user)
+		otherUserToken, _ := jwtService.Sign(other)
+
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/users/me/sessions", token).Code, "Status code should be 204")
+
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/users/test", token).Code, "The token should be revoked")
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/users/test", otherSession).Code, "The other sessions should be revoked")
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/users/test", otherUserToken).Code, "The other users shouldn't be affected")
+	})
+
+	t.Run("It should accept the token of a login right after logging out every session", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/users/me/sessions", token).Code, "Status code should be 204")
+
+		body, _ := json.Marshal(model.LoginUser{EmailOrUsername: "test", Password: "test"})
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Token string }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/users/test", resData.Token).Code, "The new token shouldn't be revoked")
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/users/test", token).Code, "The old token should be revoked")
+	})
+}
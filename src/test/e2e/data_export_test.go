@@ -0,0 +1,136 @@
+package e2e_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataExport(t *testing.T) {
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	request := func(method string, path string, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	requestExport := func(token string) model.DataExport {
+		w := request(http.MethodPost, "/users/me/export", token)
+		assert.Equal(t, http.StatusAccepted, w.Code, "Status code should be 202")
+
+		var resData struct{ Data model.DataExport }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+
+		return resData.Data
+	}
+
+	// waitExport polls the export until it's no longer pending.
+	waitExport := func(token string, id string) *httptest.ResponseRecorder {
+		for i := 0; i < 50; i++ {
+			w := request(http.MethodGet, "/users/me/export/"+id, token)
+			if w.Code != http.StatusAccepted {
+				return w
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		t.Fatal("The export should be built in less than 5 seconds")
+		return nil
+	}
+
+	t.Run("It should build a ZIP with a JSON file per exporter and a summary", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		user, err := userService.ChangeUsername(user.Id, "renamed")
+		assert.Nil(t, err)
+		token, _ := jwtService.Sign(user)
+
+		export := requestExport(token)
+		assert.Equal(t, model.DataExportPending, export.Status)
+
+		w := waitExport(token, export.Id)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		assert.Nil(t, err)
+
+		files := make(map[string][]byte)
+		for _, file := range archive.File {
+			reader, _ := file.Open()
+			files[file.Name], _ = io.ReadAll(reader)
+			reader.Close()
+		}
+
+		for _, name := range []string{"summary.txt", "account.json", "username_history.json", "email_changes.json", "sessions.json", "data_exports.json"} {
+			assert.Contains(t, files, name)
+		}
+
+		var account map[string]any
+		assert.Nil(t, json.Unmarshal(files["account.json"], &account))
+		assert.Equal(t, "renamed", account["username"])
+		assert.NotContains(t, account, "password")
+
+		var history []map[string]any
+		assert.Nil(t, json.Unmarshal(files["username_history.json"], &history))
+		assert.Len(t, history, 1)
+		assert.Equal(t, "test", history[0]["username"])
+
+		assert.Contains(t, string(files["summary.txt"]), "renamed")
+	})
+
+	t.Run("It should return the pending export instead of starting another", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		first := requestExport(token)
+		second := requestExport(token)
+
+		if second.Status == model.DataExportPending {
+			assert.Equal(t, first.Id, second.Id)
+		}
+
+		waitExport(token, first.Id)
+		waitExport(token, second.Id)
+	})
+
+	t.Run("It should retrieve a not found status for the exports of other users", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+		other, _ := userService.CreateUser(&model.BaseUser{Username: "other", Email: "other@test.com", Password: "other"})
+		otherToken, _ := jwtService.Sign(other)
+
+		export := requestExport(token)
+
+		assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/users/me/export/"+export.Id, otherToken).Code)
+
+		waitExport(token, export.Id)
+	})
+}
@@ -0,0 +1,156 @@
+package e2e_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/NutriPocket/UserService/mailer"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+var emailTokenRegex = regexp.MustCompile(`token=([0-9a-f]+)`)
+
+func TestPostChangeEmail(t *testing.T) {
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	userRepository, err := repository.NewUserRepository(nil)
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user repository: %v\n", err)
+	}
+
+	requestChange := func(token string, email string, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(model.EmailChangeRequest{Email: email, Password: password})
+		req, _ := http.NewRequest(http.MethodPost, "/users/me/email", bytes.NewBuffer(body))
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	lastToken := func(mails *mailer.MemoryMailer, to string) string {
+		messages := mails.Messages(to)
+		if len(messages) == 0 {
+			return ""
+		}
+
+		match := emailTokenRegex.FindStringSubmatch(messages[len(messages)-1].Body)
+		if match == nil {
+			return ""
+		}
+
+		return match[1]
+	}
+
+	follow := func(action string, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/auth/email/"+action+"?token="+token, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("It should retrieve an unauthorized status if the password is wrong", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		w := requestChange(token, "new@test.com", "wrong")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "Status code should be 401")
+	})
+
+	t.Run("It should retrieve a conflict status if the email is in use", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		userService.CreateUser(&model.BaseUser{Username: "other", Email: "other@test.com", Password: "other"})
+		token, _ := jwtService.Sign(user)
+
+		w := requestChange(token, "other@test.com", "test")
+
+		assert.Equal(t, http.StatusConflict, w.Code, "Status code should be 409")
+	})
+
+	t.Run("It should only change the email after the new address confirms it", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		w := requestChange(token, "new@test.com", "test")
+
+		assert.Equal(t, http.StatusAccepted, w.Code, "Status code should be 202")
+		assert.Len(t, mails.Messages("new@test.com"), 1, "The new address should receive the confirmation")
+		assert.Len(t, mails.Messages("test@test.com"), 1, "The old address should receive the notification")
+
+		saved, _ := userRepository.GetUserById(user.Id)
+		assert.Equal(t, "test@test.com", saved.Email, "The email shouldn't change before the confirmation")
+
+		w = follow("confirm", lastToken(mails, "new@test.com"))
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		saved, _ = userRepository.GetUserById(user.Id)
+		assert.Equal(t, "new@test.com", saved.Email)
+
+		w = follow("confirm", lastToken(mails, "new@test.com"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "A confirmation token can't be used twice")
+	})
+
+	t.Run("It should restore the old email when the change is undone", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		requestChange(token, "new@test.com", "test")
+		follow("confirm", lastToken(mails, "new@test.com"))
+
+		w := follow("undo", lastToken(mails, "test@test.com"))
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		saved, _ := userRepository.GetUserById(user.Id)
+		assert.Equal(t, "test@test.com", saved.Email)
+	})
+
+	t.Run("It should cancel a pending change when it's undone", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		requestChange(token, "new@test.com", "test")
+		follow("undo", lastToken(mails, "test@test.com"))
+
+		w := follow("confirm", lastToken(mails, "new@test.com"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "A cancelled change can't be confirmed")
+
+		saved, _ := userRepository.GetUserById(user.Id)
+		assert.Equal(t, "test@test.com", saved.Email)
+	})
+}
@@ -0,0 +1,67 @@
+package e2e_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NutriPocket/UserService/app"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	probe := func(router http.Handler, path string) (*httptest.ResponseRecorder, service.HealthReport) {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var report service.HealthReport
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+
+		return w, report
+	}
+
+	t.Run("It should answer the liveness probe without a token", func(t *testing.T) {
+		w, report := probe(router, "/healthz")
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Equal(t, service.HealthOK, report.Status)
+	})
+
+	t.Run("It should report each dependency as ready without a token", func(t *testing.T) {
+		w, report := probe(router, "/readyz")
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Equal(t, service.HealthOK, report.Status)
+
+		for _, name := range []string{"database", "migrations", "jwtKey"} {
+			if assert.Contains(t, report.Checks, name) {
+				assert.Equal(t, service.HealthOK, report.Checks[name].Status, report.Checks[name].Error)
+				assert.GreaterOrEqual(t, report.Checks[name].LatencyMs, 0.0)
+			}
+		}
+	})
+
+	t.Run("It should answer 503 with the error of a failing dependency", func(t *testing.T) {
+		container := &app.Container{HealthService: service.NewHealthServiceWithChecks([]service.HealthCheck{
+			{Name: "up", Check: func(ctx context.Context) error { return nil }},
+			{Name: "down", Check: func(ctx context.Context) error { return errors.New("connection refused") }},
+		})}
+
+		w, report := probe(utils.SetupRouter(container), "/readyz")
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "Status code should be 503")
+		assert.Equal(t, service.HealthFailing, report.Status)
+		assert.Equal(t, service.HealthOK, report.Checks["up"].Status)
+		assert.Equal(t, service.HealthFailing, report.Checks["down"].Status)
+		assert.Equal(t, "connection refused", report.Checks["down"].Error)
+
+		w, _ = probe(utils.SetupRouter(container), "/healthz")
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+	})
+}
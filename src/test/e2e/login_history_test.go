@@ -0,0 +1,80 @@
+package e2e_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginHistory(t *testing.T) {
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	login := func(userAgent string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(model.LoginUser{EmailOrUsername: "test", Password: "test"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(data))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	logins := func(token string) []model.Login {
+		req, _ := http.NewRequest(http.MethodGet, "/users/me/logins", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Data []model.Login }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		return resData.Data
+	}
+
+	t.Run("It should list the logins with the device and the location", func(t *testing.T) {
+		defer test.ClearUsers()
+		userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		w := login("NutriPocket/1.0 (Android 14)")
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var loginData struct{ Token string }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &loginData))
+
+		history := logins(loginData.Token)
+		assert.Len(t, history, 1)
+		assert.Equal(t, "NutriPocket/1.0 (Android 14)", history[0].UserAgent)
+		assert.Equal(t, "192.0.2.1", history[0].IP)
+		assert.Equal(t, "AR", history[0].Country)
+		assert.Equal(t, "Buenos Aires", history[0].City)
+		assert.True(t, history[0].NewDevice)
+	})
+
+	t.Run("It should alert the user of a login from a new device only", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+		userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		login("NutriPocket/1.0 (Android 14)")
+		assert.Len(t, mails.Messages("test@test.com"), 0, "The first login shouldn't be alerted")
+
+		login("NutriPocket/1.1 (Android 14)")
+		assert.Len(t, mails.Messages("test@test.com"), 0, "An app update shouldn't be a new device")
+
+		login("Mozilla/5.0 (Windows NT 10.0; Win64; x64) Firefox/131.0")
+		messages := mails.Messages("test@test.com")
+		assert.Len(t, messages, 1)
+		assert.Contains(t, messages[0].Body, "Buenos Aires, Buenos Aires, AR")
+		assert.Contains(t, messages[0].Body, "Firefox/131.0")
+	})
+}
@@ -0,0 +1,126 @@
+package e2e_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/NutriPocket/UserService/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+var loginCodeRegex = regexp.MustCompile(`\n\n(\d{6})\n\n`)
+
+func TestLoginRisk(t *testing.T) {
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	request := func(method string, path string, token string, ip string, userAgent string, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(data))
+		req.RemoteAddr = ip + ":4321"
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	login := func(ip string, userAgent string) *httptest.ResponseRecorder {
+		return request(http.MethodPost, "/auth/login", "", ip, userAgent, model.LoginUser{EmailOrUsername: "test", Password: "test"})
+	}
+
+	decodeChallenge := func(w *httptest.ResponseRecorder) model.LoginChallenge {
+		var resData struct{ Data model.LoginChallenge }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+		return resData.Data
+	}
+
+	setup := func() {
+		userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		// The first login, from Buenos Aires, is the baseline of the next ones.
+		assert.Equal(t, http.StatusOK, login("192.0.2.1", "NutriPocket/1.0 (Android 14)").Code)
+	}
+
+	t.Run("It should ask for an email code after an impossible travel", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		mails.Reset()
+		setup()
+
+		w := login("198.51.100.1", "NutriPocket/1.0 (Android 14)")
+		assert.Equal(t, http.StatusAccepted, w.Code, "Status code should be 202")
+		assert.NotContains(t, w.Body.String(), "token")
+
+		challenge := decodeChallenge(w)
+		assert.Equal(t, model.StepUpEmail, challenge.Method)
+
+		messages := mails.Messages("test@test.com")
+		assert.Len(t, messages, 1)
+		code := loginCodeRegex.FindStringSubmatch(messages[0].Body)[1]
+
+		w = request(http.MethodPost, "/auth/login/verify", "", "198.51.100.1", "", model.LoginChallengeResponse{ChallengeId: challenge.Id, Code: "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "A wrong code should be rejected")
+
+		w = request(http.MethodPost, "/auth/login/verify", "", "198.51.100.1", "", model.LoginChallengeResponse{ChallengeId: challenge.Id, Code: code})
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Contains(t, w.Body.String(), "token")
+
+		w = request(http.MethodPost, "/auth/login/verify", "", "198.51.100.1", "", model.LoginChallengeResponse{ChallengeId: challenge.Id, Code: code})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "The challenge can't be answered twice")
+	})
+
+	t.Run("It should block a login from a new device in a new country after an impossible travel", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		setup()
+
+		w := login("198.51.100.1", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Firefox/131.0")
+
+		assert.Equal(t, http.StatusForbidden, w.Code, "Status code should be 403")
+	})
+
+	t.Run("It should ask for the code of the authenticator app if it's enabled", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		setup()
+
+		var loginData struct{ Token string }
+		assert.Nil(t, json.Unmarshal(login("192.0.2.1", "NutriPocket/1.0 (Android 14)").Body.Bytes(), &loginData))
+
+		w := request(http.MethodPost, "/users/me/totp", loginData.Token, "192.0.2.1", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var enrollment struct{ Data model.TOTPEnrollment }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &enrollment))
+
+		code, _ := totp.Code(enrollment.Data.Secret, totp.Step(time.Now()))
+		w = request(http.MethodPost, "/users/me/totp/confirm", loginData.Token, "192.0.2.1", "", model.TOTPCode{Code: code})
+		assert.Equal(t, http.StatusNoContent, w.Code, "Status code should be 204")
+
+		w = login("198.51.100.1", "NutriPocket/1.0 (Android 14)")
+		assert.Equal(t, http.StatusAccepted, w.Code, "Status code should be 202")
+		challenge := decodeChallenge(w)
+		assert.Equal(t, model.StepUpTOTP, challenge.Method)
+
+		w = request(http.MethodPost, "/auth/login/verify", "", "198.51.100.1", "", model.LoginChallengeResponse{ChallengeId: challenge.Id, Code: code})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "A used code should be rejected")
+
+		next, _ := totp.Code(enrollment.Data.Secret, totp.Step(time.Now())+1)
+		w = request(http.MethodPost, "/auth/login/verify", "", "198.51.100.1", "", model.LoginChallengeResponse{ChallengeId: challenge.Id, Code: next})
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+	})
+}
@@ -2,8 +2,12 @@ package e2e_test
 
 import (
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/NutriPocket/UserService/app"
+	"github.com/NutriPocket/UserService/geoip"
+	"github.com/NutriPocket/UserService/mailer"
 	"github.com/NutriPocket/UserService/test"
 	"github.com/NutriPocket/UserService/utils"
 	"github.com/gin-gonic/gin"
@@ -13,11 +17,34 @@ import (
 var log = logging.MustGetLogger("log")
 var router *gin.Engine
 
+// container has the services of router.
+var container *app.Container
+
+// mails are the emails sent by the service, reset by the tests that check them.
+var mails = &mailer.MemoryMailer{}
+
+// locations is the GeoIP database of the service, with a network in Buenos Aires, where the httptest requests come
+// from, and one in Madrid.
+const locations = "192.0.2.0,192.0.2.255,SA,AR,Buenos Aires,Buenos Aires,-34.6,-58.4\n" +
+	"198.51.100.0,198.51.100.255,EU,ES,Madrid,Madrid,40.4,-3.7\n"
+
 func TestMain(m *testing.M) {
 	test.Setup("e2e")
 	gin.SetMode(gin.TestMode)
 
-	router = utils.SetupRouter()
+	resolver, err := geoip.Parse(strings.NewReader(locations))
+	if err != nil {
+		log.Fatalf("An error ocurred when parsing the GeoIP database: %v\n", err)
+	}
+
+	settings := test.Settings()
+
+	container, err = app.New(app.Dependencies{Config: &settings, Mailer: mails, Resolver: resolver})
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the application container: %v\n", err)
+	}
+
+	router = utils.SetupRouter(container)
 
 	code := m.Run()
 	test.TearDown("e2e")
@@ -0,0 +1,24 @@
+package e2e_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("It should expose the statistics of the database pool without a token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Equal(t, database.PrometheusContentType, w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "# TYPE userservice_db_open_connections gauge\n")
+		assert.Contains(t, w.Body.String(), "userservice_db_up{database=\"primary\"} 1\n")
+		assert.Contains(t, w.Body.String(), "userservice_db_max_open_connections{database=\"primary\"} ")
+	})
+}
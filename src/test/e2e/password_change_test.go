@@ -0,0 +1,110 @@
+package e2e_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostChangePassword(t *testing.T) {
+	gormDB, err := database.GetPoolConnection()
+	if err != nil {
+		log.Panicf("Failed to connect to database: %v", err)
+	}
+
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	request := func(method string, path string, token string, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(data))
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+		req.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	changePassword := func(token string, current string, new string) *httptest.ResponseRecorder {
+		return request(http.MethodPost, "/users/me/password", token, model.PasswordChangeRequest{CurrentPassword: current, NewPassword: new})
+	}
+
+	login := func(password string) *httptest.ResponseRecorder {
+		return request(http.MethodPost, "/auth/login", "", model.LoginUser{EmailOrUsername: "test", Password: password})
+	}
+
+	t.Run("It should retrieve an unauthorized status if the current password is wrong", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		w := changePassword(token, "wrong", "Correct-Horse-7-Battery")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "Status code should be 401")
+		assert.Equal(t, http.StatusOK, login("test").Code, "The password shouldn't change")
+	})
+
+	t.Run("It should retrieve a bad request status with the violations if the new password is weak", func(t *testing.T) {
+		defer test.ClearUsers()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		w := changePassword(token, "test", "test1234")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Status code should be 400")
+		assert.Contains(t, w.Body.String(), `"rule":"min_strength"`)
+	})
+
+	t.Run("It should change the password and log out the other sessions", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+		mails.Reset()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		otherSession, _ := jwtService.Sign(user)
+		token, _ := jwtService.Sign(user)
+
+		w := changePassword(token, "test", "Correct-Horse-7-Battery")
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Token string }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/users/me/logins", otherSession, nil).Code,
+			"The other sessions should be logged out")
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/users/me/logins", resData.Token, nil).Code,
+			"The new token should be valid")
+
+		assert.Equal(t, http.StatusUnauthorized, login("test").Code, "The old password should be invalidated")
+		assert.Equal(t, http.StatusOK, login("Correct-Horse-7-Battery").Code, "The new password should be valid")
+
+		messages := mails.Messages("test@test.com")
+		assert.Len(t, messages, 1)
+		assert.Equal(t, "Your NutriPocket password was changed", messages[0].Subject)
+
+		var count int64
+		gormDB.Raw("SELECT COUNT(*) FROM audit_log WHERE action = ? AND target_id = ?", model.AuditPasswordChange, user.Id).Scan(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
@@ -0,0 +1,45 @@
+package e2e_test
+
+import (
+	"testing"
+
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/repository/repositorytest"
+	"github.com/NutriPocket/UserService/test"
+)
+
+// The repositories of the configured database pass the conformance tests the in-memory ones pass.
+
+func TestUserRepositoryConformance(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.IUserRepository {
+		test.ClearUsers()
+
+		r, err := repository.NewUserRepository(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+	test.ClearUsers()
+}
+
+func TestJWTRepositoryConformance(t *testing.T) {
+	repositorytest.TestJWTRepository(t, func(t *testing.T) (repository.IJWTRepository, repository.IUserRepository) {
+		test.ClearUsers()
+		test.ClearBlacklist()
+
+		jwtRepository, err := repository.NewJWTRepository(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		userRepository, err := repository.NewUserRepository(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return jwtRepository, userRepository
+	})
+	test.ClearUsers()
+	test.ClearBlacklist()
+}
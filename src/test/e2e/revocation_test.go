@@ -0,0 +1,122 @@
+package e2e_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NutriPocket/UserService/model"
+	"github.com/NutriPocket/UserService/repository"
+	"github.com/NutriPocket/UserService/service"
+	"github.com/NutriPocket/UserService/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocationCache(t *testing.T) {
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	userService, err := service.NewUserService(nil, nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user service: %v\n", err)
+	}
+
+	userRepository, err := repository.NewUserRepository(nil)
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user repository: %v\n", err)
+	}
+
+	jwtRepository, err := repository.NewJWTRepository(nil)
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT repository: %v\n", err)
+	}
+
+	// The cache of the router is loaded, as it's the one checking the tokens of the requests.
+	cacheService := container.RevocationCacheService
+
+	request := func(method string, path string, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The other tests check the tokens with the database, so the cache is left stale.
+	warm := func(t *testing.T) {
+		assert.Nil(t, cacheService.Warm())
+		t.Cleanup(func() { cacheService.Cache().MarkRefreshed(time.Unix(0, 0)) })
+	}
+
+	t.Run("It should enforce the revocations and account states changed by another instance once refreshed", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		revoked, _ := userService.CreateUser(&model.BaseUser{Username: "revoked", Email: "revoked@test.com", Password: "revoked"})
+		suspended, _ := userService.CreateUser(&model.BaseUser{Username: "suspended", Email: "suspended@test.com", Password: "suspended"})
+		revokedToken, _ := jwtService.Sign(revoked)
+		suspendedToken, _ := jwtService.Sign(suspended)
+
+		warm(t)
+
+		// Another instance changes the database, this one doesn't know until it refreshes.
+		assert.Nil(t, jwtRepository.RevokeAll(revoked.Id, time.Now().Add(time.Second)))
+		assert.Nil(t, userRepository.SetAccountState(suspended.Id, model.AccountState{State: model.AccountSuspended}))
+
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/users/revoked", revokedToken).Code, "The cache shouldn't query the database")
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/users/revoked", suspendedToken).Code, "The cache shouldn't query the database")
+
+		assert.Nil(t, cacheService.Refresh())
+
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/users/revoked", revokedToken).Code, "The token should be revoked")
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/users/revoked", suspendedToken).Code, "The account should be suspended")
+
+		assert.Nil(t, userRepository.SetAccountState(suspended.Id, model.AccountState{State: model.AccountActive}))
+		assert.Nil(t, cacheService.Refresh())
+
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/users/revoked", suspendedToken).Code, "The suspension should be lifted")
+	})
+
+	t.Run("It should enforce right away the revocations of this instance and accept a new login", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		warm(t)
+
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/users/me/sessions", token).Code, "Status code should be 204")
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/users/test", token).Code, "The token should be revoked")
+
+		body, _ := json.Marshal(model.LoginUser{EmailOrUsername: "test", Password: "test"})
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var resData struct{ Token string }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resData))
+
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/users/test", resData.Token).Code, "The new token shouldn't be revoked")
+	})
+
+	t.Run("It should reject right away the tokens of an account deleted by this instance", func(t *testing.T) {
+		defer test.ClearUsers()
+		defer test.ClearAuditLog()
+
+		user, _ := userService.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		warm(t)
+
+		assert.Equal(t, http.StatusAccepted, request(http.MethodDelete, "/users/me", token).Code, "Status code should be 202")
+		w := request(http.MethodGet, "/users/test", token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "Status code should be 401")
+		assert.Contains(t, w.Body.String(), "Account pending deletion")
+	})
+}
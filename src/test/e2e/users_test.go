@@ -1,10 +1,16 @@
 package e2e_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/NutriPocket/UserService/model"
@@ -14,23 +20,32 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGetUsers(t *testing.T) {
-	jwtService, err := service.NewJWTService(nil)
+// signIn creates the user the requests are authenticated as, which must exist as its account state is checked on every
+// request, and returns its Authorization header. The listings leave it out.
+func signIn(t *testing.T, jwtService *service.JWTService) string {
+	repository, err := repository.NewUserRepository(nil)
 	if err != nil {
-		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+		t.Fatalf("An error ocurred when creating the user repository: %v\n", err)
 	}
 
-	testUser := model.User{
-		Username: "test", Email: "test@test.com",
+	caller, err := repository.CreateUser(&model.BaseUser{Username: "caller", Email: "caller@test.com", Password: "caller"})
+	if err != nil {
+		t.Fatalf("An error ocurred when creating the authenticated user: %v\n", err)
 	}
 
-	token, err := jwtService.Sign(testUser)
-
+	token, err := jwtService.Sign(caller)
 	if err != nil {
-		log.Fatalf("An error ocurred when signing testUser: %v\n", err)
+		t.Fatalf("An error ocurred when signing the authenticated user: %v\n", err)
 	}
 
-	bearerToken := fmt.Sprintf("Bearer %s", token)
+	return fmt.Sprintf("Bearer %s", token)
+}
+
+func TestGetUsers(t *testing.T) {
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
 
 	t.Run("It should retrieve an unauthorized status code if not auth header is provided", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, "/users/", nil)
@@ -53,6 +68,8 @@ func TestGetUsers(t *testing.T) {
 	})
 
 	t.Run("It should retrieve an empty array if the table is empty", func(t *testing.T) {
+		defer test.ClearUsers()
+		bearerToken := signIn(t, jwtService)
 		req, _ := http.NewRequest(http.MethodGet, "/users/", nil)
 		req.Header.Add("Authorization", bearerToken)
 		w := httptest.NewRecorder()
@@ -68,8 +85,36 @@ func TestGetUsers(t *testing.T) {
 		assert.Empty(t, data, "If the users table is empty, it should return an empty array")
 	})
 
+	t.Run("It should leave the authenticated user out of the users without shortening the limit", func(t *testing.T) {
+		defer test.ClearUsers()
+		repository, err := repository.NewUserRepository(nil)
+		if err != nil {
+			t.Errorf("An error ocurred when creating the user repository: %v\n", err)
+		}
+
+		repository.CreateUser(&model.BaseUser{Username: "test1", Email: "test1@test.com", Password: "test1"})
+		bearerToken := signIn(t, jwtService)
+
+		req, _ := http.NewRequest(http.MethodGet, "/users/?limit=1", nil)
+		req.Header.Add("Authorization", bearerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var data []model.User
+		err = json.Unmarshal(w.Body.Bytes(), &data)
+		if err != nil {
+			log.Fatal("The response body is not a []model.User parseable string, ", err)
+		}
+
+		assert.Len(t, data, 1, "The newest user is the authenticated one, so the limit should be filled by the next")
+		assert.Equal(t, "test1", data[0].Username)
+	})
+
 	t.Run("It should retrieve all the users in the table", func(t *testing.T) {
 		defer test.ClearUsers()
+		bearerToken := signIn(t, jwtService)
 		w := httptest.NewRecorder()
 		repository, err := repository.NewUserRepository(nil)
 		if err != nil {
@@ -105,6 +150,7 @@ func TestGetUsers(t *testing.T) {
 
 	t.Run("It should retrieve all the users in the table if searchUsername is an empty string", func(t *testing.T) {
 		defer test.ClearUsers()
+		bearerToken := signIn(t, jwtService)
 		w := httptest.NewRecorder()
 		repository, err := repository.NewUserRepository(nil)
 		if err != nil {
@@ -140,6 +186,7 @@ func TestGetUsers(t *testing.T) {
 
 	t.Run("It should retrieve only the users in the table that matchs searchUsername param partially", func(t *testing.T) {
 		defer test.ClearUsers()
+		bearerToken := signIn(t, jwtService)
 		w := httptest.NewRecorder()
 		repository, err := repository.NewUserRepository(nil)
 		if err != nil {
@@ -173,6 +220,7 @@ func TestGetUsers(t *testing.T) {
 
 	t.Run("It should retrieve only the users in the table that matchs searchUsername param in the string order", func(t *testing.T) {
 		defer test.ClearUsers()
+		bearerToken := signIn(t, jwtService)
 		w := httptest.NewRecorder()
 		repository, err := repository.NewUserRepository(nil)
 		if err != nil {
@@ -201,10 +249,61 @@ func TestGetUsers(t *testing.T) {
 		assert.Equal(t, "jorge", data[0].Username)
 		assert.Equal(t, "test2@test.com", data[0].Email)
 	})
+
+	t.Run("It should retrieve the users matching the display name ignoring accents and typos, ranked by relevance", func(t *testing.T) {
+		defer test.ClearUsers()
+		bearerToken := signIn(t, jwtService)
+		w := httptest.NewRecorder()
+		repository, err := repository.NewUserRepository(nil)
+		if err != nil {
+			t.Errorf("An error ocurred when creating the user repository: %v\n", err)
+		}
+
+		perezoso, _ := repository.CreateUser(&model.BaseUser{Username: "perezoso", Email: "test1@test.com", Password: "test1"})
+		jorge, _ := repository.CreateUser(&model.BaseUser{Username: "jorge", Email: "test2@test.com", Password: "test2"})
+		repository.CreateUser(&model.BaseUser{Username: "pedro", Email: "test3@test.com", Password: "test3"})
+		repository.UpdateUser(jorge.Id, &model.EditableUser{DisplayName: "Jorge Pérez"})
+
+		if err := repository.IndexUsers(); err != nil {
+			t.Errorf("An error ocurred when indexing the users: %v\n", err)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "/users/?searchUsername=PEREZ", nil)
+		req.Header.Add("Authorization", bearerToken)
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var data []model.User
+		err = json.Unmarshal(w.Body.Bytes(), &data)
+		if err != nil {
+			log.Fatal("The response body is not a []model.User parseable string, ", err)
+		}
+
+		assert.Len(t, data, 2, "The length of the array should be 2")
+		assert.Equal(t, perezoso.Username, data[0].Username)
+		assert.Equal(t, jorge.Username, data[1].Username)
+		assert.Equal(t, "Jorge Pérez", data[1].DisplayName)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/users/?searchUsername=jorje", nil)
+		req.Header.Add("Authorization", bearerToken)
+
+		router.ServeHTTP(w, req)
+
+		err = json.Unmarshal(w.Body.Bytes(), &data)
+		if err != nil {
+			log.Fatal("The response body is not a []model.User parseable string, ", err)
+		}
+
+		assert.Len(t, data, 1, "The length of the array should be 1")
+		assert.Equal(t, jorge.Username, data[0].Username)
+	})
 }
 
 func TestGetUser(t *testing.T) {
-	jwtService, err := service.NewJWTService(nil)
+	jwtService, err := service.NewJWTService(nil, test.Settings())
 	if err != nil {
 		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
 	}
@@ -213,14 +312,6 @@ func TestGetUser(t *testing.T) {
 		Username: "test", Email: "test@test.com",
 	}
 
-	token, err := jwtService.Sign(testUser)
-
-	if err != nil {
-		log.Fatalf("An error ocurred when signing testUser: %v\n", err)
-	}
-
-	bearerToken := fmt.Sprintf("Bearer %s", token)
-
 	t.Run("It should retrieve an unauthorized status code if not auth header is provided", func(t *testing.T) {
 		username := "test"
 		req, _ := http.NewRequest(http.MethodGet, "/users/"+username, nil)
@@ -243,6 +334,8 @@ func TestGetUser(t *testing.T) {
 	})
 
 	t.Run("It should retrieve a not found status if the user with username 'test' is not found", func(t *testing.T) {
+		defer test.ClearUsers()
+		bearerToken := signIn(t, jwtService)
 		username := "test"
 
 		req, _ := http.NewRequest(http.MethodGet, "/users/"+username, nil)
@@ -278,6 +371,7 @@ func TestGetUser(t *testing.T) {
 		repository.CreateUser(&model.BaseUser{Username: "test2", Email: "test2@test.com", Password: "test2"})
 		repository.CreateUser(&model.BaseUser{Username: "test3", Email: "test3@test.com", Password: "test3"})
 		defer test.ClearUsers()
+		bearerToken := signIn(t, jwtService)
 
 		req, _ := http.NewRequest(http.MethodGet, "/users/"+username, nil)
 		req.Header.Add("Authorization", bearerToken)
@@ -296,3 +390,371 @@ func TestGetUser(t *testing.T) {
 		assert.Equal(t, testUser.Email, data.Email)
 	})
 }
+
+func TestPatchUser(t *testing.T) {
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	repository, err := repository.NewUserRepository(nil)
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user repository: %v\n", err)
+	}
+
+	patchUser := func(token string, username string, displayName string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(model.EditableUser{DisplayName: displayName})
+		req, _ := http.NewRequest(http.MethodPatch, "/users/"+username, bytes.NewBuffer(body))
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("It should retrieve a forbidden status when changing the profile of another user", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		repository.CreateUser(&model.BaseUser{Username: "other", Email: "other@test.com", Password: "other"})
+		token, _ := jwtService.Sign(user)
+
+		w := patchUser(token, "other", "Impostor")
+
+		assert.Equal(t, http.StatusForbidden, w.Code, "Status code should be 403")
+
+		other, _ := repository.GetUser("other")
+		assert.Empty(t, other.DisplayName)
+	})
+
+	t.Run("It should change the own profile", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		w := patchUser(token, "test", "Tester")
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var data model.User
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &data))
+		assert.Equal(t, "Tester", data.DisplayName)
+	})
+
+	t.Run("It should let an admin change the profile of another user", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		admin, _ := repository.CreateUser(&model.BaseUser{Username: "admin", Email: "admin@test.com", Password: "admin"})
+		repository.SetRole(admin.Id, model.RoleAdmin)
+		repository.CreateUser(&model.BaseUser{Username: "other", Email: "other@test.com", Password: "other"})
+		token, _ := jwtService.Sign(admin)
+
+		w := patchUser(token, "other", "Moderated")
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+	})
+}
+
+func TestPutAvatar(t *testing.T) {
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	repository, err := repository.NewUserRepository(nil)
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user repository: %v\n", err)
+	}
+
+	multipartBody := func(content []byte) (*bytes.Buffer, string) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("avatar", "avatar.png")
+		part.Write(content)
+		writer.Close()
+
+		return body, writer.FormDataContentType()
+	}
+
+	var pngData bytes.Buffer
+	png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 300, 200)))
+
+	t.Run("It should retrieve a forbidden status when changing the avatar of another user", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		repository.CreateUser(&model.BaseUser{Username: "other", Email: "other@test.com", Password: "other"})
+		token, _ := jwtService.Sign(user)
+
+		body, contentType := multipartBody(pngData.Bytes())
+		req, _ := http.NewRequest(http.MethodPut, "/users/other/avatar", body)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code, "Status code should be 403")
+	})
+
+	t.Run("It should retrieve a bad request status if the file isn't an image", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		body, contentType := multipartBody([]byte("this is not an image"))
+		req, _ := http.NewRequest(http.MethodPut, "/users/test/avatar", body)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Status code should be 400")
+
+		var data map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &data)
+		assert.Equal(t, "Unsupported avatar format", data["title"])
+	})
+
+	t.Run("It should save the avatar thumbnails and return their URLs", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		body, contentType := multipartBody(pngData.Bytes())
+		req, _ := http.NewRequest(http.MethodPut, "/users/test/avatar", body)
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var data model.User
+		err := json.Unmarshal(w.Body.Bytes(), &data)
+		if err != nil {
+			log.Fatal("The response body is not a model.User parseable string, ", err)
+		}
+
+		assert.True(t, strings.HasSuffix(data.Picture.Small, "/avatars/"+user.Id+"/small.jpg"))
+		assert.True(t, strings.HasSuffix(data.Picture.Medium, "/avatars/"+user.Id+"/medium.jpg"))
+		assert.True(t, strings.HasSuffix(data.Picture.Large, "/avatars/"+user.Id+"/large.jpg"))
+
+		req, _ = http.NewRequest(http.MethodGet, data.Picture.Small, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "The thumbnail should be served without authorization")
+		assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	})
+}
+
+func TestGetGeneratedAvatar(t *testing.T) {
+	repository, err := repository.NewUserRepository(nil)
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user repository: %v\n", err)
+	}
+
+	t.Run("It should retrieve a not found status if the user doesn't exist", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/users/test/avatar.png", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code, "Status code should be 404")
+	})
+
+	t.Run("It should retrieve a bad request status if the size is out of range", func(t *testing.T) {
+		defer test.ClearUsers()
+		repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		req, _ := http.NewRequest(http.MethodGet, "/users/test/avatar.png?size=5000", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Status code should be 400")
+	})
+
+	t.Run("It should render the avatar without authorization and with caching headers", func(t *testing.T) {
+		defer test.ClearUsers()
+		repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		req, _ := http.NewRequest(http.MethodGet, "/users/test/avatar.png?size=64", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.Equal(t, "public, max-age=86400", w.Header().Get("Cache-Control"))
+
+		img, err := png.Decode(w.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, 64, img.Bounds().Dx())
+
+		etag := w.Header().Get("ETag")
+		req, _ = http.NewRequest(http.MethodGet, "/users/test/avatar.png?size=64", nil)
+		req.Header.Add("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code, "Status code should be 304")
+
+		req, _ = http.NewRequest(http.MethodGet, "/users/test/avatar.svg", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+		assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("It should use the generated avatar as the picture of a user without one", func(t *testing.T) {
+		defer test.ClearUsers()
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+
+		jwtService, _ := service.NewJWTService(nil, test.Settings())
+		token, _ := jwtService.Sign(user)
+
+		req, _ := http.NewRequest(http.MethodGet, "/users/test", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var data model.User
+		err := json.Unmarshal(w.Body.Bytes(), &data)
+		if err != nil {
+			log.Fatal("The response body is not a model.User parseable string, ", err)
+		}
+
+		assert.Equal(t, "/users/test/avatar.png?size=64", data.Picture.Small)
+		assert.Equal(t, "/users/test/avatar.png?size=512", data.Picture.Large)
+	})
+}
+
+// failingUsernameChanges is a user repository whose username changes fail.
+type failingUsernameChanges struct {
+	repository.IUserRepository
+}
+
+func (failingUsernameChanges) ChangeUsername(userId string, username string) (model.User, error) {
+	return model.User{}, errors.New("the username can't be changed")
+}
+
+func TestPostChangeUsername(t *testing.T) {
+	jwtService, err := service.NewJWTService(nil, test.Settings())
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the JWT service: %v\n", err)
+	}
+
+	repository, err := repository.NewUserRepository(nil)
+	if err != nil {
+		log.Fatalf("An error ocurred when creating the user repository: %v\n", err)
+	}
+
+	changeUsername := func(token string, username string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(model.UsernameChange{Username: username})
+		req, _ := http.NewRequest(http.MethodPost, "/users/me/username", bytes.NewBuffer(body))
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("It should change the username and redirect the previous one", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		w := changeUsername(token, "renamed")
+
+		assert.Equal(t, http.StatusOK, w.Code, "Status code should be 200")
+
+		var data struct {
+			Data  model.User `json:"data"`
+			Token string     `json:"token"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &data)
+		if err != nil {
+			log.Fatal("The response body is not a JSON parseable string, ", err)
+		}
+
+		assert.Equal(t, "renamed", data.Data.Username)
+		assert.Equal(t, user.Id, data.Data.Id)
+		assert.NotEmpty(t, data.Token)
+
+		req, _ := http.NewRequest(http.MethodGet, "/users/test", nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code, "Status code should be 301")
+		assert.Equal(t, "/users/renamed", w.Header().Get("Location"))
+	})
+
+	t.Run("It should retrieve a conflict status when registering a reserved username", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+		changeUsername(token, "renamed")
+
+		body, _ := json.Marshal(map[string]string{"username": "test", "email": "other@test.com", "password": "Correct-Horse-7-Battery"})
+		req, _ := http.NewRequest(http.MethodPost, "/auth/register", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code, "Status code should be 409")
+	})
+
+	t.Run("It should retrieve a too many requests status when changing the username again during the cooldown", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+		changeUsername(token, "renamed")
+
+		w := changeUsername(token, "renamed-again")
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "Status code should be 429")
+	})
+
+	t.Run("It should retrieve a conflict status if the username is in use", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		repository.CreateUser(&model.BaseUser{Username: "other", Email: "other@test.com", Password: "other"})
+		token, _ := jwtService.Sign(user)
+
+		w := changeUsername(token, "other")
+
+		assert.Equal(t, http.StatusConflict, w.Code, "Status code should be 409")
+	})
+
+	t.Run("It should retrieve a bad request status if the username is reserved for a route", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		w := changeUsername(token, "me")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Status code should be 400")
+	})
+	t.Run("It should undo the reservation of the previous username if the change fails", func(t *testing.T) {
+		defer test.ClearUsers()
+
+		user, _ := repository.CreateUser(&model.BaseUser{Username: "test", Email: "test@test.com", Password: "test"})
+		token, _ := jwtService.Sign(user)
+
+		userService, err := service.NewUserService(failingUsernameChanges{repository}, nil, test.Settings())
+		assert.Nil(t, err)
+
+		_, err = userService.ChangeUsername(user.Id, "renamed")
+		assert.NotNil(t, err)
+
+		w := changeUsername(token, "renamed")
+		assert.Equal(t, http.StatusOK, w.Code, "The failed change shouldn't start the cooldown")
+	})
+}
@@ -2,8 +2,12 @@ package test
 
 import (
 	"os"
+	"testing"
 
+	"github.com/NutriPocket/UserService/config"
 	"github.com/NutriPocket/UserService/database"
+	"github.com/NutriPocket/UserService/migrate"
+	"github.com/NutriPocket/UserService/model"
 	"github.com/joho/godotenv"
 	"github.com/op/go-logging"
 	"gorm.io/gorm"
@@ -12,6 +16,9 @@ import (
 var log = logging.MustGetLogger("log")
 var gormDB *gorm.DB
 
+// settings are the settings loaded from .env.test by Setup.
+var settings config.Config
+
 func loadEnv() {
 	if ci_test := os.Getenv("CI_TEST"); ci_test != "" {
 		return
@@ -24,8 +31,24 @@ func loadEnv() {
 }
 
 func setupDB() {
-	database.ConnectDB()
 	var err error
+
+	settings, _, err = config.Load(nil)
+	if err != nil {
+		log.Fatalf("Failed to load the config: %v", err)
+	}
+
+	database.ConnectDB(settings.Database)
+
+	migrator, err := migrate.New(database.GetConnection(), database.CurrentDialect())
+	if err != nil {
+		log.Panicf("Failed to load the migrations: %v", err)
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		log.Panicf("Failed to migrate the database: %v", err)
+	}
+
 	gormDB, err = database.GetPoolConnection()
 	if err != nil {
 		log.Panicf("Failed to connect to database: %v", err)
@@ -38,6 +61,12 @@ func ClearUsers() {
 	`).Error; err != nil {
 		log.Fatal(err)
 	}
+
+	if err := gormDB.Exec(`
+		DELETE FROM jwt_revocations
+	`).Error; err != nil {
+		log.Fatal(err)
+	}
 }
 
 func ClearBlacklist() {
@@ -48,6 +77,51 @@ func ClearBlacklist() {
 	}
 }
 
+func ClearAuditLog() {
+	if err := gormDB.Exec(`
+		DELETE FROM audit_log
+	`).Error; err != nil {
+		log.Fatal(err)
+	}
+
+	if err := gormDB.Exec(`
+		UPDATE audit_chain SET head = ?
+	`, model.AuditGenesisHash).Error; err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewDB returns a private SQLite database kept in memory, migrated and closed when the test ends, to test on a
+// database no other test uses.
+func NewDB(t testing.TB) *gorm.DB {
+	conn, err := database.Open(config.DatabaseConfig{Driver: config.SQLiteDriver, Name: config.SQLiteMemory})
+	if err != nil {
+		t.Fatalf("Failed to open the database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	migrator, err := migrate.New(conn, database.SQLite)
+	if err != nil {
+		t.Fatalf("Failed to load the migrations: %v", err)
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Failed to migrate the database: %v", err)
+	}
+
+	db, err := database.NewGorm(conn, database.SQLite)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	return db
+}
+
+// Settings returns the settings loaded by Setup, used to create the services of the tests.
+func Settings() config.Config {
+	return settings
+}
+
 func Setup(testType string) {
 	log.Infof("Setup %s tests!\n", testType)
 	loadEnv()
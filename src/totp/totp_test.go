@@ -0,0 +1,79 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA-1 secret of the test vectors of RFC 6238, appendix B.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	t.Run("It should match the test vectors of the RFC", func(t *testing.T) {
+		// The RFC codes have 8 digits, these are their last 6.
+		vectors := map[int64]string{
+			59:          "287082",
+			1111111109:  "081804",
+			1111111111:  "050471",
+			1234567890:  "005924",
+			2000000000:  "279037",
+			20000000000: "353130",
+		}
+
+		for unix, expected := range vectors {
+			code, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+			assert.Nil(t, err)
+			assert.Equal(t, expected, code, "at %d", unix)
+		}
+	})
+
+	t.Run("It should reject an invalid secret", func(t *testing.T) {
+		_, err := Code("not base32!", 1)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	t.Run("It should accept the codes of the adjacent periods only", func(t *testing.T) {
+		for _, offset := range []time.Duration{-Period, 0, Period} {
+			code, _ := Code(rfcSecret, Step(now.Add(offset)))
+			step, ok := Validate(rfcSecret, code, now)
+
+			assert.True(t, ok)
+			assert.Equal(t, Step(now.Add(offset)), step)
+		}
+
+		code, _ := Code(rfcSecret, Step(now.Add(2*Period)))
+		_, ok := Validate(rfcSecret, code, now)
+		assert.False(t, ok)
+	})
+
+	t.Run("It should ignore spaces and reject codes of the wrong length", func(t *testing.T) {
+		_, ok := Validate(rfcSecret, "050 471", now)
+		assert.True(t, ok)
+
+		_, ok = Validate(rfcSecret, "05047", now)
+		assert.False(t, ok)
+	})
+}
+
+func TestGenerateSecret(t *testing.T) {
+	t.Run("It should generate different secrets usable in an URI", func(t *testing.T) {
+		first, err := GenerateSecret()
+		assert.Nil(t, err)
+		second, _ := GenerateSecret()
+
+		assert.NotEqual(t, first, second)
+		assert.Len(t, first, 32)
+
+		uri := URI(first, "NutriPocket", "test@test.com")
+		assert.True(t, strings.HasPrefix(uri, "otpauth://totp/NutriPocket:test@test.com?"))
+		assert.Contains(t, uri, "secret="+first)
+	})
+}
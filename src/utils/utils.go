@@ -2,22 +2,48 @@
 package utils
 
 import (
+	"github.com/NutriPocket/UserService/app"
+	"github.com/NutriPocket/UserService/config"
 	"github.com/NutriPocket/UserService/routes"
+	"github.com/NutriPocket/UserService/storage"
 	"github.com/gin-gonic/gin"
+	"github.com/op/go-logging"
 
 	middlewareAuth "github.com/NutriPocket/UserService/middleware/auth_middleware"
 	middlewareErr "github.com/NutriPocket/UserService/middleware/error_handler"
+	middlewarePrimaryReads "github.com/NutriPocket/UserService/middleware/primary_reads"
+	middlewareRequestId "github.com/NutriPocket/UserService/middleware/request_id"
 )
 
+var log = logging.MustGetLogger("log")
+
 // SetupRouter sets up the routes for the application.
+// container has the services used by the middlewares and the handlers.
 // It returns a router with the middlewares and routes set up.
-func SetupRouter() *gin.Engine {
+func SetupRouter(container *app.Container) *gin.Engine {
 	router := gin.Default()
 
+	// The client IP is used by the audit log and the login risk rules, so X-Forwarded-For is only
+	// trusted from the proxies of server.trusted_proxies.
+	if err := router.SetTrustedProxies(container.Settings.Server.Proxies()); err != nil {
+		log.Fatalf("Invalid server.trusted_proxies: %v", err)
+	}
+
+	router.Use(middlewareRequestId.RequestIdMiddleware())
 	router.Use(middlewareErr.ErrorHandler())
-	router.Use(middlewareAuth.AuthMiddleware())
-	routes.AuthRoutes(router)
-	routes.UsersRoutes(router)
+	router.Use(middlewarePrimaryReads.PrimaryReadsMiddleware())
+	// The probes and the metrics are registered before AuthMiddleware, as gin only runs a middleware for the routes
+	// registered after it, so the probes and the scraper never need a token.
+	routes.HealthRoutes(router, container)
+	routes.MetricsRoutes(router, container)
+	router.Use(middlewareAuth.AuthMiddleware(container.JWTService, container.AccountStateService))
+	routes.AuthRoutes(router, container)
+	routes.UsersRoutes(router, container)
+	routes.AdminRoutes(router, container)
+
+	if container.Settings.Storage.Backend == config.LocalStorage {
+		router.Static(storage.LocalPrefix, container.Settings.Storage.LocalDir)
+	}
 
 	return router
 }